@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"graces/cluster"
+	"graces/config"
+	"graces/db"
+	"graces/rpc"
+	"graces/secret"
+	"graces/syncer"
+	"graces/txpool"
+	"graces/web/controller"
+	"graces/web/dao"
+	"graces/web/router"
+	"graces/web/service"
+	"graces/ws"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 初始化失败时关闭数据库连接的最长等待时间
+const closeDBTimeout = 10 * time.Second
+
+// App 应用容器，按依赖顺序显式构建各个组件并持有它们，统一管理它们的启动和停止，
+// 多个 App 之间不共享存储、总线、交易池等组件
+type App struct {
+	db          *db.DB
+	daos        *dao.Daos
+	cluster     *cluster.Cluster
+	txPool      *txpool.TxPool
+	syncManager *syncer.ChainDataSyncManager
+	wsManager   *ws.Manager
+	subscriber  *ws.WSSubscriber
+	deploy      *ws.Deploy
+	services    *service.Services
+	router      *gin.Engine
+	server      *http.Server
+	addr        net.Addr
+	errCh       chan error
+}
+
+// New 构建应用：主密钥 -> 存储 -> dao -> 多实例总线和选举 -> rpc -> 交易池 -> 同步器 -> websocket 管理器、订阅器和部署器 -> service -> controller -> 路由，
+// 构建过程中不会启动任何后台协程
+func New() (*App, error) {
+	gin.SetMode(config.Config.HttpConf.Mode)
+	// 不允许使用空密码或公开的默认密码创建管理员
+	if err := config.Config.AdminConf.CheckPassword(); err != nil {
+		return nil, err
+	}
+	if err := secret.InitCipher(); err != nil {
+		return nil, err
+	}
+	daos, database, err := dao.NewDaos()
+	if err != nil {
+		return nil, err
+	}
+	a := &App{
+		db:    database,
+		daos:  daos,
+		errCh: make(chan error, 1),
+	}
+	if a.cluster, err = cluster.New(a.db); err != nil {
+		a.closeDB()
+		return nil, err
+	}
+	rpcServer := rpc.NewServer(daos.Chain, daos.Node, daos.Block)
+	a.txPool = txpool.NewTxPool(daos.Chain, a.cluster.Elector)
+	a.syncManager = syncer.NewChainDataSyncManager(daos, rpcServer, a.cluster.Elector)
+	a.wsManager = ws.NewManager(daos, rpcServer, a.cluster.Bus, a.txPool)
+	a.subscriber = ws.NewWSSubscriber(a.wsManager, daos, a.syncManager, a.cluster.Elector)
+	a.deploy = ws.NewDeploy(daos, a.syncManager, a.subscriber)
+	a.wsManager.SetDeploy(a.deploy)
+	a.syncManager.AddProgressListener(a.wsManager.ForwardSyncProgress)
+	a.txPool.AddListener(a.wsManager.ForwardTxPoolEvent)
+	a.cluster.Bus.Subscribe(a.wsManager.ForwardBusMessage)
+
+	a.services = service.NewServices(a.db, daos, rpcServer, a.syncManager, a.txPool, a.wsManager, a.subscriber)
+	if err := a.services.User.EnsureAdmin(config.Config.AdminConf.Username, config.Config.AdminConf.Password); err != nil {
+		a.closeDB()
+		return nil, err
+	}
+	controllers := controller.NewControllers(a.services, a.syncManager, a.subscriber, a.deploy)
+	a.router = router.NewRouter(controllers, a.services, a.wsManager)
+	a.server = &http.Server{
+		Addr:    config.Config.HttpConf.Addr(),
+		Handler: a.router,
+	}
+	return a, nil
+}
+
+// 初始化失败时关闭已经建立的数据库连接
+func (a *App) closeDB() {
+	if a.db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeDBTimeout)
+	defer cancel()
+	if err := a.db.Close(ctx); err != nil {
+		logrus.Errorf("close db err: %v", err)
+	}
+}
+
+// Start 监听 HTTP 端口并启动后台协程，监听失败时直接返回错误
+func (a *App) Start(ctx context.Context) error {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", a.server.Addr)
+	if err != nil {
+		return err
+	}
+	a.addr = ln.Addr()
+
+	a.cluster.Run()
+	a.wsManager.Run()
+	a.subscriber.Run()
+	a.syncManager.Run()
+	a.txPool.Run()
+	go func() {
+		a.subscriber.ChainWSTopicAutoSubDelayStart(config.Config.Syncer.Delay)
+		a.syncManager.ChainDataIncrSyncDelayStart(config.Config.Syncer.Delay)
+	}()
+	go func() {
+		err := a.server.Serve(ln)
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		a.errCh <- err
+	}()
+	logrus.Infof("Graces started, listening on %s", a.addr)
+	return nil
+}
+
+// Stop 优雅停机：停止接收请求并等待处理中的请求完成，等待或终止部署脚本，
+// 取消数据同步（当前区块写入完成后退出），停止拉取交易池，向 websocket 连接发送关闭帧，
+// 释放多实例部署时持有的 leader 身份，最后关闭数据库连接。
+// ctx 控制整个停机过程的最长等待时间
+func (a *App) Stop(ctx context.Context) error {
+	var errs []error
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown http server: %w", err))
+	}
+	if err := a.deploy.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown deploy: %w", err))
+	}
+	if err := a.syncManager.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.txPool.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	a.wsManager.Stop(ctx)
+	if err := a.cluster.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if a.db != nil {
+		if err := a.db.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close db: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		for _, err := range errs[1:] {
+			logrus.Errorf("Graces stop err: %v", err)
+		}
+		return errs[0]
+	}
+	logrus.Infof("Graces stopped")
+	return nil
+}
+
+// Wait 阻塞直到 HTTP 服务退出，正常关闭时返回 nil
+func (a *App) Wait() error {
+	return <-a.errCh
+}
+
+// Addr HTTP 服务实际监听的地址，Start 之前为空
+func (a *App) Addr() string {
+	if a.addr == nil {
+		return ""
+	}
+	return a.addr.String()
+}
+
+// Router 应用的路由，便于测试时直接发起请求
+func (a *App) Router() *gin.Engine {
+	return a.router
+}
+
+// Daos 应用使用的 DAO，便于测试时准备数据
+func (a *App) Daos() *dao.Daos {
+	return a.daos
+}
@@ -0,0 +1,508 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"graces/config"
+	"graces/exterr"
+	"graces/model"
+	"graces/util"
+	"graces/ws"
+	"graces/ws/wstest"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApp_StartStop(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	config.Config.HttpConf.IP = "127.0.0.1"
+	config.Config.HttpConf.Port = "0"
+
+	graces, err := New()
+	assert.True(t, err == nil)
+	assert.True(t, graces.Start(context.Background()) == nil)
+	assert.True(t, graces.Addr() != "")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/", graces.Addr()))
+	assert.True(t, err == nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.True(t, graces.Stop(ctx) == nil)
+	assert.True(t, graces.Wait() == nil)
+
+	_, err = http.Get(fmt.Sprintf("http://%s/", graces.Addr()))
+	assert.True(t, err != nil)
+}
+
+func TestApp_LoginRequired(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	call := func(method string, path string, body string, token string) model.Result {
+		return serve(t, graces, method, path, body, token)
+	}
+
+	page := `{"page_index":1,"page_size":10}`
+	result := call(http.MethodPost, "/api/chains", page, "")
+	assert.Equal(t, http.StatusUnauthorized, result.Code)
+
+	login := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result = call(http.MethodPost, "/api/auth/login", login, "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	token := result.Data.(map[string]interface{})["token"].(string)
+
+	result = call(http.MethodPost, "/api/chains", page, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+
+	result = call(http.MethodPost, "/api/auth/logout", "", token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	result = call(http.MethodPost, "/api/chains", page, token)
+	assert.Equal(t, http.StatusUnauthorized, result.Code)
+}
+
+func TestApp_AdminPassword(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	password := config.Config.AdminConf.Password
+	defer func() { config.Config.AdminConf.Password = password }()
+
+	// 没有配置或仍是默认密码时拒绝启动
+	config.Config.AdminConf.Password = ""
+	_, err := New()
+	assert.True(t, err != nil)
+	config.Config.AdminConf.Password = config.DefaultAdminPassword
+	_, err = New()
+	assert.True(t, err != nil)
+
+	config.Config.AdminConf.Password = password
+	graces, err := New()
+	assert.True(t, err == nil)
+	login := func(password string) model.Result {
+		body := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, password)
+		return serve(t, graces, http.MethodPost, "/api/auth/login", body, "")
+	}
+	result := login(password)
+	assert.Equal(t, http.StatusOK, result.Code)
+	token := result.Data.(map[string]interface{})["token"].(string)
+
+	body := fmt.Sprintf(`{"old_password":"wrong","new_password":"new-%s"}`, password)
+	result = serve(t, graces, http.MethodPost, "/api/auth/password", body, token)
+	assert.Equal(t, exterr.ErrCodePasswordWrong, result.Code)
+	body = fmt.Sprintf(`{"old_password":%q,"new_password":"new-%s"}`, password, password)
+	result = serve(t, graces, http.MethodPost, "/api/auth/password", body, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+
+	// 修改后旧 token 和旧密码都不能再使用
+	result = serve(t, graces, http.MethodPost, "/api/chains", `{"page_index":1,"page_size":10}`, token)
+	assert.Equal(t, http.StatusUnauthorized, result.Code)
+	assert.Equal(t, exterr.ErrCodePasswordWrong, login(password).Code)
+	assert.Equal(t, http.StatusOK, login("new-"+password).Code)
+}
+
+func TestApp_Permission(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	login := func(username string, password string) string {
+		body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+		result := serve(t, graces, http.MethodPost, "/api/auth/login", body, "")
+		assert.Equal(t, http.StatusOK, result.Code)
+		return result.Data.(map[string]interface{})["token"].(string)
+	}
+	admin := login(config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+
+	result := serve(t, graces, http.MethodPost, "/api/user", `{"username":"viewer","password":"123456","role":"viewer"}`, admin)
+	assert.Equal(t, http.StatusOK, result.Code)
+	userID := result.Data.(map[string]interface{})["id"].(string)
+	viewer := login("viewer", "123456")
+
+	// 只读接口对 viewer 开放
+	result = serve(t, graces, http.MethodPost, "/api/chains", `{"page_index":1,"page_size":10}`, viewer)
+	assert.Equal(t, http.StatusOK, result.Code)
+	// 管理接口拒绝 viewer
+	result = serve(t, graces, http.MethodPost, "/api/users", `{"page_index":1,"page_size":10}`, viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+
+	chainID := primitive.NewObjectID().Hex()
+	setConfig := fmt.Sprintf(`{"chainID":%q,"blockGasLimit":"100000000"}`, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/chain/setsystemconfig", setConfig, viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+	result = serve(t, graces, http.MethodPost, "/api/account/unlock", fmt.Sprintf(`{"chain_id":%q}`, chainID), viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+
+	// 授予该链的 chain-admin 角色后可以通过权限校验，其他链仍然没有权限
+	grant := fmt.Sprintf(`{"user_id":%q,"chain_id":%q,"role":"chain-admin"}`, userID, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/user/grant", grant, admin)
+	assert.Equal(t, http.StatusOK, result.Code)
+	result = serve(t, graces, http.MethodPost, "/api/chain/setsystemconfig", setConfig, viewer)
+	assert.True(t, result.Code != exterr.ErrCodeUserHasNoPermission)
+	otherConfig := fmt.Sprintf(`{"chainID":%q,"blockGasLimit":"100000000"}`, primitive.NewObjectID().Hex())
+	result = serve(t, graces, http.MethodPost, "/api/chain/setsystemconfig", otherConfig, viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+
+	// 只按 handler 绑定的字段校验权限，同时带有多个链ID字段的请求被拒绝
+	otherID := primitive.NewObjectID().Hex()
+	result = serve(t, graces, http.MethodPost, "/api/account/unlock", fmt.Sprintf(`{"chain_id":%q}`, otherID), viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+	result = serve(t, graces, http.MethodPost, "/api/account/unlock", fmt.Sprintf(`{"chainID":%q,"account":"0x01"}`, chainID), viewer)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+	for i := 0; i < 10; i++ {
+		ambiguous := fmt.Sprintf(`{"chainid":%q,"chain_id":%q,"account":"0x01","password":"1"}`, chainID, otherID)
+		result = serve(t, graces, http.MethodPost, "/api/account/unlock", ambiguous, viewer)
+		assert.Equal(t, exterr.ErrCodeParameterInvalid, result.Code)
+	}
+	ambiguous := fmt.Sprintf(`{"chainID":%q,"chain_id":%q,"blockGasLimit":"100000000"}`, otherID, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/chain/setsystemconfig", ambiguous, viewer)
+	assert.Equal(t, exterr.ErrCodeParameterInvalid, result.Code)
+}
+
+func TestApp_APIKey(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	login := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result := serve(t, graces, http.MethodPost, "/api/auth/login", login, "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	token := result.Data.(map[string]interface{})["token"].(string)
+
+	result = serve(t, graces, http.MethodPost, "/api/apikey", `{"name":"ci","scopes":["chain:read"],"expire_days":30}`, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	created := result.Data.(map[string]interface{})
+	key := created["key"].(string)
+
+	withKey := func(method string, path string, body string) model.Result {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		graces.Router().ServeHTTP(w, req)
+		var result model.Result
+		assert.True(t, json.Unmarshal(w.Body.Bytes(), &result) == nil)
+		return result
+	}
+	page := `{"page_index":1,"page_size":10}`
+	result = withKey(http.MethodPost, "/api/chains", page)
+	assert.Equal(t, http.StatusOK, result.Code)
+	// 超出 key 的授权范围，即使用户本身是超级管理员
+	result = withKey(http.MethodPost, "/api/users", page)
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+	// 不能用 API Key 管理 API Key
+	result = withKey(http.MethodGet, "/api/apikeys", "")
+	assert.Equal(t, exterr.ErrCodeUserHasNoPermission, result.Code)
+	// 不能用 API Key 修改密码
+	result = withKey(http.MethodPost, "/api/auth/password", `{"old_password":"graces-test","new_password":"654321"}`)
+	assert.Equal(t, exterr.ErrCodeUnauthorized, result.Code)
+
+	result = serve(t, graces, http.MethodGet, "/api/apikeys", "", token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	keys := result.Data.([]interface{})
+	assert.Equal(t, 1, len(keys))
+	assert.True(t, keys[0].(map[string]interface{})["last_used_time"] != "-")
+
+	revoke := fmt.Sprintf(`{"id":%q}`, created["id"])
+	result = serve(t, graces, http.MethodPost, "/api/apikey/revoke", revoke, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	result = withKey(http.MethodPost, "/api/chains", page)
+	assert.Equal(t, http.StatusUnauthorized, result.Code)
+}
+
+func TestApp_Audit(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	login := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result := serve(t, graces, http.MethodPost, "/api/auth/login", login, "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	token := result.Data.(map[string]interface{})["token"].(string)
+
+	// 链不存在，解锁失败，但操作仍然会被记录，密码脱敏
+	chainID := primitive.NewObjectID().Hex()
+	unlock := fmt.Sprintf(`{"chain_id":%q,"account":"0x01","password":"secret-password"}`, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/account/unlock", unlock, token)
+	assert.True(t, result.Code != http.StatusOK)
+	// 链ID 不明确的请求被拒绝，也不会记录为其中任何一条链
+	otherID := primitive.NewObjectID().Hex()
+	ambiguous := fmt.Sprintf(`{"chainid":%q,"chain_id":%q,"account":"0x01","password":"1"}`, otherID, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/account/unlock", ambiguous, token)
+	assert.Equal(t, exterr.ErrCodeParameterInvalid, result.Code)
+	otherQuery := fmt.Sprintf(`{"page_index":1,"page_size":10,"chain_id":%q}`, otherID)
+	result = serve(t, graces, http.MethodPost, "/api/audit", otherQuery, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, 0, len(result.Data.(map[string]interface{})["items"].([]interface{})))
+
+	query := fmt.Sprintf(`{"page_index":1,"page_size":10,"chain_id":%q,"operation":"account.unlock"}`, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/audit", query, token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	items := result.Data.(map[string]interface{})["items"].([]interface{})
+	assert.Equal(t, 1, len(items))
+	event := items[0].(map[string]interface{})
+	assert.Equal(t, config.Config.AdminConf.Username, event["username"])
+	assert.Equal(t, false, event["success"])
+	assert.Equal(t, util.RedactedValue, event["params"].(map[string]interface{})["password"])
+
+	req := httptest.NewRequest(http.MethodPost, "/api/audit/export", strings.NewReader(query))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	w := httptest.NewRecorder()
+	graces.Router().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "time,username"))
+	assert.False(t, strings.Contains(w.Body.String(), "secret-password"))
+}
+
+func TestApp_DeleteChain(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	login := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result := serve(t, graces, http.MethodPost, "/api/auth/login", login, "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	token := result.Data.(map[string]interface{})["token"].(string)
+
+	chain := model.Chain{ID: primitive.NewObjectID(), Name: "chain-delete", IP: "127.0.0.1", RPCPort: 6791}
+	assert.True(t, graces.Daos().Chain.InsertChain(chain) == nil)
+	block := model.Block{ID: primitive.NewObjectID(), ChainID: chain.ID, Height: 1, Head: &model.BLockHead{}}
+	assert.True(t, graces.Daos().Block.InsertBlock(block) == nil)
+
+	page := `{"page_index":1,"page_size":10}`
+	total := func() float64 {
+		result := serve(t, graces, http.MethodPost, "/api/chains", page, token)
+		assert.Equal(t, http.StatusOK, result.Code)
+		return result.Data.(map[string]interface{})["total"].(float64)
+	}
+	assert.Equal(t, float64(1), total())
+	blocks := func(chainID string) model.Result {
+		body := fmt.Sprintf(`{"page_index":1,"page_size":10,"chain_id":%q}`, chainID)
+		return serve(t, graces, http.MethodPost, "/api/blocks", body, token)
+	}
+	result = blocks(chain.ID.Hex())
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, float64(1), result.Data.(map[string]interface{})["total"].(float64))
+	result = serve(t, graces, http.MethodGet, "/api/block/id/"+block.ID.Hex(), "", token)
+	assert.Equal(t, http.StatusOK, result.Code)
+
+	// 软删除后查询不到链，但链的数据仍然保留
+	result = serve(t, graces, http.MethodDelete, "/api/chain/"+chain.ID.Hex(), "", token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, float64(0), total())
+	// 已删除的链的数据不能再查询
+	assert.True(t, blocks(chain.ID.Hex()).Code != http.StatusOK)
+	assert.True(t, blocks(primitive.NewObjectID().Hex()).Code != http.StatusOK)
+	result = blocks("")
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, float64(0), result.Data.(map[string]interface{})["total"].(float64))
+	result = serve(t, graces, http.MethodGet, "/api/block/id/"+block.ID.Hex(), "", token)
+	assert.True(t, result.Code != http.StatusOK)
+	result = serve(t, graces, http.MethodGet, "/api/chain/id/"+chain.ID.Hex(), "", token)
+	assert.True(t, result.Code != http.StatusOK)
+	result = serve(t, graces, http.MethodDelete, "/api/chain/"+chain.ID.Hex(), "", token)
+	assert.True(t, result.Code != http.StatusOK)
+	cnt, err := graces.Daos().Block.Count(bson.M{"chain_id": chain.ID}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(1), cnt)
+
+	// 已软删除的链可以 purge
+	result = serve(t, graces, http.MethodDelete, "/api/chain/"+chain.ID.Hex()+"?purge=true", "", token)
+	assert.Equal(t, http.StatusOK, result.Code)
+	cnt, err = graces.Daos().Block.Count(bson.M{"chain_id": chain.ID}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(0), cnt)
+	result = serve(t, graces, http.MethodDelete, "/api/chain/"+chain.ID.Hex()+"?purge=true", "", token)
+	assert.True(t, result.Code != http.StatusOK)
+}
+
+func TestApp_Isolated(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	first, err := New()
+	assert.True(t, err == nil)
+	second, err := New()
+	assert.True(t, err == nil)
+
+	login := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	total := func(graces *App) float64 {
+		result := serve(t, graces, http.MethodPost, "/api/auth/login", login, "")
+		assert.Equal(t, http.StatusOK, result.Code)
+		token := result.Data.(map[string]interface{})["token"].(string)
+		result = serve(t, graces, http.MethodPost, "/api/chains", `{"page_index":1,"page_size":10}`, token)
+		assert.Equal(t, http.StatusOK, result.Code)
+		return result.Data.(map[string]interface{})["total"].(float64)
+	}
+
+	// 每个应用持有自己的存储，一个应用中添加的链在另一个应用中查询不到
+	chain := model.Chain{ID: primitive.NewObjectID(), Name: "chain-isolated", IP: "127.0.0.1", RPCPort: 6791}
+	assert.True(t, first.Daos().Chain.InsertChain(chain) == nil)
+	assert.Equal(t, float64(1), total(first))
+	assert.Equal(t, float64(0), total(second))
+}
+
+func TestApp_Cors(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/chains", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		graces.Router().ServeHTTP(w, req)
+		return w
+	}
+	allowed := config.CorsPolicy().AllowOrigins[0]
+	w := preflight(allowed)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, allowed, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.True(t, w.Header().Get("Access-Control-Allow-Methods") != "")
+
+	w = preflight("http://evil.example.org")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestApp_Health(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+
+	result := serve(t, graces, http.MethodGet, "/healthz", "", "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	result = serve(t, graces, http.MethodGet, "/version", "", "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, runtime.Version(), result.Data.(map[string]interface{})["go_version"])
+
+	// 没有链时只检查数据库
+	result = serve(t, graces, http.MethodGet, "/readyz", "", "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, true, result.Data.(map[string]interface{})["ready"])
+
+	// 链的 rpc 不可达时未就绪，并返回该链的状态
+	chain := model.Chain{ID: primitive.NewObjectID(), Name: "chain-unreachable", IP: "127.0.0.1", RPCPort: 1}
+	assert.True(t, graces.Daos().Chain.InsertChain(chain) == nil)
+	result = serve(t, graces, http.MethodGet, "/readyz", "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, result.Code)
+	data := result.Data.(map[string]interface{})
+	assert.Equal(t, false, data["ready"])
+	chains := data["chains"].([]interface{})
+	assert.Equal(t, 1, len(chains))
+	status := chains[0].(map[string]interface{})
+	assert.Equal(t, chain.ID.Hex(), status["chain_id"])
+	assert.Equal(t, model.HealthStatusFail, status["rpc"].(map[string]interface{})["status"])
+}
+
+func TestApp_WebsocketAuth(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+	server := httptest.NewServer(graces.Router())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws/deploy"
+
+	// 没有 token 无法建立连接
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.True(t, err != nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	login := func(username string, password string) string {
+		body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+		result := serve(t, graces, http.MethodPost, "/api/auth/login", body, "")
+		assert.Equal(t, http.StatusOK, result.Code)
+		return result.Data.(map[string]interface{})["token"].(string)
+	}
+	admin := login(config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result := serve(t, graces, http.MethodPost, "/api/user", `{"username":"ws-viewer","password":"123456","role":"viewer"}`, admin)
+	assert.Equal(t, http.StatusOK, result.Code)
+	viewer := login("ws-viewer", "123456")
+
+	// token 通过子协议传递，服务端回应 bearer 子协议
+	dialer := websocket.Dialer{Subprotocols: []string{"bearer", strings.TrimPrefix(viewer, config.Config.JWTConf.Prefix)}}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	assert.True(t, err == nil)
+	defer conn.Close()
+	assert.Equal(t, "bearer", resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	// viewer 没有启停节点的权限，命令被拒绝并记录审计日志
+	chainID := primitive.NewObjectID().Hex()
+	assert.True(t, conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"method":"startNode","chainID":%q,"nodeID":"0"}`, chainID))) == nil)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	assert.True(t, err == nil)
+	assert.Equal(t, exterr.ErrUserHasNoPermission.Error(), string(msg))
+
+	query := fmt.Sprintf(`{"page_index":1,"page_size":10,"chain_id":%q,"operation":"ws.startNode"}`, chainID)
+	result = serve(t, graces, http.MethodPost, "/api/audit", query, admin)
+	assert.Equal(t, http.StatusOK, result.Code)
+	items := result.Data.(map[string]interface{})["items"].([]interface{})
+	assert.Equal(t, 1, len(items))
+	event := items[0].(map[string]interface{})
+	assert.Equal(t, "ws-viewer", event["username"])
+	assert.Equal(t, false, event["success"])
+}
+
+func TestApp_WebsocketEnvelope(t *testing.T) {
+	config.Config.DBConf.Mode = config.DBModeMemory
+	graces, err := New()
+	assert.True(t, err == nil)
+	server := httptest.NewServer(graces.Router())
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws/deploy"
+
+	body := fmt.Sprintf(`{"username":%q,"password":%q}`, config.Config.AdminConf.Username, config.Config.AdminConf.Password)
+	result := serve(t, graces, http.MethodPost, "/api/auth/login", body, "")
+	assert.Equal(t, http.StatusOK, result.Code)
+	client, err := wstest.Dial(wsURL, result.Data.(map[string]interface{})["token"].(string))
+	assert.True(t, err == nil)
+	defer client.Close()
+
+	// 订阅成功：ack 之后 result 带上订阅信息
+	reply, err := client.Call(ws.MsgTypeSubscribe, map[string]interface{}{"topic": ws.TopicBlocks})
+	assert.True(t, err == nil)
+	assert.True(t, reply.Ack != nil)
+	assert.True(t, reply.Result.Error == nil)
+	assert.True(t, reply.Result.Payload.(map[string]interface{})["subscription"] != "")
+
+	// 命令执行失败：ack 之后 result 带上错误
+	reply, err = client.Call("stopNode", map[string]interface{}{"chainID": primitive.NewObjectID().Hex(), "nodeID": "0"})
+	assert.True(t, err == nil)
+	assert.True(t, reply.Ack != nil)
+	assert.Equal(t, exterr.ErrCodeFind, reply.Result.Error.Code)
+
+	// 未知的命令不会被接受
+	reply, err = client.Call("unknown", nil)
+	assert.True(t, err == nil)
+	assert.True(t, reply.Ack == nil)
+	assert.Equal(t, exterr.ErrCodeWebsocketMessageInvalid, reply.Result.Error.Code)
+}
+
+// 直接通过路由处理请求，返回解析后的响应
+func serve(t *testing.T, graces *App, method string, path string, body string, token string) model.Result {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	w := httptest.NewRecorder()
+	graces.Router().ServeHTTP(w, req)
+	var result model.Result
+	assert.True(t, json.Unmarshal(w.Body.Bytes(), &result) == nil)
+	return result
+}
@@ -0,0 +1,14 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"graces/config"
+)
+
+func TestMain(m *testing.M) {
+	// 配置文件中不带初始管理员密码，测试使用固定的密码
+	config.Config.AdminConf.Password = "graces-test"
+	os.Exit(m.Run())
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"graces/secret"
+	"graces/web/dao"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RotateMasterKey 使用新的主密钥重新加密所有链配置中的敏感字段，包括已被软删除的链，尚未加密的明文也会被加密，返回处理的链数量。
+// 需要在服务停止时执行，执行成功后把配置的主密钥替换为新的主密钥再启动服务
+func RotateMasterKey(newKey []byte) (int, error) {
+	next, err := secret.NewCipher(newKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := secret.InitCipher(); err != nil {
+		return 0, err
+	}
+	daos, database, err := dao.NewDaos()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if database == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = database.Close(ctx)
+	}()
+
+	chains, err := daos.Chain.ChainsIncludeDeleted(bson.M{}, options.Find())
+	if err != nil {
+		return 0, err
+	}
+	for i, chain := range chains {
+		// 已经用新的主密钥加密过的链（上次轮换中途失败）直接解密，便于重新执行
+		chainConfig, err := next.DecryptChainConfig(chain.ChainConfig)
+		if err != nil {
+			chainConfig, err = secret.DecryptChainConfig(chain.ChainConfig)
+		}
+		if err != nil {
+			return i, err
+		}
+		chainConfig, err = next.EncryptChainConfig(chainConfig)
+		if err != nil {
+			return i, err
+		}
+		update := bson.M{"$set": bson.M{"chain_config": chainConfig}}
+		if err := daos.Chain.UpdateIncludeDeleted(bson.M{"_id": chain.ID}, update, nil); err != nil {
+			return i, err
+		}
+		logrus.Infof("chain[%s] secrets re-encrypted with master key [%s]", chain.Name, next.KeyID())
+	}
+	return len(chains), nil
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"graces/config"
+	"graces/db"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// 链的 leader 选举键前缀
+const chainKeyPrefix = "chain/"
+
+// Cluster 多实例部署时实例之间共享的消息总线和 leader 选举器，未启用多实例部署时为进程内实现
+type Cluster struct {
+	// InstanceID 当前实例ID
+	InstanceID string
+	Bus        IBus
+	Elector    IElector
+}
+
+// New 按 [cluster] 配置创建消息总线和 leader 选举器，启用多实例部署时 database 不能为空
+func New(database *db.DB) (*Cluster, error) {
+	logrus.Debugf("cluster init [start]")
+	defer logrus.Debugf("cluster init [end]")
+	conf := config.Config.ClusterConf
+	instanceID := newInstanceID()
+	if conf != nil && conf.InstanceID != "" {
+		instanceID = conf.InstanceID
+	}
+	if !conf.IsEnabled() {
+		return &Cluster{
+			InstanceID: instanceID,
+			Bus:        newLocalBus(instanceID),
+			Elector:    newLocalElector(),
+		}, nil
+	}
+	if config.Config.DBConf.IsMemoryDB() || database == nil {
+		return nil, errors.New("cluster mode requires db.mode = \"mongo\"")
+	}
+	bus, err := newMongoBus(database, instanceID, conf.BusSizeInBytes())
+	if err != nil {
+		return nil, fmt.Errorf("init cluster bus: %w", err)
+	}
+	logrus.Infof("cluster mode enabled, instance [%s]", instanceID)
+	return &Cluster{
+		InstanceID: instanceID,
+		Bus:        bus,
+		Elector:    newMongoElector(database, instanceID, conf.LeaseDuration()),
+	}, nil
+}
+
+// Run 启动消息总线和 leader 选举
+func (c *Cluster) Run() {
+	c.Bus.Run()
+	c.Elector.Run()
+}
+
+// Stop 释放持有的 leader 身份并停止消息总线
+func (c *Cluster) Stop(ctx context.Context) error {
+	if err := c.Elector.Stop(ctx); err != nil {
+		return fmt.Errorf("stop cluster elector: %w", err)
+	}
+	if err := c.Bus.Stop(ctx); err != nil {
+		return fmt.Errorf("stop cluster bus: %w", err)
+	}
+	return nil
+}
+
+// ChainKey 链的 leader 选举键，链的 leader 负责订阅链的 websocket 事件和循环增量同步
+func ChainKey(chainID string) string {
+	return chainKeyPrefix + chainID
+}
+
+// ChainIDFromKey 从链的 leader 选举键中解析链ID
+func ChainIDFromKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, chainKeyPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, chainKeyPrefix), true
+}
+
+// 主机名加随机后缀，同一台机器上的多个实例也不会重复
+func newInstanceID() string {
+	suffix := strings.Split(uuid.NewV4().String(), "-")[0]
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return suffix
+	}
+	return host + "-" + suffix
+}
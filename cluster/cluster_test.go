@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBus_Publish(t *testing.T) {
+	bus := newLocalBus("instance-a")
+	received := make([]*BusMessage, 0)
+	bus.Subscribe(func(msg *BusMessage) {
+		received = append(received, msg)
+	})
+	assert.True(t, bus.Publish("group", []byte("hello")) == nil)
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, "group", received[0].Group)
+	assert.Equal(t, "hello", string(received[0].Data))
+	assert.Equal(t, "instance-a", received[0].Instance)
+}
+
+func TestLocalElector_AlwaysLeader(t *testing.T) {
+	elector := newLocalElector()
+	key := ChainKey("60d1c7d5e1b2a3c4d5e6f708")
+	assert.True(t, elector.IsLeader(key))
+	assert.True(t, elector.Campaign(key))
+	chainID, ok := ChainIDFromKey(key)
+	assert.True(t, ok)
+	assert.Equal(t, "60d1c7d5e1b2a3c4d5e6f708", chainID)
+	_, ok = ChainIDFromKey("other")
+	assert.True(t, !ok)
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 消息处理函数列表，进程内总线和 MongoDB 总线共用
+type busHandlers struct {
+	lock     sync.RWMutex
+	handlers []BusHandler
+}
+
+func (h *busHandlers) Subscribe(handler BusHandler) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.handlers = append(h.handlers, handler)
+}
+
+// 把消息交给所有处理函数
+func (h *busHandlers) dispatch(msg *BusMessage) {
+	h.lock.RLock()
+	handlers := make([]BusHandler, len(h.handlers))
+	copy(handlers, h.handlers)
+	h.lock.RUnlock()
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+// 进程内的消息总线，单实例部署时使用，发布的消息直接交给当前实例的处理函数
+type localBus struct {
+	busHandlers
+	instance string
+}
+
+func newLocalBus(instance string) *localBus {
+	return &localBus{instance: instance}
+}
+
+func (b *localBus) Publish(group string, data []byte) error {
+	b.dispatch(&BusMessage{
+		ID:       primitive.NewObjectID(),
+		Instance: b.instance,
+		Group:    group,
+		Data:     data,
+	})
+	return nil
+}
+
+func (b *localBus) Run() {}
+
+func (b *localBus) Stop(ctx context.Context) error {
+	return nil
+}
+
+// 单实例部署时的 leader 选举器，当前实例总是所有 key 的 leader
+type localElector struct{}
+
+func newLocalElector() *localElector {
+	return &localElector{}
+}
+
+func (e *localElector) Campaign(key string) bool {
+	return true
+}
+
+func (e *localElector) Resign(key string) {}
+
+func (e *localElector) IsLeader(key string) bool {
+	return true
+}
+
+func (e *localElector) OnChange(listener LeaderListener) {}
+
+func (e *localElector) Run() {}
+
+func (e *localElector) Stop(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,186 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"graces/db"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameBus = "ws_bus"
+	// 集合已存在的错误码
+	errCodeNamespaceExists = 48
+	// 写入消息和创建集合的超时时间
+	busTimeout = 10 * time.Second
+	// 读取游标失效后重新读取的间隔
+	busRetryInterval = time.Second
+)
+
+// 基于 capped collection 的消息总线：发布的消息写入集合，所有实例通过 tailable 游标按写入顺序读取，
+// 集合写满后自动淘汰最早的消息；不依赖副本集，单节点的 MongoDB 也可以使用
+type mongoBus struct {
+	busHandlers
+	instance   string
+	collection *mongo.Collection
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func newMongoBus(database *db.DB, instance string, size int64) (*mongoBus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+	createOps := options.CreateCollection().SetCapped(true).SetSizeInBytes(size)
+	err := database.Db.CreateCollection(ctx, collectionNameBus, createOps)
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == errCodeNamespaceExists) {
+		return nil, err
+	}
+	busCtx, busCancel := context.WithCancel(context.Background())
+	return &mongoBus{
+		instance:   instance,
+		collection: database.Collection(collectionNameBus),
+		ctx:        busCtx,
+		cancel:     busCancel,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Publish 当前实例的处理函数直接收到消息，写入集合后由其他实例读取
+func (b *mongoBus) Publish(group string, data []byte) error {
+	msg := &BusMessage{
+		ID:       primitive.NewObjectID(),
+		Instance: b.instance,
+		Group:    group,
+		Data:     data,
+	}
+	b.dispatch(msg)
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+	if _, err := b.collection.InsertOne(ctx, msg); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *mongoBus) Run() {
+	go b.loopTail()
+}
+
+func (b *mongoBus) Stop(ctx context.Context) error {
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 持续读取其他实例发布的消息，游标失效（如集合为空）后从最后读到的消息之后重新读取
+func (b *mongoBus) loopTail() {
+	defer close(b.done)
+	// 只读取启动之后发布的消息
+	last, ok := b.position()
+	for !ok {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(busRetryInterval):
+		}
+		last, ok = b.position()
+	}
+	for {
+		last = b.tail(b.ctx, last)
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(busRetryInterval):
+		}
+	}
+}
+
+// 集合中最后写入的消息ID，集合为空时返回空ID
+func (b *mongoBus) position() (primitive.ObjectID, bool) {
+	ctx, cancel := context.WithTimeout(b.ctx, busTimeout)
+	defer cancel()
+	findOps := options.FindOne().SetSort(bson.M{"$natural": -1}).SetProjection(bson.M{"_id": 1})
+	var msg BusMessage
+	err := b.collection.FindOne(ctx, bson.M{}, findOps).Decode(&msg)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, true
+	}
+	if err != nil {
+		if b.ctx.Err() == nil {
+			logrus.Warningf("cluster bus get position err: %v", err)
+		}
+		return primitive.NilObjectID, false
+	}
+	return msg.ID, true
+}
+
+// 从 last 之后按写入顺序读取消息，返回最后读到的消息ID。
+// 各实例生成的 ObjectID 之间没有先后顺序，不能按 _id 过滤，而是从头按自然顺序读取并跳过 last 及之前的消息；
+// last 已经被淘汰时，集合中剩下的消息都是在它之后写入的
+func (b *mongoBus) tail(ctx context.Context, last primitive.ObjectID) primitive.ObjectID {
+	skip := false
+	if !last.IsZero() {
+		count, err := b.collection.CountDocuments(ctx, bson.M{"_id": last})
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.Warningf("cluster bus tail err: %v", err)
+			}
+			return last
+		}
+		skip = count > 0
+	}
+	findOps := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(busRetryInterval).
+		SetSort(bson.M{"$natural": 1})
+	cursor, err := b.collection.Find(ctx, bson.M{}, findOps)
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.Warningf("cluster bus tail err: %v", err)
+		}
+		return last
+	}
+	defer cursor.Close(context.Background())
+	// 跳过已经读过的消息，读完当前已有的消息仍没有遇到 last 说明读取期间 last 被淘汰了
+	for skip {
+		if !cursor.TryNext(ctx) {
+			if cursor.Err() != nil || ctx.Err() != nil {
+				break
+			}
+			logrus.Warningf("cluster bus message [%s] evicted while resuming, some messages may be lost", last.Hex())
+			skip = false
+			break
+		}
+		var msg BusMessage
+		if err := cursor.Decode(&msg); err == nil && msg.ID == last {
+			skip = false
+		}
+	}
+	for !skip && cursor.Next(ctx) {
+		var msg BusMessage
+		if err := cursor.Decode(&msg); err != nil {
+			logrus.Errorf("cluster bus decode message err: %v", err)
+			continue
+		}
+		last = msg.ID
+		if msg.Instance == b.instance {
+			continue
+		}
+		b.dispatch(&msg)
+	}
+	if err := cursor.Err(); err != nil && ctx.Err() == nil {
+		logrus.Warningf("cluster bus tail err: %v", err)
+	}
+	return last
+}
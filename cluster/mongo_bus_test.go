@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"graces/db"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 需要 MongoDB，通过环境变量 GRACES_TEST_MONGO_URI 指定，未指定时跳过
+func TestMongoBus_TailAcrossInstances(t *testing.T) {
+	uri := os.Getenv("GRACES_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("GRACES_TEST_MONGO_URI not set")
+	}
+	database, err := db.NewDB(uri, "graces_bus_test_"+primitive.NewObjectID().Hex(), 10*time.Second)
+	assert.True(t, err == nil)
+	defer func() {
+		_ = database.Db.Drop(context.Background())
+		_ = database.Close(context.Background())
+	}()
+
+	a, err := newMongoBus(database, "instance-a", 1<<20)
+	assert.True(t, err == nil)
+	b, err := newMongoBus(database, "instance-b", 1<<20)
+	assert.True(t, err == nil)
+	receiver, err := newMongoBus(database, "instance-receiver", 1<<20)
+	assert.True(t, err == nil)
+	last, ok := receiver.position()
+	assert.True(t, ok)
+
+	// tail 在当前协程中分发消息
+	received := make([]string, 0)
+	var cancel context.CancelFunc
+	var want int
+	receiver.Subscribe(func(msg *BusMessage) {
+		received = append(received, string(msg.Data))
+		if len(received) == want {
+			cancel()
+		}
+	})
+	// 读到 n 条消息后结束本次读取，模拟游标失效后重新读取
+	tail := func(n int) {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		want = n
+		last = receiver.tail(ctx, last)
+	}
+
+	assert.True(t, a.Publish("group", []byte("a1")) == nil)
+	tail(1)
+
+	// instance-b 的时钟落后，生成的 ObjectID 小于之前读到的消息
+	old := &BusMessage{
+		ID:       primitive.NewObjectIDFromTimestamp(time.Now().Add(-time.Hour)),
+		Instance: b.instance,
+		Group:    "group",
+		Data:     []byte("b1"),
+	}
+	_, err = b.collection.InsertOne(context.Background(), old)
+	assert.True(t, err == nil)
+	assert.True(t, a.Publish("group", []byte("a2")) == nil)
+	tail(3)
+
+	assert.Equal(t, []string{"a1", "b1", "a2"}, received)
+}
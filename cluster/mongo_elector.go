@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"graces/db"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameLease = "leader_lease"
+	// 竞选和续约的超时时间
+	leaseTimeout = 5 * time.Second
+)
+
+// 参与竞选的 key 的状态
+type leaseState struct {
+	// 作为 leader 的租约到期时间，不是 leader 时为零值
+	until time.Time
+	// 最近一次通知监听器的 leader 身份
+	leader bool
+}
+
+// 基于租约的 leader 选举器：每个 key 对应集合中的一条租约记录，持有者定时续约，
+// 租约过期后其他实例可以接替；各实例的时钟偏差需要远小于租约时长
+type mongoElector struct {
+	instance   string
+	ttl        time.Duration
+	collection *mongo.Collection
+	lock       sync.Mutex
+	keys       map[string]*leaseState
+	listeners  []LeaderListener
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func newMongoElector(database *db.DB, instance string, ttl time.Duration) *mongoElector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &mongoElector{
+		instance:   instance,
+		ttl:        ttl,
+		collection: database.Collection(collectionNameLease),
+		keys:       make(map[string]*leaseState),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+func (e *mongoElector) Campaign(key string) bool {
+	e.lock.Lock()
+	if _, ok := e.keys[key]; !ok {
+		e.keys[key] = &leaseState{}
+	}
+	e.lock.Unlock()
+	return e.renew(key)
+}
+
+func (e *mongoElector) Resign(key string) {
+	e.lock.Lock()
+	state, ok := e.keys[key]
+	delete(e.keys, key)
+	e.lock.Unlock()
+	if ok && state.until.After(time.Now()) {
+		e.release(context.Background(), key)
+	}
+}
+
+func (e *mongoElector) IsLeader(key string) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	state, ok := e.keys[key]
+	return ok && state.until.After(time.Now())
+}
+
+func (e *mongoElector) OnChange(listener LeaderListener) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.listeners = append(e.listeners, listener)
+}
+
+func (e *mongoElector) Run() {
+	go e.loopRenew()
+}
+
+func (e *mongoElector) Stop(ctx context.Context) error {
+	e.cancel()
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.lock.Lock()
+	held := make([]string, 0, len(e.keys))
+	for key, state := range e.keys {
+		if state.until.After(time.Now()) {
+			held = append(held, key)
+		}
+	}
+	e.keys = make(map[string]*leaseState)
+	e.lock.Unlock()
+	for _, key := range held {
+		e.release(ctx, key)
+	}
+	return nil
+}
+
+// 每三分之一租约时长续约一次，不是 leader 的 key 重新竞选
+func (e *mongoElector) loopRenew() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.lock.Lock()
+			keys := make([]string, 0, len(e.keys))
+			for key := range e.keys {
+				keys = append(keys, key)
+			}
+			e.lock.Unlock()
+			for _, key := range keys {
+				e.renew(key)
+			}
+		}
+	}
+}
+
+// 竞选或续约，返回当前实例是否为 leader，leader 身份变化时通知监听器
+func (e *mongoElector) renew(key string) bool {
+	// 以请求之前的时间计算租约到期时间，本地认为的到期时间不会晚于集合中记录的到期时间
+	now := time.Now()
+	filter := bson.M{
+		"_id": key,
+		"$or": bson.A{
+			bson.M{"holder": e.instance},
+			bson.M{"expire_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": e.instance, "expire_at": now.Add(e.ttl)}}
+	ctx, cancel := context.WithTimeout(context.Background(), leaseTimeout)
+	defer cancel()
+	_, err := e.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	e.lock.Lock()
+	state, ok := e.keys[key]
+	if !ok {
+		// 竞选期间已经放弃
+		e.lock.Unlock()
+		return false
+	}
+	switch {
+	case err == nil:
+		state.until = now.Add(e.ttl)
+	case mongo.IsDuplicateKeyError(err):
+		// 其他实例持有未过期的租约
+		state.until = time.Time{}
+	default:
+		// 续约失败时保留已有的租约直到过期
+		logrus.Warningf("cluster campaign for [%s] err: %v", key, err)
+	}
+	leader := state.until.After(time.Now())
+	changed := leader != state.leader
+	state.leader = leader
+	listeners := make([]LeaderListener, len(e.listeners))
+	copy(listeners, e.listeners)
+	e.lock.Unlock()
+
+	if changed {
+		logrus.Infof("cluster instance [%s] leader of [%s]: %v", e.instance, key, leader)
+		for _, listener := range listeners {
+			listener(key, leader)
+		}
+	}
+	return leader
+}
+
+// 释放持有的租约，其他实例下次竞选时即可接替
+func (e *mongoElector) release(ctx context.Context, key string) {
+	ctx, cancel := context.WithTimeout(ctx, leaseTimeout)
+	defer cancel()
+	if _, err := e.collection.DeleteOne(ctx, bson.M{"_id": key, "holder": e.instance}); err != nil {
+		logrus.Warningf("cluster release [%s] err: %v", key, err)
+	}
+}
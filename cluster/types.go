@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IBus 在多个实例之间转发 websocket 推送消息的总线
+type IBus interface {
+	// Publish 发布消息，所有实例（包括当前实例）的处理函数都会收到
+	Publish(group string, data []byte) error
+	// Subscribe 添加消息处理函数，需要在 Run 之前调用
+	Subscribe(handler BusHandler)
+	// Run 开始接收其他实例发布的消息
+	Run()
+	// Stop 停止接收其他实例发布的消息
+	Stop(ctx context.Context) error
+}
+
+// IElector 按 key 选举 leader，同一个 key 同一时刻最多只有一个实例是 leader
+type IElector interface {
+	// Campaign 参与 key 的竞选并立即尝试成为 leader，之后定时续约或重新竞选，直到 Resign
+	Campaign(key string) bool
+	// Resign 放弃 key 的 leader 身份并停止竞选，不会通知监听器
+	Resign(key string)
+	// IsLeader 当前实例是否为 key 的 leader
+	IsLeader(key string) bool
+	// OnChange 添加 leader 身份变化的监听器，需要在 Run 之前调用
+	OnChange(listener LeaderListener)
+	// Run 开始定时续约和重新竞选
+	Run()
+	// Stop 停止竞选并释放持有的 leader 身份，其他实例可以立即接替
+	Stop(ctx context.Context) error
+}
+
+// BusMessage 总线上的消息
+type BusMessage struct {
+	ID primitive.ObjectID `bson:"_id"`
+	// 发布消息的实例ID
+	Instance string `bson:"instance"`
+	// 消息所属的 websocket 组
+	Group string `bson:"group"`
+	Data  []byte `bson:"data"`
+}
+
+// BusHandler 总线消息处理函数
+type BusHandler func(msg *BusMessage)
+
+// LeaderListener leader 身份变化的监听器，leader 为 true 表示当前实例成为 key 的 leader
+type LeaderListener func(key string, leader bool)
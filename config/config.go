@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"graces/util/validate"
@@ -11,6 +14,11 @@ import (
 
 const (
 	configFile = "config.toml"
+
+	// DBModeMongo 使用 MongoDB 存储数据
+	DBModeMongo = "mongo"
+	// DBModeMemory 使用内存存储数据，不依赖 MongoDB，用于 demo 和测试
+	DBModeMemory = "memory"
 )
 
 var (
@@ -26,16 +34,24 @@ type config struct {
 	LogConf     *logConf               `toml:"log"`
 	DBConf      *dbConf                `toml:"db"`
 	JWTConf     *jwtConf               `toml:"jwt"`
+	AdminConf   *adminConf             `toml:"admin" validate:"required"`
 	WSConf      *wsConf                `toml:"ws"`
 	ChainConfig map[string]interface{} `toml:"chain_config"`
 	Syncer      *syncer                `toml:"syncer"`
+	Shutdown    *shutdownConf          `toml:"shutdown" validate:"required"`
+	SecretConf  *secretConf            `toml:"secret"`
+	ReloadConf  *reloadConf            `toml:"reload"`
+	CorsConf    *corsConf              `toml:"cors"`
+	ClusterConf *clusterConf           `toml:"cluster"`
+	TxPoolConf  *txPoolConf            `toml:"txpool"`
 }
 
 type httpConf struct {
 	IP   string `toml:"ip" validate:"required"`
 	Port string `toml:"port" validate:"required"`
 	Mode string `toml:"mode" validate:"required,oneof=debug release test"`
-	Cors string `toml:"cors" validate:"required"`
+	// Cors 允许跨域访问的单个来源，已被 [cors] 配置取代，只在没有 [cors] 配置时使用
+	Cors string `toml:"cors"`
 }
 
 func (hc *httpConf) Addr() string {
@@ -43,6 +59,7 @@ func (hc *httpConf) Addr() string {
 }
 
 type dbConf struct {
+	Mode     string        `toml:"mode" validate:"omitempty,oneof=mongo memory"`
 	IP       string        `toml:"ip" validate:"required"`
 	Port     string        `toml:"port" validate:"required"`
 	UserName string        `toml:"username" validate:"required"`
@@ -66,9 +83,56 @@ type wsConf struct {
 	Timeout        time.Duration   `toml:"timeout" validate:"required,min=0"`
 	RetryInterval  time.Duration   `toml:"retry_interval" validate:"required,min=1"`
 	MaxRetryCnt    int64           `toml:"max_retry_cnt" validate:"required,min=0"`
+	DropPolicy     string          `toml:"drop_policy" validate:"omitempty,oneof=drop_oldest disconnect"`
+	PingInterval   time.Duration   `toml:"ping_interval" validate:"min=0"`
+	PongTimeout    time.Duration   `toml:"pong_timeout" validate:"min=0"`
+	WriteTimeout   time.Duration   `toml:"write_timeout" validate:"min=0"`
+	IdleTimeout    time.Duration   `toml:"idle_timeout" validate:"min=0"`
+	MaxReplay      int64           `toml:"max_replay_blocks" validate:"min=0"`
 	WsMsgTypesConf *wsMsgTypesConf `toml:"msg_types" validate:"required"`
 }
 
+// PingPeriod 发送 ping 帧的间隔，未配置时为 30 秒，不会超过 pong 的超时时间
+func (wc *wsConf) PingPeriod() time.Duration {
+	period := wc.PingInterval * time.Second
+	if period <= 0 {
+		period = 30 * time.Second
+	}
+	if wait := wc.PongWait(); period >= wait {
+		period = wait * 9 / 10
+	}
+	return period
+}
+
+// PongWait 等待对端数据或 pong 帧的超时时间，未配置时为 60 秒
+func (wc *wsConf) PongWait() time.Duration {
+	if wc.PongTimeout <= 0 {
+		return 60 * time.Second
+	}
+	return wc.PongTimeout * time.Second
+}
+
+// WriteWait 单次写入的超时时间，未配置时为 10 秒
+func (wc *wsConf) WriteWait() time.Duration {
+	if wc.WriteTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return wc.WriteTimeout * time.Second
+}
+
+// IdleWait 前端连接没有收发数据消息的最长时间，为 0 时不限制
+func (wc *wsConf) IdleWait() time.Duration {
+	return wc.IdleTimeout * time.Second
+}
+
+// MaxReplayBlocks 断线重连时最多回放的区块数，未配置时为 1000
+func (wc *wsConf) MaxReplayBlocks() int64 {
+	if wc.MaxReplay <= 0 {
+		return 1000
+	}
+	return wc.MaxReplay
+}
+
 type wsMsgTypesConf struct {
 	Sub *subMsgTypeConf `toml:"sub" validate:"required"`
 	Pub *pubMsgTypeConf `toml:"pub" validate:"required"`
@@ -84,19 +148,50 @@ type pubMsgTypeConf struct {
 	TXType       string `toml:"tx_type" validate:"required"`
 	StatsType    string `toml:"stats_type" validate:"required"`
 	NodeInfoType string `toml:"node_info_type" validate:"required"`
+	LogType      string `toml:"log_type" validate:"required"`
+	SyncType     string `toml:"sync_type" validate:"required"`
+	// PendingTXType 交易池中的交易增加或移除
+	PendingTXType string `toml:"pending_tx_type" validate:"required"`
 }
 
 type jwtConf struct {
+	// KeyID 当前签名秘钥的ID，签发 token 时写入头部的 kid
+	KeyID string `toml:"kid" validate:"required"`
 	// SecKey 服务端秘钥
 	SecKey string `toml:"seckey" validate:"required"`
-	// Expires JWT 过期时间，单位：秒
+	// RetiredKeys 轮换下来的旧秘钥，kid -> 秘钥，只用于校验轮换前签发的 token
+	RetiredKeys map[string]string `toml:"retired_keys"`
+	// Expires 访问 token 过期时间，单位：秒
 	Expires time.Duration `toml:"expires" validate:"required"`
+	// RefreshExpires 刷新 token 过期时间，单位：秒
+	RefreshExpires time.Duration `toml:"refresh_expires" validate:"required"`
 	// Issuer JWT 签发人
 	Issuer string `toml:"issuer"`
 	// Prefix JWT 生成 token 所添加的前缀
 	Prefix string `toml:"prefix"`
 }
 
+type adminConf struct {
+	// Username 初始管理员用户名
+	Username string `toml:"username" validate:"required"`
+	// Password 初始管理员密码，仅在管理员不存在时用于创建
+	Password string `toml:"password" validate:"omitempty,min=6"`
+}
+
+// DefaultAdminPassword 旧版本配置文件中公开的初始管理员密码，不允许使用
+const DefaultAdminPassword = "graces@123"
+
+// CheckPassword 检查初始管理员密码已配置且不是公开的默认密码
+func (c *adminConf) CheckPassword() error {
+	if c.Password == "" {
+		return errors.New("admin.password is required")
+	}
+	if c.Password == DefaultAdminPassword {
+		return errors.New("admin.password must not be the default password")
+	}
+	return nil
+}
+
 type Level uint32
 type logConf struct {
 	//Level      string `toml:"level"`
@@ -116,12 +211,121 @@ type syncer struct {
 	IncrInterval time.Duration `toml:"incr_interval" validate:"required,min=1"`
 }
 
+type shutdownConf struct {
+	// GracePeriod 优雅停机的最长等待时间，单位：秒
+	GracePeriod time.Duration `toml:"grace_period" validate:"required,min=1"`
+}
+
+type secretConf struct {
+	// MasterKeyEnv 存放主密钥的环境变量名，优先于 MasterKeyFile
+	MasterKeyEnv string `toml:"master_key_env"`
+	// MasterKeyFile 存放主密钥的文件路径
+	MasterKeyFile string `toml:"master_key_file"`
+}
+
+type reloadConf struct {
+	// WatchInterval 检查配置文件是否被修改的间隔，单位：秒，为 0 时不检查，只在收到 SIGHUP 信号时重新加载
+	WatchInterval time.Duration `toml:"watch_interval" validate:"min=0"`
+}
+
+type clusterConf struct {
+	// Enabled 是否启用多实例部署，启用后需要使用 MongoDB 存储
+	Enabled bool `toml:"enabled"`
+	// InstanceID 实例ID，为空时使用主机名加随机后缀
+	InstanceID string `toml:"instance_id"`
+	// LeaseTTL leader 租约时长，单位：秒
+	LeaseTTL time.Duration `toml:"lease_ttl" validate:"min=0"`
+	// BusSize 转发 websocket 推送消息的 capped collection 大小，单位：MB
+	BusSize int64 `toml:"bus_size" validate:"min=0"`
+}
+
+// IsEnabled 是否启用多实例部署，未配置 [cluster] 时为单实例部署
+func (cc *clusterConf) IsEnabled() bool {
+	return cc != nil && cc.Enabled
+}
+
+// LeaseDuration leader 租约时长，未配置时为 15 秒
+func (cc *clusterConf) LeaseDuration() time.Duration {
+	if cc == nil || cc.LeaseTTL <= 0 {
+		return 15 * time.Second
+	}
+	return cc.LeaseTTL * time.Second
+}
+
+// BusSizeInBytes 转发消息的 capped collection 大小，未配置时为 64MB
+func (cc *clusterConf) BusSizeInBytes() int64 {
+	if cc == nil || cc.BusSize <= 0 {
+		return 64 << 20
+	}
+	return cc.BusSize << 20
+}
+
+type txPoolConf struct {
+	// Interval 从节点拉取交易池的间隔，单位：秒，为 0 时不拉取
+	Interval time.Duration `toml:"interval" validate:"min=0"`
+}
+
+// PollInterval 拉取交易池的间隔，未配置 [txpool] 时为 3 秒，配置为 0 时不拉取
+func (tc *txPoolConf) PollInterval() time.Duration {
+	if tc == nil {
+		return 3 * time.Second
+	}
+	return tc.Interval * time.Second
+}
+
 // 加载配置信息
 func loadConfigFromFile(file string) {
-	if _, err := toml.DecodeFile(file, &Config); err != nil {
+	c, err := load(file)
+	if err != nil {
 		panic(err)
 	}
-	if err := validate.Validate(*Config); err != nil {
-		panic(err)
+	Config = c
+}
+
+// 读取配置文件，依次应用环境变量覆盖、解析敏感配置项的引用，最后进行校验
+func load(file string) (*config, error) {
+	var c *config
+	if _, err := toml.DecodeFile(findConfigFile(file), &c); err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = &config{}
+	}
+	if err := applyEnvOverrides(c); err != nil {
+		return nil, err
+	}
+	if err := resolveSecretRefs(c); err != nil {
+		return nil, err
+	}
+	if c.CorsConf == nil && c.HttpConf != nil {
+		c.CorsConf = legacyCorsConf(c.HttpConf.Cors)
 	}
+	if err := validate.Validate(*c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 从当前目录开始逐级向上查找配置文件，便于在各个包目录下运行测试
+func findConfigFile(file string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	for {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return file
+		}
+		dir = parent
+	}
+}
+
+// IsMemoryDB 是否使用内存存储
+func (dbc *dbConf) IsMemoryDB() bool {
+	return dbc.Mode == DBModeMemory
 }
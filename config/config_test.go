@@ -1,7 +1,12 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -11,3 +16,140 @@ func Test_ConfigInit(t *testing.T) {
 	logrus.Infof("config: %+v", Config)
 	assert.True(t, Config != nil)
 }
+
+func TestResolveSecretRef(t *testing.T) {
+	v, err := ResolveSecretRef("plain")
+	assert.True(t, err == nil)
+	assert.Equal(t, "plain", v)
+
+	assert.True(t, os.Setenv("GRACES_TEST_SECRET", "from-env") == nil)
+	defer os.Unsetenv("GRACES_TEST_SECRET")
+	v, err = ResolveSecretRef("env:GRACES_TEST_SECRET")
+	assert.True(t, err == nil)
+	assert.Equal(t, "from-env", v)
+	_, err = ResolveSecretRef("env:GRACES_TEST_SECRET_NOT_SET")
+	assert.True(t, err != nil)
+
+	dir, err := ioutil.TempDir("", "graces")
+	assert.True(t, err == nil)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "secret")
+	assert.True(t, ioutil.WriteFile(file, []byte("from-file\n"), 0600) == nil)
+	v, err = ResolveSecretRef("file:" + file)
+	assert.True(t, err == nil)
+	assert.Equal(t, "from-file", v)
+	_, err = ResolveSecretRef("file:" + file + ".missing")
+	assert.True(t, err != nil)
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	envs := map[string]string{
+		"GRACES_HTTP_PORT":                    "19999",
+		"GRACES_LOG_LEVEL":                    "3",
+		"GRACES_SYNCER_INCR_INTERVAL":         "60",
+		"GRACES_WS_MSG_TYPES_SUB_SEND_TYPE":   "sub",
+		"GRACES_JWT_RETIRED_KEYS_V0":          "old-key",
+		"GRACES_CHAIN_CONFIG_NODE_PASSPHRASE": "env-passphrase",
+	}
+	for k, v := range envs {
+		assert.True(t, os.Setenv(k, v) == nil)
+		defer os.Unsetenv(k)
+	}
+	c, err := load(configFile)
+	assert.True(t, err == nil)
+	assert.Equal(t, "19999", c.HttpConf.Port)
+	assert.Equal(t, Level(3), c.LogConf.Level)
+	assert.Equal(t, time.Duration(60), c.Syncer.IncrInterval)
+	assert.Equal(t, "sub", c.WSConf.WsMsgTypesConf.Sub.SendType)
+	assert.Equal(t, "old-key", c.JWTConf.RetiredKeys["v0"])
+	assert.Equal(t, "env-passphrase", c.ChainConfig["node"].(map[string]interface{})["passphrase"])
+
+	// 覆盖后的配置同样需要通过校验
+	assert.True(t, os.Setenv("GRACES_HTTP_MODE", "unknown") == nil)
+	defer os.Unsetenv("GRACES_HTTP_MODE")
+	_, err = load(configFile)
+	assert.True(t, err != nil)
+}
+
+func TestReload(t *testing.T) {
+	level := Config.LogConf.Level
+	defer func() {
+		Config.LogConf.Level = level
+		logrus.SetLevel(logrus.Level(level))
+	}()
+
+	changed, err := Reload()
+	assert.True(t, err == nil)
+	assert.Equal(t, 0, len(changed))
+
+	// 日志级别可以在运行时生效
+	assert.True(t, os.Setenv("GRACES_LOG_LEVEL", "2") == nil)
+	defer os.Unsetenv("GRACES_LOG_LEVEL")
+	changed, err = Reload()
+	assert.True(t, err == nil)
+	assert.Equal(t, []string{"log.level"}, changed)
+	assert.Equal(t, Level(2), Config.LogConf.Level)
+
+	// 端口需要重启才能生效，拒绝重新加载
+	port := Config.HttpConf.Port
+	assert.True(t, os.Setenv("GRACES_HTTP_PORT", "19999") == nil)
+	defer os.Unsetenv("GRACES_HTTP_PORT")
+	assert.True(t, os.Setenv("GRACES_LOG_LEVEL", "4") == nil)
+	_, err = Reload()
+	assert.True(t, err != nil)
+	assert.True(t, strings.Contains(err.Error(), "http.port"))
+	assert.Equal(t, port, Config.HttpConf.Port)
+	assert.Equal(t, Level(2), Config.LogConf.Level)
+}
+
+func TestReload_ConcurrentRead(t *testing.T) {
+	level := Config.LogConf.Level
+	defer func() {
+		Config.LogConf.Level = level
+		logrus.SetLevel(logrus.Level(level))
+	}()
+	assert.True(t, os.Setenv("GRACES_LOG_LEVEL", "2") == nil)
+	defer os.Unsetenv("GRACES_LOG_LEVEL")
+
+	// 重新加载时运行时的读取方不能读到正在修改的配置，使用 go test -race 检查
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = CorsPolicy().AllowsOrigin("http://localhost:8080")
+			_ = LogLevel()
+			_ = SyncIncrInterval()
+			_ = JWTExpires() + JWTRefreshExpires()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		_, err := Reload()
+		assert.True(t, err == nil)
+	}
+	<-done
+	assert.Equal(t, Level(2), LogLevel())
+}
+
+func TestCorsConf_AllowsOrigin(t *testing.T) {
+	cc := &corsConf{AllowOrigins: []string{"https://*.example.com", "http://localhost:*"}}
+	assert.True(t, cc.AllowsOrigin("https://web.example.com"))
+	assert.True(t, cc.AllowsOrigin("http://localhost:8080"))
+	assert.False(t, cc.AllowsOrigin("https://example.com"))
+	assert.False(t, cc.AllowsOrigin("http://web.example.com"))
+	assert.False(t, cc.AllowsOrigin(""))
+
+	// websocket 放行没有 Origin 的客户端和同源请求
+	assert.True(t, cc.AllowsWebsocketOrigin("", "127.0.0.1:9999"))
+	assert.True(t, cc.AllowsWebsocketOrigin("http://127.0.0.1:9999", "127.0.0.1:9999"))
+	assert.False(t, cc.AllowsWebsocketOrigin("http://evil.com", "127.0.0.1:9999"))
+
+	legacy := legacyCorsConf("http://localhost:8080")
+	assert.True(t, legacy.AllowsOrigin("http://localhost:8080"))
+	assert.False(t, legacy.AllowsOrigin("http://localhost:8081"))
+}
+
+func TestAdminConf_CheckPassword(t *testing.T) {
+	assert.True(t, (&adminConf{Username: "admin"}).CheckPassword() != nil)
+	assert.True(t, (&adminConf{Username: "admin", Password: DefaultAdminPassword}).CheckPassword() != nil)
+	assert.True(t, (&adminConf{Username: "admin", Password: "another-password"}).CheckPassword() == nil)
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+type corsConf struct {
+	// AllowOrigins 允许跨域访问的来源，支持 * 通配符，如 https://*.example.com、http://localhost:*，"*" 表示允许所有来源
+	AllowOrigins []string `toml:"allow_origins" validate:"required,min=1"`
+	// AllowMethods 允许跨域访问的请求方法
+	AllowMethods []string `toml:"allow_methods" validate:"required,min=1"`
+	// AllowHeaders 允许跨域请求携带的请求头
+	AllowHeaders []string `toml:"allow_headers"`
+	// ExposeHeaders 允许跨域请求读取的响应头
+	ExposeHeaders []string `toml:"expose_headers"`
+	// AllowCredentials 是否允许跨域请求携带 cookie 等凭证
+	AllowCredentials bool `toml:"allow_credentials"`
+	// MaxAge 预检请求结果的缓存时间，单位：秒，为 0 时不缓存
+	MaxAge time.Duration `toml:"max_age" validate:"min=0"`
+}
+
+// 没有 [cors] 配置时，按 http.cors 构建与旧版本行为一致的跨域配置
+func legacyCorsConf(origin string) *corsConf {
+	conf := &corsConf{
+		AllowMethods:     []string{"POST", "GET", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Content-Type", "AccessToken", "X-CSRF-Token", "Authorization", "Token", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Content-Type"},
+		AllowCredentials: true,
+	}
+	if origin != "" {
+		conf.AllowOrigins = []string{origin}
+	}
+	return conf
+}
+
+// AllowsOrigin 来源是否允许跨域访问
+func (cc *corsConf) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for _, pattern := range cc.AllowOrigins {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+		if pattern == "*" || matchWildcard(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsWebsocketOrigin websocket 握手请求的来源是否允许：没有 Origin 的非浏览器客户端和同源请求直接放行，
+// 其他来源与跨域配置保持一致
+func (cc *corsConf) AllowsWebsocketOrigin(origin string, host string) bool {
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, host) {
+		return true
+	}
+	return cc.AllowsOrigin(origin)
+}
+
+// 通配符匹配，* 匹配任意长度的字符
+func matchWildcard(pattern string, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
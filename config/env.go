@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 环境变量覆盖的前缀，变量名为前缀加上配置项的 toml 路径，全部大写并以下划线连接，
+// 如 http.port 对应 GRACES_HTTP_PORT，ws.msg_types.sub.send_type 对应 GRACES_WS_MSG_TYPES_SUB_SEND_TYPE
+const envPrefix = "GRACES"
+
+// 使用 GRACES_* 环境变量覆盖配置项。map 类型的配置项中，
+// map[string]string 可以通过环境变量新增键，chain_config 等其他 map 只能覆盖已有的键
+func applyEnvOverrides(c *config) error {
+	_, err := overrideValue(reflect.ValueOf(c).Elem(), envPrefix)
+	return err
+}
+
+// 按环境变量覆盖配置项，返回是否有配置项被覆盖
+func overrideValue(v reflect.Value, name string) (bool, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return false, nil
+		}
+		// 配置文件中没有的段，只在有环境变量覆盖时才创建
+		target := v
+		if v.IsNil() {
+			target = reflect.New(v.Type().Elem())
+		}
+		set, err := overrideValue(target.Elem(), name)
+		if err != nil {
+			return false, err
+		}
+		if set && v.IsNil() {
+			v.Set(target)
+		}
+		return set, nil
+	case reflect.Struct:
+		set := false
+		for i := 0; i < v.NumField(); i++ {
+			tag := strings.Split(v.Type().Field(i).Tag.Get("toml"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			ok, err := overrideValue(v.Field(i), envName(name, tag))
+			if err != nil {
+				return false, err
+			}
+			set = set || ok
+		}
+		return set, nil
+	case reflect.Map:
+		return overrideMap(v, name)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return false, nil
+	}
+	if err := setValue(v, value); err != nil {
+		return false, fmt.Errorf("env [%s]: %w", name, err)
+	}
+	return true, nil
+}
+
+func overrideMap(v reflect.Value, name string) (bool, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return false, nil
+	}
+	set := false
+	// map[string]string：环境变量中 name_ 之后的部分转为小写作为键
+	if v.Type().Elem().Kind() == reflect.String {
+		for _, kv := range os.Environ() {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], name+"_") {
+				continue
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			key := strings.ToLower(strings.TrimPrefix(parts[0], name+"_"))
+			v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(parts[1]))
+			set = true
+		}
+		return set, nil
+	}
+	// 其他 map：只覆盖已有的键
+	if m, ok := v.Interface().(map[string]interface{}); ok {
+		return overrideInterfaceMap(m, name)
+	}
+	return false, nil
+}
+
+func overrideInterfaceMap(m map[string]interface{}, name string) (bool, error) {
+	set := false
+	for k, old := range m {
+		key := envName(name, k)
+		if sub, ok := old.(map[string]interface{}); ok {
+			ok, err := overrideInterfaceMap(sub, key)
+			if err != nil {
+				return false, err
+			}
+			set = set || ok
+			continue
+		}
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		switch old.(type) {
+		case int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return false, fmt.Errorf("env [%s]: %w", key, err)
+			}
+			m[k] = n
+		case float64:
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("env [%s]: %w", key, err)
+			}
+			m[k] = n
+		case bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, fmt.Errorf("env [%s]: %w", key, err)
+			}
+			m[k] = b
+		default:
+			m[k] = value
+		}
+		set = true
+	}
+	return set, nil
+}
+
+// 按字段类型解析环境变量的值
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		// 字符串列表以逗号分隔
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func envName(prefix string, key string) string {
+	return prefix + "_" + strings.ToUpper(key)
+}
@@ -73,7 +73,7 @@ func MakeLogConfig() error {
 	if err != nil {
 		return errors.New("new logger is error")
 	}
-	level := cfg.Level
+	level := LogLevel()
 	logrus.SetLevel(logrus.Level(level))
 	logrus.SetOutput(os.Stdout)
 	logrus.SetOutput(logger)
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// 保护可以在运行时生效的配置项，运行时通过 CorsPolicy、LogLevel 等方法读取，不能直接读 Config
+	reloadLock sync.RWMutex
+
+	// 可以在运行时生效的配置项及其应用方法，其他配置项变化后需要重启服务
+	reloadable = map[string]func(next *config){
+		"log.level": func(next *config) {
+			Config.LogConf.Level = next.LogConf.Level
+			logrus.SetLevel(logrus.Level(next.LogConf.Level))
+		},
+		"syncer.incr_interval": func(next *config) {
+			Config.Syncer.IncrInterval = next.Syncer.IncrInterval
+		},
+		"http.cors": func(next *config) {
+			Config.HttpConf.Cors = next.HttpConf.Cors
+		},
+		"cors.allow_origins":     reloadCors,
+		"cors.allow_methods":     reloadCors,
+		"cors.allow_headers":     reloadCors,
+		"cors.expose_headers":    reloadCors,
+		"cors.allow_credentials": reloadCors,
+		"cors.max_age":           reloadCors,
+		"jwt.expires": func(next *config) {
+			Config.JWTConf.Expires = next.JWTConf.Expires
+		},
+		"jwt.refresh_expires": func(next *config) {
+			Config.JWTConf.RefreshExpires = next.JWTConf.RefreshExpires
+		},
+	}
+)
+
+// 跨域配置整体替换，避免请求读到一半新一半旧的配置
+func reloadCors(next *config) {
+	Config.CorsConf = next.CorsConf
+}
+
+// CorsPolicy 当前的跨域配置，重新加载时整体替换，返回值只读
+func CorsPolicy() *corsConf {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return Config.CorsConf
+}
+
+// LogLevel 当前的日志级别
+func LogLevel() Level {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return Config.LogConf.Level
+}
+
+// SyncIncrInterval 当前的增量同步间隔
+func SyncIncrInterval() time.Duration {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return Config.Syncer.IncrInterval * time.Second
+}
+
+// JWTExpires 当前的访问 token 有效期
+func JWTExpires() time.Duration {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return Config.JWTConf.Expires * time.Second
+}
+
+// JWTRefreshExpires 当前的刷新 token 有效期
+func JWTRefreshExpires() time.Duration {
+	reloadLock.RLock()
+	defer reloadLock.RUnlock()
+	return Config.JWTConf.RefreshExpires * time.Second
+}
+
+// Reload 重新加载配置文件并应用环境变量覆盖，只应用可以在运行时生效的配置项：
+// 日志级别、增量同步间隔、CORS 和 JWT 过期时间，返回发生变化的配置项。
+// 需要重启才能生效的配置项发生变化时拒绝本次重新加载，当前配置保持不变
+func Reload() ([]string, error) {
+	next, err := load(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	changed := make([]string, 0)
+	diffValue(reflect.ValueOf(Config), reflect.ValueOf(next), "", &changed)
+	sort.Strings(changed)
+	restart := make([]string, 0)
+	for _, name := range changed {
+		if _, ok := reloadable[name]; !ok {
+			restart = append(restart, name)
+		}
+	}
+	if len(restart) > 0 {
+		return nil, fmt.Errorf("config [%s] changed, restart is required", strings.Join(restart, ", "))
+	}
+	for _, name := range changed {
+		reloadable[name](next)
+	}
+	return changed, nil
+}
+
+// WatchConfigFile 定时检查配置文件的修改时间，文件被修改后重新加载配置，ctx 取消后退出
+func WatchConfigFile(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	file := findConfigFile(configFile)
+	modTime := fileModTime(file)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t := fileModTime(file)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+			logrus.Infof("config file [%s] modified, reloading", file)
+			ReloadAndLog()
+		}
+	}
+}
+
+// ReloadAndLog 重新加载配置并记录结果
+func ReloadAndLog() {
+	changed, err := Reload()
+	if err != nil {
+		logrus.Errorf("config reload rejected: %v", err)
+		return
+	}
+	if len(changed) == 0 {
+		logrus.Infof("config reloaded, nothing changed")
+		return
+	}
+	logrus.Infof("config reloaded, changed: %s", strings.Join(changed, ", "))
+}
+
+func fileModTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// 比较两份配置，按 toml 路径记录发生变化的配置项，map 和列表整体比较
+func diffValue(a reflect.Value, b reflect.Value, path string, changed *[]string) {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*changed = append(*changed, path)
+			}
+			return
+		}
+		diffValue(a.Elem(), b.Elem(), path, changed)
+		return
+	}
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changed = append(*changed, path)
+		}
+		return
+	}
+	for i := 0; i < a.NumField(); i++ {
+		tag := strings.Split(a.Type().Field(i).Tag.Get("toml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := tag
+		if path != "" {
+			name = path + "." + tag
+		}
+		diffValue(a.Field(i), b.Field(i), name, changed)
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// 环境变量引用前缀，如 password = "env:GRACES_DB_PASSWORD"
+	secretRefEnv = "env:"
+	// 文件引用前缀，如 password = "file:/run/secrets/db_password"，读取时去掉首尾空白
+	secretRefFile = "file:"
+)
+
+// ResolveSecretRef 解析敏感配置项的引用，不是引用的值原样返回
+func ResolveSecretRef(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretRefEnv):
+		name := strings.TrimPrefix(value, secretRefEnv)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable [%s] is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, secretRefFile):
+		path := strings.TrimPrefix(value, secretRefFile)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
+
+// 解析配置中所有敏感配置项的引用：数据库账号密码、JWT 秘钥、初始管理员密码和链账户默认密码
+func resolveSecretRefs(c *config) error {
+	fields := make(map[string]*string)
+	if c.DBConf != nil {
+		fields["db.username"] = &c.DBConf.UserName
+		fields["db.password"] = &c.DBConf.Password
+	}
+	if c.JWTConf != nil {
+		fields["jwt.seckey"] = &c.JWTConf.SecKey
+	}
+	if c.AdminConf != nil {
+		fields["admin.password"] = &c.AdminConf.Password
+	}
+	for name, field := range fields {
+		v, err := ResolveSecretRef(*field)
+		if err != nil {
+			return fmt.Errorf("resolve config [%s]: %w", name, err)
+		}
+		*field = v
+	}
+	if c.JWTConf != nil {
+		for kid, key := range c.JWTConf.RetiredKeys {
+			v, err := ResolveSecretRef(key)
+			if err != nil {
+				return fmt.Errorf("resolve config [jwt.retired_keys.%s]: %w", kid, err)
+			}
+			c.JWTConf.RetiredKeys[kid] = v
+		}
+	}
+	if node, ok := c.ChainConfig["node"].(map[string]interface{}); ok {
+		if passphrase, ok := node["passphrase"].(string); ok {
+			v, err := ResolveSecretRef(passphrase)
+			if err != nil {
+				return fmt.Errorf("resolve config [chain_config.node.passphrase]: %w", err)
+			}
+			node["passphrase"] = v
+		}
+	}
+	return nil
+}
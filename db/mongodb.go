@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"graces/config"
@@ -12,26 +13,33 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var (
-	DefaultDB *DB
-	ctx       context.Context
-)
+// Connect 按配置连接 MongoDB，连接或 ping 失败时返回错误
+func Connect() (*DB, error) {
+	database, err := NewDB(config.Config.DBConf.Uri(), config.Config.DBConf.DBName, config.Config.DBConf.Timeout*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Info("db successfully connected and pinged.")
+	return database, nil
+}
 
-func init() {
-	uri := options.Client().ApplyURI(config.Config.DBConf.Uri())
-	ctx, _ = context.WithTimeout(context.Background(), config.Config.DBConf.Timeout*time.Second)
-	clientConnect, err := mongo.Connect(ctx, uri)
+// NewDB 连接 MongoDB 并 ping 检查可用性
+func NewDB(uri string, dbName string, timeout time.Duration) (*DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	clientConnect, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	DefaultDB = &DB{
+	database := &DB{
 		client: clientConnect,
-		Db:     clientConnect.Database(config.Config.DBConf.DBName),
+		Db:     clientConnect.Database(dbName),
 	}
-	if err = DefaultDB.Ping(); err != nil {
-		logrus.Fatalln("failed to connection DB：", err)
+	if err = database.Ping(); err != nil {
+		_ = clientConnect.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to connection DB: %w", err)
 	}
-	logrus.Info("db successfully connected and pinged.")
+	return database, nil
 }
 
 type DB struct {
@@ -51,6 +59,11 @@ func (db *DB) Ping() error {
 	return nil
 }
 
+// Close 断开与 MongoDB 的连接
+func (db *DB) Close(ctx context.Context) error {
+	return db.client.Disconnect(ctx)
+}
+
 func BuildOptionsByQuery(pageIndex, pageSize int64) *options.FindOptions {
 	findOps := options.Find()
 	findOps.SetSkip((pageIndex - 1) * pageSize)
@@ -20,6 +20,8 @@ const (
 	ErrCodeTokenMalformed      = 10108
 	ErrCodeTokenExpired        = 10109
 	ErrCodeTokenNotValidYet    = 10110
+	ErrCodeAPIKeyInvalid       = 10111
+	ErrCodeAPIKeyExpired       = 10112
 
 	// 10200 ~ 10299 业务相关的错误
 	ErrCodeParameterInvalid   = 10200
@@ -46,4 +48,6 @@ const (
 	ErrCodeWebsocketClientSend       = 10305
 	ErrCodeWebsocketSubscription     = 10306
 	ErrCodeWebsocketSubMsgProcess    = 10307
+	ErrCodeWebsocketMessageInvalid   = 10308
+	ErrCodeWebsocketCommand          = 10309
 )
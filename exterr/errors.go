@@ -19,6 +19,8 @@ var (
 	ErrTokenMalformed      = NewError(ErrCodeTokenMalformed, "token malformed error")
 	ErrTokenExpired        = NewError(ErrCodeTokenExpired, "token expired error")
 	ErrTokenNotValidYet    = NewError(ErrCodeTokenNotValidYet, "token not valid yet error")
+	ErrAPIKeyInvalid       = NewError(ErrCodeAPIKeyInvalid, "api key invalid error")
+	ErrAPIKeyExpired       = NewError(ErrCodeAPIKeyExpired, "api key expired error")
 
 	// 10200 ~ 10299 业务相关的错误
 	ErrParameterInvalid   = NewError(ErrCodeParameterInvalid, "parameter invalid error")
@@ -45,4 +47,6 @@ var (
 	ErrWebsocketClientSend       = NewError(ErrCodeWebsocketClientSend, "ClientSend function call must be a websocket dial connection")
 	ErrWebsocketSubscription     = NewError(ErrCodeWebsocketSubscription, "websocket subscription error")
 	ErrWebsocketSubMsgProcess    = NewError(ErrCodeWebsocketSubMsgProcess, "websocket subscription message process error")
+	ErrWebsocketMessageInvalid   = NewError(ErrCodeWebsocketMessageInvalid, "websocket message invalid")
+	ErrWebsocketCommand          = NewError(ErrCodeWebsocketCommand, "websocket command failed")
 )
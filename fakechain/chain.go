@@ -0,0 +1,313 @@
+package fakechain
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Venachain/Venachain/common"
+	"github.com/Venachain/Venachain/common/hexutil"
+	"github.com/Venachain/Venachain/core/types"
+	"github.com/Venachain/Venachain/crypto"
+)
+
+// 模拟节点默认的区块 gas 上限
+const defaultBlockGasLimit = 1500000000
+
+type block struct {
+	header   *types.Header
+	txs      []*types.Transaction
+	receipts []*types.Receipt
+}
+
+func (b *block) number() uint64 {
+	return b.header.Number.Uint64()
+}
+
+type txEntry struct {
+	tx      *types.Transaction
+	block   *block
+	index   int
+	receipt *types.Receipt
+}
+
+// SendTx 签名一笔交易并放入交易池，等待下一次 Mine 时打包
+func (n *Node) SendTx(spec TxSpec) (common.Hash, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	tx, err := n.signTx(spec)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	n.pending = append(n.pending, tx)
+	return tx.Hash(), nil
+}
+
+// Mine 将交易池中的交易和 specs 描述的交易打包成新区块，并推送给所有 newHeads 订阅者
+func (n *Node) Mine(specs ...TxSpec) (*types.Header, error) {
+	n.lock.Lock()
+	txs := n.pending
+	n.pending = nil
+	failed := make(map[common.Hash]bool)
+	for _, spec := range specs {
+		tx, err := n.signTx(spec)
+		if err != nil {
+			n.lock.Unlock()
+			return nil, err
+		}
+		if spec.Failed {
+			failed[tx.Hash()] = true
+		}
+		txs = append(txs, tx)
+	}
+	b := n.appendBlock(txs, failed)
+	head := renderHeader(b.header)
+	n.lock.Unlock()
+
+	n.notifyNewHead(head)
+	return b.header, nil
+}
+
+// BlockNumber 当前最新区块高度
+func (n *Node) BlockNumber() uint64 {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.latest().number()
+}
+
+// Header 获取指定高度的区块头，不存在时返回 nil
+func (n *Node) Header(number uint64) *types.Header {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if number >= uint64(len(n.blocks)) {
+		return nil
+	}
+	return n.blocks[number].header
+}
+
+// Transactions 获取指定高度区块中的交易，不存在时返回 nil
+func (n *Node) Transactions(number uint64) []*types.Transaction {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if number >= uint64(len(n.blocks)) {
+		return nil
+	}
+	return n.blocks[number].txs
+}
+
+// 调用方需持有写锁
+func (n *Node) signTx(spec TxSpec) (*types.Transaction, error) {
+	gas := spec.Gas
+	if gas == 0 {
+		gas = 21000
+	}
+	var tx *types.Transaction
+	if spec.To == "" {
+		tx = types.NewContractCreation(n.nonce, big.NewInt(spec.Value), gas, big.NewInt(spec.GasPrice), spec.Data)
+	} else {
+		tx = types.NewTransaction(n.nonce, common.HexToAddress(spec.To), big.NewInt(spec.Value), gas, big.NewInt(spec.GasPrice), spec.Data)
+	}
+	signed, err := types.SignTx(tx, types.HomesteadSigner{}, n.key)
+	if err != nil {
+		return nil, err
+	}
+	n.nonce++
+	return signed, nil
+}
+
+// 调用方需持有写锁
+func (n *Node) appendBlock(txs []*types.Transaction, failed map[common.Hash]bool) *block {
+	header := &types.Header{
+		Number:      big.NewInt(int64(len(n.blocks))),
+		Coinbase:    n.from,
+		GasLimit:    defaultBlockGasLimit,
+		Time:        big.NewInt(time.Now().Unix()),
+		TxHash:      types.DeriveSha(types.Transactions(txs)),
+		ReceiptHash: types.EmptyRootHash,
+		Root:        types.EmptyRootHash,
+		Extra:       []byte{},
+	}
+	if len(n.blocks) > 0 {
+		header.ParentHash = n.latest().header.Hash()
+	}
+	b := &block{header: header, txs: txs}
+
+	var cumulative uint64
+	receipts := make([]*types.Receipt, len(txs))
+	for i, tx := range txs {
+		cumulative += tx.Gas()
+		receipt := &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: cumulative,
+			Logs:              []*types.Log{},
+			TxHash:            tx.Hash(),
+			GasUsed:           tx.Gas(),
+		}
+		if failed[tx.Hash()] {
+			receipt.Status = types.ReceiptStatusFailed
+		}
+		if tx.To() == nil {
+			receipt.ContractAddress = crypto.CreateAddress(n.from, tx.Nonce())
+		}
+		receipts[i] = receipt
+	}
+	if len(receipts) > 0 {
+		header.GasUsed = cumulative
+		header.ReceiptHash = types.DeriveSha(types.Receipts(receipts))
+	}
+	b.receipts = receipts
+
+	n.blocks = append(n.blocks, b)
+	n.byHash[header.Hash()] = b
+	for i, tx := range txs {
+		n.txs[tx.Hash()] = &txEntry{tx: tx, block: b, index: i, receipt: receipts[i]}
+	}
+	return b
+}
+
+func (n *Node) latest() *block {
+	return n.blocks[len(n.blocks)-1]
+}
+
+func (n *Node) blockByTag(tag string) *block {
+	switch tag {
+	case "", "latest", "pending":
+		return n.latest()
+	case "earliest":
+		return n.blocks[0]
+	}
+	number, err := strconv.ParseUint(strings.TrimPrefix(tag, "0x"), 16, 64)
+	if err != nil || number >= uint64(len(n.blocks)) {
+		return nil
+	}
+	return n.blocks[number]
+}
+
+func (n *Node) getBlockByNumber(params []json.RawMessage) (interface{}, error) {
+	var tag string
+	var full bool
+	if len(params) == 0 || json.Unmarshal(params[0], &tag) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid block number"}
+	}
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &full)
+	}
+	b := n.blockByTag(tag)
+	if b == nil {
+		return nil, nil
+	}
+	return n.renderBlock(b, full), nil
+}
+
+func (n *Node) getBlockByHash(params []json.RawMessage) (interface{}, error) {
+	var hash common.Hash
+	var full bool
+	if len(params) == 0 || json.Unmarshal(params[0], &hash) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid block hash"}
+	}
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &full)
+	}
+	b, ok := n.byHash[hash]
+	if !ok {
+		return nil, nil
+	}
+	return n.renderBlock(b, full), nil
+}
+
+func (n *Node) getTransactionByHash(params []json.RawMessage) (interface{}, error) {
+	var hash common.Hash
+	if len(params) == 0 || json.Unmarshal(params[0], &hash) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid transaction hash"}
+	}
+	entry, ok := n.txs[hash]
+	if !ok {
+		return nil, nil
+	}
+	return n.renderTx(entry.tx, entry.block, entry.index), nil
+}
+
+func (n *Node) getTransactionReceipt(params []json.RawMessage) (interface{}, error) {
+	var hash common.Hash
+	if len(params) == 0 || json.Unmarshal(params[0], &hash) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid transaction hash"}
+	}
+	entry, ok := n.txs[hash]
+	if !ok {
+		return nil, nil
+	}
+	fields := toMap(entry.receipt)
+	fields["blockHash"] = entry.block.header.Hash()
+	fields["blockNumber"] = hexUint64(entry.block.number())
+	fields["transactionIndex"] = hexUint64(uint64(entry.index))
+	fields["from"] = n.from
+	fields["to"] = entry.tx.To()
+	return fields, nil
+}
+
+func (n *Node) pendingTransactions() []interface{} {
+	txs := make([]interface{}, 0, len(n.pending))
+	for _, tx := range n.pending {
+		txs = append(txs, n.renderTx(tx, nil, 0))
+	}
+	return txs
+}
+
+func (n *Node) getCode(params []json.RawMessage) (interface{}, error) {
+	var address common.Address
+	if len(params) == 0 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid address"}
+	}
+	return hexutil.Bytes(n.code[address]), nil
+}
+
+func (n *Node) renderBlock(b *block, full bool) map[string]interface{} {
+	fields := renderHeader(b.header)
+	txs := make([]interface{}, len(b.txs))
+	for i, tx := range b.txs {
+		if full {
+			txs[i] = n.renderTx(tx, b, i)
+		} else {
+			txs[i] = tx.Hash()
+		}
+	}
+	fields["transactions"] = txs
+	fields["uncles"] = []interface{}{}
+	return fields
+}
+
+func (n *Node) renderTx(tx *types.Transaction, b *block, index int) map[string]interface{} {
+	fields := toMap(tx)
+	fields["from"] = n.from
+	if b != nil {
+		fields["blockHash"] = b.header.Hash()
+		fields["blockNumber"] = hexUint64(b.number())
+		fields["transactionIndex"] = hexUint64(uint64(index))
+	} else {
+		fields["blockHash"] = nil
+		fields["blockNumber"] = nil
+		fields["transactionIndex"] = nil
+	}
+	return fields
+}
+
+func renderHeader(header *types.Header) map[string]interface{} {
+	fields := toMap(header)
+	fields["hash"] = header.Hash()
+	return fields
+}
+
+// 借助类型自身的 MarshalJSON 转为 map，以便追加 RPC 层的额外字段
+func toMap(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		panic(err)
+	}
+	return fields
+}
@@ -0,0 +1,386 @@
+// Package fakechain 提供一个进程内的 Venachain 模拟节点，
+// 通过 HTTP 和 websocket 提供脚本化的 JSON-RPC 服务，用于离线集成测试。
+package fakechain
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"graces/model"
+
+	"github.com/Venachain/Venachain/common"
+	"github.com/Venachain/Venachain/core/types"
+	"github.com/Venachain/Venachain/crypto"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Node 模拟的 Venachain 节点
+type Node struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	lock     sync.RWMutex
+	key      *ecdsa.PrivateKey
+	from     common.Address
+	nonce    uint64
+	blocks   []*block
+	byHash   map[common.Hash]*block
+	txs      map[common.Hash]*txEntry
+	pending  []*types.Transaction
+	code     map[common.Address][]byte
+	cns      []CNSEntry
+	nodes    []NodeEntry
+	calls    map[string]interface{}
+	handlers map[string]Handler
+	counts   map[string]int
+
+	subLock sync.Mutex
+	subs    map[string]*wsConn
+	subSeq  uint64
+}
+
+type wsConn struct {
+	lock sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *wsConn) writeJSON(v interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// NewNode 创建并启动一个只包含创世区块的模拟节点
+func NewNode() (*Node, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{
+		key:      key,
+		from:     crypto.PubkeyToAddress(key.PublicKey),
+		byHash:   make(map[common.Hash]*block),
+		txs:      make(map[common.Hash]*txEntry),
+		code:     make(map[common.Address][]byte),
+		calls:    make(map[string]interface{}),
+		handlers: make(map[string]Handler),
+		counts:   make(map[string]int),
+		subs:     make(map[string]*wsConn),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+	n.appendBlock(nil, nil)
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	return n, nil
+}
+
+// Close 关闭模拟节点，断开所有 websocket 连接
+func (n *Node) Close() {
+	n.subLock.Lock()
+	for id, c := range n.subs {
+		_ = c.conn.Close()
+		delete(n.subs, id)
+	}
+	n.subLock.Unlock()
+	n.server.CloseClientConnections()
+	n.server.Close()
+}
+
+// URL HTTP JSON-RPC 地址
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// WSURL websocket JSON-RPC 地址
+func (n *Node) WSURL() string {
+	return "ws" + strings.TrimPrefix(n.server.URL, "http")
+}
+
+// IP 节点监听的 IP
+func (n *Node) IP() string {
+	host, _, _ := net.SplitHostPort(n.server.Listener.Addr().String())
+	return host
+}
+
+// Port 节点监听的端口，HTTP 与 websocket 共用
+func (n *Node) Port() uint64 {
+	_, port, _ := net.SplitHostPort(n.server.Listener.Addr().String())
+	p, _ := strconv.ParseUint(port, 10, 64)
+	return p
+}
+
+// From 模拟节点用于签名交易的账户地址
+func (n *Node) From() common.Address {
+	return n.from
+}
+
+// Chain 构建一个指向该模拟节点的链信息，配置与 config.toml 中的默认链配置一致
+func (n *Node) Chain(name string) *model.Chain {
+	return &model.Chain{
+		ID:      primitive.NewObjectID(),
+		Name:    name,
+		IP:      n.IP(),
+		RPCPort: n.Port(),
+		P2PPort: n.Port(),
+		WSPort:  n.Port(),
+		ChainConfig: map[string]interface{}{
+			"node": map[string]interface{}{
+				"keyfile_path": "./keystore",
+				"passphrase":   "0",
+			},
+			"ws": map[string]interface{}{
+				"topics": map[string]interface{}{
+					"new_heads": map[string]interface{}{
+						"name":   "newHeads",
+						"params": `{"jsonrpc":"2.0","method":"eth_subscribe", "params": ["newHeads"],"id":"subscription"}`,
+					},
+				},
+			},
+		},
+	}
+}
+
+// DropConnections 断开所有订阅的 websocket 连接，模拟节点重启，HTTP 服务不受影响
+func (n *Node) DropConnections() {
+	n.subLock.Lock()
+	defer n.subLock.Unlock()
+	for _, c := range n.subs {
+		_ = c.conn.Close()
+	}
+}
+
+// Handle 注册或覆盖指定方法的处理器
+func (n *Node) Handle(method string, handler Handler) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.handlers[method] = handler
+}
+
+// SetCode 设置合约地址上的代码，供 eth_getCode 返回
+func (n *Node) SetCode(address string, code []byte) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.code[common.HexToAddress(address)] = code
+}
+
+// SetCNS 设置 CNS 管理合约中已注册的合约
+func (n *Node) SetCNS(entries ...CNSEntry) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.cns = entries
+}
+
+// SetNodes 设置节点管理合约中的节点
+func (n *Node) SetNodes(nodes ...NodeEntry) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.nodes = nodes
+}
+
+// SetCallResult 设置 eth_call 调用指定地址上的 funcName 时返回的字符串结果
+func (n *Node) SetCallResult(address string, funcName string, result string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.calls[callKey(address, funcName)] = result
+}
+
+// Calls 返回指定方法被调用的次数
+func (n *Node) Calls(method string) int {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.counts[method]
+}
+
+// Subscriptions 当前有效的 websocket 订阅数量
+func (n *Node) Subscriptions() int {
+	n.subLock.Lock()
+	defer n.subLock.Unlock()
+	return len(n.subs)
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		n.serveWS(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = json.NewEncoder(w).Encode(&response{
+			Jsonrpc: "2.0",
+			Error:   &Error{Code: ErrCodeInvalidRequest, Message: err.Error()},
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(n.dispatch(nil, &req))
+}
+
+func (n *Node) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := n.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn}
+	defer func() {
+		n.subLock.Lock()
+		for id, sub := range n.subs {
+			if sub == c {
+				delete(n.subs, id)
+			}
+		}
+		n.subLock.Unlock()
+		_ = conn.Close()
+	}()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			_ = c.writeJSON(&response{
+				Jsonrpc: "2.0",
+				Error:   &Error{Code: ErrCodeInvalidRequest, Message: err.Error()},
+			})
+			continue
+		}
+		if err := c.writeJSON(n.dispatch(c, &req)); err != nil {
+			return
+		}
+	}
+}
+
+func (n *Node) dispatch(c *wsConn, req *request) *response {
+	res := &response{Jsonrpc: "2.0", ID: req.ID}
+	result, err := n.call(c, req.Method, req.Params)
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			res.Error = e
+		} else {
+			res.Error = &Error{Code: ErrCodeInternal, Message: err.Error()}
+		}
+		return res
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	res.Result = result
+	return res
+}
+
+func (n *Node) call(c *wsConn, method string, params []json.RawMessage) (interface{}, error) {
+	n.lock.Lock()
+	n.counts[method]++
+	handler, ok := n.handlers[method]
+	n.lock.Unlock()
+	if ok {
+		return handler(params)
+	}
+
+	switch method {
+	case "eth_subscribe":
+		return n.subscribe(c, params)
+	case "eth_unsubscribe":
+		return n.unsubscribe(params)
+	}
+
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	switch method {
+	case "eth_blockNumber":
+		return hexUint64(n.latest().number()), nil
+	case "eth_getBlockByNumber":
+		return n.getBlockByNumber(params)
+	case "eth_getBlockByHash":
+		return n.getBlockByHash(params)
+	case "eth_getTransactionByHash":
+		return n.getTransactionByHash(params)
+	case "eth_getTransactionReceipt":
+		return n.getTransactionReceipt(params)
+	case "eth_pendingTransactions":
+		return n.pendingTransactions(), nil
+	case "eth_getCode":
+		return n.getCode(params)
+	case "eth_call":
+		return n.ethCall(params)
+	case "eth_mining":
+		return true, nil
+	case "eth_gasPrice", "net_peerCount":
+		return hexUint64(0), nil
+	case "eth_accounts", "personal_listAccounts":
+		return []string{n.from.Hex()}, nil
+	case "admin_peers":
+		return []interface{}{}, nil
+	case "net_version":
+		return "1", nil
+	}
+	return nil, &Error{Code: ErrCodeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+}
+
+func (n *Node) subscribe(c *wsConn, params []json.RawMessage) (interface{}, error) {
+	if c == nil {
+		return nil, &Error{Code: ErrCodeMethodNotFound, Message: "notifications not supported"}
+	}
+	var topic string
+	if len(params) == 0 || json.Unmarshal(params[0], &topic) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "missing subscription topic"}
+	}
+	if topic != "newHeads" {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: fmt.Sprintf("unsupported subscription topic: %s", topic)}
+	}
+	n.subLock.Lock()
+	defer n.subLock.Unlock()
+	n.subSeq++
+	id := hexUint64(n.subSeq)
+	n.subs[id] = c
+	return id, nil
+}
+
+func (n *Node) unsubscribe(params []json.RawMessage) (interface{}, error) {
+	var id string
+	if len(params) == 0 || json.Unmarshal(params[0], &id) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "missing subscription id"}
+	}
+	n.subLock.Lock()
+	defer n.subLock.Unlock()
+	_, ok := n.subs[id]
+	delete(n.subs, id)
+	return ok, nil
+}
+
+// 向所有 newHeads 订阅者推送区块头
+func (n *Node) notifyNewHead(head interface{}) {
+	n.subLock.Lock()
+	subs := make(map[string]*wsConn, len(n.subs))
+	for id, c := range n.subs {
+		subs[id] = c
+	}
+	n.subLock.Unlock()
+	for id, c := range subs {
+		_ = c.writeJSON(&notification{
+			Jsonrpc: "2.0",
+			Method:  "eth_subscription",
+			Params: subscriptionResult{
+				Subscription: id,
+				Result:       head,
+			},
+		})
+	}
+}
+
+func hexUint64(v uint64) string {
+	return "0x" + strconv.FormatUint(v, 16)
+}
@@ -0,0 +1,146 @@
+package fakechain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	precompile "github.com/Venachain/Venachain/cmd/vcl/client/precompiled"
+	"github.com/Venachain/Venachain/common"
+	"github.com/Venachain/Venachain/common/hexutil"
+	"github.com/Venachain/Venachain/rlp"
+	"github.com/Venachain/Venachain/rpc"
+	"github.com/Venachain/Venachain/venaclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNode_BlockAndReceipt(t *testing.T) {
+	node, err := NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+
+	head, err := node.Mine(
+		TxSpec{To: "0x0000000000000000000000000000000000000001", Value: 1},
+		TxSpec{Data: []byte{0x60, 0x80}, Gas: 100000},
+	)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(1), node.BlockNumber())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cli, err := venaclient.DialContext(ctx, node.URL())
+	assert.True(t, err == nil)
+
+	block, err := cli.BlockByNumber(ctx, big.NewInt(1))
+	assert.True(t, err == nil)
+	assert.Equal(t, head.Hash(), block.Hash())
+	assert.Equal(t, 2, block.Transactions().Len())
+
+	latest, err := cli.BlockByNumber(ctx, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, head.Hash(), latest.Hash())
+
+	byHash, err := cli.HeaderByHash(ctx, head.Hash())
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(1), byHash.Number.Uint64())
+
+	receipt, err := cli.TransactionReceipt(ctx, block.Transactions()[1].Hash())
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(100000), receipt.GasUsed)
+	assert.True(t, receipt.ContractAddress != common.Address{})
+
+	_, err = cli.BlockByNumber(ctx, big.NewInt(5))
+	assert.True(t, err != nil)
+}
+
+func TestNode_PrecompileCall(t *testing.T) {
+	node, err := NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	node.SetCNS(CNSEntry{Name: "demo", Version: "0.0.0.1", Address: "0x1000000000000000000000000000000000000001", Enabled: true})
+	node.SetNodes(NodeEntry{Name: "0", Owner: "0x", ExternalIP: "127.0.0.1", RPCPort: 6791, P2PPort: 16791, Type: 1, Status: 1})
+
+	cli, err := rpc.Dial(node.URL())
+	assert.True(t, err == nil)
+
+	call := func(to string, items ...[]byte) string {
+		data, err := rlp.EncodeToBytes(items)
+		assert.True(t, err == nil)
+		var res hexutil.Bytes
+		err = cli.Call(&res, "eth_call", map[string]interface{}{"to": to, "data": hexutil.Bytes(data)}, "latest")
+		assert.True(t, err == nil)
+		return string(bytes.Trim(res[64:], "\x00"))
+	}
+	txType := []byte{0x09}
+
+	contracts := call(precompile.CnsManagementAddress, txType, []byte("getRegisteredContracts"), []byte("(0,0)"))
+	var cnsRes struct {
+		Data []CNSEntry `json:"data"`
+	}
+	assert.True(t, json.Unmarshal([]byte(contracts), &cnsRes) == nil)
+	assert.Equal(t, "demo", cnsRes.Data[0].Name)
+
+	addr := call(precompile.CnsManagementAddress, txType, []byte("getContractAddress"), []byte("demo"), []byte("0.0.0.1"))
+	assert.Equal(t, "0x1000000000000000000000000000000000000001", addr)
+
+	nodes := call(precompile.NodeManagementAddress, txType, []byte("getAllNodes"))
+	var nodeRes struct {
+		Data []NodeEntry `json:"data"`
+	}
+	assert.True(t, json.Unmarshal([]byte(nodes), &nodeRes) == nil)
+	assert.Equal(t, 6791, nodeRes.Data[0].RPCPort)
+}
+
+func TestNode_NewHeadsSubscription(t *testing.T) {
+	node, err := NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(node.WSURL(), nil)
+	assert.True(t, err == nil)
+	defer conn.Close()
+
+	sub := `{"jsonrpc":"2.0","method":"eth_subscribe","params":["newHeads"],"id":"subscription newHeads 1"}`
+	assert.True(t, conn.WriteMessage(websocket.TextMessage, []byte(sub)) == nil)
+	var reply struct {
+		ID     string `json:"id"`
+		Result string `json:"result"`
+	}
+	assert.True(t, conn.ReadJSON(&reply) == nil)
+	assert.Equal(t, "subscription newHeads 1", reply.ID)
+	assert.Equal(t, 1, node.Subscriptions())
+
+	head, err := node.Mine()
+	assert.True(t, err == nil)
+	var push struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string                 `json:"subscription"`
+			Result       map[string]interface{} `json:"result"`
+		} `json:"params"`
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.True(t, conn.ReadJSON(&push) == nil)
+	assert.Equal(t, "eth_subscription", push.Method)
+	assert.Equal(t, reply.Result, push.Params.Subscription)
+	assert.Equal(t, head.Hash().Hex(), push.Params.Result["hash"])
+}
+
+func TestNode_Handle(t *testing.T) {
+	node, err := NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	node.Handle("eth_mining", func(params []json.RawMessage) (interface{}, error) {
+		return nil, &Error{Code: -32000, Message: "mining disabled"}
+	})
+	cli, err := rpc.Dial(node.URL())
+	assert.True(t, err == nil)
+	var mining bool
+	err = cli.Call(&mining, "eth_mining")
+	assert.True(t, err != nil)
+	assert.Equal(t, 1, node.Calls("eth_mining"))
+}
@@ -0,0 +1,118 @@
+package fakechain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	precompile "github.com/Venachain/Venachain/cmd/vcl/client/precompiled"
+	"github.com/Venachain/Venachain/common"
+	"github.com/Venachain/Venachain/common/hexutil"
+	"github.com/Venachain/Venachain/rlp"
+)
+
+type callArgs struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Data hexutil.Bytes `json:"data"`
+}
+
+// 预编译合约返回结果的统一结构
+type precompileResult struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+func callKey(address string, funcName string) string {
+	return strings.ToLower(common.HexToAddress(address).Hex()) + "." + funcName
+}
+
+// eth_call 调用，根据 to 地址和 rlp 编码的函数名返回脚本化的结果
+func (n *Node) ethCall(params []json.RawMessage) (interface{}, error) {
+	var args callArgs
+	if len(params) == 0 || json.Unmarshal(params[0], &args) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "invalid call arguments"}
+	}
+	funcName, callParams := decodeCallData(args.Data)
+
+	if res, ok := n.calls[callKey(args.To, funcName)]; ok {
+		return encodeString(fmt.Sprintf("%v", res)), nil
+	}
+
+	switch strings.ToLower(common.HexToAddress(args.To).Hex()) {
+	case strings.ToLower(common.HexToAddress(precompile.CnsManagementAddress).Hex()):
+		return n.cnsCall(funcName, callParams)
+	case strings.ToLower(common.HexToAddress(precompile.NodeManagementAddress).Hex()):
+		return n.nodeCall(funcName)
+	}
+	return nil, &Error{Code: ErrCodeInternal, Message: fmt.Sprintf("no scripted result for %s on %s", funcName, args.To)}
+}
+
+func (n *Node) cnsCall(funcName string, params []string) (interface{}, error) {
+	switch funcName {
+	case "getRegisteredContracts":
+		data := make([]CNSEntry, len(n.cns))
+		copy(data, n.cns)
+		return encodeJSON(&precompileResult{Code: 0, Msg: "ok", Data: data})
+	case "getContractAddress":
+		if len(params) < 2 {
+			return nil, &Error{Code: ErrCodeInvalidParams, Message: "getContractAddress needs name and version"}
+		}
+		for _, entry := range n.cns {
+			if entry.Name == params[0] && entry.Version == params[1] {
+				return encodeString(entry.Address), nil
+			}
+		}
+		return encodeString(common.Address{}.Hex()), nil
+	}
+	return nil, &Error{Code: ErrCodeMethodNotFound, Message: fmt.Sprintf("unknown cns manager function: %s", funcName)}
+}
+
+func (n *Node) nodeCall(funcName string) (interface{}, error) {
+	switch funcName {
+	case "getAllNodes":
+		data := make([]NodeEntry, len(n.nodes))
+		copy(data, n.nodes)
+		return encodeJSON(&precompileResult{Code: 0, Msg: "success", Data: data})
+	}
+	return nil, &Error{Code: ErrCodeMethodNotFound, Message: fmt.Sprintf("unknown node manager function: %s", funcName)}
+}
+
+// 系统合约的调用数据为 rlp([txType, funcName, params...])
+func decodeCallData(data []byte) (string, []string) {
+	var items [][]byte
+	if err := rlp.DecodeBytes(data, &items); err != nil || len(items) < 2 {
+		return "", nil
+	}
+	params := make([]string, 0, len(items)-2)
+	for _, item := range items[2:] {
+		params = append(params, string(item))
+	}
+	return string(items[1]), params
+}
+
+func encodeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return encodeString(string(data)), nil
+}
+
+// 按 abi 的 string 类型编码返回值：偏移量、长度、按 32 字节补齐的数据
+func encodeString(s string) hexutil.Bytes {
+	size := (len(s) + 31) / 32 * 32
+	out := make([]byte, 64+size)
+	out[31] = 32
+	putUint(out[32:64], uint64(len(s)))
+	copy(out[64:], s)
+	return out
+}
+
+func putUint(word []byte, v uint64) {
+	for i := len(word) - 1; i >= 0 && v > 0; i-- {
+		word[i] = byte(v)
+		v >>= 8
+	}
+}
@@ -0,0 +1,86 @@
+package fakechain
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 常用的 JSON-RPC 错误码
+const (
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+)
+
+// Handler 自定义 JSON-RPC 方法处理器，返回值会被序列化为 result
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+// Error JSON-RPC 响应中的 error 对象
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// CNSEntry CNS 管理合约中的一条注册记录
+type CNSEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Address string `json:"address"`
+	Origin  string `json:"origin"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NodeEntry 节点管理合约中的一条节点记录
+type NodeEntry struct {
+	Name       string `json:"name"`
+	Owner      string `json:"owner"`
+	Desc       string `json:"desc"`
+	Type       int    `json:"type"`
+	Status     int    `json:"status"`
+	ExternalIP string `json:"externalIP"`
+	InternalIP string `json:"internalIP"`
+	PublicKey  string `json:"publicKey"`
+	RPCPort    int    `json:"rpcPort"`
+	P2PPort    int    `json:"p2pPort"`
+}
+
+// TxSpec 待打包交易的描述，To 为空时表示合约部署
+type TxSpec struct {
+	To       string
+	Value    int64
+	Gas      uint64
+	GasPrice int64
+	Data     []byte
+	// 交易执行失败时置为 true，收据 status 为 0
+	Failed bool
+}
+
+type request struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type notification struct {
+	Jsonrpc string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
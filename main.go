@@ -1,28 +1,108 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"graces/app"
 	"graces/config"
-	"graces/syncer"
-	"graces/web/router"
-	"graces/ws"
+	"graces/secret"
 
-	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	gin.SetMode(config.Config.HttpConf.Mode)
 	if err := config.MakeLogConfig(); err != nil {
 		log.Fatalf("%v", err)
 	}
-	gracesRouter := router.InitRouter()
-	ws.DefaultWSSubscriber.ChainWSTopicAutoSubDelayStart(config.Config.Syncer.Delay)
-	syncer.DefaultChainDataSyncManager.ChainDataIncrSyncDelayStart(config.Config.Syncer.Delay)
-	err := gracesRouter.Run(config.Config.HttpConf.Addr())
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "gen-key":
+			genKey()
+			return
+		case "rotate-key":
+			rotateKey(os.Args[2:])
+			return
+		}
+	}
+	graces, err := app.New()
 	if err != nil {
+		log.Fatalf("init graces failed: %v", err)
+	}
+	if err := graces.Start(context.Background()); err != nil {
 		logrus.Errorf("Graces start err: %v", err)
 		return
 	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- graces.Wait()
+	}()
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if conf := config.Config.ReloadConf; conf != nil {
+		go config.WatchConfigFile(watchCtx, conf.WatchInterval*time.Second)
+	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+loop:
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				logrus.Errorf("Graces run err: %v", err)
+			}
+			break loop
+		case <-hup:
+			logrus.Infof("Graces receive signal [SIGHUP], reloading config...")
+			config.ReloadAndLog()
+		case sig := <-quit:
+			logrus.Infof("Graces receive signal [%v], shutting down...", sig)
+			break loop
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Config.Shutdown.GracePeriod*time.Second)
+	defer cancel()
+	if err := graces.Stop(ctx); err != nil {
+		logrus.Errorf("Graces shutdown err: %v", err)
+	}
+}
+
+// 生成一个新的主密钥并输出到标准输出
+func genKey() {
+	key, err := secret.GenerateKey()
+	if err != nil {
+		log.Fatalf("generate master key failed: %v", err)
+	}
+	fmt.Println(key)
+}
+
+// 使用新的主密钥重新加密链配置中的敏感字段，旧的主密钥从配置中读取
+func rotateKey(args []string) {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	keyEnv := fs.String("new-key-env", "GRACES_NEW_MASTER_KEY", "environment variable holding the new master key")
+	keyFile := fs.String("new-key-file", "", "file holding the new master key")
+	_ = fs.Parse(args)
+
+	key, err := secret.LoadMasterKey(*keyEnv, *keyFile)
+	if err != nil {
+		log.Fatalf("load new master key failed: %v", err)
+	}
+	if key == nil {
+		log.Fatalf("new master key is not provided, set $%s or -new-key-file", *keyEnv)
+	}
+	cnt, err := app.RotateMasterKey(key)
+	if err != nil {
+		log.Fatalf("rotate master key failed after %d chains: %v", cnt, err)
+	}
+	logrus.Infof("%d chains re-encrypted, replace the configured master key with the new one before restarting", cnt)
 }
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"graces/model"
+	"graces/util"
+	"graces/web/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录响应内容的 ResponseWriter，用于审计时获取操作结果
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Audit 记录修改状态的操作：操作人、链、参数（敏感字段脱敏）、交易哈希和操作结果。
+// 放在 LoginAuth 之后、Permit 之前使用，没有权限的操作也会被记录。sources 与同一接口的 Permit 相同，
+// 请求体中有多个链ID字段时不记录链ID，记录通过 audits 写入
+func Audit(audits service.IAuditService, operation string, sources ...ChainSource) gin.HandlerFunc {
+	if len(sources) == 0 {
+		sources = []ChainSource{ChainParam(defaultChainParam)}
+	}
+	return func(ctx *gin.Context) {
+		params := auditParams(ctx)
+		chainID, _ := chainIDFromRequest(ctx, sources, requestJSON(ctx))
+		writer := &auditWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+
+		ctx.Next()
+
+		event := model.AuditEvent{
+			Source:    model.AuditSourceHTTP,
+			ClientIP:  ctx.ClientIP(),
+			ChainID:   chainID,
+			Operation: operation,
+			Params:    params,
+		}
+		if user, ok := GetUser(ctx); ok {
+			event.UserID = user.ID.Hex()
+			event.Username = user.Username
+		}
+		if apiKey, ok := GetAPIKey(ctx); ok {
+			event.APIKey = apiKey.Prefix
+		}
+		var result model.Result
+		if err := json.Unmarshal(writer.body.Bytes(), &result); err != nil {
+			event.ErrMsg = "unknown response"
+		} else {
+			event.Success = writer.Status() < http.StatusMultipleChoices && result.Code == http.StatusOK
+			event.TxHash = util.FindTxHash(result.Data)
+			if !event.Success {
+				event.ErrMsg = result.Msg
+			}
+		}
+		_ = audits.Record(event)
+	}
+}
+
+// 收集操作参数：路径参数、JSON 请求体或表单字段，上传的文件只记录文件名
+func auditParams(ctx *gin.Context) map[string]interface{} {
+	params := make(map[string]interface{})
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		if form, err := ctx.MultipartForm(); err == nil {
+			for k, v := range form.Value {
+				params[k] = strings.Join(v, ",")
+			}
+			for k, files := range form.File {
+				names := make([]string, 0, len(files))
+				for _, file := range files {
+					names = append(names, file.Filename)
+				}
+				params[k] = strings.Join(names, ",")
+			}
+		}
+	} else {
+		for k, v := range requestJSON(ctx) {
+			params[k] = v
+		}
+	}
+	// 路径参数优先，chainid 等参数通常在路径中
+	for _, p := range ctx.Params {
+		params[p.Key] = p.Value
+	}
+	return params
+}
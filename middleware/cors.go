@@ -2,26 +2,44 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"graces/config"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Cors 处理跨域资源共享问题
+// Cors 处理跨域资源共享问题，按 [cors] 配置校验请求来源，允许的来源原样写回 Access-Control-Allow-Origin
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		method := c.Request.Method
-
-		c.Header("Access-Control-Allow-Origin", config.Config.HttpConf.Cors)
-		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token")
-		c.Header("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
-		c.Header("Access-Control-Allow-Credentials", "true")
+		policy := config.CorsPolicy()
+		origin := c.GetHeader("Origin")
+		allowed := policy.AllowsOrigin(origin)
+		if allowed {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+			if policy.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if origin != "" {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 
-		//放行所有OPTIONS方法
-		if method == "OPTIONS" {
+		// 预检请求直接返回，不允许的来源返回 403
+		if c.Request.Method == http.MethodOptions {
+			if origin != "" && !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Header("Access-Control-Allow-Methods", strings.Join(policy.AllowMethods, ", "))
+			c.Header("Access-Control-Allow-Headers", strings.Join(policy.AllowHeaders, ", "))
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.FormatInt(int64(policy.MaxAge), 10))
+			}
 			c.AbortWithStatus(http.StatusNoContent)
+			return
 		}
 		// 处理请求
 		c.Next()
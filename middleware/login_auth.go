@@ -6,15 +6,72 @@ import (
 	"graces/exterr"
 	"graces/model"
 	"graces/secret"
+	"graces/web/service"
 	"graces/web/util/response"
 
 	"github.com/gin-gonic/gin"
 )
 
-// LoginAuth 登录用户认证
-func LoginAuth() gin.HandlerFunc {
+const (
+	// ClaimsKey 认证通过后 token 解析出的信息在 gin.Context 中的键
+	ClaimsKey = "claims"
+	// UserKey 认证通过后登录用户在 gin.Context 中的键
+	UserKey = "user"
+	// APIKeyKey 使用 API Key 认证时 key 信息在 gin.Context 中的键
+	APIKeyKey = "apikey"
+
+	// APIKeyHeader 携带 API Key 的请求头
+	APIKeyHeader = "X-API-Key"
+)
+
+// GetClaims 获取当前请求登录用户的 token 信息
+func GetClaims(ctx *gin.Context) (*secret.CustomClaims, bool) {
+	v, ok := ctx.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*secret.CustomClaims)
+	return claims, ok
+}
+
+// GetUser 获取当前请求的登录用户
+func GetUser(ctx *gin.Context) (*model.User, bool) {
+	v, ok := ctx.Get(UserKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := v.(*model.User)
+	return user, ok
+}
+
+// GetAPIKey 获取当前请求使用的 API Key，使用 JWT 认证时不存在
+func GetAPIKey(ctx *gin.Context) (*model.APIKey, bool) {
+	v, ok := ctx.Get(APIKeyKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := v.(*model.APIKey)
+	return key, ok
+}
+
+// LoginAuth 登录用户认证，支持 Authorization 请求头中的 JWT 和 X-API-Key 请求头中的 API Key
+func LoginAuth(users service.IUserService, apiKeys service.IAPIKeyService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		result := model.Result{}
+		if key := ctx.Request.Header.Get(APIKeyHeader); key != "" {
+			user, apiKey, err := apiKeys.CheckAPIKey(key)
+			if err != nil {
+				ctx.Abort()
+				result.Code = http.StatusUnauthorized
+				result.Msg = err.Error()
+				response.Fail(ctx, result)
+				return
+			}
+			ctx.Set(UserKey, user)
+			ctx.Set(APIKeyKey, apiKey)
+			ctx.Next()
+			return
+		}
 		// 首先在请求头获取 token
 		auth := ctx.Request.Header.Get("Authorization")
 		if len(auth) == 0 {
@@ -25,8 +82,16 @@ func LoginAuth() gin.HandlerFunc {
 			return
 		}
 		// 解析 token
-		j := secret.NewJWT()
-		claims, err := j.ParseToken(auth)
+		claims, err := secret.NewJWT().ParseToken(auth)
+		if err != nil {
+			ctx.Abort()
+			result.Code = http.StatusUnauthorized
+			result.Msg = err.Error()
+			response.Fail(ctx, result)
+			return
+		}
+		// 校验用户是否存在以及 token 是否已经被吊销
+		user, err := users.CheckToken(claims)
 		if err != nil {
 			ctx.Abort()
 			result.Code = http.StatusUnauthorized
@@ -34,11 +99,9 @@ func LoginAuth() gin.HandlerFunc {
 			response.Fail(ctx, result)
 			return
 		}
-		// 认证通过则刷新 JWT 过期时间
-		_, _ = j.RefreshToken(auth)
-
 		// 继续交由下一个路由处理，并将解析出的信息传递下去
-		ctx.Set("claims", claims)
+		ctx.Set(ClaimsKey, claims)
+		ctx.Set(UserKey, user)
 		ctx.Next()
 	}
 }
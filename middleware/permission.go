@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/util"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 默认从该路径参数中获取链ID
+const defaultChainParam = "chainid"
+
+// ChainSource 接口中链ID的来源，必须与 handler 实际使用的路径参数或请求体字段一致
+type ChainSource struct {
+	// 路径参数名
+	param string
+	// JSON 请求体的顶层字段名
+	field string
+}
+
+// ChainParam 链ID来自路径参数
+func ChainParam(name string) ChainSource {
+	return ChainSource{param: name}
+}
+
+// ChainField 链ID来自 JSON 请求体的顶层字段，name 为 handler 绑定的 json tag
+func ChainField(name string) ChainSource {
+	return ChainSource{field: name}
+}
+
+// Permit 校验登录用户是否拥有接口声明的权限，使用 API Key 时同时校验 key 的授权范围，必须在 LoginAuth 之后使用。
+// 链ID 按 sources 依次从路径参数或 JSON 请求体的字段中获取，默认只从路径参数 chainid 中获取，
+// 获取到链ID时会同时考虑用户在该链上被授予的角色；请求体中有多个链ID字段时拒绝请求
+func Permit(perm model.Permission, sources ...ChainSource) gin.HandlerFunc {
+	if !perm.IsValid() {
+		logrus.Panicf("unknown permission [%s]", perm)
+	}
+	if len(sources) == 0 {
+		sources = []ChainSource{ChainParam(defaultChainParam)}
+	}
+	return func(ctx *gin.Context) {
+		user, ok := GetUser(ctx)
+		if !ok {
+			ctx.Abort()
+			response.ErrorHandler(ctx, exterr.ErrUnauthorized)
+			return
+		}
+		chainID, ok := chainIDFromRequest(ctx, sources, requestJSON(ctx))
+		if !ok {
+			ctx.Abort()
+			response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, "ambiguous chain id"))
+			return
+		}
+		// 使用 API Key 时还要在 key 的授权范围之内
+		apiKey, isAPIKey := GetAPIKey(ctx)
+		if !user.HasPermission(perm, chainID) || (isAPIKey && !apiKey.Allows(perm, chainID)) {
+			logrus.Warningf("user [%s] has no permission [%s] on chain [%s]: %s %s",
+				user.Username, perm, chainID, ctx.Request.Method, ctx.Request.URL.Path)
+			ctx.Abort()
+			response.ErrorHandler(ctx, exterr.ErrUserHasNoPermission)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// 按 sources 从路径参数或 JSON 请求体 fields 中获取链ID，请求体中有多个链ID字段时 ok 为 false
+func chainIDFromRequest(ctx *gin.Context, sources []ChainSource, fields map[string]interface{}) (string, bool) {
+	if _, ok := util.ChainIDField(fields, ""); !ok {
+		return "", false
+	}
+	for _, source := range sources {
+		if source.param != "" {
+			if id := ctx.Param(source.param); id != "" {
+				return id, true
+			}
+			continue
+		}
+		if id, _ := util.ChainIDField(fields, source.field); id != "" {
+			return id, true
+		}
+	}
+	return "", true
+}
+
+// 读取 JSON 请求体并解析为 map，读取后会将请求体还原，不影响后续的数据绑定
+func requestJSON(ctx *gin.Context) map[string]interface{} {
+	if ctx.Request.Body == nil || !strings.Contains(ctx.ContentType(), "json") {
+		return nil
+	}
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return nil
+	}
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"graces/config"
+	"graces/exterr"
+	"graces/model"
+	"graces/secret"
+	"graces/web/service"
+	"graces/web/util/response"
+	"graces/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WSAuth websocket 连接认证，浏览器无法携带 Authorization 请求头，
+// JWT 通过 Sec-WebSocket-Protocol 请求头或 token 查询参数传递，认证信息会附加到 websocket 连接上
+func WSAuth(users service.IUserService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := ws.TokenFromRequest(ctx.Request)
+		if token == "" {
+			wsUnauthorized(ctx, exterr.ErrUnauthorized)
+			return
+		}
+		claims, err := secret.NewJWT().ParseToken(config.Config.JWTConf.Prefix + token)
+		if err != nil {
+			wsUnauthorized(ctx, err)
+			return
+		}
+		user, err := users.CheckToken(claims)
+		if err != nil {
+			wsUnauthorized(ctx, err)
+			return
+		}
+		ctx.Set(ClaimsKey, claims)
+		ctx.Set(UserKey, user)
+		ctx.Set(ws.AuthKey, &ws.Auth{Claims: claims, User: user})
+		ctx.Next()
+	}
+}
+
+// 握手失败时客户端拿不到响应体，只能通过 HTTP 状态码判断，因此直接返回 401
+func wsUnauthorized(ctx *gin.Context, err error) {
+	ctx.Abort()
+	response.Response(ctx, http.StatusUnauthorized, model.Result{Code: http.StatusUnauthorized, Msg: err.Error()})
+}
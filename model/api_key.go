@@ -0,0 +1,101 @@
+package model
+
+import (
+	"graces/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey 用户签发的 API Key，供 CI、监控脚本等程序调用接口使用，只保存 key 的摘要
+type APIKey struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
+	// 名称，用于区分用途
+	Name string `json:"name" bson:"name"`
+	// key 的前几位，用于识别是哪一个 key
+	Prefix string `json:"prefix" bson:"prefix"`
+	// key 的 SHA-256 摘要
+	KeyHash string `json:"-" bson:"key_hash"`
+	// 授权范围，与接口权限一致
+	Scopes []Permission `json:"scopes" bson:"scopes"`
+	// 限定只能访问的链，为空时不限制
+	ChainID string `json:"chain_id" bson:"chain_id"`
+	// 是否已经被吊销
+	Revoked      bool  `json:"revoked" bson:"revoked"`
+	ExpiresAt    int64 `json:"expires_at" bson:"expires_at"`
+	LastUsedTime int64 `json:"last_used_time" bson:"last_used_time"`
+	CreateTime   int64 `json:"create_time" bson:"create_time"`
+}
+
+// Allows API Key 的授权范围是否包含指定链上的指定权限，限定了链的 key 不能访问其他链和不区分链的接口
+func (key *APIKey) Allows(perm Permission, chainID string) bool {
+	if key.ChainID != "" && key.ChainID != chainID {
+		return false
+	}
+	for _, scope := range key.Scopes {
+		if scope.Includes(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyDTO 创建 API Key DTO
+type APIKeyDTO struct {
+	// 名称
+	Name string `json:"name" binding:"required,min=1,max=50"`
+	// 授权范围：chain:read、chain:operate、chain:admin、system:admin，高级别的权限包含低级别的权限
+	Scopes []Permission `json:"scopes" binding:"required,min=1,max=4"`
+	// 限定只能访问的链ID，为空时不限制
+	ChainID string `json:"chain_id" binding:"min=0,max=50"`
+	// 有效天数
+	ExpireDays int64 `json:"expire_days" binding:"required,min=1,max=3650"`
+}
+
+// APIKeyRevokeDTO 吊销 API Key DTO
+type APIKeyRevokeDTO struct {
+	// API Key ID
+	ID string `json:"id" binding:"required,min=1,max=50"`
+}
+
+type APIKeyVO struct {
+	// 主键ID
+	ID string `json:"id"`
+	// 名称
+	Name string `json:"name"`
+	// key 的前几位
+	Prefix string `json:"prefix"`
+	// 授权范围
+	Scopes []Permission `json:"scopes"`
+	// 限定只能访问的链ID
+	ChainID string `json:"chain_id"`
+	// 是否已经被吊销
+	Revoked bool `json:"revoked"`
+	// 过期时间
+	ExpiresAt string `json:"expires_at"`
+	// 最后一次使用时间
+	LastUsedTime string `json:"last_used_time"`
+	// 创建时间
+	CreateTime string `json:"create_time"`
+}
+
+// APIKeyCreatedVO 新创建的 API Key，完整的 key 只在创建时返回一次
+type APIKeyCreatedVO struct {
+	APIKeyVO
+	// 完整的 key，请求时放在 X-API-Key 请求头中
+	Key string `json:"key"`
+}
+
+func (key *APIKey) ToVO() *APIKeyVO {
+	return &APIKeyVO{
+		ID:           key.ID.Hex(),
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Scopes:       key.Scopes,
+		ChainID:      key.ChainID,
+		Revoked:      key.Revoked,
+		ExpiresAt:    util.Timestamp2TimeStr(key.ExpiresAt),
+		LastUsedTime: util.Timestamp2TimeStr(key.LastUsedTime),
+		CreateTime:   util.Timestamp2TimeStr(key.CreateTime),
+	}
+}
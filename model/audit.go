@@ -0,0 +1,108 @@
+package model
+
+import (
+	"graces/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// AuditSourceHTTP 通过 HTTP 接口发起的操作
+	AuditSourceHTTP = "http"
+	// AuditSourceWebsocket 通过 websocket 命令发起的操作
+	AuditSourceWebsocket = "websocket"
+
+	// AuditStatusSuccess 查询成功的操作
+	AuditStatusSuccess = "success"
+	// AuditStatusFailure 查询失败的操作
+	AuditStatusFailure = "failure"
+)
+
+// AuditEvent 审计事件，记录修改状态的操作
+type AuditEvent struct {
+	ID primitive.ObjectID `json:"id" bson:"_id"`
+	// 操作人
+	UserID   string `json:"user_id" bson:"user_id"`
+	Username string `json:"username" bson:"username"`
+	// 使用 API Key 操作时 key 的前几位
+	APIKey string `json:"api_key" bson:"api_key"`
+	// 来源：http、websocket
+	Source   string `json:"source" bson:"source"`
+	ClientIP string `json:"client_ip" bson:"client_ip"`
+	// 操作的链
+	ChainID string `json:"chain_id" bson:"chain_id"`
+	// 操作名称，如 chain.setsystemconfig
+	Operation string `json:"operation" bson:"operation"`
+	// 操作参数，敏感字段已经脱敏
+	Params map[string]interface{} `json:"params" bson:"params"`
+	// 操作产生的交易哈希
+	TxHash string `json:"tx_hash" bson:"tx_hash"`
+	// 操作是否成功
+	Success bool `json:"success" bson:"success"`
+	// 失败原因
+	ErrMsg     string `json:"err_msg" bson:"err_msg"`
+	CreateTime int64  `json:"create_time" bson:"create_time"`
+}
+
+type AuditQueryCondition struct {
+	PageDTO
+	// 操作人用户名
+	Username string `json:"username" binding:"min=0,max=50"`
+	// 链ID
+	ChainID string `json:"chain_id" binding:"min=0,max=50"`
+	// 操作名称
+	Operation string `json:"operation" binding:"min=0,max=50"`
+	// 操作结果：success、failure，为空时不限
+	Status string `json:"status" binding:"omitempty,oneof=success failure"`
+	// 开始时间，时间戳，单位：秒
+	StartTime int64 `json:"start_time" binding:"min=0"`
+	// 结束时间，时间戳，单位：秒
+	EndTime int64 `json:"end_time" binding:"min=0"`
+}
+
+type AuditEventVO struct {
+	// 主键ID
+	ID string `json:"id"`
+	// 操作人ID
+	UserID string `json:"user_id"`
+	// 操作人用户名
+	Username string `json:"username"`
+	// 使用 API Key 操作时 key 的前几位
+	APIKey string `json:"api_key"`
+	// 来源：http、websocket
+	Source string `json:"source"`
+	// 客户端IP
+	ClientIP string `json:"client_ip"`
+	// 链ID
+	ChainID string `json:"chain_id"`
+	// 操作名称
+	Operation string `json:"operation"`
+	// 操作参数，敏感字段已经脱敏
+	Params map[string]interface{} `json:"params"`
+	// 交易哈希
+	TxHash string `json:"tx_hash"`
+	// 操作是否成功
+	Success bool `json:"success"`
+	// 失败原因
+	ErrMsg string `json:"err_msg"`
+	// 操作时间
+	CreateTime string `json:"create_time"`
+}
+
+func (event *AuditEvent) ToVO() *AuditEventVO {
+	return &AuditEventVO{
+		ID:         event.ID.Hex(),
+		UserID:     event.UserID,
+		Username:   event.Username,
+		APIKey:     event.APIKey,
+		Source:     event.Source,
+		ClientIP:   event.ClientIP,
+		ChainID:    event.ChainID,
+		Operation:  event.Operation,
+		Params:     event.Params,
+		TxHash:     event.TxHash,
+		Success:    event.Success,
+		ErrMsg:     event.ErrMsg,
+		CreateTime: util.Timestamp2TimeStr(event.CreateTime),
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// MaskedPassphrase 返回给前端的链账户密码掩码，更新链信息时传入掩码表示不修改密码
+const MaskedPassphrase = "******"
+
 type Chain struct {
 	ID          primitive.ObjectID     `json:"id" bson:"_id"`
 	Name        string                 `json:"name" bson:"name"`
@@ -108,7 +111,7 @@ func (chain *Chain) ToVO() (*ChainVO, error) {
 	}
 	if vo.ChainConfig != nil {
 		if _, ok := vo.ChainConfig["node"].(map[string]interface{}); ok {
-			vo.ChainConfig["node"].(map[string]interface{})["passphrase"] = "******"
+			vo.ChainConfig["node"].(map[string]interface{})["passphrase"] = MaskedPassphrase
 		}
 	}
 	vo.ID = chain.ID.Hex()
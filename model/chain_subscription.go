@@ -0,0 +1,85 @@
+package model
+
+import (
+	"graces/util"
+)
+
+// 链变更事件类型
+const (
+	ChainEventInsert = "insert"
+	ChainEventUpdate = "update"
+	ChainEventDelete = "delete"
+)
+
+// 链的 websocket 订阅状态
+const (
+	ChainSubStatusSubscribed   = "subscribed"
+	ChainSubStatusReconnecting = "reconnecting"
+	ChainSubStatusFailed       = "failed"
+	ChainSubStatusUnsubscribed = "unsubscribed"
+	// 多实例部署时由其他实例订阅，当前实例成为链的 leader 后订阅
+	ChainSubStatusStandby = "standby"
+)
+
+// ChainEvent 链新增、修改、删除事件
+type ChainEvent struct {
+	// 事件类型：新增（insert）、修改（update）、删除（delete）
+	Type string
+	// 事件发生后的链信息，删除事件为被删除的链
+	Chain *Chain
+	// 修改前的链信息，只有修改事件才有
+	Old *Chain
+}
+
+type ChainSubscriptionInfo struct {
+	// 链ID
+	ChainID string `json:"chain_id"`
+	// 链名称
+	Name string `json:"name"`
+	// 订阅状态：已订阅（subscribed）、重连中（reconnecting）、订阅失败（failed）、已取消订阅（unsubscribed）
+	Status string `json:"status"`
+	// 订阅的 topics
+	Topics []string `json:"topics"`
+	// 当前是否有存活的订阅连接
+	Connected bool `json:"connected"`
+	// 最近一次断线后的重连次数
+	RetryCnt int64 `json:"retry_cnt"`
+	// 错误信息
+	ErrMsg string `json:"err_msg"`
+	// 状态更新时间
+	UpdateTime int64 `json:"update_time"`
+}
+
+type ChainSubscriptionVO struct {
+	// 链ID
+	ChainID string `json:"chain_id"`
+	// 链名称
+	Name string `json:"name"`
+	// 订阅状态：已订阅（subscribed）、重连中（reconnecting）、订阅失败（failed）、已取消订阅（unsubscribed）
+	Status string `json:"status"`
+	// 订阅的 topics
+	Topics []string `json:"topics"`
+	// 当前是否有存活的订阅连接
+	Connected bool `json:"connected"`
+	// 最近一次断线后的重连次数
+	RetryCnt int64 `json:"retry_cnt"`
+	// 错误信息
+	ErrMsg string `json:"err_msg"`
+	// 状态更新时间
+	UpdateTime string `json:"update_time"`
+}
+
+func (info *ChainSubscriptionInfo) ToVO() *ChainSubscriptionVO {
+	topics := make([]string, len(info.Topics))
+	copy(topics, info.Topics)
+	return &ChainSubscriptionVO{
+		ChainID:    info.ChainID,
+		Name:       info.Name,
+		Status:     info.Status,
+		Topics:     topics,
+		Connected:  info.Connected,
+		RetryCnt:   info.RetryCnt,
+		ErrMsg:     info.ErrMsg,
+		UpdateTime: util.Timestamp2TimeStr(info.UpdateTime),
+	}
+}
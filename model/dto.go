@@ -12,19 +12,6 @@ type Result struct {
 	Request string `json:"request"`
 }
 
-type JsonRpcStruct struct {
-	Jsonrpc string   `json:"jsonrpc" bson:"jsonrpc"`
-	Method  string   `json:"method" bson:"method"`
-	Params  []string `json:"params" bson:"params"`
-	Id      int      `json:"id" bson:"id"`
-}
-
-type Response struct {
-	Jsonrpc string      `json:"jsonrpc" bson:"jsonrpc"`
-	Id      int         `json:"id" bson:"id"`
-	Result  interface{} `json:"Result" bson:"id"`
-}
-
 type NodeSyncReq struct {
 	// 节点 IP 地址
 	Ip string `json:"ip" bson:"ip"`
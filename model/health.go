@@ -0,0 +1,49 @@
+package model
+
+// 检查项状态
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+type ReadinessVO struct {
+	// 是否就绪，数据库、所有链的 rpc 和 websocket 订阅都正常时为 true
+	Ready bool `json:"ready"`
+	// 数据库状态
+	DB HealthCheckVO `json:"db"`
+	// 每条链的状态
+	Chains []*ChainStatusVO `json:"chains"`
+}
+
+type HealthCheckVO struct {
+	// 检查结果，ok 或 fail
+	Status string `json:"status"`
+	// 检查失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+type ChainStatusVO struct {
+	// 链ID
+	ChainID string `json:"chain_id"`
+	// 链名称
+	Name string `json:"name"`
+	// 链第一个节点的 rpc 状态
+	RPC HealthCheckVO `json:"rpc"`
+	// 链第一个节点的最新区块高度
+	BlockNumber uint64 `json:"block_number"`
+	// websocket 订阅状态
+	WS HealthCheckVO `json:"ws"`
+}
+
+// NewHealthCheckVO 根据检查的错误构建检查结果
+func NewHealthCheckVO(err error) HealthCheckVO {
+	if err != nil {
+		return HealthCheckVO{Status: HealthStatusFail, Error: err.Error()}
+	}
+	return HealthCheckVO{Status: HealthStatusOK}
+}
+
+// OK 检查是否通过
+func (c HealthCheckVO) OK() bool {
+	return c.Status == HealthStatusOK
+}
@@ -0,0 +1,110 @@
+package model
+
+// Role 用户角色，权限从低到高依次为：viewer < operator < chain-admin < super-admin
+type Role string
+
+const (
+	// RoleViewer 只读用户，只能查看浏览器数据
+	RoleViewer Role = "viewer"
+	// RoleOperator 运维人员，可以触发数据同步、锁定账户等日常操作
+	RoleOperator Role = "operator"
+	// RoleChainAdmin 链管理员，可以修改链上系统配置、解锁账户、管理防火墙和 CNS、部署合约
+	RoleChainAdmin Role = "chain-admin"
+	// RoleSuperAdmin 超级管理员，拥有所有权限，可以添加链和管理用户
+	RoleSuperAdmin Role = "super-admin"
+)
+
+// Permission 接口权限，在路由中为每个接口声明
+type Permission string
+
+const (
+	// PermChainRead 查看链数据
+	PermChainRead Permission = "chain:read"
+	// PermChainOperate 链的日常运维操作
+	PermChainOperate Permission = "chain:operate"
+	// PermChainAdmin 修改链上状态的管理操作
+	PermChainAdmin Permission = "chain:admin"
+	// PermSystemAdmin 系统管理操作，如添加链、管理用户
+	PermSystemAdmin Permission = "system:admin"
+)
+
+var (
+	roleLevels = map[Role]int{
+		RoleViewer:     1,
+		RoleOperator:   2,
+		RoleChainAdmin: 3,
+		RoleSuperAdmin: 4,
+	}
+	// 每种权限所需的最低角色
+	permissionRoles = map[Permission]Role{
+		PermChainRead:    RoleViewer,
+		PermChainOperate: RoleOperator,
+		PermChainAdmin:   RoleChainAdmin,
+		PermSystemAdmin:  RoleSuperAdmin,
+	}
+)
+
+// IsValid 是否为已定义的角色
+func (r Role) IsValid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Level 角色等级，未定义的角色为 0
+func (r Role) Level() int {
+	return roleLevels[r]
+}
+
+// IsValid 是否为已定义的权限
+func (p Permission) IsValid() bool {
+	_, ok := permissionRoles[p]
+	return ok
+}
+
+// Includes 权限是否包含另一个权限，所需角色更高的权限包含所需角色更低的权限，
+// 例如 chain:admin 包含 chain:operate 和 chain:read
+func (p Permission) Includes(other Permission) bool {
+	required, ok := permissionRoles[p]
+	if !ok {
+		return false
+	}
+	return required.Allows(other)
+}
+
+// Allows 角色是否拥有指定权限
+func (r Role) Allows(perm Permission) bool {
+	required, ok := permissionRoles[perm]
+	if !ok {
+		return false
+	}
+	return r.Level() >= required.Level()
+}
+
+// ChainGrant 用户在某条链上被授予的角色
+type ChainGrant struct {
+	ChainID string `json:"chain_id" bson:"chain_id"`
+	Role    Role   `json:"role" bson:"role"`
+}
+
+// RoleForChain 用户在指定链上的有效角色，取全局角色和该链授权角色中较高的一个；
+// chainID 为空时只使用全局角色
+func (user *User) RoleForChain(chainID string) Role {
+	role := user.Role
+	if chainID == "" {
+		return role
+	}
+	for _, grant := range user.Grants {
+		if grant.ChainID == chainID && grant.Role.Level() > role.Level() {
+			role = grant.Role
+		}
+	}
+	return role
+}
+
+// HasPermission 用户是否拥有指定链上的指定权限，系统管理权限只看全局角色
+func (user *User) HasPermission(perm Permission, chainID string) bool {
+	if perm == PermSystemAdmin {
+		return user.Role.Allows(perm)
+	}
+	return user.RoleForChain(chainID).Allows(perm)
+}
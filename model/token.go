@@ -0,0 +1,34 @@
+package model
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// RefreshToken 服务端保存的刷新 token，只保存摘要。
+// 每次刷新都会作废旧的刷新 token 并换发新的，同一次登录换发的刷新 token 属于同一个会话
+type RefreshToken struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
+	// 登录会话ID
+	SessionID string `json:"session_id" bson:"session_id"`
+	// 刷新 token 的 SHA-256 摘要
+	TokenHash string `json:"-" bson:"token_hash"`
+	// 是否已经使用或被吊销
+	Revoked    bool  `json:"revoked" bson:"revoked"`
+	ExpiresAt  int64 `json:"expires_at" bson:"expires_at"`
+	CreateTime int64 `json:"create_time" bson:"create_time"`
+}
+
+// RevokedToken 被吊销的访问 token，过期之后即可清理
+type RevokedToken struct {
+	ID primitive.ObjectID `json:"id" bson:"_id"`
+	// 访问 token 的 jti
+	TokenID    string             `json:"token_id" bson:"token_id"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	ExpiresAt  int64              `json:"expires_at" bson:"expires_at"`
+	CreateTime int64              `json:"create_time" bson:"create_time"`
+}
+
+// RefreshDTO 刷新 token DTO
+type RefreshDTO struct {
+	// 登录或上一次刷新时返回的刷新 token
+	RefreshToken string `json:"refresh_token" binding:"required,min=1,max=128"`
+}
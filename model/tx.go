@@ -1,12 +1,6 @@
 package model
 
 import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
-	"time"
-
 	"graces/exterr"
 	"graces/util"
 
@@ -147,6 +141,26 @@ type ReceiptVO struct {
 	GasUsed uint64 `json:"gas_used"`
 }
 
+// TXLogVO 交易收据中的合约日志
+type TXLogVO struct {
+	// 所属链ID
+	ChainID string `json:"chain_id"`
+	// 所属交易哈希
+	TxHash string `json:"tx_hash"`
+	// 所属区块高度
+	Height uint64 `json:"height"`
+	// 产生日志的合约地址
+	Address string `json:"address"`
+	// 日志的 topics
+	Topics []string `json:"topics"`
+	// 日志数据
+	Data string `json:"data"`
+	// 日志在区块中的序号
+	LogIndex string `json:"log_index"`
+	// 所属交易调用的合约方法
+	Method string `json:"method"`
+}
+
 func (tx *TX) ToVO() (*TXVO, error) {
 	var vo TXVO
 	if err := util.SimpleCopyProperties(&vo, tx); err != nil {
@@ -179,40 +193,6 @@ func (tx *TX) ToContract() *Contract {
 	return contract
 }
 
-func GetRpcResult(endpoint string, method string, params []string) (interface{}, error) {
-	client := &http.Client{}
-	client.Timeout = 2 * time.Second
-	request := NewJsonRpcStruct(method, params)
-	jsonstr, _ := json.Marshal(request)
-
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonstr))
-	if err != nil {
-		logrus.Errorln("request error")
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	response, err := client.Do(req)
-	if err != nil {
-		logrus.Warnf("node is disable %s", endpoint)
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	result, _ := ioutil.ReadAll(response.Body)
-	res := new(Response)
-	json.Unmarshal(result, res)
-	return res.Result, nil
-}
-
-func NewJsonRpcStruct(method string, params []string) *JsonRpcStruct {
-	return &JsonRpcStruct{
-		Jsonrpc: "2.0",
-		Method:  method,
-		Params:  params,
-		Id:      1,
-	}
-}
-
 func (receipt *Receipt) ToVO() (*ReceiptVO, error) {
 	var vo ReceiptVO
 	if err := util.SimpleCopyProperties(&vo, receipt); err != nil {
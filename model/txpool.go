@@ -0,0 +1,105 @@
+package model
+
+import (
+	"graces/exterr"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 交易池事件的类型
+const (
+	// TxPoolActionAdd 交易进入交易池
+	TxPoolActionAdd = "add"
+	// TxPoolActionRemove 交易离开交易池，可能已被打包，也可能被节点丢弃
+	TxPoolActionRemove = "remove"
+)
+
+// PendingTX 交易池中等待打包的交易
+type PendingTX struct {
+	// 所属链ID
+	ChainID string `json:"chain_id"`
+	// 交易哈希
+	Hash string `json:"hash"`
+	// 交易发起人地址
+	From string `json:"from"`
+	// 交易目标地址，部署合约时为空
+	To string `json:"to"`
+	// Gas 限制
+	GasLimit uint64 `json:"gas_limit"`
+	// Gas 价格
+	GasPrice uint64 `json:"gas_price"`
+	// 随机数
+	Nonce string `json:"nonce"`
+	// input 数据，与已打包的交易一样不带 0x 前缀
+	Input string `json:"input"`
+	// 交易数额
+	Value uint64 `json:"value"`
+	// 首次在交易池中发现的时间，单位：秒
+	FirstSeen int64 `json:"first_seen"`
+}
+
+// TxPoolEvent 交易池中的交易增加或移除的事件
+type TxPoolEvent struct {
+	// 事件类型：add、remove
+	Action string `json:"action"`
+	// 增加或移除的交易
+	TX *PendingTX `json:"tx"`
+}
+
+// PendingTXVO 交易池中的交易及其解析后的详细信息
+type PendingTXVO struct {
+	// 交易哈希
+	Hash string `json:"hash"`
+	// 交易发起人地址
+	From string `json:"from"`
+	// 交易目标地址
+	To string `json:"to"`
+	// Gas 限制
+	GasLimit uint64 `json:"gas_limit"`
+	// Gas 价格
+	GasPrice uint64 `json:"gas_price"`
+	// 随机数
+	Nonce string `json:"nonce"`
+	// input 数据
+	Input string `json:"input"`
+	// 交易数额
+	Value uint64 `json:"value"`
+	// 首次在交易池中发现的时间
+	FirstSeen string `json:"first_seen"`
+	// 交易执行的操作： 合约部署、合约调用或者转账
+	Action string `json:"action"`
+	// 交易的详细信息
+	Detail *TxDetail `json:"detail"`
+}
+
+// TxPoolVO 链的交易池
+type TxPoolVO struct {
+	// 所属链ID
+	ChainID string `json:"chain_id"`
+	// 交易池中的交易数
+	Count int `json:"count"`
+	// 最近一次从节点拉取交易池的时间，从未拉取成功时为空
+	UpdateTime string `json:"update_time"`
+	// 交易池中的交易，按首次发现的时间排序
+	TXs []*PendingTXVO `json:"txs"`
+}
+
+// ToTX 转换为未打包的交易，用于复用已打包交易的解析逻辑
+func (tx *PendingTX) ToTX() (*TX, error) {
+	chainID, err := primitive.ObjectIDFromHex(tx.ChainID)
+	if err != nil {
+		return nil, exterr.ErrObjectIDInvalid
+	}
+	return &TX{
+		ChainID:  chainID,
+		Hash:     tx.Hash,
+		From:     tx.From,
+		To:       tx.To,
+		GasLimit: tx.GasLimit,
+		GasPrice: tx.GasPrice,
+		Nonce:    tx.Nonce,
+		Input:    tx.Input,
+		Value:    tx.Value,
+		Receipt:  &Receipt{},
+	}, nil
+}
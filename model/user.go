@@ -0,0 +1,119 @@
+package model
+
+import (
+	"graces/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type User struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Username string             `json:"username" bson:"username"`
+	// 使用 bcrypt 加密后的密码
+	Password string `json:"-" bson:"password"`
+	// 全局角色
+	Role Role `json:"role" bson:"role"`
+	// 按链授予的角色
+	Grants []ChainGrant `json:"grants" bson:"grants"`
+	// 令牌版本号，登出时自增，使之前签发的 token 全部失效
+	TokenVersion int64 `json:"token_version" bson:"token_version"`
+	CreateTime   int64 `json:"create_time" bson:"create_time"`
+	UpdateTime   int64 `json:"update_time" bson:"update_time"`
+}
+
+// LoginDTO 用户登录DTO
+type LoginDTO struct {
+	// 用户名
+	Username string `json:"username" binding:"required,min=1,max=50"`
+	// 密码
+	Password string `json:"password" binding:"required,min=1,max=72"`
+}
+
+// PasswordDTO 修改密码DTO
+type PasswordDTO struct {
+	// 旧密码
+	OldPassword string `json:"old_password" binding:"required,min=1,max=72"`
+	// 新密码
+	NewPassword string `json:"new_password" binding:"required,min=6,max=72"`
+}
+
+// UserDTO 创建用户DTO
+type UserDTO struct {
+	// 用户名
+	Username string `json:"username" binding:"required,min=1,max=50"`
+	// 密码
+	Password string `json:"password" binding:"required,min=6,max=72"`
+	// 全局角色：viewer、operator、chain-admin、super-admin
+	Role Role `json:"role" binding:"required"`
+}
+
+// UserRoleDTO 设置用户全局角色DTO
+type UserRoleDTO struct {
+	// 用户ID
+	UserID string `json:"user_id" binding:"required,min=1,max=50"`
+	// 全局角色：viewer、operator、chain-admin、super-admin
+	Role Role `json:"role" binding:"required"`
+}
+
+// UserGrantDTO 设置用户在某条链上的角色DTO
+type UserGrantDTO struct {
+	// 用户ID
+	UserID string `json:"user_id" binding:"required,min=1,max=50"`
+	// 链ID
+	ChainID string `json:"chain_id" binding:"required,min=1,max=50"`
+	// 授予的角色，为空时撤销该链上的授权
+	Role Role `json:"role"`
+}
+
+// UserLogoutDTO 强制用户退出所有会话DTO
+type UserLogoutDTO struct {
+	// 用户ID
+	UserID string `json:"user_id" binding:"required,min=1,max=50"`
+}
+
+type UserQueryCondition struct {
+	PageDTO
+	// 用户名
+	Username string `json:"username" binding:"min=0,max=50"`
+	// 全局角色
+	Role Role `json:"role"`
+}
+
+type UserVO struct {
+	// 主键ID
+	ID string `json:"id"`
+	// 用户名
+	Username string `json:"username"`
+	// 全局角色
+	Role Role `json:"role"`
+	// 按链授予的角色
+	Grants []ChainGrant `json:"grants"`
+	// 创建时间
+	CreateTime string `json:"create_time"`
+	// 最后一次更新时间
+	UpdateTime string `json:"update_time"`
+}
+
+type TokenVO struct {
+	// 带前缀的访问 token，请求时放在 Authorization 请求头中
+	Token string `json:"token"`
+	// 访问 token 过期时间，时间戳，单位：秒
+	ExpiresAt int64 `json:"expires_at"`
+	// 刷新 token，用于换取新的访问 token，只能使用一次
+	RefreshToken string `json:"refresh_token"`
+	// 刷新 token 过期时间，时间戳，单位：秒
+	RefreshExpiresAt int64 `json:"refresh_expires_at"`
+	// 登录用户信息
+	User *UserVO `json:"user,omitempty"`
+}
+
+func (user *User) ToVO() *UserVO {
+	return &UserVO{
+		ID:         user.ID.Hex(),
+		Username:   user.Username,
+		Role:       user.Role,
+		Grants:     user.Grants,
+		CreateTime: util.Timestamp2TimeStr(user.CreateTime),
+		UpdateTime: util.Timestamp2TimeStr(user.UpdateTime),
+	}
+}
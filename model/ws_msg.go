@@ -78,10 +78,68 @@ type WSSubMsgDTO struct {
 	ID string `json:"id" binding:"min=0,max=50"`
 	// 消息类型
 	Type string `json:"type" binding:"min=0,max=50"`
+	// 事件游标，同一条链上按事件的先后递增，重连时通过 last_cursor 回放错过的事件
+	Cursor string `json:"cursor,omitempty"`
 	// 消息内容
 	Content interface{} `json:"content"`
 }
 
+// WSEnvelope 前端 ws 连接的结构化消息，请求、应答和推送使用相同的格式，详见 docs 中的 websocket 消息协议
+type WSEnvelope struct {
+	// 协议版本，当前为 1
+	V int `json:"v"`
+	// 消息类型：请求为命令名，应答为 ack、output、result，推送为事件类型
+	Type string `json:"type"`
+	// 消息ID，请求的ID由前端生成，同一连接内不能重复
+	ID string `json:"id,omitempty"`
+	// 应答所对应的请求ID
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// 推送事件的游标
+	Cursor string `json:"cursor,omitempty"`
+	// 消息内容
+	Payload interface{} `json:"payload,omitempty"`
+	// 命令执行失败的原因，只出现在 result 中
+	Error *exterr.ExtError `json:"error,omitempty"`
+}
+
+// WSTopicSubscribeDTO 前端 ws 连接订阅 topic 的请求参数
+type WSTopicSubscribeDTO struct {
+	// 订阅的 topic：blocks、txs、logs、stats、nodes、sync
+	Topic string `json:"topic"`
+	// 过滤条件，只对交易和日志生效
+	Filter WSTopicFilter `json:"filter"`
+	// 最后收到的事件游标，不为空时先回放该游标之后的区块、交易和日志
+	LastCursor string `json:"last_cursor"`
+}
+
+// WSTopicFilter 订阅的过滤条件，为空的条件不参与过滤
+type WSTopicFilter struct {
+	// 交易的发起方或接收方地址，日志所属的合约地址
+	Address string `json:"address"`
+	// 交易调用或部署的合约地址，日志所属的合约地址
+	Contract string `json:"contract"`
+	// 交易调用的合约方法，日志所属交易调用的合约方法
+	Method string `json:"method"`
+	// 交易的最小数额，只对交易生效
+	MinValue uint64 `json:"min_value"`
+}
+
+// WSTopicUnsubscribeDTO 前端 ws 连接取消订阅的请求参数
+type WSTopicUnsubscribeDTO struct {
+	// 订阅成功时返回的订阅ID
+	Subscription string `json:"subscription"`
+}
+
+// WSTopicSubscriptionVO 前端 ws 连接订阅成功后返回的订阅信息
+type WSTopicSubscriptionVO struct {
+	// 订阅ID，取消订阅时使用
+	Subscription string `json:"subscription"`
+	// 订阅的 topic
+	Topic string `json:"topic"`
+	// 过滤条件
+	Filter WSTopicFilter `json:"filter"`
+}
+
 type WSManagerVO struct {
 	// 当前 websocket 客户端分组数量
 	GroupLen int64 `json:"group_len"`
@@ -97,6 +155,10 @@ type WSManagerVO struct {
 	ChanGroupMessageLen int64 `json:"chan_group_message_len"`
 	// websocket 向所有客户端广播消息时，消息缓冲队列的长度
 	ChanBroadCastMessageLen int64 `json:"chan_broad_cast_message_len"`
+	// 因客户端缓冲队列已满被丢弃的消息总数
+	DroppedCnt int64 `json:"dropped_cnt"`
+	// 因客户端消费过慢被断开的连接总数
+	DisconnectCnt int64 `json:"disconnect_cnt"`
 }
 
 type WSGroupVO struct {
@@ -111,6 +173,8 @@ type WSClientVO struct {
 	ID string `json:"id"`
 	// websocket 该客户端所在的组
 	Group string `json:"group"`
+	// 建立连接的用户，拨号连接为空
+	Username string `json:"username"`
 	// websocket 连接本地地址
 	LocalAddr string `json:"local_addr"`
 	// websocket 连接远程地址
@@ -123,6 +187,14 @@ type WSClientVO struct {
 	IsDial bool `json:"is_dial"`
 	// 连接断线后已重试连接的次数
 	RetryCnt int64 `json:"retry_cnt"`
+	// 缓冲队列已满时的处理策略：drop_oldest、disconnect
+	DropPolicy string `json:"drop_policy"`
+	// 因缓冲队列已满被丢弃的消息数
+	DroppedCnt uint64 `json:"dropped_cnt"`
+	// 连接关闭的原因，如 pong timeout、idle timeout、slow consumer，连接未关闭时为空
+	CloseReason string `json:"close_reason"`
+	// 连接关闭的时间
+	CloseTime string `json:"close_time"`
 }
 
 func (w *WSMsg) ValueOfDTO(dto WSMsgDTO) error {
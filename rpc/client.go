@@ -54,6 +54,20 @@ func NewClient(ctx context.Context, url string, passphrase string, keyfilePath s
 	return client, nil
 }
 
+// RemoveClient 从缓存中移除 url 对应的 RPC 客户端并关闭其连接，链地址变更或链被删除后调用
+func RemoveClient(url string) {
+	lock.Lock()
+	cli, ok := cliContainer[url]
+	delete(cliContainer, url)
+	lock.Unlock()
+	if !ok {
+		return
+	}
+	cli.venaClient.Close()
+	cli.rpcClient.Close()
+	logrus.Debugf("rpc client [%s] removed", url)
+}
+
 func (client *Client) EthClient() *venaclient.Client {
 	return client.venaClient
 }
@@ -0,0 +1,210 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Venachain/Venachain/common/hexutil"
+)
+
+const (
+	jsonrpcVersion = "2.0"
+	// 默认的 HTTP JSON-RPC 请求超时时间
+	defaultJSONRPCTimeout = 2 * time.Second
+)
+
+var (
+	ErrJSONRPCEmptyResult = errors.New("jsonrpc: empty result")
+)
+
+// JSONRPCClient 基于 HTTP 的节点 JSON-RPC 客户端
+type JSONRPCClient struct {
+	endpoint   string
+	httpClient *http.Client
+	id         uint64
+}
+
+// JSONRPCError JSON-RPC 响应中的 error 对象
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCTransaction 交易池中的交易
+type RPCTransaction struct {
+	Hash     string `json:"hash"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Gas      string `json:"gas"`
+	GasPrice string `json:"gasPrice"`
+	Nonce    string `json:"nonce"`
+	Input    string `json:"input"`
+	Value    string `json:"value"`
+}
+
+// PeerInfo admin_peers 返回的节点连接信息
+type PeerInfo struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Caps    []string `json:"caps"`
+	Network struct {
+		LocalAddress  string `json:"localAddress"`
+		RemoteAddress string `json:"remoteAddress"`
+		Inbound       bool   `json:"inbound"`
+		Trusted       bool   `json:"trusted"`
+		Static        bool   `json:"static"`
+	} `json:"network"`
+}
+
+type jsonrpcRequest struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      uint64        `json:"id"`
+}
+
+type jsonrpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *JSONRPCError   `json:"error"`
+}
+
+// NewJSONRPCClient 创建指定节点地址的 JSON-RPC 客户端
+func NewJSONRPCClient(endpoint string) *JSONRPCClient {
+	return &JSONRPCClient{
+		endpoint: endpoint,
+		httpClient: &http.Client{
+			Timeout: defaultJSONRPCTimeout,
+		},
+	}
+}
+
+// Call 调用 method 并将结果解析到 result 中
+func (c *JSONRPCClient) Call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(&jsonrpcRequest{
+		Jsonrpc: jsonrpcVersion,
+		Method:  method,
+		Params:  params,
+		ID:      atomic.AddUint64(&c.id, 1),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected http status %d", method, resp.StatusCode)
+	}
+	var res jsonrpcResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if len(res.Result) == 0 || string(res.Result) == "null" {
+		return fmt.Errorf("%s: %w", method, ErrJSONRPCEmptyResult)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid result: %w", method, err)
+	}
+	return nil
+}
+
+// BlockNumber eth_blockNumber
+func (c *JSONRPCClient) BlockNumber(ctx context.Context) (uint64, error) {
+	var hex hexutil.Uint64
+	if err := c.Call(ctx, &hex, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
+// Mining eth_mining
+func (c *JSONRPCClient) Mining(ctx context.Context) (bool, error) {
+	var mining bool
+	if err := c.Call(ctx, &mining, "eth_mining"); err != nil {
+		return false, err
+	}
+	return mining, nil
+}
+
+// GasPrice eth_gasPrice
+func (c *JSONRPCClient) GasPrice(ctx context.Context) (uint64, error) {
+	var hex hexutil.Uint64
+	if err := c.Call(ctx, &hex, "eth_gasPrice"); err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
+
+// PendingTransactions eth_pendingTransactions
+func (c *JSONRPCClient) PendingTransactions(ctx context.Context) ([]*RPCTransaction, error) {
+	var txs []*RPCTransaction
+	if err := c.Call(ctx, &txs, "eth_pendingTransactions"); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// GetCode eth_getCode，block 为空时默认查询 latest
+func (c *JSONRPCClient) GetCode(ctx context.Context, address string, block string) ([]byte, error) {
+	if block == "" {
+		block = "latest"
+	}
+	var code hexutil.Bytes
+	if err := c.Call(ctx, &code, "eth_getCode", address, block); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// AdminPeers admin_peers
+func (c *JSONRPCClient) AdminPeers(ctx context.Context) ([]*PeerInfo, error) {
+	var peers []*PeerInfo
+	if err := c.Call(ctx, &peers, "admin_peers"); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// PeerCount net_peerCount
+func (c *JSONRPCClient) PeerCount(ctx context.Context) (uint64, error) {
+	var hex hexutil.Uint64
+	if err := c.Call(ctx, &hex, "net_peerCount"); err != nil {
+		return 0, err
+	}
+	return uint64(hex), nil
+}
@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟节点，按 method 返回预设的 result 或 error
+func newFakeNode(t *testing.T, results map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		result, ok := results[req.Method]
+		if !ok {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"jsonrpc": jsonrpcVersion,
+				"id":      req.ID,
+				"error": map[string]interface{}{
+					"code":    -32601,
+					"message": "the method " + req.Method + " does not exist/is not available",
+				},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": jsonrpcVersion,
+			"id":      req.ID,
+			"result":  json.RawMessage(result),
+		})
+	}))
+}
+
+func TestJSONRPCClient_Methods(t *testing.T) {
+	node := newFakeNode(t, map[string]string{
+		"eth_blockNumber":         `"0x21"`,
+		"eth_mining":              `true`,
+		"eth_gasPrice":            `"0x3b9aca00"`,
+		"eth_pendingTransactions": `[{"hash":"0x01","from":"0xaa"},{"hash":"0x02","from":"0xbb"}]`,
+		"eth_getCode":             `"0xc3010203"`,
+		"admin_peers":             `[{"id":"abc","name":"venachain","network":{"remoteAddress":"127.0.0.1:16791","inbound":true}}]`,
+		"net_peerCount":           `"0x3"`,
+	})
+	defer node.Close()
+	cli := NewJSONRPCClient(node.URL)
+	ctx := context.Background()
+
+	number, err := cli.BlockNumber(ctx)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(33), number)
+
+	mining, err := cli.Mining(ctx)
+	assert.True(t, err == nil)
+	assert.True(t, mining)
+
+	price, err := cli.GasPrice(ctx)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(1000000000), price)
+
+	txs, err := cli.PendingTransactions(ctx)
+	assert.True(t, err == nil)
+	assert.Equal(t, 2, len(txs))
+	assert.Equal(t, "0x02", txs[1].Hash)
+
+	code, err := cli.GetCode(ctx, "0x0000000000000000000000000000000000000011", "")
+	assert.True(t, err == nil)
+	assert.Equal(t, []byte{0xc3, 0x01, 0x02, 0x03}, code)
+
+	peers, err := cli.AdminPeers(ctx)
+	assert.True(t, err == nil)
+	assert.Equal(t, 1, len(peers))
+	assert.True(t, peers[0].Network.Inbound)
+
+	count, err := cli.PeerCount(ctx)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(3), count)
+
+	height, err := GetBlockNumber(node.URL)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(33), height)
+}
+
+func TestJSONRPCClient_Errors(t *testing.T) {
+	node := newFakeNode(t, map[string]string{
+		"eth_mining":   `"yes"`,
+		"eth_gasPrice": `null`,
+	})
+	defer node.Close()
+	cli := NewJSONRPCClient(node.URL)
+	ctx := context.Background()
+
+	// JSON-RPC error 对象
+	_, err := cli.BlockNumber(ctx)
+	rpcErr, ok := err.(*JSONRPCError)
+	assert.True(t, ok)
+	assert.Equal(t, -32601, rpcErr.Code)
+
+	// 结果类型不匹配
+	_, err = cli.Mining(ctx)
+	assert.True(t, err != nil)
+
+	// 空结果
+	_, err = cli.GasPrice(ctx)
+	assert.True(t, err != nil)
+
+	// 非 200 响应
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	_, err = NewJSONRPCClient(bad.URL).PeerCount(ctx)
+	assert.True(t, err != nil)
+
+	// 节点不可达
+	bad.Close()
+	_, err = NewJSONRPCClient(bad.URL).PeerCount(ctx)
+	assert.True(t, err != nil)
+}
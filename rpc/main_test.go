@@ -0,0 +1,19 @@
+package rpc
+
+import (
+	"os"
+	"testing"
+
+	"graces/web/dao"
+)
+
+var server *Server
+
+func TestMain(m *testing.M) {
+	daos, _, err := dao.NewDaos()
+	if err != nil {
+		daos = dao.NewMemoryDaos()
+	}
+	server = NewServer(daos.Chain, daos.Node, daos.Block)
+	os.Exit(m.Run())
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"graces/model"
+	"graces/secret"
 	"graces/util"
 	"graces/web/dao"
 
@@ -32,6 +33,22 @@ const (
 	DefaultContractInterpreter = "wasm"
 )
 
+// Server 按链ID获取链上数据，链和节点的连接信息从 DAO 中读取
+type Server struct {
+	chainDao dao.IChainDao
+	nodeDao  dao.INodeDao
+	blockDao dao.IBlockDao
+}
+
+// NewServer 使用指定的 DAO 创建 Server
+func NewServer(chainDao dao.IChainDao, nodeDao dao.INodeDao, blockDao dao.IBlockDao) *Server {
+	return &Server{
+		chainDao: chainDao,
+		nodeDao:  nodeDao,
+		blockDao: blockDao,
+	}
+}
+
 // Ping ping 指定 url 看是否能 ping 通
 // 支持的 Scheme 有：http、https、ws、wss、stdio、stdio、ipc
 func Ping(url string) (bool, error) {
@@ -44,9 +61,9 @@ func Ping(url string) (bool, error) {
 }
 
 // GetLatestBlockFromChain 获取链上的最新区块
-func GetLatestBlockFromChain(chainID string) (*types.Block, error) {
+func (s *Server) GetLatestBlockFromChain(chainID string) (*types.Block, error) {
 	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	cli, err := GetRPCClientByChainID(chainID)
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -54,17 +71,17 @@ func GetLatestBlockFromChain(chainID string) (*types.Block, error) {
 }
 
 // GetLatestBlockFromDB 获取数据库中的最新区块
-func GetLatestBlockFromDB(chainID string) (*model.Block, error) {
+func (s *Server) GetLatestBlockFromDB(chainID string) (*model.Block, error) {
 	id, err := primitive.ObjectIDFromHex(chainID)
 	if err != nil {
 		return nil, err
 	}
-	return dao.DefaultBlockDao.LatestBlock(id)
+	return s.blockDao.LatestBlock(id)
 }
 
 // GetBlockByHash 通过 hash 从链上获取区块，并组装为数据库 model
-func GetBlockByHash(chainID string, hash string) (*model.Block, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetBlockByHash(chainID string, hash string) (*model.Block, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,8 +90,8 @@ func GetBlockByHash(chainID string, hash string) (*model.Block, error) {
 }
 
 // GetBlockHeadByHash 通过 hash 从链上获取区块头，并组装为数据库 model
-func GetBlockHeadByHash(chainID string, hash string) (*model.BLockHead, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetBlockHeadByHash(chainID string, hash string) (*model.BLockHead, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,8 +100,8 @@ func GetBlockHeadByHash(chainID string, hash string) (*model.BLockHead, error) {
 }
 
 // GetBlockByNumber 通过 number（高度） 从链上获取区块，并组装为数据库 model
-func GetBlockByNumber(chainID string, number int64) (*model.Block, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetBlockByNumber(chainID string, number int64) (*model.Block, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,8 +110,8 @@ func GetBlockByNumber(chainID string, number int64) (*model.Block, error) {
 }
 
 // GetBlockHeadByNumber 通过 number（高度） 从链上获取区块头，并组装为数据库 model
-func GetBlockHeadByNumber(chainID string, number int64) (*model.BLockHead, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetBlockHeadByNumber(chainID string, number int64) (*model.BLockHead, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -103,8 +120,8 @@ func GetBlockHeadByNumber(chainID string, number int64) (*model.BLockHead, error
 }
 
 // GetTXDataByBlockHash 通过 区块hash 从链上获取区块内的交易数据，并组装为数据库 model
-func GetTXDataByBlockHash(chainID string, blockID string, blockHash string) ([]*model.TX, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetTXDataByBlockHash(chainID string, blockID string, blockHash string) ([]*model.TX, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -113,12 +130,12 @@ func GetTXDataByBlockHash(chainID string, blockID string, blockHash string) ([]*
 	if err != nil {
 		return nil, err
 	}
-	return getDBTXDataByBlock(chainID, blockID, block)
+	return s.getDBTXDataByBlock(chainID, blockID, block)
 }
 
 // GetTXDataByBlockNumber 通过 区块高度 从链上获取区块内的交易数据，并组装为数据库 model
-func GetTXDataByBlockNumber(chainID string, blockID string, number int64) ([]*model.TX, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetTXDataByBlockNumber(chainID string, blockID string, number int64) ([]*model.TX, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -127,12 +144,12 @@ func GetTXDataByBlockNumber(chainID string, blockID string, number int64) ([]*mo
 	if err != nil {
 		return nil, err
 	}
-	return getDBTXDataByBlock(chainID, blockID, block)
+	return s.getDBTXDataByBlock(chainID, blockID, block)
 }
 
 // GetTXReceiptByTXHash 通过 交易hash 从链上获取该交易的收据数据，并组装为数据库 model
-func GetTXReceiptByTXHash(chainID string, txHash string) (*model.Receipt, error) {
-	cli, err := GetRPCClientByChainID(chainID)
+func (s *Server) GetTXReceiptByTXHash(chainID string, txHash string) (*model.Receipt, error) {
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -141,9 +158,9 @@ func GetTXReceiptByTXHash(chainID string, txHash string) (*model.Receipt, error)
 }
 
 // GetAllCNS 从链上获取所有的 CNS 信息
-func GetAllCNS(chainID string) ([]*model.CNS, error) {
+func (s *Server) GetAllCNS(chainID string) ([]*model.CNS, error) {
 	// 1、通过 chainID 获取其对应的链 rpc 连接客户端
-	cli, err := GetRPCClientByChainID(chainID)
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -171,8 +188,8 @@ func GetAllCNS(chainID string) ([]*model.CNS, error) {
 }
 
 // GetRPCClientByChainID 通过 链id 信息获取其对应的 rpc 客户端连接
-func GetRPCClientByChainID(chainID string) (*Client, error) {
-	chain, err := getChainByID(chainID)
+func (s *Server) GetRPCClientByChainID(chainID string) (*Client, error) {
+	chain, err := s.getChainByID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -180,9 +197,9 @@ func GetRPCClientByChainID(chainID string) (*Client, error) {
 	return getRPCClientByChain(ctx, *chain)
 }
 
-func GetRPCClientByChainIDAndNodeID(chainID string, nodeID string) (*Client, error) {
-	chain, err := getChainByID(chainID)
-	node, err := getNodeByID(nodeID)
+func (s *Server) GetRPCClientByChainIDAndNodeID(chainID string, nodeID string) (*Client, error) {
+	chain, err := s.getChainByID(chainID)
+	node, err := s.getNodeByID(nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -202,7 +219,10 @@ func getRPCClientByChainAndNodeID(chain model.Chain, node model.Node) (*Client,
 		return nil, errors.New("chain [%v] without node config")
 	}
 	keyfilePath := nodeConfig["keyfile_path"].(string)
-	passphrase := nodeConfig["passphrase"].(string)
+	passphrase, err := secret.Decrypt(nodeConfig["passphrase"].(string))
+	if err != nil {
+		return nil, err
+	}
 	client, err := NewClient(ctx, uri.String(), passphrase, keyfilePath)
 	if err != nil {
 		return nil, err
@@ -211,9 +231,9 @@ func getRPCClientByChainAndNodeID(chain model.Chain, node model.Node) (*Client,
 }
 
 // GetAllNodes 获取指定链上的所有节点数据
-func GetAllNodes(chainID string) ([]*model.Node, error) {
+func (s *Server) GetAllNodes(chainID string) ([]*model.Node, error) {
 	// 1、通过 chainID 获取其对应的链 rpc 连接客户端
-	cli, err := GetRPCClientByChainID(chainID)
+	cli, err := s.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -233,8 +253,26 @@ func GetAllNodes(chainID string) ([]*model.Node, error) {
 	return nodes, nil
 }
 
+// RemoveRPCClientsByChain 移除链及其所有节点缓存的 RPC 客户端
+func (s *Server) RemoveRPCClientsByChain(chain model.Chain) {
+	RemoveClient(chainRPCURL(chain))
+	nodes, err := s.nodeDao.Nodes(bson.M{"chain_id": chain.ID}, nil)
+	if err != nil {
+		logrus.Warningf("chain[%s] load nodes err: %v", chain.Name, err)
+		return
+	}
+	for _, node := range nodes {
+		host := fmt.Sprintf("%v:%v", node.ExternalIP, node.RPCPort)
+		uri := url.URL{
+			Scheme: "http",
+			Host:   host,
+		}
+		RemoveClient(uri.String())
+	}
+}
+
 // 通过 id 获取链信息
-func getChainByID(chainID string) (*model.Chain, error) {
+func (s *Server) getChainByID(chainID string) (*model.Chain, error) {
 	id, err := primitive.ObjectIDFromHex(chainID)
 	if err != nil {
 		return nil, err
@@ -242,7 +280,7 @@ func getChainByID(chainID string) (*model.Chain, error) {
 	filter := bson.M{
 		"_id": id,
 	}
-	chain, err := dao.DefaultChainDao.Chain(filter)
+	chain, err := s.chainDao.Chain(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -250,7 +288,7 @@ func getChainByID(chainID string) (*model.Chain, error) {
 }
 
 // 通过 id 获取节点信息
-func getNodeByID(nodeID string) (*model.Node, error) {
+func (s *Server) getNodeByID(nodeID string) (*model.Node, error) {
 	id, err := primitive.ObjectIDFromHex(nodeID)
 	if err != nil {
 		return nil, err
@@ -258,7 +296,7 @@ func getNodeByID(nodeID string) (*model.Node, error) {
 	filter := bson.M{
 		"_id": id,
 	}
-	node, err := dao.DefaultNodeDao.Node(filter)
+	node, err := s.nodeDao.Node(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -267,38 +305,45 @@ func getNodeByID(nodeID string) (*model.Node, error) {
 
 // 通过链配置信息获取其对应的 rpc 客户端连接
 func getRPCClientByChain(ctx context.Context, chain model.Chain) (*Client, error) {
-	host := fmt.Sprintf("%v:%v", chain.IP, chain.RPCPort)
-	uri := url.URL{
-		Scheme: "http",
-		Host:   host,
-	}
 	nodeConfig, ok := chain.ChainConfig["node"].(map[string]interface{})
 	if !ok {
 		return nil, errors.New("chain [%v] without node config")
 	}
 	keyfilePath := nodeConfig["keyfile_path"].(string)
-	passphrase := nodeConfig["passphrase"].(string)
-	client, err := NewClient(ctx, uri.String(), passphrase, keyfilePath)
+	passphrase, err := secret.Decrypt(nodeConfig["passphrase"].(string))
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(ctx, chainRPCURL(chain), passphrase, keyfilePath)
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
 
+// 链第一个节点的 rpc 地址
+func chainRPCURL(chain model.Chain) string {
+	host := fmt.Sprintf("%v:%v", chain.IP, chain.RPCPort)
+	uri := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	return uri.String()
+}
+
+// CheckChain 检查链第一个节点的 rpc 是否可达，返回节点最新区块的高度
+func CheckChain(ctx context.Context, chain model.Chain) (uint64, error) {
+	return NewJSONRPCClient(chainRPCURL(chain)).BlockNumber(ctx)
+}
+
+// PendingTransactions 获取链第一个节点交易池中等待打包的交易
+func PendingTransactions(ctx context.Context, chain model.Chain) ([]*RPCTransaction, error) {
+	return NewJSONRPCClient(chainRPCURL(chain)).PendingTransactions(ctx)
+}
+
 // GetBlockNumber 获取指定节点的最新区块的高度
 func GetBlockNumber(endpoint string) (uint64, error) {
-	height, err := model.GetRpcResult(endpoint, "eth_blockNumber", []string{})
-	if height == nil {
-		return 0, err
-	}
-	if blockHeight, ok := height.(string); ok {
-		blockNumber, err := util.Hex2Number(blockHeight)
-		if err != nil {
-			return 0, err
-		}
-		return blockNumber, nil
-	}
-	return 0, errors.New("height not be a string")
+	return NewJSONRPCClient(endpoint).BlockNumber(context.Background())
 }
 
 func getBlockByNumber(ctx context.Context, client *Client, chainID string, number int64) (*model.Block, error) {
@@ -340,7 +385,7 @@ func getBlockHeadByHash(ctx context.Context, client *Client, hash string) (*mode
 }
 
 // 通过 *types.Block 获取 []model.TX 交易数据
-func getDBTXDataByBlock(chainID string, blockID string, block *types.Block) ([]*model.TX, error) {
+func (s *Server) getDBTXDataByBlock(chainID string, blockID string, block *types.Block) ([]*model.TX, error) {
 	if block == nil {
 		return nil, errors.New("block is nil")
 	}
@@ -354,7 +399,7 @@ func getDBTXDataByBlock(chainID string, blockID string, block *types.Block) ([]*
 		}
 		dbTX.Height = block.NumberU64()
 		dbTX.Timestamp = block.Time().Int64()
-		receipt, err := GetTXReceiptByTXHash(chainID, tx.Hash().Hex())
+		receipt, err := s.GetTXReceiptByTXHash(chainID, tx.Hash().Hex())
 		if nil != err {
 			logrus.Errorln("fail to get transaction receipt.err:", err)
 			return nil, err
@@ -0,0 +1,258 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"graces/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 主密钥长度，使用 AES-256-GCM 加密
+	masterKeyLen = 32
+	// 密文前缀，密文格式为 enc:v1:<主密钥ID>:<base64(nonce+密文)>
+	encryptedPrefix = "enc:v1:"
+)
+
+var (
+	// DefaultCipher 使用配置的主密钥加解密敏感字段，未配置主密钥时为 nil，敏感字段以明文存储
+	DefaultCipher *Cipher
+
+	// ChainConfigSecrets chain_config 中需要加密存储的字段路径
+	ChainConfigSecrets = [][]string{
+		{"node", "passphrase"},
+	}
+
+	errNoMasterKey = errors.New("master key is not configured, can't decrypt secret")
+)
+
+// Cipher 敏感字段加解密器
+type Cipher struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// InitCipher 根据配置加载主密钥并初始化默认的加解密器，需要在 dao 初始化之前调用
+func InitCipher() error {
+	var env, file string
+	if conf := config.Config.SecretConf; conf != nil {
+		env, file = conf.MasterKeyEnv, conf.MasterKeyFile
+	}
+	key, err := LoadMasterKey(env, file)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		logrus.Warningln("master key is not configured, secrets in chain_config will be stored in plaintext")
+		DefaultCipher = nil
+		return nil
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return err
+	}
+	DefaultCipher = c
+	return nil
+}
+
+// LoadMasterKey 加载主密钥：环境变量优先，其次为密钥文件，都没有配置时返回 nil
+func LoadMasterKey(env string, file string) ([]byte, error) {
+	if env != "" {
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			return ParseKey(value)
+		}
+	}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read master key file: %w", err)
+		}
+		return ParseKey(string(data))
+	}
+	return nil, nil
+}
+
+// ParseKey 解析 base64 或 hex 编码的 32 字节主密钥
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == masterKeyLen {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(value); err == nil && len(key) == masterKeyLen {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be %d bytes encoded in base64 or hex", masterKeyLen)
+}
+
+// GenerateKey 生成一个 base64 编码的随机主密钥
+func GenerateKey() (string, error) {
+	key := make([]byte, masterKeyLen)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// NewCipher 使用 32 字节的主密钥创建加解密器
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != masterKeyLen {
+		return nil, fmt.Errorf("master key must be %d bytes", masterKeyLen)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &Cipher{
+		keyID: hex.EncodeToString(sum[:4]),
+		aead:  aead,
+	}, nil
+}
+
+// KeyID 主密钥ID，取主密钥摘要的前 4 个字节，用于识别密文是由哪个主密钥加密的
+func (c *Cipher) KeyID() string {
+	return c.keyID
+}
+
+// Encrypt 加密明文，已经是密文的值原样返回
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(c.keyID))
+	return encryptedPrefix + c.keyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密密文，不是密文的值视为尚未加密的旧数据原样返回
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("malformed secret")
+	}
+	if parts[0] != c.keyID {
+		return "", fmt.Errorf("secret was encrypted by master key [%s], current master key is [%s]", parts[0], c.keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed secret: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("malformed secret")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(c.keyID))
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptChainConfig 加密 chain_config 中的敏感字段，返回新的配置，不修改传入的配置
+func (c *Cipher) EncryptChainConfig(chainConfig map[string]interface{}) (map[string]interface{}, error) {
+	return transformChainConfig(chainConfig, c.Encrypt)
+}
+
+// DecryptChainConfig 解密 chain_config 中的敏感字段，返回新的配置，不修改传入的配置
+func (c *Cipher) DecryptChainConfig(chainConfig map[string]interface{}) (map[string]interface{}, error) {
+	return transformChainConfig(chainConfig, c.Decrypt)
+}
+
+// IsEncrypted 是否为加密后的值
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt 使用默认的加解密器加密，未配置主密钥时返回明文
+func Encrypt(plaintext string) (string, error) {
+	if DefaultCipher == nil {
+		return plaintext, nil
+	}
+	return DefaultCipher.Encrypt(plaintext)
+}
+
+// Decrypt 使用默认的加解密器解密，未配置主密钥时只能处理明文
+func Decrypt(value string) (string, error) {
+	if DefaultCipher == nil {
+		if IsEncrypted(value) {
+			return "", errNoMasterKey
+		}
+		return value, nil
+	}
+	return DefaultCipher.Decrypt(value)
+}
+
+// EncryptChainConfig 使用默认的加解密器加密 chain_config 中的敏感字段
+func EncryptChainConfig(chainConfig map[string]interface{}) (map[string]interface{}, error) {
+	return transformChainConfig(chainConfig, Encrypt)
+}
+
+// DecryptChainConfig 使用默认的加解密器解密 chain_config 中的敏感字段
+func DecryptChainConfig(chainConfig map[string]interface{}) (map[string]interface{}, error) {
+	return transformChainConfig(chainConfig, Decrypt)
+}
+
+// 复制 chain_config 并对其中的敏感字段进行转换，只复制敏感字段路径上的 map
+func transformChainConfig(chainConfig map[string]interface{}, transform func(string) (string, error)) (map[string]interface{}, error) {
+	if chainConfig == nil {
+		return nil, nil
+	}
+	result := copyMap(chainConfig)
+	for _, path := range ChainConfigSecrets {
+		m := result
+		for _, key := range path[:len(path)-1] {
+			sub, ok := m[key].(map[string]interface{})
+			if !ok {
+				m = nil
+				break
+			}
+			sub = copyMap(sub)
+			m[key] = sub
+			m = sub
+		}
+		if m == nil {
+			continue
+		}
+		key := path[len(path)-1]
+		value, ok := m[key].(string)
+		if !ok {
+			continue
+		}
+		transformed, err := transform(value)
+		if err != nil {
+			return nil, fmt.Errorf("chain_config.%s: %w", strings.Join(path, "."), err)
+		}
+		m[key] = transformed
+	}
+	return result, nil
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
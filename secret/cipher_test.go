@@ -0,0 +1,51 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCipher_ChainConfig(t *testing.T) {
+	key, err := GenerateKey()
+	assert.True(t, err == nil)
+	raw, err := ParseKey(key)
+	assert.True(t, err == nil)
+	c, err := NewCipher(raw)
+	assert.True(t, err == nil)
+
+	chainConfig := map[string]interface{}{
+		"node": map[string]interface{}{"keyfile_path": "./keystore", "passphrase": "0"},
+	}
+	encrypted, err := c.EncryptChainConfig(chainConfig)
+	assert.True(t, err == nil)
+	passphrase := encrypted["node"].(map[string]interface{})["passphrase"].(string)
+	assert.True(t, IsEncrypted(passphrase))
+	assert.Equal(t, "./keystore", encrypted["node"].(map[string]interface{})["keyfile_path"])
+	// 不修改传入的配置
+	assert.Equal(t, "0", chainConfig["node"].(map[string]interface{})["passphrase"])
+
+	// 重复加密不会改变密文
+	again, err := c.Encrypt(passphrase)
+	assert.True(t, err == nil)
+	assert.Equal(t, passphrase, again)
+
+	decrypted, err := c.DecryptChainConfig(encrypted)
+	assert.True(t, err == nil)
+	assert.Equal(t, "0", decrypted["node"].(map[string]interface{})["passphrase"])
+
+	// 其他主密钥无法解密
+	other, err := GenerateKey()
+	assert.True(t, err == nil)
+	raw, err = ParseKey(other)
+	assert.True(t, err == nil)
+	rotated, err := NewCipher(raw)
+	assert.True(t, err == nil)
+	_, err = rotated.Decrypt(passphrase)
+	assert.True(t, err != nil)
+
+	// 明文视为尚未加密的旧数据
+	plain, err := rotated.Decrypt("0")
+	assert.True(t, err == nil)
+	assert.Equal(t, "0", plain)
+}
@@ -1,6 +1,10 @@
 package secret
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"graces/config"
 	"graces/exterr"
 	"strings"
@@ -9,22 +13,29 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
+// 签名秘钥ID在 token 头部中的字段名
+const headerKeyID = "kid"
+
 // CustomClaims 自定义 Claims，继承 jwt.StandardClaims 并添加一些自己需要的信息
 type CustomClaims struct {
 	UserId   string `json:"userId"`
 	Username string `json:"username"`
+	// TokenVersion 签发时用户的令牌版本号，用户退出所有会话后旧版本的 token 失效
+	TokenVersion int64 `json:"tokenVersion"`
+	// SessionID 登录会话ID，同一次登录后刷新得到的 token 属于同一个会话
+	SessionID string `json:"sid"`
 	jwt.StandardClaims
 }
 
 func NewDefaultCustomClaims() *CustomClaims {
-	expiresTime := time.Now().Add(config.Config.JWTConf.Expires * time.Second).Unix()
+	expiresTime := time.Now().Add(config.JWTExpires()).Unix()
 	return &CustomClaims{
 		UserId:   "",
 		Username: "",
 		StandardClaims: jwt.StandardClaims{
 			Audience:  "",                           // 受众
 			ExpiresAt: expiresTime,                  // 失效时间
-			Id:        "",                           // 编号
+			Id:        RandomString(16),             // 编号，吊销 token 时使用
 			IssuedAt:  time.Now().Unix(),            // 签发时间
 			Issuer:    config.Config.JWTConf.Issuer, // 签发人
 			NotBefore: time.Now().Unix(),            // 生效时间
@@ -35,36 +46,47 @@ func NewDefaultCustomClaims() *CustomClaims {
 
 // JWT 结构
 type JWT struct {
-	// SigningKey 密钥信息
+	// SigningKey 当前签名秘钥
 	SigningKey []byte
+	// KeyID 当前签名秘钥的ID
+	KeyID string
+	// VerifyKeys 校验 token 可用的秘钥，kid -> 秘钥，包含当前秘钥和轮换下来的旧秘钥
+	VerifyKeys map[string][]byte
 }
 
 // NewJWT 创建一个 JWT 实例
 func NewJWT() *JWT {
+	conf := config.Config.JWTConf
+	keys := make(map[string][]byte, len(conf.RetiredKeys)+1)
+	for kid, key := range conf.RetiredKeys {
+		keys[kid] = []byte(key)
+	}
+	keys[conf.KeyID] = []byte(conf.SecKey)
 	return &JWT{
-		[]byte(config.Config.JWTConf.SecKey),
+		SigningKey: []byte(conf.SecKey),
+		KeyID:      conf.KeyID,
+		VerifyKeys: keys,
 	}
 }
 
-// CreateToken 创建 JWT
+// CreateToken 创建 JWT，头部带上当前签名秘钥的 kid
 func (j *JWT) CreateToken(claims CustomClaims) (token string, err error) {
 	// 通过 HS256 算法生成 tokenClaims ,这就是我们的 HEADER 部分和 PAYLOAD。
 	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenClaims.Header[headerKeyID] = j.KeyID
 	token, err = tokenClaims.SignedString(j.SigningKey)
 	// 返回添加了前缀的 token
 	return config.Config.JWTConf.Prefix + token, err
 }
 
-// ParseToken 解析 JWT
+// ParseToken 解析 JWT，根据头部的 kid 选择校验秘钥
 func (j *JWT) ParseToken(tokenStr string) (*CustomClaims, error) {
 	auth := strings.Fields(tokenStr)
-	if len(auth) < 1 {
+	if len(auth) < 2 {
 		return nil, exterr.ErrTokenInvalid
 	}
 	// 解析 token 时去除前缀的影响
-	token, err := jwt.ParseWithClaims(auth[1], &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
+	token, err := jwt.ParseWithClaims(auth[1], &CustomClaims{}, j.verifyKey)
 	if err != nil {
 		if ve, ok := err.(*jwt.ValidationError); ok {
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
@@ -87,44 +109,33 @@ func (j *JWT) ParseToken(tokenStr string) (*CustomClaims, error) {
 	return nil, exterr.ErrTokenInvalid
 }
 
-// RefreshToken 刷新 JWT
-func (j *JWT) RefreshToken(tokenStr string) (string, error) {
-	auth := strings.Fields(tokenStr)
-	if len(auth) < 1 {
-		return "", exterr.ErrTokenInvalid
+// 按 kid 查找校验秘钥，没有 kid 的 token 是支持秘钥轮换之前签发的，使用当前秘钥校验
+func (j *JWT) verifyKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
-	// 解析 token 时去除前缀的影响
-	token, err := jwt.ParseWithClaims(auth[1], &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header[headerKeyID]
+	if !ok {
 		return j.SigningKey, nil
-	})
-	if err != nil {
-		return "", err
 	}
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		claims.StandardClaims.ExpiresAt = time.Now().Add(config.Config.JWTConf.Expires * time.Second).Unix()
-		return j.CreateToken(*claims)
+	key, ok := j.VerifyKeys[fmt.Sprint(kid)]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %v", kid)
 	}
-	return "", exterr.ErrTokenInvalid
+	return key, nil
 }
 
-// InvalidToken 让 JWT 失效
-// 这里实测，并没有产生应有的效果
-func (j *JWT) InvalidToken(tokenStr string) error {
-	auth := strings.Fields(tokenStr)
-	if len(auth) < 1 {
-		return exterr.ErrTokenInvalid
+// RandomString 生成 n 字节的随机数，以十六进制字符串返回
+func RandomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
 	}
-	// 解析 token 时去除前缀的影响
-	token, err := jwt.ParseWithClaims(auth[1], &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
-	if err != nil {
-		return err
-	}
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		// 使这个 JWT 立即失效
-		claims.StandardClaims.ExpiresAt = time.Now().Unix()
-		return nil
-	}
-	return exterr.ErrTokenInvalid
+	return hex.EncodeToString(b)
+}
+
+// HashToken 计算 token 的 SHA-256 摘要，服务端只保存摘要，不保存 token 原文
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
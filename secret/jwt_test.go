@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"testing"
+
+	"graces/exterr"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT_KeyRotation(t *testing.T) {
+	old := &JWT{
+		SigningKey: []byte("old-key"),
+		KeyID:      "v1",
+		VerifyKeys: map[string][]byte{"v1": []byte("old-key")},
+	}
+	claims := NewDefaultCustomClaims()
+	claims.UserId = "user"
+	token, err := old.CreateToken(*claims)
+	assert.True(t, err == nil)
+
+	// 轮换秘钥后，旧秘钥签发的 token 仍然可以校验
+	rotated := &JWT{
+		SigningKey: []byte("new-key"),
+		KeyID:      "v2",
+		VerifyKeys: map[string][]byte{"v1": []byte("old-key"), "v2": []byte("new-key")},
+	}
+	parsed, err := rotated.ParseToken(token)
+	assert.True(t, err == nil)
+	assert.Equal(t, "user", parsed.UserId)
+
+	// 旧秘钥删除后，旧 token 失效
+	retired := &JWT{
+		SigningKey: []byte("new-key"),
+		KeyID:      "v2",
+		VerifyKeys: map[string][]byte{"v2": []byte("new-key")},
+	}
+	_, err = retired.ParseToken(token)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+}
@@ -0,0 +1,34 @@
+package syncer
+
+import (
+	"os"
+	"testing"
+
+	"graces/cluster"
+	"graces/rpc"
+	"graces/web/dao"
+)
+
+var (
+	testManager *ChainDataSyncManager
+	testSyncer  *syncer
+)
+
+func TestMain(m *testing.M) {
+	testManager = newTestManager()
+	testSyncer = testManager.syncer
+	testManager.Run()
+	os.Exit(m.Run())
+}
+
+func newTestManager() *ChainDataSyncManager {
+	daos, _, err := dao.NewDaos()
+	if err != nil {
+		daos = dao.NewMemoryDaos()
+	}
+	c, err := cluster.New(nil)
+	if err != nil {
+		panic(err)
+	}
+	return NewChainDataSyncManager(daos, rpc.NewServer(daos.Chain, daos.Node, daos.Block), c.Elector)
+}
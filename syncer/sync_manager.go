@@ -1,8 +1,10 @@
 package syncer
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"graces/cluster"
 	"graces/config"
 	"graces/exterr"
 	"graces/model"
@@ -22,6 +24,8 @@ const (
 	gcInterval = 30 * 60
 	// 同步已完成的数据至少存留的时间：2分钟
 	keepTime = 2 * 60 * 1000
+	// 同一条链同步进度通知的最小间隔
+	progressNotifyInterval = time.Second
 
 	// StatusPrepare 同步状态：准备同步
 	StatusPrepare = "prepare"
@@ -41,68 +45,133 @@ const (
 )
 
 var (
-	DefaultChainDataSyncManager *chainDataSyncManager
+	errSyncCanceled = errors.New("chain data sync canceled")
 )
 
-func init() {
-	DefaultChainDataSyncManager = newChainSyncManager()
-	go DefaultChainDataSyncManager.errProcessAndGC()
-}
-
-func newChainSyncManager() *chainDataSyncManager {
-	return &chainDataSyncManager{
+// SyncProgressListener 链数据同步进度监听器
+type SyncProgressListener func(info *model.ChainDataSyncInfoVO)
+
+// NewChainDataSyncManager 创建链数据同步管理器，多实例部署时由 elector 判断当前实例是否为链的 leader
+func NewChainDataSyncManager(daos *dao.Daos, rpcServer *rpc.Server, elector cluster.IElector) *ChainDataSyncManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ChainDataSyncManager{
+		syncer:            newSyncer(daos, rpcServer),
+		chainDao:          daos.Chain,
+		rpcServer:         rpcServer,
+		elector:           elector,
 		syncInfoContainer: make(map[string]*model.ChainDataSyncInfo),
+		notifyTimes:       make(map[string]time.Time),
 		ErrChan:           make(chan *model.SyncErrMsg),
+		ctx:               ctx,
+		cancel:            cancel,
 	}
 }
 
-type chainDataSyncManager struct {
+// ChainDataSyncManager 链数据同步管理器
+type ChainDataSyncManager struct {
+	syncer            *syncer
+	chainDao          dao.IChainDao
+	rpcServer         *rpc.Server
+	elector           cluster.IElector
 	syncInfoContainer map[string]*model.ChainDataSyncInfo
 	lock              sync.Mutex
 	ErrChan           chan *model.SyncErrMsg
+	// 停止时取消 ctx，正在进行的同步在写完当前区块后退出
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	// 同步进度监听器，以及每条链最近一次通知的时间
+	listeners   []SyncProgressListener
+	notifyTimes map[string]time.Time
+}
+
+// Run 启动同步错误处理和同步记录清理协程
+func (manager *ChainDataSyncManager) Run() {
+	go manager.errProcessAndGC()
+}
+
+// Stop 取消所有同步任务并等待它们在写完当前区块后退出，ctx 超时则不再等待
+func (manager *ChainDataSyncManager) Stop(ctx context.Context) error {
+	manager.cancel()
+	done := make(chan struct{})
+	go func() {
+		manager.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logrus.Infof("chain data sync manager stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for chain data sync to stop: %w", ctx.Err())
+	}
+}
+
+// 上报同步错误，同步管理器停止后不再阻塞
+func (manager *ChainDataSyncManager) reportErr(msg *model.SyncErrMsg) {
+	select {
+	case manager.ErrChan <- msg:
+	case <-manager.ctx.Done():
+		logrus.Warningf("chain[%s] data sync stopped: %v", msg.ChainID, msg.Err)
+	}
 }
 
 // ChainDataIncrSyncDelayStart 链数据循环增量同步延迟启动
-func (manager *chainDataSyncManager) ChainDataIncrSyncDelayStart(delay time.Duration) {
+func (manager *ChainDataSyncManager) ChainDataIncrSyncDelayStart(delay time.Duration) {
 	if delay < 0 {
 		delay = 0
 	}
 	target := time.Until(time.Now().Add(delay * time.Second))
 	timer := time.NewTimer(target)
+	defer timer.Stop()
 	select {
 	case <-timer.C:
 		go manager.loopIncrSync()
-		break
+	case <-manager.ctx.Done():
 	}
 }
 
 // ChainDataIncrSyncStart 链数据循环增量同步立即启动
-func (manager *chainDataSyncManager) ChainDataIncrSyncStart() {
+func (manager *ChainDataSyncManager) ChainDataIncrSyncStart() {
 	go manager.loopIncrSync()
 }
 
 // 增量循环同步
-func (manager *chainDataSyncManager) loopIncrSync() {
+func (manager *ChainDataSyncManager) loopIncrSync() {
 	defer func() {
 		if err := recover(); err != nil {
 			logrus.Errorf("unknown panic，loopIncrSync：%+v", err)
 		}
 	}()
 
-	interval := config.Config.Syncer.IncrInterval * time.Second
+	interval := config.SyncIncrInterval()
 	logrus.Infof("chain data increment synchronize [start], sync interval: [%v/once]", interval)
-	ticker := time.NewTicker(interval)
+	// 每次触发时重新读取同步间隔，配置重新加载后从下一轮开始生效
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 	for {
 		select {
-		case <-ticker.C:
+		case <-manager.ctx.Done():
+			logrus.Infof("chain data increment synchronize [stop]")
+			return
+		case <-timer.C:
+			if next := config.SyncIncrInterval(); next != interval {
+				logrus.Infof("chain data increment synchronize interval changed: [%v/once]", next)
+				interval = next
+			}
+			timer.Reset(interval)
 			filter := bson.M{}
 			findOps := options.Find().SetProjection(bson.D{{"_id", 1}, {"name", 1}})
-			chains, err := dao.DefaultChainDao.Chains(filter, findOps)
+			chains, err := manager.chainDao.Chains(filter, findOps)
 			if err != nil || len(chains) == 0 {
 				logrus.Infof("no chains need to increment synchronize")
 				continue
 			}
 			for _, chain := range chains {
+				// 多实例部署时只由链的 leader 执行循环增量同步
+				if !manager.elector.IsLeader(cluster.ChainKey(chain.ID.Hex())) {
+					continue
+				}
 				manager.IncrSyncStart(chain.ID.Hex(), true)
 			}
 		}
@@ -110,20 +179,50 @@ func (manager *chainDataSyncManager) loopIncrSync() {
 }
 
 // GetChainDataSyncInfo 获取同步信息
-func (manager *chainDataSyncManager) GetChainDataSyncInfo(chainID string) (*model.ChainDataSyncInfo, bool) {
+func (manager *ChainDataSyncManager) GetChainDataSyncInfo(chainID string) (*model.ChainDataSyncInfo, bool) {
 	info, ok := manager.syncInfoContainer[chainID]
 	return info, ok
 }
 
 // PutChainDataSyncInfo 往同步管理器的容器中添加链的同步信息
-func (manager *chainDataSyncManager) PutChainDataSyncInfo(info *model.ChainDataSyncInfo) {
+func (manager *ChainDataSyncManager) PutChainDataSyncInfo(info *model.ChainDataSyncInfo) {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
 	manager.syncInfoContainer[info.ChainID] = info
 }
 
+// AddProgressListener 添加同步进度监听器，每同步一个区块以及同步结束时通知，同一条链的通知间隔不小于 1 秒
+func (manager *ChainDataSyncManager) AddProgressListener(listener SyncProgressListener) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.listeners = append(manager.listeners, listener)
+}
+
+// 通知同步进度，force 为 true 时忽略通知间隔
+func (manager *ChainDataSyncManager) notifyProgress(chainID string, force bool) {
+	manager.lock.Lock()
+	info, ok := manager.syncInfoContainer[chainID]
+	if !ok || len(manager.listeners) == 0 || (!force && time.Since(manager.notifyTimes[chainID]) < progressNotifyInterval) {
+		manager.lock.Unlock()
+		return
+	}
+	manager.notifyTimes[chainID] = time.Now()
+	listeners := make([]SyncProgressListener, len(manager.listeners))
+	copy(listeners, manager.listeners)
+	manager.lock.Unlock()
+
+	vo, err := info.ToVO()
+	if err != nil {
+		logrus.Errorf("chain[%s] notify sync progress err: %v", chainID, err)
+		return
+	}
+	for _, listener := range listeners {
+		listener(vo)
+	}
+}
+
 // IncrSyncStart 开始增量同步
-func (manager *chainDataSyncManager) IncrSyncStart(chainID string, isAsync bool) {
+func (manager *ChainDataSyncManager) IncrSyncStart(chainID string, isAsync bool) {
 	if isAsync {
 		go manager.syncStart(chainID, false)
 		return
@@ -132,7 +231,7 @@ func (manager *chainDataSyncManager) IncrSyncStart(chainID string, isAsync bool)
 }
 
 // FullSyncStart 开始全量同步
-func (manager *chainDataSyncManager) FullSyncStart(chainID string, isAsync bool) {
+func (manager *ChainDataSyncManager) FullSyncStart(chainID string, isAsync bool) {
 	if isAsync {
 		go manager.syncStart(chainID, true)
 		return
@@ -140,7 +239,13 @@ func (manager *chainDataSyncManager) FullSyncStart(chainID string, isAsync bool)
 	manager.syncStart(chainID, true)
 }
 
-func (manager *chainDataSyncManager) syncStart(chainID string, isFullSync bool) {
+func (manager *ChainDataSyncManager) syncStart(chainID string, isFullSync bool) {
+	if manager.ctx.Err() != nil {
+		logrus.Infof("chain data sync manager is stopped, ignore sync for chain[%s]", chainID)
+		return
+	}
+	manager.running.Add(1)
+	defer manager.running.Done()
 	defer func() {
 		if err := recover(); err != nil {
 			logrus.Errorf("unknown panic，syncStart：%+v", err)
@@ -157,7 +262,7 @@ func (manager *chainDataSyncManager) syncStart(chainID string, isFullSync bool)
 }
 
 // 数据同步处理
-func (manager *chainDataSyncManager) syncProcess(chainID string, isFullSync bool) {
+func (manager *ChainDataSyncManager) syncProcess(chainID string, isFullSync bool) {
 	chainSyncInfo := manager.BuildChainSyncInfo(chainID)
 	if chainSyncInfo.Status == StatusSyncing {
 		logrus.Infof("this chain[%s] is syncing, don't repeat sync for it", chainID)
@@ -180,11 +285,11 @@ func (manager *chainDataSyncManager) syncProcess(chainID string, isFullSync bool
 		if err != nil {
 			chainSyncInfo.NodeDataSyncInfo.ErrMsg = err.Error()
 			chainSyncInfo.NodeDataSyncInfo.Status = StatusError
-			DefaultChainDataSyncManager.ErrChan <- &model.SyncErrMsg{
+			manager.reportErr(&model.SyncErrMsg{
 				ChainID: chainID,
 				ErrType: ErrTypeNodeSync,
 				Err:     err,
-			}
+			})
 			return
 		}
 	}(waitGroup)
@@ -201,11 +306,11 @@ func (manager *chainDataSyncManager) syncProcess(chainID string, isFullSync bool
 		if err != nil {
 			chainSyncInfo.CNSDataSyncInfo.ErrMsg = err.Error()
 			chainSyncInfo.CNSDataSyncInfo.Status = StatusError
-			DefaultChainDataSyncManager.ErrChan <- &model.SyncErrMsg{
+			manager.reportErr(&model.SyncErrMsg{
 				ChainID: chainID,
 				ErrType: ErrTypeCNSSync,
 				Err:     err,
-			}
+			})
 			return
 		}
 	}(waitGroup)
@@ -222,11 +327,11 @@ func (manager *chainDataSyncManager) syncProcess(chainID string, isFullSync bool
 		if err != nil {
 			chainSyncInfo.BlockDataSyncInfo.ErrMsg = err.Error()
 			chainSyncInfo.BlockDataSyncInfo.Status = StatusError
-			DefaultChainDataSyncManager.ErrChan <- &model.SyncErrMsg{
+			manager.reportErr(&model.SyncErrMsg{
 				ChainID: chainID,
 				ErrType: ErrTypeBlockOrTXSync,
 				Err:     err,
-			}
+			})
 			return
 		}
 	}(waitGroup)
@@ -238,11 +343,12 @@ func (manager *chainDataSyncManager) syncProcess(chainID string, isFullSync bool
 		chainSyncInfo.Status = StatusSuccess
 		logrus.Infof("chain[%s] data sync success", chainID)
 	}
+	manager.notifyProgress(chainID, true)
 	return
 }
 
 // BuildChainSyncInfo 构建链数据同步信息
-func (manager *chainDataSyncManager) BuildChainSyncInfo(chainID string) *model.ChainDataSyncInfo {
+func (manager *ChainDataSyncManager) BuildChainSyncInfo(chainID string) *model.ChainDataSyncInfo {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
 	chainSyncInfo, ok := manager.GetChainDataSyncInfo(chainID)
@@ -258,7 +364,7 @@ func (manager *chainDataSyncManager) BuildChainSyncInfo(chainID string) *model.C
 }
 
 // SyncBlockAndTX 同步区块和交易
-func (manager *chainDataSyncManager) SyncBlockAndTX(chainID string, isFullSync bool) error {
+func (manager *ChainDataSyncManager) SyncBlockAndTX(chainID string, isFullSync bool) error {
 	logrus.Debugf("chain[%v] block data and tx data sync [start]", chainID)
 	defer logrus.Debugf("chain[%v] block data and tx data sync [end]", chainID)
 	chainSyncInfo, ok := manager.GetChainDataSyncInfo(chainID)
@@ -283,12 +389,12 @@ func (manager *chainDataSyncManager) SyncBlockAndTX(chainID string, isFullSync b
 	if !isFullSync {
 		blockSyncInfo.CurrentHeight = 0
 		// 非全量同步，即增量同步才数据库中查询块高最高的区块
-		dbBLock, _ := rpc.GetLatestBlockFromDB(chainID)
+		dbBLock, _ := manager.rpcServer.GetLatestBlockFromDB(chainID)
 		if dbBLock != nil {
 			blockSyncInfo.CurrentHeight = dbBLock.Height
 		}
 	}
-	latestBlock, err := rpc.GetLatestBlockFromChain(chainID)
+	latestBlock, err := manager.rpcServer.GetLatestBlockFromChain(chainID)
 	if err != nil {
 		return exterr.NewError(exterr.ErrCodeChainDataSync, err)
 	}
@@ -308,7 +414,7 @@ func (manager *chainDataSyncManager) SyncBlockAndTX(chainID string, isFullSync b
 }
 
 // SyncCNS 同步 cns
-func (manager *chainDataSyncManager) SyncCNS(chainID string, isFullSync bool) error {
+func (manager *ChainDataSyncManager) SyncCNS(chainID string, isFullSync bool) error {
 	logrus.Debugf("chain[%v] cns data sync [start]", chainID)
 	defer logrus.Debugf("chain[%v] cns data sync [end]", chainID)
 	chainSyncInfo, ok := manager.GetChainDataSyncInfo(chainID)
@@ -329,15 +435,18 @@ func (manager *chainDataSyncManager) SyncCNS(chainID string, isFullSync bool) er
 		chainSyncInfo.CNSDataSyncInfo = sncDataSyncInfo
 	}
 	sncDataSyncInfo.Status = StatusSyncing
-	allCNS, err := rpc.GetAllCNS(chainID)
+	allCNS, err := manager.rpcServer.GetAllCNS(chainID)
 	if err != nil {
 		sncDataSyncInfo.ErrMsg = err.Error()
 		return err
 	}
 	sncDataSyncInfo.Size = len(allCNS)
 	for i, cns := range allCNS {
+		if manager.ctx.Err() != nil {
+			return errSyncCanceled
+		}
 		sncDataSyncInfo.Index = i + 1
-		err = DefaultSyncer.saveCNS(*cns, isFullSync)
+		err = manager.syncer.saveCNS(*cns, isFullSync)
 		if err != nil {
 			return err
 		}
@@ -360,7 +469,7 @@ func (manager *chainDataSyncManager) SyncCNS(chainID string, isFullSync bool) er
 }
 
 // SyncNode 节点同步
-func (manager *chainDataSyncManager) SyncNode(chainID string, isFullSync bool) error {
+func (manager *ChainDataSyncManager) SyncNode(chainID string, isFullSync bool) error {
 	logrus.Debugf("chain[%v] node data sync [start]", chainID)
 	defer logrus.Debugf("chain[%v] node data sync [end]", chainID)
 	chainSyncInfo, ok := manager.GetChainDataSyncInfo(chainID)
@@ -381,14 +490,17 @@ func (manager *chainDataSyncManager) SyncNode(chainID string, isFullSync bool) e
 		chainSyncInfo.NodeDataSyncInfo = nodeDataSyncInfo
 	}
 	chainSyncInfo.Status = StatusSyncing
-	allNodes, err := rpc.GetAllNodes(chainID)
+	allNodes, err := manager.rpcServer.GetAllNodes(chainID)
 	if err != nil {
 		return err
 	}
 	nodeDataSyncInfo.Size = len(allNodes)
 	for i, node := range allNodes {
+		if manager.ctx.Err() != nil {
+			return errSyncCanceled
+		}
 		nodeDataSyncInfo.Index = i + 1
-		err = DefaultSyncer.saveNode(*node, isFullSync)
+		err = manager.syncer.saveNode(*node, isFullSync)
 		if err != nil {
 			return err
 		}
@@ -411,14 +523,18 @@ func (manager *chainDataSyncManager) SyncNode(chainID string, isFullSync bool) e
 }
 
 // 同步区块和交易
-func (manager *chainDataSyncManager) syncBlockBySyncInfo(chainID string, blockSyncInfo *model.BlockDataSyncInfo, isFullSync bool) error {
+func (manager *ChainDataSyncManager) syncBlockBySyncInfo(chainID string, blockSyncInfo *model.BlockDataSyncInfo, isFullSync bool) error {
 	if blockSyncInfo == nil {
 		return errors.New("blockSyncInfo must not be nil")
 	}
 	// TODO：需要处理可能因为中间数据同步出错而导致的区块数据不全问题
 	startHeight := blockSyncInfo.CurrentHeight
 	for ; blockSyncInfo.CurrentHeight <= blockSyncInfo.LatestHeight; blockSyncInfo.CurrentHeight++ {
-		err := DefaultSyncer.syncBlockByNumber(chainID, int64(blockSyncInfo.CurrentHeight), isFullSync)
+		// 停止时在当前区块写入完成后退出，下次增量同步从数据库中最高的区块继续
+		if manager.ctx.Err() != nil {
+			return errSyncCanceled
+		}
+		err := manager.syncer.syncBlockByNumber(chainID, int64(blockSyncInfo.CurrentHeight), isFullSync)
 		if err != nil {
 			//blockSyncInfo.ErrMsg = err.Error()
 			//return err
@@ -437,6 +553,7 @@ func (manager *chainDataSyncManager) syncBlockBySyncInfo(chainID string, blockSy
 		// 更新预计完成时间
 		blockSyncInfo.EstimateCompleteTime = time.Now().Unix() + int64(blockSyncInfo.LatestHeight-blockSyncInfo.CurrentHeight)*blockSyncInfo.BlockSyncTimeAvg
 		manager.setEstimateCompleteTime(chainID, blockSyncInfo.EstimateCompleteTime)
+		manager.notifyProgress(chainID, false)
 	}
 	// 正常执行完成时，当前高度会比最新区块的高度大 1
 	blockSyncInfo.CurrentHeight = blockSyncInfo.LatestHeight
@@ -444,13 +561,13 @@ func (manager *chainDataSyncManager) syncBlockBySyncInfo(chainID string, blockSy
 }
 
 // 设置预计完成时间
-func (manager *chainDataSyncManager) setEstimateCompleteTime(chainID string, estimateCompleteTime int64) {
+func (manager *ChainDataSyncManager) setEstimateCompleteTime(chainID string, estimateCompleteTime int64) {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
 	manager.syncInfoContainer[chainID].EstimateCompleteTime = manager.max(manager.syncInfoContainer[chainID].EstimateCompleteTime, estimateCompleteTime)
 }
 
-func (manager *chainDataSyncManager) max(a, b int64) int64 {
+func (manager *ChainDataSyncManager) max(a, b int64) int64 {
 	if a > b {
 		return a
 	}
@@ -458,7 +575,7 @@ func (manager *chainDataSyncManager) max(a, b int64) int64 {
 }
 
 // 数据同步错误处理和清理已经完成同步的记录信息
-func (manager *chainDataSyncManager) errProcessAndGC() {
+func (manager *ChainDataSyncManager) errProcessAndGC() {
 	defer func() {
 		if err := recover(); err != nil {
 			logrus.Errorf("unknown panic，errProcessAndGC：%+v", err)
@@ -466,8 +583,11 @@ func (manager *chainDataSyncManager) errProcessAndGC() {
 	}()
 
 	ticker := time.NewTicker(gcInterval * time.Second)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-manager.ctx.Done():
+			return
 		case syncErrMsg := <-manager.ErrChan:
 			logrus.Errorf("chain[%s] data sync fail: %+v", syncErrMsg.ChainID, syncErrMsg.Err)
 			manager.errProcess(syncErrMsg)
@@ -478,7 +598,7 @@ func (manager *chainDataSyncManager) errProcessAndGC() {
 }
 
 // 清理已经完成同步的记录信息，回收内存
-func (manager *chainDataSyncManager) gc() {
+func (manager *ChainDataSyncManager) gc() {
 	deleteKey := make([]string, 0)
 	for key, chainSyncInfo := range manager.syncInfoContainer {
 		// 数据同步已完成，且超过保存时间才删除
@@ -489,13 +609,14 @@ func (manager *chainDataSyncManager) gc() {
 	manager.lock.Lock()
 	for _, key := range deleteKey {
 		delete(manager.syncInfoContainer, key)
+		delete(manager.notifyTimes, key)
 		logrus.Infof("ChainInfoSyncManager GC: delete completed syncInfo [%v]", key)
 	}
 	manager.lock.Unlock()
 }
 
 // 错误处理
-func (manager *chainDataSyncManager) errProcess(syncErrMsg *model.SyncErrMsg) {
+func (manager *ChainDataSyncManager) errProcess(syncErrMsg *model.SyncErrMsg) {
 	syncInfo, ok := manager.GetChainDataSyncInfo(syncErrMsg.ChainID)
 	if !ok {
 		logrus.Infof("no sync error msg need to process for chain[%s]", syncErrMsg.ChainID)
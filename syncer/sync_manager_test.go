@@ -1,24 +1,40 @@
 package syncer
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"graces/model"
+
 	"github.com/stretchr/testify/assert"
 )
 
 func TestChainInfoSyncManager_IncrSyncStart(t *testing.T) {
-	DefaultChainDataSyncManager.IncrSyncStart(chainID, true)
+	testManager.IncrSyncStart(chainID, true)
 	time.Sleep(2 * time.Second)
-	info, _ := DefaultChainDataSyncManager.GetChainDataSyncInfo(chainID)
+	info, _ := testManager.GetChainDataSyncInfo(chainID)
 	t.Logf("%+v", info)
 	assert.True(t, info != nil && info.Status == StatusSuccess)
 }
 
 func TestChainDataSyncManager_FullSyncStart(t *testing.T) {
-	DefaultChainDataSyncManager.FullSyncStart(chainID, true)
+	testManager.FullSyncStart(chainID, true)
 	time.Sleep(2 * time.Second)
-	info, _ := DefaultChainDataSyncManager.GetChainDataSyncInfo(chainID)
+	info, _ := testManager.GetChainDataSyncInfo(chainID)
 	t.Logf("%+v", info)
 	assert.True(t, info != nil && info.Status == StatusSuccess)
 }
+
+func TestChainDataSyncManager_Stop(t *testing.T) {
+	manager := newTestManager()
+	manager.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.True(t, manager.Stop(ctx) == nil)
+
+	manager.IncrSyncStart(chainID, false)
+	_, ok := manager.GetChainDataSyncInfo(chainID)
+	assert.True(t, !ok)
+	manager.reportErr(&model.SyncErrMsg{ChainID: chainID, Err: errSyncCanceled})
+}
@@ -12,29 +12,21 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	DefaultSyncer *syncer
-)
-
-func init() {
-	DefaultSyncer = newSyncer()
-}
-
-func newSyncer() *syncer {
+func newSyncer(daos *dao.Daos, rpcServer *rpc.Server) *syncer {
 	return &syncer{
-		chainDao: dao.DefaultChainDao,
-		nodeDao:  dao.DefaultNodeDao,
+		daos:      daos,
+		rpcServer: rpcServer,
 	}
 }
 
 type syncer struct {
-	chainDao dao.IChainDao
-	nodeDao  dao.INodeDao
+	daos      *dao.Daos
+	rpcServer *rpc.Server
 }
 
 // BlockFullSync 区块全量同步
 func (s *syncer) BlockFullSync(chainID string) error {
-	latestBlock, err := rpc.GetLatestBlockFromChain(chainID)
+	latestBlock, err := s.rpcServer.GetLatestBlockFromChain(chainID)
 	if err != nil {
 		return err
 	}
@@ -62,7 +54,7 @@ func (s *syncer) BlockIncrSync(chainID string, curHeight uint64, targetHeight ui
 
 // 通过块高同步单个区块
 func (s *syncer) syncBlockByNumber(chainID string, number int64, isFullSync bool) error {
-	block, err := rpc.GetBlockByNumber(chainID, number)
+	block, err := s.rpcServer.GetBlockByNumber(chainID, number)
 	if err != nil {
 		return err
 	}
@@ -78,7 +70,7 @@ func (s *syncer) syncBlockByNumber(chainID string, number int64, isFullSync bool
 		"chain_id": cid,
 		"hash":     block.Hash,
 	}
-	dbBlock, err := dao.DefaultBlockDao.Block(filter)
+	dbBlock, err := s.daos.Block.Block(filter)
 	if err != nil {
 		return err
 	}
@@ -95,9 +87,9 @@ func (s *syncer) saveBlockData(block model.Block, isFullSync bool) error {
 	}
 	// 增量同步，对于不存在的数据则插入，对于已存在的数据则不做任何处理，因为链上的区块数据不会被修改
 	if !isFullSync {
-		dbBlock, err := dao.DefaultBlockDao.Block(filter)
+		dbBlock, err := s.daos.Block.Block(filter)
 		if err != nil || dbBlock.ID.IsZero() {
-			err = dao.DefaultBlockDao.InsertBlock(block)
+			err = s.daos.Block.InsertBlock(block)
 			if err != nil {
 				return err
 			}
@@ -123,7 +115,7 @@ func (s *syncer) saveBlockData(block model.Block, isFullSync bool) error {
 	updateOptions := options.Update()
 	upsert := true
 	updateOptions.Upsert = &upsert
-	err := dao.DefaultBlockDao.Update(filter, update, updateOptions)
+	err := s.daos.Block.Update(filter, update, updateOptions)
 	if err != nil {
 		return err
 	}
@@ -132,7 +124,7 @@ func (s *syncer) saveBlockData(block model.Block, isFullSync bool) error {
 
 // SyncCNS 同步 CNS 数据
 func (s *syncer) SyncCNS(chainID string, isFullSync bool) error {
-	allCNS, err := rpc.GetAllCNS(chainID)
+	allCNS, err := s.rpcServer.GetAllCNS(chainID)
 	if err != nil {
 		return err
 	}
@@ -157,9 +149,9 @@ func (s *syncer) saveCNS(cns model.CNS, isFullSync bool) error {
 	}
 	// 增量同步，对于不存在的数据则插入，对于已存在的数据则不做任何处理，因为链上的区块数据不会被修改
 	if !isFullSync {
-		dbCNS, err := dao.DefaultCNSDao.CNS(filter)
+		dbCNS, err := s.daos.CNS.CNS(filter)
 		if err != nil || dbCNS.ID.IsZero() {
-			err = dao.DefaultCNSDao.InsertCNS(cns)
+			err = s.daos.CNS.InsertCNS(cns)
 			if err != nil {
 				return err
 			}
@@ -178,7 +170,7 @@ func (s *syncer) saveCNS(cns model.CNS, isFullSync bool) error {
 	updateOptions := options.Update()
 	upsert := true
 	updateOptions.Upsert = &upsert
-	err := dao.DefaultCNSDao.Update(filter, update, updateOptions)
+	err := s.daos.CNS.Update(filter, update, updateOptions)
 	if err != nil {
 		return err
 	}
@@ -187,7 +179,7 @@ func (s *syncer) saveCNS(cns model.CNS, isFullSync bool) error {
 
 // SyncNode 同步节点数据
 func (s *syncer) SyncNode(chainID string, isFullSync bool) error {
-	nodes, err := rpc.GetAllNodes(chainID)
+	nodes, err := s.rpcServer.GetAllNodes(chainID)
 	if err != nil {
 		return err
 	}
@@ -203,7 +195,7 @@ func (s *syncer) SyncNode(chainID string, isFullSync bool) error {
 // 保存 node 数据入库
 // 节点状态是会变化的，所以无论是增量还是全量更新都要更新节点数据
 func (s *syncer) saveNode(node model.Node, isFullSync bool) error {
-	chain, err := dao.DefaultChainDao.Chain(bson.M{"_id": node.ChainID})
+	chain, err := s.daos.Chain.Chain(bson.M{"_id": node.ChainID})
 	if err != nil {
 		return err
 	}
@@ -230,7 +222,7 @@ func (s *syncer) saveNode(node model.Node, isFullSync bool) error {
 	updateOptions := options.Update()
 	updateOptions.Upsert = &upsert
 	// 数据存在则更新，不存在则插入
-	err = dao.DefaultNodeDao.Update(find, update, updateOptions)
+	err = s.daos.Node.Update(find, update, updateOptions)
 	if err != nil {
 		return err
 	}
@@ -239,7 +231,7 @@ func (s *syncer) saveNode(node model.Node, isFullSync bool) error {
 
 // 通过块高同步区块内的交易数据
 func (s *syncer) syncTXDataByBlockNumber(chainID string, blockID string, blockNumber int64, isFullSync bool) error {
-	txs, err := rpc.GetTXDataByBlockNumber(chainID, blockID, blockNumber)
+	txs, err := s.rpcServer.GetTXDataByBlockNumber(chainID, blockID, blockNumber)
 	if err != nil {
 		return err
 	}
@@ -262,10 +254,10 @@ func (s *syncer) saveTXData(tx model.TX, isFullSync bool) error {
 	}
 	// 增量同步，对于不存在的数据则插入，对于已存在的数据则不做任何处理，因为链上的区块数据不会被修改
 	if !isFullSync {
-		dbTX, err := dao.DefaultTXDao.TX(filter)
+		dbTX, err := s.daos.TX.TX(filter)
 		// 如果数据不存在则插入新的数据
 		if err != nil || dbTX.ID.IsZero() {
-			err = dao.DefaultTXDao.InsertTX(tx)
+			err = s.daos.TX.InsertTX(tx)
 			if err != nil {
 				return err
 			}
@@ -299,7 +291,7 @@ func (s *syncer) saveTXData(tx model.TX, isFullSync bool) error {
 	updateOptions := options.Update()
 	upsert := true
 	updateOptions.Upsert = &upsert
-	err := dao.DefaultTXDao.Update(filter, update, updateOptions)
+	err := s.daos.TX.Update(filter, update, updateOptions)
 	if err != nil {
 		return err
 	}
@@ -325,10 +317,10 @@ func (s *syncer) saveContractData(tx model.TX, isFullSync bool) error {
 			"tx_hash":  contract.TxHash,
 			"address":  contract.Address,
 		}
-		dbContract, err := dao.DefaultContractDao.Contract(filter)
+		dbContract, err := s.daos.Contract.Contract(filter)
 		// 如果数据不存在则插入新的数据
 		if err != nil || dbContract.ID.IsZero() {
-			err = dao.DefaultContractDao.InsertContract(*contract)
+			err = s.daos.Contract.InsertContract(*contract)
 			if err != nil {
 				return err
 			}
@@ -352,7 +344,7 @@ func (s *syncer) saveContractData(tx model.TX, isFullSync bool) error {
 	updateOptions := options.Update()
 	upsert := true
 	updateOptions.Upsert = &upsert
-	err := dao.DefaultContractDao.Update(filter, update, updateOptions)
+	err := s.daos.Contract.Update(filter, update, updateOptions)
 	if err != nil {
 		return err
 	}
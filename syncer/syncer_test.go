@@ -1,7 +1,6 @@
 package syncer
 
 import (
-	"graces/rpc"
 	"log"
 	"testing"
 	"time"
@@ -14,32 +13,32 @@ var (
 )
 
 func Test_Init(t *testing.T) {
-	log.Printf("DefaultSyncer: %+v", DefaultSyncer)
-	assert.True(t, DefaultSyncer != nil)
+	log.Printf("testSyncer: %+v", testSyncer)
+	assert.True(t, testSyncer != nil)
 	time.Sleep(5 * time.Second)
 }
 
 func TestSyncer_BlockSyncIncr(t *testing.T) {
-	block, err := rpc.GetLatestBlockFromChain(chainID)
+	block, err := testSyncer.rpcServer.GetLatestBlockFromChain(chainID)
 	assert.True(t, err == nil)
 	if block == nil {
 		return
 	}
-	err = DefaultSyncer.BlockIncrSync(chainID, 0, block.NumberU64())
+	err = testSyncer.BlockIncrSync(chainID, 0, block.NumberU64())
 	assert.True(t, err == nil)
 }
 
 func TestSyncer_BlockSyncFull(t *testing.T) {
-	err := DefaultSyncer.BlockFullSync(chainID)
+	err := testSyncer.BlockFullSync(chainID)
 	assert.True(t, err == nil)
 }
 
 func TestSyncer_SyncNode(t *testing.T) {
-	err := DefaultSyncer.SyncNode(chainID, true)
+	err := testSyncer.SyncNode(chainID, true)
 	assert.True(t, err == nil)
 }
 
 func TestSyncer_SyncCNS(t *testing.T) {
-	err := DefaultSyncer.SyncCNS(chainID, true)
+	err := testSyncer.SyncCNS(chainID, true)
 	assert.True(t, err == nil)
 }
@@ -0,0 +1,327 @@
+package txpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"graces/cluster"
+	"graces/config"
+	"graces/model"
+	"graces/rpc"
+	"graces/web/dao"
+
+	"github.com/Venachain/Venachain/common/hexutil"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// 单次拉取交易池的超时时间
+	pollTimeout = 5 * time.Second
+	// 等待立即拉取的链的队列长度
+	refreshQueueSize = 64
+)
+
+// Listener 交易池变化监听器
+type Listener func(chainID string, event *model.TxPoolEvent)
+
+// NewTxPool 创建交易池，多实例部署时由 elector 判断当前实例是否为链的 leader
+func NewTxPool(chainDao dao.IChainDao, elector cluster.IElector) *TxPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TxPool{
+		chainDao: chainDao,
+		elector:  elector,
+		pools:    make(map[string]*chainPool),
+		live:     make(map[string]bool),
+		inflight: make(map[string]bool),
+		refresh:  make(chan string, refreshQueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// 单条链的交易池
+type chainPool struct {
+	txs map[string]*model.PendingTX
+	// 最近一次拉取成功的时间，单位：秒
+	updateTime int64
+}
+
+// TxPool 交易池，每个实例都定时拉取节点的交易池，与上一次的结果比较得到增加和移除的交易，
+// 多实例部署时只由链的 leader 通知监听器，避免重复推送
+type TxPool struct {
+	chainDao  dao.IChainDao
+	elector   cluster.IElector
+	lock      sync.RWMutex
+	pools     map[string]*chainPool
+	listeners []Listener
+	// 仍然存在的链ID，已删除的链在拉取结束后不再更新交易池，也不再通知监听器
+	live map[string]bool
+	// 正在拉取的链ID，上一次拉取还没有结束的链跳过本次拉取
+	inflight map[string]bool
+	// 需要立即拉取的链ID，如链上出了新区块
+	refresh chan string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// Run 启动定时拉取交易池的协程，拉取间隔为 0 时不启动
+func (p *TxPool) Run() {
+	interval := config.Config.TxPoolConf.PollInterval()
+	if interval <= 0 {
+		logrus.Infof("txpool polling disabled")
+		return
+	}
+	p.running.Add(1)
+	go p.loop(interval)
+}
+
+// Stop 停止拉取交易池并等待当前的拉取结束，ctx 超时则不再等待
+func (p *TxPool) Stop(ctx context.Context) error {
+	p.cancel()
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logrus.Infof("txpool stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for txpool to stop: %w", ctx.Err())
+	}
+}
+
+// AddListener 添加交易池变化监听器，需要在 Run 之前调用
+func (p *TxPool) AddListener(listener Listener) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.listeners = append(p.listeners, listener)
+}
+
+// Pending 获取链交易池中的交易，按首次发现的时间排序，ok 为 false 表示还没有拉取成功过
+func (p *TxPool) Pending(chainID string) (txs []*model.PendingTX, updateTime int64, ok bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	pool, ok := p.pools[chainID]
+	if !ok {
+		return nil, 0, false
+	}
+	txs = make([]*model.PendingTX, 0, len(pool.txs))
+	for _, tx := range pool.txs {
+		txs = append(txs, tx)
+	}
+	sortPendingTXs(txs)
+	return txs, pool.updateTime, true
+}
+
+// Refresh 请求立即拉取链的交易池，队列已满时忽略
+func (p *TxPool) Refresh(chainID string) {
+	select {
+	case p.refresh <- chainID:
+	default:
+	}
+}
+
+func (p *TxPool) loop(interval time.Duration) {
+	defer p.running.Done()
+	defer func() {
+		if err := recover(); err != nil {
+			logrus.Errorf("unknown panic，txpool loop：%+v", err)
+		}
+	}()
+
+	logrus.Infof("txpool polling [start], poll interval: [%v/once]", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			logrus.Infof("txpool polling [stop]")
+			return
+		case <-ticker.C:
+			p.pollAll()
+		case chainID := <-p.refresh:
+			p.pollChain(chainID)
+		}
+	}
+}
+
+// 并发拉取所有链的交易池，已删除的链不再维护
+func (p *TxPool) pollAll() {
+	findOps := options.Find().SetProjection(bson.D{{"_id", 1}, {"ip", 1}, {"rpc_port", 1}})
+	chains, err := p.chainDao.Chains(bson.M{}, findOps)
+	if err != nil {
+		logrus.Warningf("txpool list chains err: %v", err)
+		return
+	}
+	ids := make(map[string]bool, len(chains))
+	for _, chain := range chains {
+		ids[chain.ID.Hex()] = true
+	}
+	p.lock.Lock()
+	p.live = ids
+	for chainID := range p.pools {
+		if !ids[chainID] {
+			delete(p.pools, chainID)
+		}
+	}
+	p.lock.Unlock()
+
+	for _, chain := range chains {
+		p.startPoll(*chain)
+	}
+}
+
+func (p *TxPool) pollChain(chainID string) {
+	objectID, err := primitive.ObjectIDFromHex(chainID)
+	if err != nil {
+		return
+	}
+	chain, err := p.chainDao.Chain(bson.M{"_id": objectID})
+	if err != nil {
+		logrus.Debugf("txpool get chain[%s] err: %v", chainID, err)
+		return
+	}
+	p.startPoll(*chain)
+}
+
+// 在新的协程中拉取链的交易池，链的上一次拉取还没有结束时跳过，避免响应慢的节点拖慢其他链或堆积请求
+// chain 为刚从存储中查询到的链，同时记录为仍然存在的链
+func (p *TxPool) startPoll(chain model.Chain) {
+	chainID := chain.ID.Hex()
+	p.lock.Lock()
+	if p.inflight[chainID] {
+		p.lock.Unlock()
+		logrus.Debugf("chain[%s] txpool poll still in flight, skip", chainID)
+		return
+	}
+	p.inflight[chainID] = true
+	p.live[chainID] = true
+	p.lock.Unlock()
+
+	p.running.Add(1)
+	go func() {
+		defer p.running.Done()
+		defer func() {
+			p.lock.Lock()
+			delete(p.inflight, chainID)
+			p.lock.Unlock()
+		}()
+		defer func() {
+			if err := recover(); err != nil {
+				logrus.Errorf("unknown panic，chain[%s] txpool poll：%+v", chainID, err)
+			}
+		}()
+		p.poll(chain)
+	}()
+}
+
+// 拉取链第一个节点的交易池
+func (p *TxPool) poll(chain model.Chain) {
+	ctx, cancel := context.WithTimeout(p.ctx, pollTimeout)
+	defer cancel()
+	txs, err := rpc.PendingTransactions(ctx, chain)
+	if err != nil && !errors.Is(err, rpc.ErrJSONRPCEmptyResult) {
+		logrus.Debugf("chain[%s] fetch pending transactions err: %v", chain.ID.Hex(), err)
+		return
+	}
+	p.update(chain.ID.Hex(), txs, time.Now().Unix())
+}
+
+// 用最新拉取的交易替换链的交易池，返回增加和移除交易的事件，拉取期间链已被删除时忽略拉取结果
+func (p *TxPool) update(chainID string, txs []*rpc.RPCTransaction, now int64) []*model.TxPoolEvent {
+	p.lock.Lock()
+	if !p.live[chainID] {
+		p.lock.Unlock()
+		logrus.Debugf("chain[%s] was deleted during txpool poll, skip", chainID)
+		return nil
+	}
+	pool, ok := p.pools[chainID]
+	if !ok {
+		pool = &chainPool{txs: make(map[string]*model.PendingTX)}
+		p.pools[chainID] = pool
+	}
+	next := make(map[string]*model.PendingTX, len(txs))
+	var added, removed []*model.PendingTX
+	for _, tx := range txs {
+		if tx == nil || tx.Hash == "" {
+			continue
+		}
+		if old, ok := pool.txs[tx.Hash]; ok {
+			next[tx.Hash] = old
+			continue
+		}
+		pending := toPendingTX(chainID, tx, now)
+		next[tx.Hash] = pending
+		added = append(added, pending)
+	}
+	for hash, old := range pool.txs {
+		if _, ok := next[hash]; !ok {
+			removed = append(removed, old)
+		}
+	}
+	pool.txs = next
+	pool.updateTime = now
+	listeners := make([]Listener, len(p.listeners))
+	copy(listeners, p.listeners)
+	p.lock.Unlock()
+
+	sortPendingTXs(removed)
+	events := make([]*model.TxPoolEvent, 0, len(added)+len(removed))
+	for _, tx := range added {
+		events = append(events, &model.TxPoolEvent{Action: model.TxPoolActionAdd, TX: tx})
+	}
+	for _, tx := range removed {
+		events = append(events, &model.TxPoolEvent{Action: model.TxPoolActionRemove, TX: tx})
+	}
+	// 多实例部署时只由链的 leader 通知
+	if len(events) == 0 || !p.elector.IsLeader(cluster.ChainKey(chainID)) {
+		return events
+	}
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(chainID, event)
+		}
+	}
+	return events
+}
+
+func toPendingTX(chainID string, tx *rpc.RPCTransaction, now int64) *model.PendingTX {
+	pending := &model.PendingTX{
+		ChainID:   chainID,
+		Hash:      tx.Hash,
+		From:      tx.From,
+		To:        tx.To,
+		Input:     strings.TrimPrefix(tx.Input, "0x"),
+		FirstSeen: now,
+	}
+	pending.GasLimit, _ = hexutil.DecodeUint64(tx.Gas)
+	pending.GasPrice, _ = hexutil.DecodeUint64(tx.GasPrice)
+	if nonce, err := hexutil.DecodeUint64(tx.Nonce); err == nil {
+		pending.Nonce = strconv.FormatUint(nonce, 10)
+	}
+	if value, err := hexutil.DecodeBig(tx.Value); err == nil {
+		pending.Value = value.Uint64()
+	}
+	return pending
+}
+
+func sortPendingTXs(txs []*model.PendingTX) {
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].FirstSeen != txs[j].FirstSeen {
+			return txs[i].FirstSeen < txs[j].FirstSeen
+		}
+		return txs[i].Hash < txs[j].Hash
+	})
+}
@@ -0,0 +1,153 @@
+package txpool
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"graces/cluster"
+	"graces/fakechain"
+	"graces/model"
+	"graces/web/dao"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTxPool_Poll(t *testing.T) {
+	node, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	chain := node.Chain("chain-txpool")
+	chainID := chain.ID.Hex()
+
+	pool := newTestTxPool(t)
+	var events []*model.TxPoolEvent
+	pool.AddListener(func(id string, event *model.TxPoolEvent) {
+		assert.Equal(t, chainID, id)
+		events = append(events, event)
+	})
+	_, _, ok := pool.Pending(chainID)
+	assert.False(t, ok)
+	poll := func() {
+		pool.startPoll(*chain)
+		pool.running.Wait()
+	}
+
+	// 新进入交易池的交易通知 add
+	hash, err := node.SendTx(fakechain.TxSpec{To: "0x0000000000000000000000000000000000000011", Value: 7})
+	assert.True(t, err == nil)
+	poll()
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, model.TxPoolActionAdd, events[0].Action)
+	assert.Equal(t, hash.Hex(), events[0].TX.Hash)
+	assert.Equal(t, uint64(7), events[0].TX.Value)
+	txs, updateTime, ok := pool.Pending(chainID)
+	assert.True(t, ok)
+	assert.True(t, updateTime > 0)
+	assert.Equal(t, 1, len(txs))
+
+	// 交易池没有变化时不通知
+	poll()
+	assert.Equal(t, 1, len(events))
+
+	// 打包后的交易通知 remove
+	_, err = node.Mine()
+	assert.True(t, err == nil)
+	poll()
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, model.TxPoolActionRemove, events[1].Action)
+	assert.Equal(t, hash.Hex(), events[1].TX.Hash)
+	txs, _, _ = pool.Pending(chainID)
+	assert.Equal(t, 0, len(txs))
+}
+
+func TestTxPool_PollAllSkipsInFlight(t *testing.T) {
+	slow, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer slow.Close()
+	fast, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer fast.Close()
+	release := make(chan struct{})
+	slow.Handle("eth_pendingTransactions", func(params []json.RawMessage) (interface{}, error) {
+		<-release
+		return []interface{}{}, nil
+	})
+
+	pool := newTestTxPool(t)
+	slowChain := slow.Chain("chain-slow")
+	fastChain := fast.Chain("chain-fast")
+	assert.True(t, pool.chainDao.InsertChain(*slowChain) == nil)
+	assert.True(t, pool.chainDao.InsertChain(*fastChain) == nil)
+	inflight := func(chainID string) bool {
+		pool.lock.RLock()
+		defer pool.lock.RUnlock()
+		return pool.inflight[chainID]
+	}
+
+	// 响应慢的链不会阻塞其他链的拉取
+	pool.pollAll()
+	waitFor(t, func() bool { return slow.Calls("eth_pendingTransactions") == 1 && !inflight(fastChain.ID.Hex()) })
+	_, _, ok := pool.Pending(fastChain.ID.Hex())
+	assert.True(t, ok)
+
+	// 上一次拉取还没有结束的链跳过本次拉取
+	pool.pollAll()
+	waitFor(t, func() bool { return fast.Calls("eth_pendingTransactions") == 2 && !inflight(fastChain.ID.Hex()) })
+	assert.Equal(t, 1, slow.Calls("eth_pendingTransactions"))
+	assert.True(t, inflight(slowChain.ID.Hex()))
+
+	close(release)
+	pool.running.Wait()
+	assert.False(t, inflight(slowChain.ID.Hex()))
+	_, _, ok = pool.Pending(slowChain.ID.Hex())
+	assert.True(t, ok)
+}
+
+func TestTxPool_SkipDeletedChain(t *testing.T) {
+	node, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	release := make(chan struct{})
+	node.Handle("eth_pendingTransactions", func(params []json.RawMessage) (interface{}, error) {
+		<-release
+		return []interface{}{}, nil
+	})
+
+	pool := newTestTxPool(t)
+	events := 0
+	pool.AddListener(func(id string, event *model.TxPoolEvent) {
+		events++
+	})
+	chain := node.Chain("chain-deleted")
+	assert.True(t, pool.chainDao.InsertChain(*chain) == nil)
+	pool.pollAll()
+	waitFor(t, func() bool { return node.Calls("eth_pendingTransactions") == 1 })
+
+	// 拉取期间链被删除，拉取结束后不再创建交易池
+	_, err = pool.chainDao.DeleteMany(bson.M{"_id": chain.ID})
+	assert.True(t, err == nil)
+	pool.pollAll()
+	close(release)
+	pool.running.Wait()
+	_, _, ok := pool.Pending(chain.ID.Hex())
+	assert.False(t, ok)
+	assert.Equal(t, 0, events)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestTxPool(t *testing.T) *TxPool {
+	c, err := cluster.New(nil)
+	assert.True(t, err == nil)
+	return NewTxPool(dao.NewMemoryDaos().Chain, c.Elector)
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -14,6 +16,15 @@ import (
 
 const (
 	TimeTemplate = "2006-01-02 15:04:05"
+
+	// RedactedValue 脱敏后的字段值
+	RedactedValue = "******"
+)
+
+var (
+	// 参数名（转小写并去掉 _ 和 - 后）包含这些词的字段视为敏感字段
+	sensitiveWords = []string{"password", "passwd", "secret", "seckey", "privatekey", "mnemonic", "keystore", "token", "apikey"}
+	txHashRegexp   = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
 )
 
 // SimpleCopyProperties 反射浅拷贝
@@ -165,3 +176,124 @@ func JsonStringPatch(value interface{}) (interface{}, error) {
 	}
 	return value, nil
 }
+
+// 参数名归一化：转小写并去掉 _ 和 -，使 chain_id、chainID、chain-id 等写法一致
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// RedactParams 复制参数并把其中的敏感字段替换为 RedactedValue，嵌套的 map 和数组也会处理
+func RedactParams(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if isSensitiveKey(k) {
+			res[k] = RedactedValue
+			continue
+		}
+		res[k] = redactValue(v)
+	}
+	return res
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return RedactParams(val)
+	case []interface{}:
+		res := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			res = append(res, redactValue(item))
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	k := normalizeKey(key)
+	for _, word := range sensitiveWords {
+		if strings.Contains(k, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChainIDField 从请求参数的顶层字段 field 中获取链ID，字段名与 encoding/json 一样不区分大小写，
+// field 应与 handler 绑定的 json tag 一致。参数中有多个 chain_id、chainid、chainID 等写法的字段时 ok 为 false，
+// 此时无法确定 handler 实际使用的链，调用方应拒绝该请求
+func ChainIDField(params map[string]interface{}, field string) (id string, ok bool) {
+	keys := 0
+	for k, v := range params {
+		if normalizeKey(k) != "chainid" {
+			continue
+		}
+		keys++
+		if s, isString := v.(string); isString && field != "" && strings.EqualFold(k, field) {
+			id = s
+		}
+	}
+	if keys > 1 {
+		return "", false
+	}
+	return id, true
+}
+
+// FindTxHash 在接口返回的数据中查找交易哈希：优先取 tx_hash、txHash、transactionHash 等字段，
+// 其次取第一个形如交易哈希的字符串
+func FindTxHash(data interface{}) string {
+	if hash := findTxHashByKey(data); hash != "" {
+		return hash
+	}
+	return findTxHashByValue(data)
+}
+
+func findTxHashByKey(data interface{}) string {
+	switch val := data.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			key := normalizeKey(k)
+			if hash, ok := v.(string); ok && hash != "" && (key == "txhash" || key == "transactionhash") {
+				return hash
+			}
+		}
+		for _, v := range val {
+			if hash := findTxHashByKey(v); hash != "" {
+				return hash
+			}
+		}
+	case []interface{}:
+		for _, v := range val {
+			if hash := findTxHashByKey(v); hash != "" {
+				return hash
+			}
+		}
+	}
+	return ""
+}
+
+func findTxHashByValue(data interface{}) string {
+	switch val := data.(type) {
+	case string:
+		if txHashRegexp.MatchString(val) {
+			return val
+		}
+	case map[string]interface{}:
+		for _, v := range val {
+			if hash := findTxHashByValue(v); hash != "" {
+				return hash
+			}
+		}
+	case []interface{}:
+		for _, v := range val {
+			if hash := findTxHashByValue(v); hash != "" {
+				return hash
+			}
+		}
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,3 +34,48 @@ func TestSimpleCopyProperties(t *testing.T) {
 	t.Log(err)
 	assert.True(t, err == nil)
 }
+
+func TestRedactParams(t *testing.T) {
+	params := map[string]interface{}{
+		"chain_id": "61bc0c6e5a9f8b1f2c3d4e5f",
+		"account":  "0x0000000000000000000000000000000000000001",
+		"password": "123456",
+		"nodes": []interface{}{
+			map[string]interface{}{"ip": "127.0.0.1", "privateKey": "abc"},
+		},
+	}
+	res := RedactParams(params)
+	assert.Equal(t, RedactedValue, res["password"])
+	assert.Equal(t, params["account"], res["account"])
+	node := res["nodes"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, RedactedValue, node["privateKey"])
+	assert.Equal(t, "127.0.0.1", node["ip"])
+	// 不修改原参数
+	assert.Equal(t, "123456", params["password"])
+}
+
+func TestChainIDField(t *testing.T) {
+	id, ok := ChainIDField(map[string]interface{}{"chain_id": "a", "account": "0x01"}, "chain_id")
+	assert.True(t, ok)
+	assert.Equal(t, "a", id)
+	// 字段名与 encoding/json 一样不区分大小写，其他写法的字段不会被 handler 绑定
+	id, ok = ChainIDField(map[string]interface{}{"CHAINID": "a"}, "chainID")
+	assert.True(t, ok)
+	assert.Equal(t, "a", id)
+	id, ok = ChainIDField(map[string]interface{}{"chain_id": "a"}, "chainID")
+	assert.True(t, ok)
+	assert.Equal(t, "", id)
+	// 有多个链ID字段时无法确定 handler 使用的链
+	_, ok = ChainIDField(map[string]interface{}{"chainid": "a", "chain_id": "b"}, "chain_id")
+	assert.False(t, ok)
+	_, ok = ChainIDField(map[string]interface{}{"chainID": "a", "chainid": "b"}, "")
+	assert.False(t, ok)
+}
+
+func TestFindTxHash(t *testing.T) {
+	hash := "0x" + strings.Repeat("ab", 32)
+	assert.Equal(t, hash, FindTxHash(map[string]interface{}{"status": "ok", "tx_hash": hash}))
+	assert.Equal(t, hash, FindTxHash([]interface{}{map[string]interface{}{"result": hash}}))
+	assert.Equal(t, "", FindTxHash("0x1234"))
+	assert.Equal(t, "", FindTxHash(nil))
+}
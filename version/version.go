@@ -0,0 +1,31 @@
+package version
+
+import "runtime"
+
+// 构建信息，编译时通过 ldflags 注入，如：
+// go build -ldflags "-X graces/version.GitCommit=$(git rev-parse HEAD) -X graces/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	// GitCommit 构建时的 git commit
+	GitCommit = "unknown"
+	// BuildTime 构建时间
+	BuildTime = "unknown"
+)
+
+// Info 构建信息
+type Info struct {
+	// 构建时的 git commit
+	GitCommit string `json:"git_commit"`
+	// 构建时间
+	BuildTime string `json:"build_time"`
+	// 编译使用的 Go 版本
+	GoVersion string `json:"go_version"`
+}
+
+// Get 获取当前程序的构建信息
+func Get() Info {
+	return Info{
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -9,16 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	DefaultAccountController *AccountController
-)
-
-func init() {
-	DefaultAccountController = newAccountController()
-}
-
-func newAccountController() *AccountController {
-	return &AccountController{service: service.DefaultAccountService}
+func newAccountController(services *service.Services) *AccountController {
+	return &AccountController{service: services.Account}
 }
 
 //LockAccount go doc
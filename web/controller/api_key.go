@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"graces/exterr"
+	"graces/middleware"
+	"graces/model"
+	"graces/web/service"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAPIKeyController(services *service.Services) *APIKeyController {
+	return &APIKeyController{service: services.APIKey}
+}
+
+// 获取登录用户，API Key 只能由登录用户本人管理，不能再用 API Key 管理 API Key
+func (c *APIKeyController) loginUser(ctx *gin.Context) (*model.User, bool) {
+	if _, ok := middleware.GetAPIKey(ctx); ok {
+		response.ErrorHandler(ctx, exterr.ErrUserHasNoPermission)
+		return nil, false
+	}
+	user, ok := middleware.GetUser(ctx)
+	if !ok {
+		response.ErrorHandler(ctx, exterr.ErrUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+//CreateAPIKey go doc
+//@Summary 创建 API Key
+//@Description 为当前用户创建 API Key，授权范围不能超出用户自己的权限，完整的 key 只在创建时返回一次，请求时放在 X-API-Key 请求头中
+//@Tags API Key
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.APIKeyDTO true "创建 API Key DTO"
+//@Success 200 {object} model.Result{data=model.APIKeyCreatedVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/apikey [post]
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	result := model.Result{}
+	user, ok := c.loginUser(ctx)
+	if !ok {
+		return
+	}
+	// 数据绑定
+	dto := model.APIKeyDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	data, e := c.service.CreateAPIKey(user, dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = data
+	response.Success(ctx, result)
+	return
+}
+
+//APIKeys go doc
+//@Summary 查询 API Key
+//@Description 查询当前用户的所有 API Key
+//@Tags API Key
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Success 200 {object} model.Result{data=[]model.APIKeyVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/apikeys [get]
+func (c *APIKeyController) APIKeys(ctx *gin.Context) {
+	result := model.Result{}
+	user, ok := c.loginUser(ctx)
+	if !ok {
+		return
+	}
+	data, e := c.service.APIKeys(user)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = data
+	response.Success(ctx, result)
+	return
+}
+
+//RevokeAPIKey go doc
+//@Summary 吊销 API Key
+//@Description 吊销当前用户的 API Key，吊销后立即失效
+//@Tags API Key
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.APIKeyRevokeDTO true "吊销 API Key DTO"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/apikey/revoke [post]
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	result := model.Result{}
+	user, ok := c.loginUser(ctx)
+	if !ok {
+		return
+	}
+	// 数据绑定
+	dto := model.APIKeyRevokeDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	if e := c.service.RevokeAPIKey(user, dto.ID); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/web/service"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func newAuditController(services *service.Services) *AuditController {
+	return &AuditController{service: services.Audit}
+}
+
+//AuditEvents go doc
+//@Summary 查询审计日志
+//@Description 按操作人、链、操作名称、操作结果和时间范围分页查询修改状态的操作记录
+//@Tags 审计日志
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param condition body model.AuditQueryCondition true "审计日志查询条件"
+//@Success 200 {object} model.Result{data=model.PageInfo{items=[]model.AuditEventVO}} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/audit [post]
+func (c *AuditController) AuditEvents(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.AuditQueryCondition{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	items, e := c.service.AuditEvents(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	total, e := c.service.Count(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	pageInfo := &model.PageInfo{}
+	pageData, e := pageInfo.Build(dto.PageDTO, items, total)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = pageData
+	response.Success(ctx, result)
+	return
+}
+
+//Export go doc
+//@Summary 导出审计日志
+//@Description 按条件导出审计日志为 CSV 文件，忽略分页参数
+//@Tags 审计日志
+//@version 1.0
+//@Accept json
+//@Produce  text/csv
+//@Param condition body model.AuditQueryCondition true "审计日志查询条件"
+//@Success 200 {file} file CSV 文件
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/audit/export [post]
+func (c *AuditController) Export(ctx *gin.Context) {
+	// 数据绑定
+	dto := model.AuditQueryCondition{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	filename := fmt.Sprintf("audit_events_%s.csv", time.Now().Format("20060102150405"))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	if e := c.service.ExportCSV(dto, ctx.Writer); e != nil {
+		// 响应头已经写出，只能记录错误
+		logrus.Errorf("export audit events err: %v", e)
+	}
+	return
+}
@@ -9,17 +9,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	DefaultBlockController *BlockController
-)
-
-func init() {
-	DefaultBlockController = newBlockController()
-}
-
-func newBlockController() *BlockController {
+func newBlockController(services *service.Services) *BlockController {
 	return &BlockController{
-		service: service.DefaultBlockService,
+		service: services.Block,
 	}
 }
 
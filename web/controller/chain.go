@@ -3,6 +3,7 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"graces/exterr"
@@ -17,17 +18,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	DefaultChainController *ChainController
-)
-
-func init() {
-	DefaultChainController = newChainController()
-}
-
-func newChainController() *ChainController {
+func newChainController(services *service.Services, syncManager *syncer.ChainDataSyncManager,
+	subscriber *ws.WSSubscriber, deploy *ws.Deploy) *ChainController {
 	return &ChainController{
-		service: service.DefaultChainService,
+		service:        services.Chain,
+		accountService: services.Account,
+		syncManager:    syncManager,
+		subscriber:     subscriber,
+		deploy:         deploy,
 	}
 }
 
@@ -59,6 +57,73 @@ func (c *ChainController) InsertChain(ctx *gin.Context) {
 	return
 }
 
+//UpdateChain godoc
+//@Summary 更新链信息
+//@Description 更新链信息，链地址或配置变更后会重新连接链并重新订阅 websocket 事件
+//@Tags 链信息管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param id path string true "id" "链信息id"
+//@Param chainDTO body model.ChainDTO true "链信息"
+//@Success 200 {object} model.Result 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/chain/{id} [put]
+func (c *ChainController) UpdateChain(ctx *gin.Context) {
+	result := model.Result{}
+	id := ctx.Param("id")
+	if len(id) == 0 {
+		response.ErrorHandler(ctx, exterr.ErrParameterInvalid)
+		return
+	}
+	// 数据绑定
+	dto := model.ChainDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+
+	if e := c.service.UpdateChain(id, dto); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	response.Success(ctx, result)
+	return
+}
+
+//DeleteChain godoc
+//@Summary 删除链信息
+//@Description 软删除链信息并断开链的 websocket 订阅，purge 为 true 时同时物理删除链的区块、交易、合约、CNS 和节点数据
+//@Tags 链信息管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param id path string true "id" "链信息id"
+//@Param purge query bool false "是否物理删除链及其数据"
+//@Success 200 {object} model.Result 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/chain/{id} [delete]
+func (c *ChainController) DeleteChain(ctx *gin.Context) {
+	result := model.Result{}
+	id := ctx.Param("id")
+	if len(id) == 0 {
+		response.ErrorHandler(ctx, exterr.ErrParameterInvalid)
+		return
+	}
+	purge, e := strconv.ParseBool(ctx.DefaultQuery("purge", "false"))
+	if e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+
+	if e := c.service.DeleteChain(id, purge); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	response.Success(ctx, result)
+	return
+}
+
 //ChainById go doc
 //@Summary 查询链信息
 //@Description 通过 id 查询链信息
@@ -182,7 +247,7 @@ func (c *ChainController) IncrSyncStart(ctx *gin.Context) {
 		response.Fail(ctx, result)
 		return
 	}
-	syncer.DefaultChainDataSyncManager.IncrSyncStart(chainID, true)
+	c.syncManager.IncrSyncStart(chainID, true)
 	result.Data = chainID
 	response.Success(ctx, result)
 	return
@@ -213,7 +278,7 @@ func (c *ChainController) FullSyncStart(ctx *gin.Context) {
 		response.Fail(ctx, result)
 		return
 	}
-	syncer.DefaultChainDataSyncManager.FullSyncStart(chainID, true)
+	c.syncManager.FullSyncStart(chainID, true)
 	result.Data = chainID
 	response.Success(ctx, result)
 	return
@@ -237,7 +302,7 @@ func (c *ChainController) ChainDataSyncInfo(ctx *gin.Context) {
 		response.ErrorHandler(ctx, exterr.ErrParameterInvalid)
 		return
 	}
-	info, ok := syncer.DefaultChainDataSyncManager.GetChainDataSyncInfo(chainID)
+	info, ok := c.syncManager.GetChainDataSyncInfo(chainID)
 	if !ok {
 		result.Data = nil
 		response.Success(ctx, result)
@@ -253,6 +318,35 @@ func (c *ChainController) ChainDataSyncInfo(ctx *gin.Context) {
 	return
 }
 
+//ChainSubscription go doc
+//@Summary 链的 websocket 订阅状态
+//@Description 查询链的 websocket topics 订阅状态，链新增、修改、删除后订阅器会立即订阅或取消订阅
+//@Tags 链信息管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param chainid path string true "chainid" "链ID"
+//@Success 200 {object} model.Result{data=model.ChainSubscriptionVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/chain/subscription/{chainid} [GET]
+func (c *ChainController) ChainSubscription(ctx *gin.Context) {
+	result := model.Result{}
+	chainID := ctx.Param("chainid")
+	if len(chainID) == 0 {
+		response.ErrorHandler(ctx, exterr.ErrParameterInvalid)
+		return
+	}
+	info, ok := c.subscriber.SubscriptionInfo(chainID)
+	if !ok {
+		result.Data = nil
+		response.Success(ctx, result)
+		return
+	}
+	result.Data = *info.ToVO()
+	response.Success(ctx, result)
+	return
+}
+
 //GetSystemConfig godoc
 //@Summary 获取当前账户的系统参数
 //@Description 获取系统参数
@@ -486,7 +580,7 @@ func (c *ChainController) DeployContract(ctx *gin.Context) {
 	}
 
 	//unlock account
-	address, err := service.DefaultAccountService.FirstAccount(chainID)
+	address, err := c.accountService.FirstAccount(chainID)
 	if err != nil {
 		response.ErrorHandler(ctx, err)
 		return
@@ -501,15 +595,15 @@ func (c *ChainController) DeployContract(ctx *gin.Context) {
 		Duration: 0,
 	}
 
-	unlock, err := service.DefaultAccountService.UnlockAccount(unlockAccountDTO)
+	unlock, err := c.accountService.UnlockAccount(unlockAccountDTO)
 	if err != nil || !unlock {
 		response.ErrorHandler(ctx, err)
 		return
 	}
 
 	//todo 待优化：可根据特定account来部署合约
-	account, _ := service.DefaultAccountService.FirstAccount(chainID)
-	res, err := ws.DefaultDeploy.DeployContract(chainID, account, files)
+	account, _ := c.accountService.FirstAccount(chainID)
+	res, err := c.deploy.DeployContract(chainID, account, files)
 	if err != nil {
 		response.ErrorHandler(ctx, err)
 	} else {
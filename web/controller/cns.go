@@ -9,16 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	DefaultCNSController *CNSController
-)
-
-func init() {
-	DefaultCNSController = newCNSController()
-}
-
-func newCNSController() *CNSController {
-	return &CNSController{service: service.DefaultCNSService}
+func newCNSController(services *service.Services) *CNSController {
+	return &CNSController{service: services.CNS}
 }
 
 //CNSByID go doc
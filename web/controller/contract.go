@@ -10,17 +10,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	DefaultContractController *ContractController
-)
-
-func init() {
-	DefaultContractController = newContractController()
-}
-
-func newContractController() *ContractController {
+func newContractController(services *service.Services) *ContractController {
 	return &ContractController{
-		service: service.DefaultContractService,
+		service: services.Contract,
 	}
 }
 
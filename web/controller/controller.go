@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"graces/syncer"
+	"graces/web/service"
+	"graces/ws"
+)
+
+// Controllers 应用使用的所有 controller
+type Controllers struct {
+	Chain     *ChainController
+	Block     *BlockController
+	TX        *TXController
+	Node      *NodeController
+	CNS       *CNSController
+	Contract  *ContractController
+	Account   *AccountController
+	Websocket *WebsocketController
+	User      *UserController
+	APIKey    *APIKeyController
+	Audit     *AuditController
+	Health    *HealthController
+	TxPool    *TxPoolController
+}
+
+// NewControllers 使用应用的 service 创建所有 controller，链的同步、订阅状态查询和合约部署直接使用对应的组件
+func NewControllers(services *service.Services, syncManager *syncer.ChainDataSyncManager,
+	subscriber *ws.WSSubscriber, deploy *ws.Deploy) *Controllers {
+	return &Controllers{
+		Chain:     newChainController(services, syncManager, subscriber, deploy),
+		Block:     newBlockController(services),
+		TX:        newTXController(services),
+		Node:      newNodeController(services),
+		CNS:       newCNSController(services),
+		Contract:  newContractController(services),
+		Account:   newAccountController(services),
+		Websocket: newWebSocketController(services),
+		User:      newUserController(services),
+		APIKey:    newAPIKeyController(services),
+		Audit:     newAuditController(services),
+		Health:    newHealthController(services),
+		TxPool:    newTxPoolController(services),
+	}
+}
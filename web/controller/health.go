@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+
+	"graces/model"
+	"graces/version"
+	"graces/web/service"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newHealthController(services *service.Services) *HealthController {
+	return &HealthController{service: services.Health}
+}
+
+//Healthz go doc
+//@Summary 存活检查
+//@Description 进程存活即返回成功，不检查依赖
+//@Tags 健康检查
+//@version 1.0
+//@Produce  json
+//@Success 200 {object} model.Result 成功后返回值
+//@Router /healthz [get]
+func (c *HealthController) Healthz(ctx *gin.Context) {
+	result := model.Result{Msg: "ok"}
+	response.Success(ctx, result)
+	return
+}
+
+//Readyz go doc
+//@Summary 就绪检查
+//@Description 检查数据库、每条链的 rpc 和 websocket 订阅状态，未就绪时返回 503 及每条链的状态
+//@Tags 健康检查
+//@version 1.0
+//@Produce  json
+//@Success 200 {object} model.Result{data=model.ReadinessVO} 就绪
+//@Failure 503 {object} model.Result{data=model.ReadinessVO} 未就绪
+//@Router /readyz [get]
+func (c *HealthController) Readyz(ctx *gin.Context) {
+	result := model.Result{}
+	vo, e := c.service.Readiness()
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = vo
+	if !vo.Ready {
+		result.Code = http.StatusServiceUnavailable
+		result.Msg = "not ready"
+		response.Response(ctx, http.StatusServiceUnavailable, result)
+		return
+	}
+	response.Success(ctx, result)
+	return
+}
+
+//Version go doc
+//@Summary 构建信息
+//@Description 返回构建时注入的 git commit、构建时间以及 Go 版本
+//@Tags 健康检查
+//@version 1.0
+//@Produce  json
+//@Success 200 {object} model.Result{data=version.Info} 成功后返回值
+//@Router /version [get]
+func (c *HealthController) Version(ctx *gin.Context) {
+	result := model.Result{}
+	result.Data = version.Get()
+	response.Success(ctx, result)
+	return
+}
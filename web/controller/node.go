@@ -10,17 +10,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	DefaultNodeController *NodeController
-)
-
-func init() {
-	DefaultNodeController = newNodeController()
-}
-
-func newNodeController() *NodeController {
+func newNodeController(services *service.Services) *NodeController {
 	return &NodeController{
-		service: service.DefaultNodeService,
+		service: services.Node,
 	}
 }
 
@@ -11,17 +11,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	DefaultTXController *TXController
-)
-
-func init() {
-	DefaultTXController = newTXController()
-}
-
-func newTXController() *TXController {
+func newTXController(services *service.Services) *TXController {
 	return &TXController{
-		service: service.DefaultTXService,
+		service: services.TX,
 	}
 }
 
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"graces/exterr"
+	"graces/model"
+	"graces/web/service"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTxPoolController(services *service.Services) *TxPoolController {
+	return &TxPoolController{service: services.TxPool}
+}
+
+//TxPool go doc
+//@Summary 链的交易池
+//@Description 查询链第一个节点交易池中等待打包的交易，按首次发现的时间排序，交易池的变化可以通过 websocket 订阅 pending_txs 实时获取
+//@Tags 交易池
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param chainid path string true "chainid" "链ID"
+//@Success 200 {object} model.Result{data=model.TxPoolVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/txpool/{chainid} [GET]
+func (c *TxPoolController) TxPool(ctx *gin.Context) {
+	result := model.Result{}
+	chainID := ctx.Param("chainid")
+	if len(chainID) == 0 {
+		response.ErrorHandler(ctx, exterr.ErrParameterInvalid)
+		return
+	}
+	vo, err := c.service.TxPool(chainID)
+	if err != nil {
+		response.ErrorHandler(ctx, err)
+		return
+	}
+	result.Data = *vo
+	response.Success(ctx, result)
+	return
+}
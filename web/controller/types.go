@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"graces/syncer"
 	"graces/web/service"
+	"graces/ws"
 )
 
 type WebsocketController struct {
@@ -9,7 +11,11 @@ type WebsocketController struct {
 }
 
 type ChainController struct {
-	service service.IChainService
+	service        service.IChainService
+	accountService service.IAccountService
+	syncManager    *syncer.ChainDataSyncManager
+	subscriber     *ws.WSSubscriber
+	deploy         *ws.Deploy
 }
 
 type BlockController struct {
@@ -35,3 +41,23 @@ type CNSController struct {
 type AccountController struct {
 	service service.IAccountService
 }
+
+type UserController struct {
+	service service.IUserService
+}
+
+type APIKeyController struct {
+	service service.IAPIKeyService
+}
+
+type AuditController struct {
+	service service.IAuditService
+}
+
+type HealthController struct {
+	service service.IHealthService
+}
+
+type TxPoolController struct {
+	service service.ITxPoolService
+}
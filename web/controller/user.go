@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"graces/exterr"
+	"graces/middleware"
+	"graces/model"
+	"graces/web/service"
+	"graces/web/util/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newUserController(services *service.Services) *UserController {
+	return &UserController{service: services.User}
+}
+
+//Login go doc
+//@Summary 用户登录
+//@Description 校验用户名和密码，成功后返回 token，之后的请求需要在 Authorization 请求头中携带该 token
+//@Tags 用户认证
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.LoginDTO true "用户登录DTO"
+//@Success 200 {object} model.Result{data=model.TokenVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/auth/login [post]
+func (c *UserController) Login(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.LoginDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	data, e := c.service.Login(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = data
+	response.Success(ctx, result)
+	return
+}
+
+//Refresh go doc
+//@Summary 刷新 token
+//@Description 使用刷新 token 换取新的访问 token 和刷新 token，旧的刷新 token 随即作废，重复使用会导致整个会话失效
+//@Tags 用户认证
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.RefreshDTO true "刷新 token DTO"
+//@Success 200 {object} model.Result{data=model.TokenVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/auth/refresh [post]
+func (c *UserController) Refresh(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.RefreshDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	data, e := c.service.Refresh(dto.RefreshToken)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = data
+	response.Success(ctx, result)
+	return
+}
+
+//Logout go doc
+//@Summary 用户登出
+//@Description 登出当前会话，当前 token 和该会话的刷新 token 立即失效
+//@Tags 用户认证
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param Authorization header string true "登录返回的 token"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/auth/logout [post]
+func (c *UserController) Logout(ctx *gin.Context) {
+	result := model.Result{}
+	claims, ok := middleware.GetClaims(ctx)
+	if !ok {
+		response.ErrorHandler(ctx, exterr.ErrUnauthorized)
+		return
+	}
+	if e := c.service.Logout(claims); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
+
+//LogoutAll go doc
+//@Summary 退出所有会话
+//@Description 退出当前用户的所有会话，该用户已经签发的 token 全部失效
+//@Tags 用户认证
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param Authorization header string true "登录返回的 token"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/auth/logout/all [post]
+func (c *UserController) LogoutAll(ctx *gin.Context) {
+	result := model.Result{}
+	claims, ok := middleware.GetClaims(ctx)
+	if !ok {
+		response.ErrorHandler(ctx, exterr.ErrUnauthorized)
+		return
+	}
+	if e := c.service.LogoutAll(claims.UserId); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
+
+//ChangePassword go doc
+//@Summary 修改密码
+//@Description 校验旧密码后修改当前用户的密码，该用户已经签发的 token 全部失效，需要重新登录；API Key 不能修改密码
+//@Tags 用户认证
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param Authorization header string true "登录返回的 token"
+//@Param dto body model.PasswordDTO true "修改密码DTO"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/auth/password [post]
+func (c *UserController) ChangePassword(ctx *gin.Context) {
+	result := model.Result{}
+	claims, ok := middleware.GetClaims(ctx)
+	if !ok {
+		response.ErrorHandler(ctx, exterr.ErrUnauthorized)
+		return
+	}
+	// 数据绑定
+	dto := model.PasswordDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	if e := c.service.ChangePassword(claims.UserId, dto); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
+
+//LogoutUser go doc
+//@Summary 强制用户退出所有会话
+//@Description 使指定用户已经签发的 token 全部失效，只有超级管理员可以操作
+//@Tags 用户管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.UserLogoutDTO true "强制用户退出DTO"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/user/logout [post]
+func (c *UserController) LogoutUser(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.UserLogoutDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	if e := c.service.LogoutAll(dto.UserID); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
+
+//CreateUser go doc
+//@Summary 创建用户
+//@Description 创建用户并设置全局角色，只有超级管理员可以操作
+//@Tags 用户管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.UserDTO true "创建用户DTO"
+//@Success 200 {object} model.Result{data=model.UserVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/user [post]
+func (c *UserController) CreateUser(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.UserDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	data, e := c.service.CreateUser(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = data
+	response.Success(ctx, result)
+	return
+}
+
+//Users go doc
+//@Summary 查询用户
+//@Description 按条件分页查询用户，只有超级管理员可以操作
+//@Tags 用户管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param condition body model.UserQueryCondition true "用户查询条件"
+//@Success 200 {object} model.Result{data=model.PageInfo{items=[]model.UserVO}} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/users [post]
+func (c *UserController) Users(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.UserQueryCondition{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	items, e := c.service.Users(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	total, e := c.service.Count(dto)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	pageInfo := &model.PageInfo{}
+	pageData, e := pageInfo.Build(dto.PageDTO, items, total)
+	if e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = pageData
+	response.Success(ctx, result)
+	return
+}
+
+//SetRole go doc
+//@Summary 设置用户全局角色
+//@Description 设置用户的全局角色，只有超级管理员可以操作
+//@Tags 用户管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.UserRoleDTO true "设置用户全局角色DTO"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/user/role [post]
+func (c *UserController) SetRole(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.UserRoleDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	if e := c.service.SetRole(dto); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
+
+//SetGrant go doc
+//@Summary 设置用户在链上的角色
+//@Description 授予或撤销用户在某条链上的角色，角色为空时撤销，只有超级管理员可以操作
+//@Tags 用户管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Param dto body model.UserGrantDTO true "设置用户在链上的角色DTO"
+//@Success 200 {object} model.Result{data=bool} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Failure 403 {object} model.Result 没有权限
+//@Router /api/user/grant [post]
+func (c *UserController) SetGrant(ctx *gin.Context) {
+	result := model.Result{}
+	// 数据绑定
+	dto := model.UserGrantDTO{}
+	if e := ctx.BindJSON(&dto); e != nil {
+		response.ErrorHandler(ctx, exterr.NewError(exterr.ErrCodeParameterInvalid, e.Error()))
+		return
+	}
+	if e := c.service.SetGrant(dto); e != nil {
+		response.ErrorHandler(ctx, e)
+		return
+	}
+	result.Data = true
+	response.Success(ctx, result)
+	return
+}
@@ -9,17 +9,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	DefaultWebsocketController *WebsocketController
-)
-
-func init() {
-	DefaultWebsocketController = newWebSocketController()
-}
-
-func newWebSocketController() *WebsocketController {
+func newWebSocketController(services *service.Services) *WebsocketController {
 	return &WebsocketController{
-		service: service.DefaultWebsocketService,
+		service: services.Websocket,
 	}
 }
 
@@ -64,6 +56,24 @@ func (c *WebsocketController) Groups(ctx *gin.Context) {
 	return
 }
 
+//ClosedClients go doc
+//@Summary 最近关闭的 WebSocket 客户端信息
+//@Description 查询最近关闭的 WebSocket 客户端信息，包含关闭的原因，最多保留 100 个
+//@Tags WebSocket 管理
+//@version 1.0
+//@Accept json
+//@Produce  json
+//@Success 200 {object} model.Result{data=[]model.WSClientVO} 成功后返回值
+//@Failure 400 {object} model.Result 请求参数有误
+//@Router /api/ws/closed [GET]
+func (c *WebsocketController) ClosedClients(ctx *gin.Context) {
+	result := model.Result{
+		Data: c.service.ClosedClients(),
+	}
+	response.Success(ctx, result)
+	return
+}
+
 //GroupByName go doc
 //@Summary 单个 WebSocket 组信息
 //@Description 通过 组名称 查询 WebSocket 中指定组的详细信息
@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"context"
+
+	"graces/db"
+	"graces/model"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameAPIKey = "api_keys"
+)
+
+func newAPIKeyDao(db *db.DB) IAPIKeyDao {
+	return &apiKeyDao{db}
+}
+
+type apiKeyDao struct {
+	*db.DB
+}
+
+func (d *apiKeyDao) InsertAPIKey(key model.APIKey) error {
+	collection := d.Db.Collection(collectionNameAPIKey)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.InsertOne(ctx, key)
+	if err != nil {
+		logrus.Errorln(err)
+		return err
+	}
+	logrus.Debugf("insert api key: %s", key.Prefix)
+	return nil
+}
+
+func (d *apiKeyDao) APIKey(filter interface{}) (*model.APIKey, error) {
+	collection := d.Db.Collection(collectionNameAPIKey)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	var key model.APIKey
+	err := collection.FindOne(ctx, filter).Decode(&key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (d *apiKeyDao) APIKeys(filter interface{}, findOps *options.FindOptions) ([]*model.APIKey, error) {
+	collection := d.Db.Collection(collectionNameAPIKey)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	cursor, err := collection.Find(ctx, filter, findOps)
+	if err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	results := make([]*model.APIKey, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *apiKeyDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	collection := d.Db.Collection(collectionNameAPIKey)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.UpdateOne(ctx, filter, update, updateOps)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("filter: %+v, update: %+v", filter, update)
+	return nil
+}
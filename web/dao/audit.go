@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"context"
+
+	"graces/db"
+	"graces/model"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameAuditEvent = "audit_events"
+)
+
+func newAuditDao(db *db.DB) IAuditDao {
+	return &auditDao{db}
+}
+
+type auditDao struct {
+	*db.DB
+}
+
+func (d *auditDao) InsertAuditEvent(event model.AuditEvent) error {
+	collection := d.Db.Collection(collectionNameAuditEvent)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.InsertOne(ctx, event)
+	if err != nil {
+		logrus.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+func (d *auditDao) AuditEvents(filter interface{}, findOps *options.FindOptions) ([]*model.AuditEvent, error) {
+	collection := d.Db.Collection(collectionNameAuditEvent)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	cursor, err := collection.Find(ctx, filter, findOps)
+	if err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	results := make([]*model.AuditEvent, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *auditDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	collection := d.Db.Collection(collectionNameAuditEvent)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+	count, err := collection.CountDocuments(ctx, filter, countOps)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
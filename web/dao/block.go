@@ -20,15 +20,11 @@ const (
 )
 
 var (
-	DefaultBlockDao IBlockDao
+	errDocumentNil = errors.New("document is nil")
 )
 
-func init() {
-	DefaultBlockDao = newBlockDao()
-}
-
-func newBlockDao() *blockDao {
-	return &blockDao{db.DefaultDB}
+func newBlockDao(db *db.DB) *blockDao {
+	return &blockDao{db}
 }
 
 type blockDao struct {
@@ -128,7 +124,7 @@ func (d *blockDao) LatestBlock(chainID primitive.ObjectID) (*model.Block, error)
 		return nil, err
 	}
 	if len(result) == 0 {
-		return nil, errors.New("document is nil")
+		return nil, errDocumentNil
 	}
 	filter := bson.M{
 		"chain_id": result[0]["_id"],
@@ -136,3 +132,14 @@ func (d *blockDao) LatestBlock(chainID primitive.ObjectID) (*model.Block, error)
 	}
 	return d.Block(filter)
 }
+
+func (d *blockDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameBlock)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
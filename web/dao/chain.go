@@ -8,6 +8,7 @@ import (
 	"graces/model"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -16,14 +17,6 @@ const (
 	defaultTimeout       = 30 * time.Second
 )
 
-var (
-	DefaultChainDao IChainDao
-)
-
-func init() {
-	DefaultChainDao = newChainDao(db.DefaultDB)
-}
-
 type chainDao struct {
 	*db.DB
 }
@@ -49,7 +42,7 @@ func (d *chainDao) Chain(filter interface{}) (*model.Chain, error) {
 	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
 
 	var c model.Chain
-	err := collection.FindOne(ctx, filter).Decode(&c)
+	err := collection.FindOne(ctx, notDeleted(filter)).Decode(&c)
 	if err != nil {
 		logrus.Errorln(err)
 		return nil, err
@@ -63,7 +56,7 @@ func (d *chainDao) Chains(filter interface{}, findOps *options.FindOptions) ([]*
 	collection := d.Db.Collection(collectionNameChains)
 	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
 
-	cursor, err := collection.Find(ctx, filter, findOps)
+	cursor, err := collection.Find(ctx, notDeleted(filter), findOps)
 	if err != nil {
 		logrus.Errorln(err)
 		return nil, err
@@ -82,9 +75,72 @@ func (d *chainDao) Chains(filter interface{}, findOps *options.FindOptions) ([]*
 func (d *chainDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
 	collection := d.Db.Collection(collectionNameChains)
 	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
-	count, err := collection.CountDocuments(ctx, filter, countOps)
+	count, err := collection.CountDocuments(ctx, notDeleted(filter), countOps)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
+
+func (d *chainDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	collection := d.Db.Collection(collectionNameChains)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+	_, err := collection.UpdateOne(ctx, notDeleted(filter), update, updateOps)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("update chain success, filter：%v, update: %v, updateOps: %v", filter, update, updateOps)
+	return nil
+}
+
+// DeleteMany 物理删除匹配的链，包括已被软删除的链
+func (d *chainDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameChains)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// ChainsIncludeDeleted 查询匹配的链，包括已被软删除的链
+func (d *chainDao) ChainsIncludeDeleted(filter interface{}, findOps *options.FindOptions) ([]*model.Chain, error) {
+	collection := d.Db.Collection(collectionNameChains)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	cursor, err := collection.Find(ctx, filter, findOps)
+	if err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+	results := make([]*model.Chain, 0)
+	if err := cursor.All(ctx, &results); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateIncludeDeleted 更新匹配的链，包括已被软删除的链
+func (d *chainDao) UpdateIncludeDeleted(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	collection := d.Db.Collection(collectionNameChains)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+	_, err := collection.UpdateOne(ctx, filter, update, updateOps)
+	return err
+}
+
+// 在过滤条件上追加未被软删除的条件，delete_time 不存在或为 0 的链视为未删除
+func notDeleted(filter interface{}) interface{} {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return bson.M{"$and": bson.A{
+		filter,
+		bson.M{"$or": bson.A{
+			bson.M{"delete_time": bson.M{"$exists": false}},
+			bson.M{"delete_time": bson.M{"$lte": 0}},
+		}},
+	}}
+}
@@ -14,16 +14,8 @@ const (
 	collectionNameCNS = "cns"
 )
 
-var (
-	DefaultCNSDao ICNSDao
-)
-
-func init() {
-	DefaultCNSDao = newCNSDao()
-}
-
-func newCNSDao() ICNSDao {
-	return &cnsDao{db.DefaultDB}
+func newCNSDao(db *db.DB) ICNSDao {
+	return &cnsDao{db}
 }
 
 type cnsDao struct {
@@ -97,3 +89,14 @@ func (d *cnsDao) Update(filter interface{}, update interface{}, updateOps *optio
 	logrus.Debugf("filter: %+v, update: %+v", filter, update)
 	return nil
 }
+
+func (d *cnsDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameCNS)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
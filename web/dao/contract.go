@@ -14,16 +14,8 @@ const (
 	collectionNameContract = "contracts"
 )
 
-var (
-	DefaultContractDao IContractDao
-)
-
-func init() {
-	DefaultContractDao = newContractDao()
-}
-
-func newContractDao() IContractDao {
-	return &contractDao{db.DefaultDB}
+func newContractDao(db *db.DB) IContractDao {
+	return &contractDao{db}
 }
 
 type contractDao struct {
@@ -96,3 +88,14 @@ func (d *contractDao) Update(filter interface{}, update interface{}, updateOps *
 	logrus.Debugf("filter: %+v, update: %+v", filter, update)
 	return nil
 }
+
+func (d *contractDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameContract)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"graces/config"
+	"graces/db"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Daos 应用使用的所有 DAO，每个应用持有自己的一组 DAO
+type Daos struct {
+	Chain        IChainDao
+	WSMsg        IWSMsgDao
+	Block        IBlockDao
+	TX           ITXDao
+	Node         INodeDao
+	CNS          ICNSDao
+	Contract     IContractDao
+	User         IUserDao
+	RefreshToken IRefreshTokenDao
+	RevokedToken IRevokedTokenDao
+	APIKey       IAPIKeyDao
+	Audit        IAuditDao
+}
+
+// NewDaos 根据配置的存储模式创建所有 DAO，mongo 模式下会先连接数据库并返回该连接，内存模式下返回的连接为 nil
+func NewDaos() (*Daos, *db.DB, error) {
+	if config.Config.DBConf.IsMemoryDB() {
+		logrus.Warningln("db mode is memory, all data will be lost after restart")
+		return NewMemoryDaos(), nil, nil
+	}
+	database, err := db.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewMongoDaos(database), database, nil
+}
+
+// NewMongoDaos 使用 MongoDB 创建所有 DAO
+func NewMongoDaos(database *db.DB) *Daos {
+	return &Daos{
+		Chain:        newChainDao(database),
+		WSMsg:        newWSMsgDao(database),
+		Block:        newBlockDao(database),
+		TX:           newTXDao(database),
+		Node:         newNodeDao(database),
+		CNS:          newCNSDao(database),
+		Contract:     newContractDao(database),
+		User:         newUserDao(database),
+		RefreshToken: newRefreshTokenDao(database),
+		RevokedToken: newRevokedTokenDao(database),
+		APIKey:       newAPIKeyDao(database),
+		Audit:        newAuditDao(database),
+	}
+}
+
+// NewMemoryDaos 使用内存存储创建所有 DAO，每次调用都会得到一份全新的空数据
+func NewMemoryDaos() *Daos {
+	return &Daos{
+		Chain:        newMemChainDao(),
+		WSMsg:        newMemWSMsgDao(),
+		Block:        newMemBlockDao(),
+		TX:           newMemTXDao(),
+		Node:         newMemNodeDao(),
+		CNS:          newMemCNSDao(),
+		Contract:     newMemContractDao(),
+		User:         newMemUserDao(),
+		RefreshToken: newMemRefreshTokenDao(),
+		RevokedToken: newMemRevokedTokenDao(),
+		APIKey:       newMemAPIKeyDao(),
+		Audit:        newMemAuditDao(),
+	}
+}
@@ -0,0 +1,726 @@
+package dao
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// memCollection 内存集合，文档统一转换为 bson.M 保存，
+// 支持 MongoDB 查询语法的一个子集：等值、$ne、$gt(e)、$lt(e)、$in、$nin、$exists、$regex、$or、$and、$nor，
+// 更新支持 $set、$unset、$inc、$setOnInsert 以及 upsert
+type memCollection struct {
+	lock sync.RWMutex
+	docs []bson.M
+}
+
+func newMemCollection() *memCollection {
+	return &memCollection{
+		docs: make([]bson.M, 0),
+	}
+}
+
+func (c *memCollection) insertOne(document interface{}) error {
+	doc, err := toDoc(document)
+	if err != nil {
+		return err
+	}
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, d := range c.docs {
+		if valueEqual(d["_id"], doc["_id"]) {
+			return fmt.Errorf("E11000 duplicate key error: _id %v", doc["_id"])
+		}
+	}
+	c.docs = append(c.docs, doc)
+	return nil
+}
+
+func (c *memCollection) findOne(filter interface{}, result interface{}) error {
+	docs, err := c.query(filter, nil)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return fromDoc(docs[0], result)
+}
+
+// find 查询文档并解码到 results 中，results 必须是指向切片的指针
+func (c *memCollection) find(filter interface{}, findOps *options.FindOptions, results interface{}) error {
+	docs, err := c.query(filter, findOps)
+	if err != nil {
+		return err
+	}
+	sliceValue := reflect.ValueOf(results)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.Elem().Kind() != reflect.Slice {
+		return errors.New("results argument must be a pointer to a slice")
+	}
+	sliceValue = sliceValue.Elem()
+	elemType := sliceValue.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	out := reflect.MakeSlice(sliceValue.Type(), 0, len(docs))
+	for _, doc := range docs {
+		elem := reflect.New(elemType)
+		if err := fromDoc(doc, elem.Interface()); err != nil {
+			return err
+		}
+		if isPtr {
+			out = reflect.Append(out, elem)
+		} else {
+			out = reflect.Append(out, elem.Elem())
+		}
+	}
+	sliceValue.Set(out)
+	return nil
+}
+
+func (c *memCollection) count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	docs, err := c.query(filter, nil)
+	if err != nil {
+		return 0, err
+	}
+	cnt := int64(len(docs))
+	if countOps != nil && countOps.Skip != nil {
+		cnt -= *countOps.Skip
+		if cnt < 0 {
+			cnt = 0
+		}
+	}
+	if countOps != nil && countOps.Limit != nil && *countOps.Limit > 0 && cnt > *countOps.Limit {
+		cnt = *countOps.Limit
+	}
+	return cnt, nil
+}
+
+// updateOne 更新第一个匹配的文档，upsert 为 true 且没有匹配文档时插入新文档
+func (c *memCollection) updateOne(filter interface{}, update interface{}, upsert bool) error {
+	f, u, err := toUpdateDocs(filter, update)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, doc := range c.docs {
+		if matchDoc(doc, f) {
+			return applyUpdate(doc, u, false)
+		}
+	}
+	if !upsert {
+		return nil
+	}
+	doc := bson.M{}
+	for k, v := range f {
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+		if ops, ok := asDoc(v); ok && isOperatorDoc(ops) {
+			continue
+		}
+		setPath(doc, k, v)
+	}
+	if err := applyUpdate(doc, u, true); err != nil {
+		return err
+	}
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+	c.docs = append(c.docs, doc)
+	return nil
+}
+
+// updateMany 更新所有匹配的文档，返回更新的文档数量
+func (c *memCollection) updateMany(filter interface{}, update interface{}) (int64, error) {
+	f, u, err := toUpdateDocs(filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var cnt int64
+	for _, doc := range c.docs {
+		if matchDoc(doc, f) {
+			if err := applyUpdate(doc, u, false); err != nil {
+				return cnt, err
+			}
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+// deleteMany 删除所有匹配的文档，返回删除的文档数量
+func (c *memCollection) deleteMany(filter interface{}) (int64, error) {
+	f, err := toDoc(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	kept := make([]bson.M, 0, len(c.docs))
+	for _, doc := range c.docs {
+		if !matchDoc(doc, f) {
+			kept = append(kept, doc)
+		}
+	}
+	cnt := int64(len(c.docs) - len(kept))
+	c.docs = kept
+	return cnt, nil
+}
+
+// 转换过滤条件和更新文档，更新文档只能包含更新操作符
+func toUpdateDocs(filter interface{}, update interface{}) (bson.M, bson.M, error) {
+	f, err := toDoc(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := toDoc(update)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k := range u {
+		if !strings.HasPrefix(k, "$") {
+			return nil, nil, errors.New("update document must contain key beginning with '$'")
+		}
+	}
+	return f, u, nil
+}
+
+// 按条件过滤、排序、分页，返回文档副本
+func (c *memCollection) query(filter interface{}, findOps *options.FindOptions) ([]bson.M, error) {
+	f, err := toDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	// 持有读锁时复制匹配的文档，更新操作会原地修改存储的文档
+	c.lock.RLock()
+	matched := make([]bson.M, 0)
+	for _, doc := range c.docs {
+		if !matchDoc(doc, f) {
+			continue
+		}
+		cp, err := toDoc(doc)
+		if err != nil {
+			c.lock.RUnlock()
+			return nil, err
+		}
+		matched = append(matched, cp)
+	}
+	c.lock.RUnlock()
+
+	if findOps != nil && findOps.Sort != nil {
+		keys, err := toSortKeys(findOps.Sort)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(matched, func(i, j int) bool {
+			for _, key := range keys {
+				a, _ := lookup(matched[i], key.key)
+				b, _ := lookup(matched[j], key.key)
+				if r := compareOrder(a, b); r != 0 {
+					return r*key.dir < 0
+				}
+			}
+			return false
+		})
+	}
+	if findOps != nil && findOps.Skip != nil && *findOps.Skip > 0 {
+		if *findOps.Skip >= int64(len(matched)) {
+			matched = matched[:0]
+		} else {
+			matched = matched[*findOps.Skip:]
+		}
+	}
+	if findOps != nil && findOps.Limit != nil && *findOps.Limit != 0 {
+		limit := *findOps.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit < int64(len(matched)) {
+			matched = matched[:limit]
+		}
+	}
+
+	return matched, nil
+}
+
+type sortKey struct {
+	key string
+	dir int
+}
+
+func toSortKeys(s interface{}) ([]sortKey, error) {
+	data, err := bson.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	keys := make([]sortKey, 0, len(d))
+	for _, e := range d {
+		dir := 1
+		if n, ok := toFloat(e.Value); ok && n < 0 {
+			dir = -1
+		}
+		keys = append(keys, sortKey{key: e.Key, dir: dir})
+	}
+	return keys, nil
+}
+
+// 将任意文档（struct、bson.M、bson.D）转换为 bson.M
+func toDoc(v interface{}) (bson.M, error) {
+	if v == nil {
+		return bson.M{}, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func fromDoc(doc bson.M, result interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, result)
+}
+
+func asDoc(v interface{}) (bson.M, bool) {
+	switch d := v.(type) {
+	case bson.M:
+		return d, true
+	case map[string]interface{}:
+		return d, true
+	case bson.D:
+		return d.Map(), true
+	}
+	return nil, false
+}
+
+func asArray(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case bson.A:
+		return a, true
+	case []interface{}:
+		return a, true
+	}
+	return nil, false
+}
+
+func isOperatorDoc(doc bson.M) bool {
+	if len(doc) == 0 {
+		return false
+	}
+	for k := range doc {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// 按 "a.b.c" 形式的路径取值
+func lookup(doc bson.M, path string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := asDoc(cur)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func setPath(doc bson.M, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	cur := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asDoc(cur[part])
+		if !ok {
+			next = bson.M{}
+		}
+		cur[part] = next
+		cur = next
+	}
+	cur[parts[len(parts)-1]] = value
+}
+
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	cur := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := asDoc(cur[part])
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, parts[len(parts)-1])
+}
+
+func applyUpdate(doc bson.M, update bson.M, inserting bool) error {
+	for op, arg := range update {
+		fields, ok := asDoc(arg)
+		if !ok {
+			return fmt.Errorf("modifier %s requires a document", op)
+		}
+		switch op {
+		case "$set":
+			for k, v := range fields {
+				setPath(doc, k, v)
+			}
+		case "$setOnInsert":
+			if inserting {
+				for k, v := range fields {
+					setPath(doc, k, v)
+				}
+			}
+		case "$unset":
+			for k := range fields {
+				unsetPath(doc, k)
+			}
+		case "$inc":
+			for k, v := range fields {
+				delta, ok := toFloat(v)
+				if !ok {
+					return fmt.Errorf("cannot increment with non-numeric argument: %v", v)
+				}
+				old, _ := lookup(doc, k)
+				setPath(doc, k, addNumber(old, v, delta))
+			}
+		default:
+			return fmt.Errorf("unsupported update operator: %s", op)
+		}
+	}
+	return nil
+}
+
+func addNumber(old interface{}, inc interface{}, delta float64) interface{} {
+	switch o := old.(type) {
+	case int32:
+		return o + int32(delta)
+	case int64:
+		return o + int64(delta)
+	case float64:
+		return o + delta
+	case nil:
+		return inc
+	}
+	return inc
+}
+
+func matchDoc(doc bson.M, filter bson.M) bool {
+	for k, cond := range filter {
+		switch k {
+		case "$or", "$and", "$nor":
+			subs, ok := asArray(cond)
+			if !ok {
+				return false
+			}
+			any, all := false, true
+			for _, sub := range subs {
+				subFilter, ok := asDoc(sub)
+				if !ok {
+					return false
+				}
+				if matchDoc(doc, subFilter) {
+					any = true
+				} else {
+					all = false
+				}
+			}
+			if (k == "$or" && !any) || (k == "$and" && !all) || (k == "$nor" && any) {
+				return false
+			}
+		default:
+			val, exists := lookup(doc, k)
+			if !matchCond(val, exists, cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchCond(val interface{}, exists bool, cond interface{}) bool {
+	ops, ok := asDoc(cond)
+	if !ok || !isOperatorDoc(ops) {
+		return matchEqual(val, cond)
+	}
+	for op, arg := range ops {
+		switch op {
+		case "$eq":
+			if !matchEqual(val, arg) {
+				return false
+			}
+		case "$ne":
+			if matchEqual(val, arg) {
+				return false
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			if !exists {
+				return false
+			}
+			r, ok := compareValue(val, arg)
+			if !ok {
+				return false
+			}
+			if (op == "$gt" && r <= 0) || (op == "$gte" && r < 0) || (op == "$lt" && r >= 0) || (op == "$lte" && r > 0) {
+				return false
+			}
+		case "$in", "$nin":
+			items, ok := asArray(arg)
+			if !ok {
+				return false
+			}
+			in := false
+			for _, item := range items {
+				if matchEqual(val, item) {
+					in = true
+					break
+				}
+			}
+			if in != (op == "$in") {
+				return false
+			}
+		case "$exists":
+			want, _ := arg.(bool)
+			if exists != want {
+				return false
+			}
+		case "$regex":
+			if !matchRegex(val, arg, ops["$options"]) {
+				return false
+			}
+		case "$options":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func matchEqual(val interface{}, cond interface{}) bool {
+	if items, ok := asArray(val); ok {
+		if _, condIsArray := asArray(cond); !condIsArray {
+			for _, item := range items {
+				if valueEqual(item, cond) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return valueEqual(val, cond)
+}
+
+func matchRegex(val interface{}, pattern interface{}, options interface{}) bool {
+	s, ok := val.(string)
+	if !ok {
+		return false
+	}
+	var expr, flags string
+	switch p := pattern.(type) {
+	case string:
+		expr = p
+	case primitive.Regex:
+		expr, flags = p.Pattern, p.Options
+	default:
+		return false
+	}
+	if o, ok := options.(string); ok {
+		flags += o
+	}
+	if strings.Contains(flags, "i") {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+func valueEqual(a, b interface{}) bool {
+	if r, ok := compareValue(a, b); ok {
+		return r == 0
+	}
+	da, aok := asDoc(a)
+	db, bok := asDoc(b)
+	if aok && bok {
+		if len(da) != len(db) {
+			return false
+		}
+		for k, v := range da {
+			if !valueEqual(v, db[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	aa, aok := asArray(a)
+	ab, bok := asArray(b)
+	if aok && bok {
+		if len(aa) != len(ab) {
+			return false
+		}
+		for i := range aa {
+			if !valueEqual(aa[i], ab[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// 比较两个同类的标量值，类型不可比较时 ok 为 false
+func compareValue(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(va, vb), true
+	case bool:
+		vb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case va == vb:
+			return 0, true
+		case !va:
+			return -1, true
+		}
+		return 1, true
+	case primitive.ObjectID:
+		vb, ok := b.(primitive.ObjectID)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(va[:], vb[:]), true
+	case primitive.DateTime:
+		vb, ok := b.(primitive.DateTime)
+		if !ok {
+			return 0, false
+		}
+		return compareInt(int64(va), int64(vb)), true
+	case time.Time:
+		vb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return compareInt(va.UnixNano(), vb.UnixNano()), true
+	}
+	return 0, false
+}
+
+// 排序用的比较，不存在的字段和不可比较的类型按类型顺序排列
+func compareOrder(a, b interface{}) int {
+	if r, ok := compareValue(a, b); ok {
+		return r
+	}
+	return compareInt(int64(typeOrder(a)), int64(typeOrder(b)))
+}
+
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int32, int64, float64, int, uint64:
+		return 1
+	case string:
+		return 2
+	case bson.M, bson.D, map[string]interface{}:
+		return 3
+	case bson.A, []interface{}:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	case primitive.DateTime, time.Time:
+		return 7
+	}
+	return 8
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
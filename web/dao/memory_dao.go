@@ -0,0 +1,471 @@
+package dao
+
+import (
+	"graces/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func isUpsert(updateOps *options.UpdateOptions) bool {
+	return updateOps != nil && updateOps.Upsert != nil && *updateOps.Upsert
+}
+
+// ========================= chain ==============================
+
+type memChainDao struct {
+	c *memCollection
+}
+
+func newMemChainDao() *memChainDao {
+	return &memChainDao{newMemCollection()}
+}
+
+func (d *memChainDao) InsertChain(chain model.Chain) error {
+	return d.c.insertOne(chain)
+}
+
+func (d *memChainDao) Chain(filter interface{}) (*model.Chain, error) {
+	var chain model.Chain
+	if err := d.c.findOne(notDeleted(filter), &chain); err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
+
+func (d *memChainDao) Chains(filter interface{}, findOps *options.FindOptions) ([]*model.Chain, error) {
+	results := make([]*model.Chain, 0)
+	if err := d.c.find(notDeleted(filter), findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memChainDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(notDeleted(filter), countOps)
+}
+
+func (d *memChainDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(notDeleted(filter), update, isUpsert(updateOps))
+}
+
+func (d *memChainDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+func (d *memChainDao) ChainsIncludeDeleted(filter interface{}, findOps *options.FindOptions) ([]*model.Chain, error) {
+	results := make([]*model.Chain, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memChainDao) UpdateIncludeDeleted(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+// ========================= ws msg ==============================
+
+type memWSMsgDao struct {
+	c *memCollection
+}
+
+func newMemWSMsgDao() *memWSMsgDao {
+	return &memWSMsgDao{newMemCollection()}
+}
+
+func (d *memWSMsgDao) WSMsg(filter interface{}) (*model.WSMsg, error) {
+	var msg model.WSMsg
+	if err := d.c.findOne(filter, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (d *memWSMsgDao) InsertWSMsg(msg model.WSMsg) error {
+	return d.c.insertOne(msg)
+}
+
+func (d *memWSMsgDao) UpdateWSMsg(filter interface{}, update interface{}) error {
+	return d.c.updateOne(filter, update, false)
+}
+
+func (d *memWSMsgDao) UpdateWSMsgHash(msgID string, topic string, msgHash string) error {
+	filter, update, err := buildWSMsgHashUpdate(msgID, topic, msgHash)
+	if err != nil {
+		return err
+	}
+	return d.UpdateWSMsg(filter, update)
+}
+
+// ========================= block ==============================
+
+type memBlockDao struct {
+	c *memCollection
+}
+
+func newMemBlockDao() *memBlockDao {
+	return &memBlockDao{newMemCollection()}
+}
+
+func (d *memBlockDao) Block(filter interface{}) (*model.Block, error) {
+	var block model.Block
+	if err := d.c.findOne(filter, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (d *memBlockDao) Blocks(filter interface{}, findOps *options.FindOptions) ([]*model.Block, error) {
+	results := make([]*model.Block, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memBlockDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memBlockDao) LatestBlock(chainID primitive.ObjectID) (*model.Block, error) {
+	findOps := options.Find().SetSort(bson.D{{"height", -1}}).SetLimit(1)
+	blocks, err := d.Blocks(bson.M{"chain_id": chainID}, findOps)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, errDocumentNil
+	}
+	return blocks[0], nil
+}
+
+func (d *memBlockDao) InsertBlock(block model.Block) error {
+	return d.c.insertOne(block)
+}
+
+func (d *memBlockDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+func (d *memBlockDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+// ========================= tx ==============================
+
+type memTXDao struct {
+	c *memCollection
+}
+
+func newMemTXDao() *memTXDao {
+	return &memTXDao{newMemCollection()}
+}
+
+func (d *memTXDao) InsertTX(tx model.TX) error {
+	return d.c.insertOne(tx)
+}
+
+func (d *memTXDao) TX(filter interface{}) (*model.TX, error) {
+	var tx model.TX
+	if err := d.c.findOne(filter, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (d *memTXDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+func (d *memTXDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+func (d *memTXDao) TXs(filter interface{}, findOps *options.FindOptions) ([]*model.TX, error) {
+	results := make([]*model.TX, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memTXDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+// ========================= node ==============================
+
+type memNodeDao struct {
+	c *memCollection
+}
+
+func newMemNodeDao() *memNodeDao {
+	return &memNodeDao{newMemCollection()}
+}
+
+func (d *memNodeDao) Node(filter interface{}) (*model.Node, error) {
+	var node model.Node
+	if err := d.c.findOne(filter, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func (d *memNodeDao) InsertNode(node model.Node) error {
+	return d.c.insertOne(node)
+}
+
+func (d *memNodeDao) Nodes(filter interface{}, findOps *options.FindOptions) ([]*model.Node, error) {
+	results := make([]*model.Node, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memNodeDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memNodeDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+func (d *memNodeDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+// ========================= cns ==============================
+
+type memCNSDao struct {
+	c *memCollection
+}
+
+func newMemCNSDao() *memCNSDao {
+	return &memCNSDao{newMemCollection()}
+}
+
+func (d *memCNSDao) InsertCNS(cns model.CNS) error {
+	return d.c.insertOne(cns)
+}
+
+func (d *memCNSDao) CNS(filter interface{}) (*model.CNS, error) {
+	var cns model.CNS
+	if err := d.c.findOne(filter, &cns); err != nil {
+		return nil, err
+	}
+	return &cns, nil
+}
+
+func (d *memCNSDao) CNSs(filter interface{}, findOps *options.FindOptions) ([]*model.CNS, error) {
+	results := make([]*model.CNS, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memCNSDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memCNSDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+func (d *memCNSDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+// ========================= contract ==============================
+
+type memContractDao struct {
+	c *memCollection
+}
+
+func newMemContractDao() *memContractDao {
+	return &memContractDao{newMemCollection()}
+}
+
+func (d *memContractDao) InsertContract(contract model.Contract) error {
+	return d.c.insertOne(contract)
+}
+
+func (d *memContractDao) Contract(filter interface{}) (*model.Contract, error) {
+	var contract model.Contract
+	if err := d.c.findOne(filter, &contract); err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+func (d *memContractDao) Contracts(filter interface{}, findOps *options.FindOptions) ([]*model.Contract, error) {
+	results := make([]*model.Contract, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memContractDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memContractDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+func (d *memContractDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+// ========================= user ==============================
+
+type memUserDao struct {
+	c *memCollection
+}
+
+func newMemUserDao() *memUserDao {
+	return &memUserDao{newMemCollection()}
+}
+
+func (d *memUserDao) InsertUser(user model.User) error {
+	return d.c.insertOne(user)
+}
+
+func (d *memUserDao) User(filter interface{}) (*model.User, error) {
+	var user model.User
+	if err := d.c.findOne(filter, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *memUserDao) Users(filter interface{}, findOps *options.FindOptions) ([]*model.User, error) {
+	results := make([]*model.User, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memUserDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memUserDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+// ========================= token ==============================
+
+type memRefreshTokenDao struct {
+	c *memCollection
+}
+
+func newMemRefreshTokenDao() *memRefreshTokenDao {
+	return &memRefreshTokenDao{newMemCollection()}
+}
+
+func (d *memRefreshTokenDao) InsertRefreshToken(token model.RefreshToken) error {
+	return d.c.insertOne(token)
+}
+
+func (d *memRefreshTokenDao) RefreshToken(filter interface{}) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := d.c.findOne(filter, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (d *memRefreshTokenDao) UpdateMany(filter interface{}, update interface{}) (int64, error) {
+	return d.c.updateMany(filter, update)
+}
+
+func (d *memRefreshTokenDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+type memRevokedTokenDao struct {
+	c *memCollection
+}
+
+func newMemRevokedTokenDao() *memRevokedTokenDao {
+	return &memRevokedTokenDao{newMemCollection()}
+}
+
+func (d *memRevokedTokenDao) InsertRevokedToken(token model.RevokedToken) error {
+	return d.c.insertOne(token)
+}
+
+func (d *memRevokedTokenDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
+
+func (d *memRevokedTokenDao) DeleteMany(filter interface{}) (int64, error) {
+	return d.c.deleteMany(filter)
+}
+
+// ========================= api key ==============================
+
+type memAPIKeyDao struct {
+	c *memCollection
+}
+
+func newMemAPIKeyDao() *memAPIKeyDao {
+	return &memAPIKeyDao{newMemCollection()}
+}
+
+func (d *memAPIKeyDao) InsertAPIKey(key model.APIKey) error {
+	return d.c.insertOne(key)
+}
+
+func (d *memAPIKeyDao) APIKey(filter interface{}) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := d.c.findOne(filter, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (d *memAPIKeyDao) APIKeys(filter interface{}, findOps *options.FindOptions) ([]*model.APIKey, error) {
+	results := make([]*model.APIKey, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memAPIKeyDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	return d.c.updateOne(filter, update, isUpsert(updateOps))
+}
+
+// ========================= audit ==============================
+
+type memAuditDao struct {
+	c *memCollection
+}
+
+func newMemAuditDao() *memAuditDao {
+	return &memAuditDao{newMemCollection()}
+}
+
+func (d *memAuditDao) InsertAuditEvent(event model.AuditEvent) error {
+	return d.c.insertOne(event)
+}
+
+func (d *memAuditDao) AuditEvents(filter interface{}, findOps *options.FindOptions) ([]*model.AuditEvent, error) {
+	results := make([]*model.AuditEvent, 0)
+	if err := d.c.find(filter, findOps, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *memAuditDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	return d.c.count(filter, countOps)
+}
@@ -0,0 +1,126 @@
+package dao
+
+import (
+	"fmt"
+	"testing"
+
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMemBlockDao_QueryAndSort(t *testing.T) {
+	d := newMemBlockDao()
+	chainID := primitive.NewObjectID()
+	for i := 1; i <= 5; i++ {
+		err := d.InsertBlock(model.Block{
+			ID:      primitive.NewObjectID(),
+			ChainID: chainID,
+			Hash:    fmt.Sprintf("0xABC%d", i),
+			Height:  uint64(i),
+		})
+		assert.True(t, err == nil)
+	}
+	assert.True(t, d.InsertBlock(model.Block{ID: primitive.NewObjectID(), ChainID: primitive.NewObjectID(), Height: 9}) == nil)
+
+	count, err := d.Count(bson.M{"chain_id": chainID}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(5), count)
+
+	latest, err := d.LatestBlock(chainID)
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(5), latest.Height)
+
+	findOps := options.Find().SetSort(bson.D{{"height", -1}}).SetSkip(1).SetLimit(2)
+	blocks, err := d.Blocks(bson.M{"chain_id": chainID, "height": bson.M{"$gte": 2}}, findOps)
+	assert.True(t, err == nil)
+	assert.Equal(t, 2, len(blocks))
+	assert.Equal(t, uint64(4), blocks[0].Height)
+	assert.Equal(t, uint64(3), blocks[1].Height)
+
+	block, err := d.Block(bson.M{"hash": bson.M{"$regex": "^(?i)0xabc2$"}})
+	assert.True(t, err == nil)
+	assert.Equal(t, uint64(2), block.Height)
+
+	blocks, err = d.Blocks(bson.M{"$or": []bson.M{{"height": 1}, {"height": bson.M{"$in": []int{3, 9}}}}}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, 3, len(blocks))
+
+	_, err = d.Block(bson.M{"height": 100})
+	assert.True(t, err == mongo.ErrNoDocuments)
+	_, err = d.LatestBlock(primitive.NewObjectID())
+	assert.True(t, err == errDocumentNil)
+}
+
+func TestMemNodeDao_Upsert(t *testing.T) {
+	d := newMemNodeDao()
+	chainID := primitive.NewObjectID()
+	filter := bson.M{"chain_id": chainID, "name": "node-0"}
+	upsert := options.Update().SetUpsert(true)
+
+	err := d.Update(filter, bson.M{"$set": bson.M{"status": 1}}, upsert)
+	assert.True(t, err == nil)
+	err = d.Update(filter, bson.M{"$set": bson.M{"status": 2}}, upsert)
+	assert.True(t, err == nil)
+
+	count, err := d.Count(bson.M{"chain_id": chainID}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(1), count)
+	node, err := d.Node(filter)
+	assert.True(t, err == nil)
+	assert.Equal(t, "node-0", node.Name)
+	assert.Equal(t, 2, node.Status)
+	assert.True(t, !node.ID.IsZero())
+
+	err = d.Update(bson.M{"name": "node-1"}, bson.M{"$set": bson.M{"status": 1}}, nil)
+	assert.True(t, err == nil)
+	count, _ = d.Count(bson.M{}, nil)
+	assert.Equal(t, int64(1), count)
+
+	err = d.Update(filter, bson.M{"status": 3}, upsert)
+	assert.True(t, err != nil)
+}
+
+func TestMemCollection_QueryDuringUpdate(t *testing.T) {
+	c := newMemCollection()
+	for i := 0; i < 10; i++ {
+		assert.True(t, c.insertOne(bson.M{"_id": i, "status": i}) == nil)
+	}
+	// 查询排序和复制文档时，并发的更新不能修改正在读取的文档
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_, _ = c.updateMany(bson.M{}, bson.M{"$inc": bson.M{"status": 1}})
+		}
+	}()
+	findOps := options.Find().SetSort(bson.M{"status": -1})
+	for i := 0; i < 200; i++ {
+		docs, err := c.query(bson.M{}, findOps)
+		assert.True(t, err == nil)
+		assert.Equal(t, 10, len(docs))
+	}
+	<-done
+}
+
+func TestMemChainDao_IncludeDeleted(t *testing.T) {
+	d := newMemChainDao()
+	chain := model.Chain{ID: primitive.NewObjectID(), Name: "chain-deleted", DeleteTime: 1}
+	assert.True(t, d.InsertChain(chain) == nil)
+
+	chains, err := d.Chains(bson.M{}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, 0, len(chains))
+	chains, err = d.ChainsIncludeDeleted(bson.M{}, nil)
+	assert.True(t, err == nil)
+	assert.Equal(t, 1, len(chains))
+
+	update := bson.M{"$set": bson.M{"name": "chain-renamed"}}
+	assert.True(t, d.UpdateIncludeDeleted(bson.M{"_id": chain.ID}, update, nil) == nil)
+	chains, _ = d.ChainsIncludeDeleted(bson.M{"name": "chain-renamed"}, nil)
+	assert.Equal(t, 1, len(chains))
+}
@@ -14,17 +14,9 @@ const (
 	collectionNameNode = "nodes"
 )
 
-var (
-	DefaultNodeDao INodeDao
-)
-
-func init() {
-	DefaultNodeDao = newNodeDao()
-}
-
-func newNodeDao() *nodeDao {
+func newNodeDao(db *db.DB) *nodeDao {
 	return &nodeDao{
-		db.DefaultDB,
+		db,
 	}
 }
 
@@ -99,3 +91,14 @@ func (d *nodeDao) Update(filter interface{}, update interface{}, updateOps *opti
 	logrus.Debugf("update node success, filter：%v, update: %v, updateOps: %v", filter, update, updateOps)
 	return nil
 }
+
+func (d *nodeDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameNode)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+
+	"graces/db"
+	"graces/model"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameRefreshToken = "refresh_tokens"
+	collectionNameRevokedToken = "revoked_tokens"
+)
+
+func newRefreshTokenDao(db *db.DB) IRefreshTokenDao {
+	return &refreshTokenDao{db}
+}
+
+type refreshTokenDao struct {
+	*db.DB
+}
+
+func (d *refreshTokenDao) InsertRefreshToken(token model.RefreshToken) error {
+	collection := d.Db.Collection(collectionNameRefreshToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.InsertOne(ctx, token)
+	if err != nil {
+		logrus.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+func (d *refreshTokenDao) RefreshToken(filter interface{}) (*model.RefreshToken, error) {
+	collection := d.Db.Collection(collectionNameRefreshToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	var token model.RefreshToken
+	err := collection.FindOne(ctx, filter).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (d *refreshTokenDao) UpdateMany(filter interface{}, update interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameRefreshToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	logrus.Debugf("filter: %+v, update: %+v", filter, update)
+	return result.ModifiedCount, nil
+}
+
+func (d *refreshTokenDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameRefreshToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func newRevokedTokenDao(db *db.DB) IRevokedTokenDao {
+	return &revokedTokenDao{db}
+}
+
+type revokedTokenDao struct {
+	*db.DB
+}
+
+func (d *revokedTokenDao) InsertRevokedToken(token model.RevokedToken) error {
+	collection := d.Db.Collection(collectionNameRevokedToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.InsertOne(ctx, token)
+	if err != nil {
+		logrus.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+func (d *revokedTokenDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	collection := d.Db.Collection(collectionNameRevokedToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+	count, err := collection.CountDocuments(ctx, filter, countOps)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d *revokedTokenDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameRevokedToken)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
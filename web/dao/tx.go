@@ -16,16 +16,8 @@ const (
 	collectionNameTX = "txs"
 )
 
-var (
-	DefaultTXDao ITXDao
-)
-
-func init() {
-	DefaultTXDao = newTXDao()
-}
-
-func newTXDao() *txDao {
-	return &txDao{db.DefaultDB}
+func newTXDao(db *db.DB) *txDao {
+	return &txDao{db}
 }
 
 type txDao struct {
@@ -119,3 +111,14 @@ func (d *txDao) TXByDate(timestamp int64) (int64, error) {
 	}
 	return amount, nil
 }
+
+func (d *txDao) DeleteMany(filter interface{}) (int64, error) {
+	collection := d.Db.Collection(collectionNameTX)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
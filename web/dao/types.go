@@ -12,6 +12,12 @@ type IChainDao interface {
 	Chain(filter interface{}) (*model.Chain, error)
 	Chains(filter interface{}, findOps *options.FindOptions) ([]*model.Chain, error)
 	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
+	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+	DeleteMany(filter interface{}) (int64, error)
+	// ChainsIncludeDeleted 查询匹配的链，包括已被软删除的链
+	ChainsIncludeDeleted(filter interface{}, findOps *options.FindOptions) ([]*model.Chain, error)
+	// UpdateIncludeDeleted 更新匹配的链，包括已被软删除的链
+	UpdateIncludeDeleted(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
 }
 
 type IWSMsgDao interface {
@@ -28,6 +34,7 @@ type IBlockDao interface {
 	LatestBlock(chainID primitive.ObjectID) (*model.Block, error)
 	InsertBlock(block model.Block) error
 	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+	DeleteMany(filter interface{}) (int64, error)
 }
 
 type ITXDao interface {
@@ -36,6 +43,7 @@ type ITXDao interface {
 	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
 	TXs(filter interface{}, findOps *options.FindOptions) ([]*model.TX, error)
 	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
+	DeleteMany(filter interface{}) (int64, error)
 }
 
 type INodeDao interface {
@@ -44,6 +52,7 @@ type INodeDao interface {
 	Nodes(filter interface{}, findOps *options.FindOptions) ([]*model.Node, error)
 	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
 	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+	DeleteMany(filter interface{}) (int64, error)
 }
 
 type ICNSDao interface {
@@ -52,6 +61,7 @@ type ICNSDao interface {
 	CNSs(filter interface{}, findOps *options.FindOptions) ([]*model.CNS, error)
 	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
 	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+	DeleteMany(filter interface{}) (int64, error)
 }
 
 type IContractDao interface {
@@ -60,4 +70,39 @@ type IContractDao interface {
 	Contracts(filter interface{}, findOps *options.FindOptions) ([]*model.Contract, error)
 	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
 	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+	DeleteMany(filter interface{}) (int64, error)
+}
+
+type IUserDao interface {
+	InsertUser(user model.User) error
+	User(filter interface{}) (*model.User, error)
+	Users(filter interface{}, findOps *options.FindOptions) ([]*model.User, error)
+	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
+	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+}
+
+type IRefreshTokenDao interface {
+	InsertRefreshToken(token model.RefreshToken) error
+	RefreshToken(filter interface{}) (*model.RefreshToken, error)
+	UpdateMany(filter interface{}, update interface{}) (int64, error)
+	DeleteMany(filter interface{}) (int64, error)
+}
+
+type IRevokedTokenDao interface {
+	InsertRevokedToken(token model.RevokedToken) error
+	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
+	DeleteMany(filter interface{}) (int64, error)
+}
+
+type IAPIKeyDao interface {
+	InsertAPIKey(key model.APIKey) error
+	APIKey(filter interface{}) (*model.APIKey, error)
+	APIKeys(filter interface{}, findOps *options.FindOptions) ([]*model.APIKey, error)
+	Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error
+}
+
+type IAuditDao interface {
+	InsertAuditEvent(event model.AuditEvent) error
+	AuditEvents(filter interface{}, findOps *options.FindOptions) ([]*model.AuditEvent, error)
+	Count(filter interface{}, countOps *options.CountOptions) (int64, error)
 }
@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+
+	"graces/db"
+	"graces/model"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionNameUser = "users"
+)
+
+func newUserDao(db *db.DB) IUserDao {
+	return &userDao{db}
+}
+
+type userDao struct {
+	*db.DB
+}
+
+func (d *userDao) InsertUser(user model.User) error {
+	collection := d.Db.Collection(collectionNameUser)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.InsertOne(ctx, user)
+	if err != nil {
+		logrus.Errorln(err)
+		return err
+	}
+	logrus.Debugf("insert user: %s", user.Username)
+	return nil
+}
+
+func (d *userDao) User(filter interface{}) (*model.User, error) {
+	collection := d.Db.Collection(collectionNameUser)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	var user model.User
+	err := collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (d *userDao) Users(filter interface{}, findOps *options.FindOptions) ([]*model.User, error) {
+	collection := d.Db.Collection(collectionNameUser)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	cursor, err := collection.Find(ctx, filter, findOps)
+	if err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	results := make([]*model.User, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *userDao) Count(filter interface{}, countOps *options.CountOptions) (int64, error) {
+	collection := d.Db.Collection(collectionNameUser)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+	count, err := collection.CountDocuments(ctx, filter, countOps)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d *userDao) Update(filter interface{}, update interface{}, updateOps *options.UpdateOptions) error {
+	collection := d.Db.Collection(collectionNameUser)
+	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+
+	_, err := collection.UpdateOne(ctx, filter, update, updateOps)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("filter: %+v, update: %+v", filter, update)
+	return nil
+}
@@ -16,17 +16,9 @@ const (
 	collectionNameWSMessage = "ws_msg"
 )
 
-var (
-	DefaultWSMsgDao IWSMsgDao
-)
-
-func init() {
-	DefaultWSMsgDao = newWSMsgDao()
-}
-
-func newWSMsgDao() *wsMsgDao {
+func newWSMsgDao(db *db.DB) *wsMsgDao {
 	return &wsMsgDao{
-		DB: db.DefaultDB,
+		DB: db,
 	}
 }
 
@@ -61,9 +53,18 @@ func (d *wsMsgDao) UpdateWSMsg(filter interface{}, update interface{}) error {
 }
 
 func (d *wsMsgDao) UpdateWSMsgHash(msgID string, topic string, msgHash string) error {
+	filter, update, err := buildWSMsgHashUpdate(msgID, topic, msgHash)
+	if err != nil {
+		return err
+	}
+	return d.UpdateWSMsg(filter, update)
+}
+
+// 构建更新订阅消息哈希的过滤条件和更新内容
+func buildWSMsgHashUpdate(msgID string, topic string, msgHash string) (bson.M, bson.M, error) {
 	id, err := primitive.ObjectIDFromHex(msgID)
 	if err != nil {
-		return exterr.ErrObjectIDInvalid
+		return nil, nil, exterr.ErrObjectIDInvalid
 	}
 	filter := bson.M{
 		"_id":              id,
@@ -74,7 +75,7 @@ func (d *wsMsgDao) UpdateWSMsgHash(msgID string, topic string, msgHash string) e
 			"hash": msgHash,
 		},
 	}
-	return d.UpdateWSMsg(filter, update)
+	return filter, update, nil
 }
 
 func (d *wsMsgDao) WSMsg(filter interface{}) (*model.WSMsg, error) {
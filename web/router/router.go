@@ -8,6 +8,7 @@ import (
 	"graces/middleware"
 	"graces/model"
 	"graces/web/controller"
+	"graces/web/service"
 	"graces/web/util/response"
 	"graces/ws"
 	// swagger embed files
@@ -18,25 +19,19 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-var (
-	myRouter *gin.Engine
-)
-
-func init() {
-	myRouter = gin.Default()
-}
-
-func InitRouter() *gin.Engine {
-	myRouter.Use(middleware.PanicHandler())
-	myRouter.Use(middleware.Cors())
+// NewRouter 创建路由，接口由 controllers 处理，认证和审计使用 services，websocket 连接交给 wsManager
+func NewRouter(controllers *controller.Controllers, services *service.Services, wsManager *ws.Manager) *gin.Engine {
+	engine := gin.Default()
+	engine.Use(middleware.PanicHandler())
+	engine.Use(middleware.Cors())
 
 	// debug 模式才注册 swagger 组件
 	if gin.Mode() == gin.DebugMode {
-		swaggerRouter()
+		swaggerRouter(engine)
 	}
-	customRouter()
+	customRouter(engine, controllers, services, wsManager)
 
-	return myRouter
+	return engine
 }
 
 // SwaggerRouter swagger 路由
@@ -49,25 +44,25 @@ func InitRouter() *gin.Engine {
 // @license.name ""
 // @license.url ""
 // @host http://127.0.0.1:9999
-func swaggerRouter() {
+func swaggerRouter(engine *gin.Engine) {
 
 	// The url pointing to API definition
 	uri := fmt.Sprintf("http://%s:%s/swagger/doc.json",
 		config.Config.HttpConf.IP,
 		config.Config.HttpConf.Port)
 	url := ginSwagger.URL(uri)
-	myRouter.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
+	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 }
 
-func customRouter() {
-	myRouter.NoRoute(func(ctx *gin.Context) {
+func customRouter(engine *gin.Engine, controllers *controller.Controllers, services *service.Services, wsManager *ws.Manager) {
+	engine.NoRoute(func(ctx *gin.Context) {
 		result := model.Result{
 			Code: http.StatusNotFound,
 			Msg:  "no resource",
 		}
 		response.Fail(ctx, result)
 	})
-	myRouter.NoMethod(func(ctx *gin.Context) {
+	engine.NoMethod(func(ctx *gin.Context) {
 		result := model.Result{
 			Code: http.StatusNotFound,
 			Msg:  "no resource",
@@ -76,121 +71,188 @@ func customRouter() {
 	})
 
 	// 首页
-	indexGroup := myRouter.Group("/")
+	indexGroup := engine.Group("/")
 	{
 		indexGroup.Any("", func(ctx *gin.Context) {
 			result := model.Result{Code: http.StatusOK, Msg: "Welcome Graces Server", Data: nil}
 			response.Success(ctx, result)
 			return
 		})
+		// 健康检查和构建信息，供编排系统探测，不需要登录
+		indexGroup.GET("/healthz", controllers.Health.Healthz)
+		indexGroup.GET("/readyz", controllers.Health.Readyz)
+		indexGroup.GET("/version", controllers.Health.Version)
 	}
 
-	// 业务接口
-	api := myRouter.Group("/api")
+	// 不需要登录的接口
+	public := engine.Group("/api")
 	{
-		// websocket
-		wsGroup := api.Group("/ws")
+		auth := public.Group("/auth")
+		{
+			auth.POST("/login", controllers.User.Login)
+			auth.POST("/refresh", controllers.User.Refresh)
+		}
+		// 浏览器建立 websocket 连接时无法携带 Authorization 请求头，由 WSAuth 从子协议或查询参数中获取 token，
+		// 组名称为链ID时校验用户在该链上的查看权限
+		wsGroup := public.Group("/ws")
 		{
 			if gin.Mode() == gin.DebugMode {
 				wsGroup.StaticFile("/ws_node.html", "./ws/ws_node.html")
 				wsGroup.StaticFile("/ws_deploy.html", "./ws/ws_deploy.html")
 				wsGroup.StaticFile("/ws_sub_test.html", "./ws/ws_sub_test.html")
 			}
+			wsGroup.GET("/:group", middleware.WSAuth(services.User), middleware.Permit(model.PermChainRead, middleware.ChainParam("group")), wsManager.WsClient)
+		}
+	}
+
+	// 业务接口，需要登录或者使用 API Key，每个接口通过 middleware.Permit 声明所需的权限：
+	// 查询类接口只需要 viewer，同步、锁定账户等日常操作需要 operator，
+	// 修改链上状态、部署合约需要 chain-admin，添加链、管理用户需要 super-admin；
+	// 链ID 不在路径参数 chainid 中的接口通过 middleware.ChainParam/ChainField 声明 handler 实际使用的字段；
+	// 修改状态的接口通过 middleware.Audit 记录审计日志
+	api := engine.Group("/api", middleware.LoginAuth(services.User, services.APIKey))
+	{
+		auth := api.Group("/auth")
+		{
+			auth.POST("/logout", controllers.User.Logout)
+			auth.POST("/logout/all", controllers.User.LogoutAll)
+			auth.POST("/password", middleware.Audit(services.Audit, "user.password"), controllers.User.ChangePassword)
+		}
+
+		user := api.Group("/user")
+		{
+			user.POST("", middleware.Audit(services.Audit, "user.create"), middleware.Permit(model.PermSystemAdmin), controllers.User.CreateUser)
+			user.POST("/role", middleware.Audit(services.Audit, "user.role"), middleware.Permit(model.PermSystemAdmin), controllers.User.SetRole)
+			user.POST("/grant", middleware.Audit(services.Audit, "user.grant", middleware.ChainField("chain_id")), middleware.Permit(model.PermSystemAdmin), controllers.User.SetGrant)
+			user.POST("/logout", middleware.Audit(services.Audit, "user.logout"), middleware.Permit(model.PermSystemAdmin), controllers.User.LogoutUser)
+		}
+		users := api.Group("/users", middleware.Permit(model.PermSystemAdmin))
+		{
+			users.POST("", controllers.User.Users)
+		}
+
+		// 所有用户都可以管理自己的 API Key
+		apiKey := api.Group("/apikey", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")))
+		{
+			apiKey.POST("", middleware.Audit(services.Audit, "apikey.create", middleware.ChainField("chain_id")), controllers.APIKey.CreateAPIKey)
+			apiKey.POST("/revoke", middleware.Audit(services.Audit, "apikey.revoke"), controllers.APIKey.RevokeAPIKey)
+		}
+		apiKeys := api.Group("/apikeys", middleware.Permit(model.PermChainRead))
+		{
+			apiKeys.GET("", controllers.APIKey.APIKeys)
+		}
 
-			wsGroup.GET("/:group", ws.DefaultWebsocketManager.WsClient)
-			wsGroup.GET("/manager", controller.DefaultWebsocketController.Manager)
-			wsGroup.GET("/groups", controller.DefaultWebsocketController.Groups)
-			wsGroup.GET("/group/:group", controller.DefaultWebsocketController.GroupByName)
+		// 审计日志，只能查看有管理权限的链
+		audit := api.Group("/audit", middleware.Permit(model.PermChainAdmin, middleware.ChainField("chain_id")))
+		{
+			audit.POST("", controllers.Audit.AuditEvents)
+			audit.POST("/export", controllers.Audit.Export)
+		}
+
+		// websocket
+		wsGroup := api.Group("/ws")
+		{
+			wsGroup.GET("/manager", middleware.Permit(model.PermChainRead), controllers.Websocket.Manager)
+			wsGroup.GET("/groups", middleware.Permit(model.PermChainRead), controllers.Websocket.Groups)
+			wsGroup.GET("/group/:group", middleware.Permit(model.PermChainRead), controllers.Websocket.GroupByName)
+			wsGroup.GET("/closed", middleware.Permit(model.PermChainRead), controllers.Websocket.ClosedClients)
 
 			if gin.Mode() == gin.DebugMode {
-				wsGroup.POST("/send", controller.DefaultWebsocketController.Send)
-				wsGroup.POST("/sendgroup", controller.DefaultWebsocketController.SendGroup)
-				wsGroup.POST("/sendall", controller.DefaultWebsocketController.SendAll)
-				wsGroup.POST("/dial", controller.DefaultWebsocketController.Dial)
-				wsGroup.POST("/clientsend", controller.DefaultWebsocketController.ClientSend)
+				wsGroup.POST("/send", middleware.Permit(model.PermSystemAdmin), controllers.Websocket.Send)
+				wsGroup.POST("/sendgroup", middleware.Permit(model.PermSystemAdmin), controllers.Websocket.SendGroup)
+				wsGroup.POST("/sendall", middleware.Permit(model.PermSystemAdmin), controllers.Websocket.SendAll)
+				wsGroup.POST("/dial", middleware.Permit(model.PermSystemAdmin), controllers.Websocket.Dial)
+				wsGroup.POST("/clientsend", middleware.Permit(model.PermSystemAdmin), controllers.Websocket.ClientSend)
 			}
 		}
 
 		chain := api.Group("/chain")
 		{
-			chain.GET("/id/:id", controller.DefaultChainController.ChainById)
-			chain.GET("/name/:name", controller.DefaultChainController.ChainByName)
-			chain.GET("/incrsync/start/:chainid", controller.DefaultChainController.IncrSyncStart)
-			chain.GET("/fullsync/start/:chainid", controller.DefaultChainController.FullSyncStart)
-			chain.GET("/sync/info/:chainid", controller.DefaultChainController.ChainDataSyncInfo)
-			chain.GET("/getsystemconfig/:id", controller.DefaultChainController.GetSystemConfig)
-			chain.GET("/stats/:chainid", controller.DefaultBlockController.Stats)
-			chain.GET("/stats/tx/count/:chainid", controller.DefaultTXController.TxAmountStats)
+			chain.GET("/id/:id", middleware.Permit(model.PermChainRead, middleware.ChainParam("id")), controllers.Chain.ChainById)
+			chain.GET("/name/:name", middleware.Permit(model.PermChainRead), controllers.Chain.ChainByName)
+			chain.GET("/incrsync/start/:chainid", middleware.Audit(services.Audit, "chain.incrsync"), middleware.Permit(model.PermChainOperate), controllers.Chain.IncrSyncStart)
+			chain.GET("/fullsync/start/:chainid", middleware.Audit(services.Audit, "chain.fullsync"), middleware.Permit(model.PermChainOperate), controllers.Chain.FullSyncStart)
+			chain.GET("/sync/info/:chainid", middleware.Permit(model.PermChainRead), controllers.Chain.ChainDataSyncInfo)
+			chain.GET("/subscription/:chainid", middleware.Permit(model.PermChainRead), controllers.Chain.ChainSubscription)
+			chain.GET("/getsystemconfig/:id", middleware.Permit(model.PermChainRead, middleware.ChainParam("id")), controllers.Chain.GetSystemConfig)
+			chain.GET("/stats/:chainid", middleware.Permit(model.PermChainRead), controllers.Block.Stats)
+			chain.GET("/stats/tx/count/:chainid", middleware.Permit(model.PermChainRead), controllers.TX.TxAmountStats)
 
-			chain.POST("/setsystemconfig", controller.DefaultChainController.SetSystemConfig)
-			chain.POST("", controller.DefaultChainController.InsertChain)
-			chain.POST("/deploy/contract/:chainid", controller.DefaultChainController.DeployContract)
+			chain.POST("/setsystemconfig", middleware.Audit(services.Audit, "chain.setsystemconfig", middleware.ChainField("chainID")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chainID")), controllers.Chain.SetSystemConfig)
+			chain.POST("", middleware.Audit(services.Audit, "chain.insert"), middleware.Permit(model.PermSystemAdmin), controllers.Chain.InsertChain)
+			chain.POST("/deploy/contract/:chainid", middleware.Audit(services.Audit, "chain.deploycontract"), middleware.Permit(model.PermChainAdmin), controllers.Chain.DeployContract)
+			chain.PUT("/:id", middleware.Audit(services.Audit, "chain.update", middleware.ChainParam("id")), middleware.Permit(model.PermChainAdmin, middleware.ChainParam("id")), controllers.Chain.UpdateChain)
+			chain.DELETE("/:id", middleware.Audit(services.Audit, "chain.delete", middleware.ChainParam("id")), middleware.Permit(model.PermSystemAdmin, middleware.ChainParam("id")), controllers.Chain.DeleteChain)
 		}
 		chains := api.Group("/chains")
 		{
-			chains.POST("", controller.DefaultChainController.Chains)
+			chains.POST("", middleware.Permit(model.PermChainRead), controllers.Chain.Chains)
 		}
 
 		block := api.Group("/block")
 		{
-			block.GET("/id/:id", controller.DefaultBlockController.BlockByID)
-			block.POST("/hash", controller.DefaultBlockController.BlockByHash)
+			block.GET("/id/:id", middleware.Permit(model.PermChainRead), controllers.Block.BlockByID)
+			block.POST("/hash", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Block.BlockByHash)
 		}
 		blocks := api.Group("/blocks")
 		{
-			blocks.POST("", controller.DefaultBlockController.Blocks)
+			blocks.POST("", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Block.Blocks)
 		}
 
 		tx := api.Group("/tx")
 		{
-			tx.GET("/id/:id", controller.DefaultTXController.TXByID)
-			tx.POST("/hash", controller.DefaultTXController.TXByHash)
+			tx.GET("/id/:id", middleware.Permit(model.PermChainRead), controllers.TX.TXByID)
+			tx.POST("/hash", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.TX.TXByHash)
 		}
 		txs := api.Group("/txs")
 		{
-			txs.POST("", controller.DefaultTXController.TXs)
-			txs.POST("contractcall", controller.DefaultTXController.TXsForContractCall)
+			txs.POST("", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.TX.TXs)
+			txs.POST("contractcall", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.TX.TXsForContractCall)
+		}
+		txPool := api.Group("/txpool")
+		{
+			txPool.GET("/:chainid", middleware.Permit(model.PermChainRead), controllers.TxPool.TxPool)
 		}
 		node := api.Group("/node")
 		{
-			node.GET("/id/:id", controller.DefaultNodeController.NodeByID)
+			node.GET("/id/:id", middleware.Permit(model.PermChainRead), controllers.Node.NodeByID)
 		}
 		nodes := api.Group("/nodes")
 		{
-			nodes.POST("", controller.DefaultNodeController.Nodes)
-			nodes.POST("/sync", controller.DefaultNodeController.NodeSync)
+			nodes.POST("", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Node.Nodes)
+			nodes.POST("/sync", middleware.Audit(services.Audit, "node.sync"), middleware.Permit(model.PermChainOperate), controllers.Node.NodeSync)
 		}
 		contract := api.Group("/contract")
 		{
-			contract.POST("/openfirewall", controller.DefaultContractController.FireWallOpen)
-			contract.POST("/closefirewall", controller.DefaultContractController.FireWallClose)
-			contract.POST("/getfirewallstatus", controller.DefaultContractController.GetFirewallStatus)
+			contract.POST("/openfirewall", middleware.Audit(services.Audit, "contract.openfirewall", middleware.ChainField("chainid")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chainid")), controllers.Contract.FireWallOpen)
+			contract.POST("/closefirewall", middleware.Audit(services.Audit, "contract.closefirewall", middleware.ChainField("chainid")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chainid")), controllers.Contract.FireWallClose)
+			contract.POST("/getfirewallstatus", middleware.Permit(model.PermChainRead, middleware.ChainField("chainid")), controllers.Contract.GetFirewallStatus)
 
-			contract.POST("/address", controller.DefaultContractController.ContractByAddress)
+			contract.POST("/address", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Contract.ContractByAddress)
 		}
 		contracts := api.Group("/contracts")
 		{
-			contracts.POST("", controller.DefaultContractController.Contracts)
+			contracts.POST("", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Contract.Contracts)
 		}
 
 		cns := api.Group("/cns")
 		{
-			cns.GET("/:id", controller.DefaultCNSController.CNSByID)
-			cns.POST("/register", controller.DefaultCNSController.Register)
-			cns.POST("/redirect", controller.DefaultCNSController.Redirect)
+			cns.GET("/:id", middleware.Permit(model.PermChainRead), controllers.CNS.CNSByID)
+			cns.POST("/register", middleware.Audit(services.Audit, "cns.register", middleware.ChainField("chain_id")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chain_id")), controllers.CNS.Register)
+			cns.POST("/redirect", middleware.Audit(services.Audit, "cns.redirect", middleware.ChainField("chain_id")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chain_id")), controllers.CNS.Redirect)
 		}
 		cnss := api.Group("cnss")
 		{
-			cnss.POST("", controller.DefaultCNSController.CNSs)
+			cnss.POST("", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.CNS.CNSs)
 		}
 
 		account := api.Group("/account")
 		{
-			account.POST("/lock", controller.DefaultAccountController.LockAccount)
-			account.POST("/unlock", controller.DefaultAccountController.UnlockAccount)
+			account.POST("/lock", middleware.Audit(services.Audit, "account.lock", middleware.ChainField("chain_id")), middleware.Permit(model.PermChainOperate, middleware.ChainField("chain_id")), controllers.Account.LockAccount)
+			account.POST("/unlock", middleware.Audit(services.Audit, "account.unlock", middleware.ChainField("chain_id")), middleware.Permit(model.PermChainAdmin, middleware.ChainField("chain_id")), controllers.Account.UnlockAccount)
 			//todo roleset
 			//account.POST("/roleset",controller.DefaultAccountController.SetRole)
-			account.POST("/list", controller.DefaultAccountController.ListAccount)
+			account.POST("/list", middleware.Permit(model.PermChainRead, middleware.ChainField("chain_id")), controllers.Account.ListAccount)
 		}
 	}
 }
@@ -16,23 +16,20 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var (
-	DefaultAccountService IAccountService
-)
-
-func init() {
-	DefaultAccountService = newAccountService()
-}
-
-func newAccountService() IAccountService {
-	return &accountService{}
+func newAccountService(daos *dao.Daos, rpcServer *rpc.Server) IAccountService {
+	return &accountService{
+		nodeDao:   daos.Node,
+		rpcServer: rpcServer,
+	}
 }
 
 type accountService struct {
+	nodeDao   dao.INodeDao
+	rpcServer *rpc.Server
 }
 
 func (s *accountService) LockAccount(dto model.LockAccountDTO) (bool, error) {
-	client, err := rpc.GetRPCClientByChainID(dto.ChainID)
+	client, err := s.rpcServer.GetRPCClientByChainID(dto.ChainID)
 	if err != nil {
 		return false, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
 	}
@@ -47,7 +44,7 @@ func (s *accountService) LockAccount(dto model.LockAccountDTO) (bool, error) {
 }
 
 func (s *accountService) UnlockAccount(dto model.UnlockAccountDTO) (bool, error) {
-	client, err := rpc.GetRPCClientByChainID(dto.ChainID)
+	client, err := s.rpcServer.GetRPCClientByChainID(dto.ChainID)
 	if err != nil {
 		return false, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
 	}
@@ -62,7 +59,7 @@ func (s *accountService) UnlockAccount(dto model.UnlockAccountDTO) (bool, error)
 }
 
 func (s *accountService) FirstAccount(chainID string) (string, error) {
-	client, err := rpc.GetRPCClientByChainID(chainID)
+	client, err := s.rpcServer.GetRPCClientByChainID(chainID)
 	if err != nil {
 		return "", exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
@@ -84,7 +81,7 @@ func (s *accountService) ListAccounts(dto model.AccountDTO) ([]*model.AccountVO,
 	var accounts []*model.AccountVO
 	// 获取指定节点的账户信息
 	if dto.NodeID != "" {
-		client, err := rpc.GetRPCClientByChainIDAndNodeID(dto.ChainID, dto.NodeID)
+		client, err := s.rpcServer.GetRPCClientByChainIDAndNodeID(dto.ChainID, dto.NodeID)
 		if err != nil {
 			return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 		}
@@ -110,13 +107,13 @@ func (s *accountService) ListAccounts(dto model.AccountDTO) ([]*model.AccountVO,
 	filter["chain_id"], _ = primitive.ObjectIDFromHex(dto.ChainID)
 	condition := model.NodeQueryCondition{}
 	findOps := util.BuildOptionsByQuery(condition.PageIndex, condition.PageSize)
-	nodes, err := dao.DefaultNodeDao.Nodes(filter, findOps)
+	nodes, err := s.nodeDao.Nodes(filter, findOps)
 	if err != nil {
 		logrus.Errorln(err)
 	}
 
 	for _, node := range nodes {
-		client, err := rpc.GetRPCClientByChainIDAndNodeID(dto.ChainID, node.ID.Hex())
+		client, err := s.rpcServer.GetRPCClientByChainIDAndNodeID(dto.ChainID, node.ID.Hex())
 		if err != nil {
 			return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 		}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"time"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/secret"
+	"graces/web/dao"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// API Key 的固定前缀，便于在日志和代码仓库中识别泄露的 key
+	apiKeyPrefix = "gk_"
+	// 返回给用户用于识别 key 的长度
+	apiKeyDisplayLen = 11
+	// 最后使用时间的更新间隔，避免每次请求都写库，单位：秒
+	apiKeyTouchInterval = 60
+)
+
+func newAPIKeyService(daos *dao.Daos) IAPIKeyService {
+	return &apiKeyService{
+		dao:     daos.APIKey,
+		userDao: daos.User,
+	}
+}
+
+type apiKeyService struct {
+	dao     dao.IAPIKeyDao
+	userDao dao.IUserDao
+}
+
+func (s *apiKeyService) CreateAPIKey(user *model.User, dto model.APIKeyDTO) (*model.APIKeyCreatedVO, error) {
+	if _, err := primitive.ObjectIDFromHex(dto.ChainID); dto.ChainID != "" && err != nil {
+		return nil, exterr.ErrObjectIDInvalid
+	}
+	// 授权范围不能超出用户自己的权限
+	for _, scope := range dto.Scopes {
+		if !scope.IsValid() {
+			return nil, exterr.NewError(exterr.ErrCodeParameterInvalid, "unknown scope: "+string(scope))
+		}
+		if !user.HasPermission(scope, dto.ChainID) {
+			return nil, exterr.ErrUserHasNoPermission
+		}
+	}
+
+	key := apiKeyPrefix + secret.RandomString(24)
+	now := time.Now()
+	apiKey := model.APIKey{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		Name:       dto.Name,
+		Prefix:     key[:apiKeyDisplayLen],
+		KeyHash:    secret.HashToken(key),
+		Scopes:     dto.Scopes,
+		ChainID:    dto.ChainID,
+		ExpiresAt:  now.Add(time.Duration(dto.ExpireDays) * 24 * time.Hour).Unix(),
+		CreateTime: now.Unix(),
+	}
+	if err := s.dao.InsertAPIKey(apiKey); err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	logrus.Infof("user [%s] created api key [%s] %v", user.Username, apiKey.Prefix, apiKey.Scopes)
+	return &model.APIKeyCreatedVO{
+		APIKeyVO: *apiKey.ToVO(),
+		Key:      key,
+	}, nil
+}
+
+func (s *apiKeyService) APIKeys(user *model.User) ([]*model.APIKeyVO, error) {
+	findOps := options.Find().SetSort(bson.D{{"_id", -1}})
+	keys, err := s.dao.APIKeys(bson.M{"user_id": user.ID}, findOps)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	vos := make([]*model.APIKeyVO, 0, len(keys))
+	for _, key := range keys {
+		vos = append(vos, key.ToVO())
+	}
+	return vos, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(user *model.User, id string) error {
+	keyID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return exterr.ErrObjectIDInvalid
+	}
+	// 只能吊销自己的 key
+	filter := bson.M{"_id": keyID, "user_id": user.ID}
+	if _, err := s.dao.APIKey(filter); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return exterr.ErrAPIKeyInvalid
+		}
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if err := s.dao.Update(filter, bson.M{"$set": bson.M{"revoked": true}}, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	return nil
+}
+
+func (s *apiKeyService) CheckAPIKey(key string) (*model.User, *model.APIKey, error) {
+	apiKey, err := s.dao.APIKey(bson.M{"key_hash": secret.HashToken(key)})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, exterr.ErrAPIKeyInvalid
+		}
+		return nil, nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if apiKey.Revoked {
+		return nil, nil, exterr.ErrAPIKeyInvalid
+	}
+	now := time.Now().Unix()
+	if apiKey.ExpiresAt < now {
+		return nil, nil, exterr.ErrAPIKeyExpired
+	}
+	user, err := s.userDao.User(bson.M{"_id": apiKey.UserID})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, exterr.ErrAPIKeyInvalid
+		}
+		return nil, nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if now-apiKey.LastUsedTime >= apiKeyTouchInterval {
+		update := bson.M{"$set": bson.M{"last_used_time": now}}
+		if err := s.dao.Update(bson.M{"_id": apiKey.ID}, update, nil); err != nil {
+			logrus.Errorf("update api key [%s] last used time err: %v", apiKey.Prefix, err)
+		}
+		apiKey.LastUsedTime = now
+	}
+	return user, apiKey, nil
+}
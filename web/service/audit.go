@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/util"
+	"graces/web/dao"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 单次导出的最大记录数
+const auditExportLimit = 100000
+
+var (
+	auditCSVHeader = []string{"time", "username", "user_id", "api_key", "source", "client_ip",
+		"chain_id", "operation", "success", "tx_hash", "err_msg", "params"}
+)
+
+func newAuditService(daos *dao.Daos) IAuditService {
+	return &auditService{
+		dao: daos.Audit,
+	}
+}
+
+type auditService struct {
+	dao dao.IAuditDao
+}
+
+func (s *auditService) Record(event model.AuditEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	if event.CreateTime == 0 {
+		event.CreateTime = time.Now().Unix()
+	}
+	event.Params = util.RedactParams(event.Params)
+	if err := s.dao.InsertAuditEvent(event); err != nil {
+		logrus.Errorf("record audit event [%s] by [%s] err: %v", event.Operation, event.Username, err)
+		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	return nil
+}
+
+func (s *auditService) AuditEvents(condition model.AuditQueryCondition) ([]*model.AuditEventVO, error) {
+	findOps := util.BuildOptionsByQuery(condition.PageIndex, condition.PageSize)
+	findOps.Sort = bson.D{{"create_time", -1}, {"_id", -1}}
+	events, err := s.dao.AuditEvents(s.buildFilterByCondition(condition), findOps)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	vos := make([]*model.AuditEventVO, 0, len(events))
+	for _, event := range events {
+		vos = append(vos, event.ToVO())
+	}
+	return vos, nil
+}
+
+func (s *auditService) Count(condition model.AuditQueryCondition) (int64, error) {
+	cnt, err := s.dao.Count(s.buildFilterByCondition(condition), nil)
+	if err != nil {
+		return 0, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	return cnt, nil
+}
+
+func (s *auditService) ExportCSV(condition model.AuditQueryCondition, w io.Writer) error {
+	findOps := options.Find().SetSort(bson.D{{"create_time", -1}, {"_id", -1}}).SetLimit(auditExportLimit)
+	events, err := s.dao.AuditEvents(s.buildFilterByCondition(condition), findOps)
+	if err != nil {
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(auditCSVHeader); err != nil {
+		return err
+	}
+	for _, event := range events {
+		params, _ := json.Marshal(event.Params)
+		record := []string{
+			util.Timestamp2TimeStr(event.CreateTime),
+			event.Username,
+			event.UserID,
+			event.APIKey,
+			event.Source,
+			event.ClientIP,
+			event.ChainID,
+			event.Operation,
+			strconv.FormatBool(event.Success),
+			event.TxHash,
+			event.ErrMsg,
+			string(params),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// 构建查询条件过滤器
+func (s *auditService) buildFilterByCondition(condition model.AuditQueryCondition) bson.M {
+	filter := bson.M{}
+	if condition.Username != "" {
+		filter["username"] = condition.Username
+	}
+	if condition.ChainID != "" {
+		filter["chain_id"] = condition.ChainID
+	}
+	if condition.Operation != "" {
+		filter["operation"] = condition.Operation
+	}
+	switch condition.Status {
+	case model.AuditStatusSuccess:
+		filter["success"] = true
+	case model.AuditStatusFailure:
+		filter["success"] = false
+	}
+	createTime := bson.M{}
+	if condition.StartTime > 0 {
+		createTime["$gte"] = condition.StartTime
+	}
+	if condition.EndTime > 0 {
+		createTime["$lte"] = condition.EndTime
+	}
+	if len(createTime) > 0 {
+		filter["create_time"] = createTime
+	}
+	return filter
+}
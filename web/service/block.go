@@ -1,12 +1,10 @@
 package service
 
 import (
-	"context"
 	"fmt"
 	"reflect"
 	"time"
 
-	"graces/db"
 	"graces/exterr"
 	"graces/model"
 	"graces/util"
@@ -18,22 +16,22 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	DefaultBlockService IBlockService
-)
-
-func init() {
-	DefaultBlockService = newBlockService()
-}
-
-func newBlockService() IBlockService {
+func newBlockService(daos *dao.Daos, services *Services) IBlockService {
 	return &blockService{
-		dao: dao.DefaultBlockDao,
+		dao:      daos.Block,
+		chainDao: daos.Chain,
+		txDao:    daos.TX,
+		nodeDao:  daos.Node,
+		services: services,
 	}
 }
 
 type blockService struct {
-	dao dao.IBlockDao
+	dao      dao.IBlockDao
+	chainDao dao.IChainDao
+	txDao    dao.ITXDao
+	nodeDao  dao.INodeDao
+	services *Services
 }
 
 func (s *blockService) BlockByID(id string) (*model.BlockVO, error) {
@@ -48,6 +46,9 @@ func (s *blockService) BlockByID(id string) (*model.BlockVO, error) {
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
+	if err := checkLiveChain(s.chainDao, block.ChainID); err != nil {
+		return nil, err
+	}
 	return block.ToVO()
 }
 
@@ -56,6 +57,9 @@ func (s *blockService) BlockByHash(chainID string, hash string) (*model.BlockVO,
 	if err != nil {
 		return nil, exterr.ErrObjectIDInvalid
 	}
+	if err := checkLiveChain(s.chainDao, cid); err != nil {
+		return nil, err
+	}
 	filter := bson.M{
 		"chain_id": cid,
 		"hash":     bson.M{"$regex": fmt.Sprintf("^(?i)%s$", hash)},
@@ -112,9 +116,12 @@ func (s *blockService) Count(condition model.BlockQueryCondition) (int64, error)
 
 func (s *blockService) ChainStats(chainID string) (model.StatsVO, error) {
 	var result model.StatsVO
-	result.TotalTx = getTotalTx(chainID)
-	result.TotalContract = getTotalContract(chainID)
-	result.TotalNode = getTotalNode(chainID)
+	if _, err := liveChainCondition(s.chainDao, chainID); err != nil {
+		return result, err
+	}
+	result.TotalTx = getTotalTx(chainID, s)
+	result.TotalContract = getTotalContract(chainID, s)
+	result.TotalNode = getTotalNode(chainID, s)
 	result.LatestBlock = getLatestBlock(chainID, s)
 	return result, nil
 }
@@ -129,13 +136,11 @@ func (s *blockService) buildFilterByCondition(condition model.BlockQueryConditio
 		}
 		filter["_id"] = id
 	}
-	if !reflect.ValueOf(condition.ChainID).IsZero() {
-		chainID, err := primitive.ObjectIDFromHex(condition.ChainID)
-		if err != nil {
-			return nil, exterr.ErrObjectIDInvalid
-		}
-		filter["chain_id"] = chainID
+	chainID, err := liveChainCondition(s.chainDao, condition.ChainID)
+	if err != nil {
+		return nil, err
 	}
+	filter["chain_id"] = chainID
 	if !reflect.ValueOf(condition.Proposer).IsZero() {
 		filter["proposer"] = condition.Proposer
 	}
@@ -166,16 +171,13 @@ func (s *blockService) buildFilterByCondition(condition model.BlockQueryConditio
 	return filter, nil
 }
 
-func getTotalTx(chainID string) int64 {
-	collection := db.DefaultDB.Collection(collectionNameTX)
-	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
-
+func getTotalTx(chainID string, s *blockService) int64 {
 	objectId, err := primitive.ObjectIDFromHex(chainID)
 	if err != nil {
 		return 0
 	}
 	filter := bson.M{"chain_id": objectId}
-	count, err := collection.CountDocuments(ctx, filter)
+	count, err := s.txDao.Count(filter, nil)
 	if nil != err {
 		logrus.Errorln("get tx count error")
 		return 0
@@ -183,7 +185,7 @@ func getTotalTx(chainID string) int64 {
 	return count
 }
 
-func getTotalContract(chainID string) int64 {
+func getTotalContract(chainID string, s *blockService) int64 {
 	//collection := db.DefaultDB.Collection(collectionNameTX)
 	//ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
 	objectId, err := primitive.ObjectIDFromHex(chainID)
@@ -198,7 +200,7 @@ func getTotalContract(chainID string) int64 {
 	contractCondition := model.ContractQueryCondition{
 		ChainID: chainID,
 	}
-	count, err := DefaultContractService.Count(contractCondition)
+	count, err := s.services.Contract.Count(contractCondition)
 	if nil != err {
 		logrus.Errorln("get contract count error")
 		return 0
@@ -206,15 +208,13 @@ func getTotalContract(chainID string) int64 {
 	return count
 }
 
-func getTotalNode(chainID string) int64 {
-	collection := db.DefaultDB.Collection(collectionNameNode)
-	ctx, _ := context.WithTimeout(context.Background(), defaultTimeout)
+func getTotalNode(chainID string, s *blockService) int64 {
 	objectId, err := primitive.ObjectIDFromHex(chainID)
 	if err != nil {
 		return 0
 	}
 	filter := bson.M{"chain_id": objectId}
-	count, err := collection.CountDocuments(ctx, filter)
+	count, err := s.nodeDao.Count(filter, nil)
 	if nil != err {
 		logrus.Errorln("get node count error")
 		return 0
@@ -236,7 +236,7 @@ func getLatestBlock(chainID string, s *blockService) uint64 {
 	//	return 0
 	//}
 	//return res
-	block, err := dao.DefaultBlockDao.LatestBlock(objectId)
+	block, err := s.dao.LatestBlock(objectId)
 	if err != nil {
 		return 0
 	}
@@ -10,6 +10,7 @@ import (
 	"graces/exterr"
 	"graces/model"
 	"graces/rpc"
+	"graces/secret"
 	"graces/util"
 	"graces/web/dao"
 	"graces/ws"
@@ -18,37 +19,41 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	collectionNameTX     = "txs"
-	collectionNameNode   = "nodes"
-	collectionNameBlocks = "blocks"
-	defaultTimeout       = 120 * time.Second
-)
-
-var (
-	DefaultChainService IChainService
-)
-
-func init() {
-	DefaultChainService = newChainService(dao.DefaultChainDao)
-}
-
-func newChainService(dao dao.IChainDao) *chainService {
+func newChainService(daos *dao.Daos, rpcServer *rpc.Server, subscriber *ws.WSSubscriber, services *Services) *chainService {
 	return &chainService{
-		dao: dao,
+		dao:        daos.Chain,
+		daos:       daos,
+		rpcServer:  rpcServer,
+		subscriber: subscriber,
+		services:   services,
 	}
 }
 
 type chainService struct {
 	dao dao.IChainDao
+	// 删除链时一并删除链的区块、交易、合约、CNS 和节点数据
+	daos       *dao.Daos
+	rpcServer  *rpc.Server
+	subscriber *ws.WSSubscriber
+	services   *Services
 }
 
 //IsExist
 // 判断一条链是否存在，当前的判断方法是判断 name 相同，或 IP 相同且 prc_port、p2p_port、ws_port 至少有一个相同
 func (s chainService) IsExist(chainDTO model.ChainDTO) bool {
+	chain, err := s.dao.Chain(s.buildExistFilter(chainDTO))
+	if err != nil || chain.ID.IsZero() {
+		return false
+	}
+	return true
+}
+
+// 构建链验重的过滤器
+func (s chainService) buildExistFilter(chainDTO model.ChainDTO) bson.M {
 	filter := bson.M{}
 	filter["$or"] = []bson.D{
 		{{"name", chainDTO.Name}},
@@ -63,11 +68,7 @@ func (s chainService) IsExist(chainDTO model.ChainDTO) bool {
 		}},
 	}
 	// 同SQL：select * from chains where name = #{name} or (ip = #{ip} and (rpc_port = #{rpc_port} or p2p_port = #{p2p_port} or ws_port = #{ws_port}))
-	chain, err := s.dao.Chain(filter)
-	if err != nil || chain.ID.IsZero() {
-		return false
-	}
-	return true
+	return filter
 }
 
 func (s *chainService) InsertChain(chainDTO model.ChainDTO) error {
@@ -86,6 +87,9 @@ func (s *chainService) InsertChain(chainDTO model.ChainDTO) error {
 	if chain.ChainConfig == nil {
 		chain.ChainConfig = config.Config.ChainConfig
 	}
+	if err := ws.ValidateTopics(chain.ChainConfig); err != nil {
+		return exterr.NewError(exterr.ErrCodeParameterInvalid, err.Error())
+	}
 
 	// 3、ping
 	_, err = s.ping(*chain)
@@ -93,16 +97,141 @@ func (s *chainService) InsertChain(chainDTO model.ChainDTO) error {
 		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
 	}
 
-	// 4、保存链数据
+	// 4、加密敏感字段后保存链数据
+	chain.ChainConfig, err = secret.EncryptChainConfig(chain.ChainConfig)
+	if err != nil {
+		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
 	err = s.dao.InsertChain(*chain)
 	if err != nil {
 		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
 	}
 
-	// 5、给新增的链订阅事件
-	err = ws.DefaultWSSubscriber.SubTopicsForChain(chain)
+	// 5、通知订阅器给新增的链订阅事件
+	s.subscriber.Publish(model.ChainEvent{Type: model.ChainEventInsert, Chain: chain})
+	return nil
+}
+
+// UpdateChain 更新链信息，链地址或配置变更后会移除旧的 RPC 客户端并重新订阅 websocket topics
+func (s *chainService) UpdateChain(id string, chainDTO model.ChainDTO) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
+		return exterr.ErrObjectIDInvalid
+	}
+	filter := bson.M{"_id": objectId}
+	old, err := s.dao.Chain(filter)
+	if err != nil {
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+
+	// 1、验重，排除自身
+	existFilter := bson.M{"$and": []bson.M{s.buildExistFilter(chainDTO), {"_id": bson.M{"$ne": objectId}}}}
+	if chain, err := s.dao.Chain(existFilter); err == nil && !chain.ID.IsZero() {
+		msg := fmt.Sprintf("chain[%s] already exists (duplicate name, IP and port number)", chainDTO.Name)
+		return exterr.NewError(exterr.ErrCodeParameterInvalid, msg)
+	}
+
+	// 2、构建更新后的链数据
+	chain := *old
+	chain.Name = chainDTO.Name
+	chain.IP = chainDTO.IP
+	chain.RPCPort = chainDTO.RPCPort
+	chain.P2PPort = chainDTO.P2PPort
+	chain.WSPort = chainDTO.WSPort
+	chain.Desc = chainDTO.Desc
+	if chainDTO.ChainConfig != nil {
+		chain.ChainConfig, err = s.mergeChainConfig(old.ChainConfig, chainDTO.ChainConfig)
+		if err != nil {
+			return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+		}
+		if err := ws.ValidateTopics(chain.ChainConfig); err != nil {
+			return exterr.NewError(exterr.ErrCodeParameterInvalid, err.Error())
+		}
+	}
+	chain.UpdateTime = time.Now().Unix()
+
+	// 3、链地址变更时 ping 新地址
+	if chain.IP != old.IP || chain.RPCPort != old.RPCPort || chain.P2PPort != old.P2PPort || chain.WSPort != old.WSPort {
+		if _, err := s.ping(chain); err != nil {
+			return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+		}
+	}
+
+	// 4、保存链数据
+	update := bson.M{"$set": bson.M{
+		"name":         chain.Name,
+		"ip":           chain.IP,
+		"rpc_port":     chain.RPCPort,
+		"p2p_port":     chain.P2PPort,
+		"ws_port":      chain.WSPort,
+		"desc":         chain.Desc,
+		"chain_config": chain.ChainConfig,
+		"update_time":  chain.UpdateTime,
+	}}
+	if err := s.dao.Update(filter, update, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+
+	// 5、移除旧的 RPC 客户端，并通知订阅器按新的链信息重新订阅事件
+	s.rpcServer.RemoveRPCClientsByChain(*old)
+	s.subscriber.Publish(model.ChainEvent{Type: model.ChainEventUpdate, Chain: &chain, Old: old})
+	return nil
+}
+
+// DeleteChain 软删除链，断开它的 websocket 订阅并移除 RPC 客户端；
+// purge 为 true 时物理删除链及其区块、交易、合约、CNS 和节点数据，已被软删除的链也可以 purge
+func (s *chainService) DeleteChain(id string, purge bool) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return exterr.ErrObjectIDInvalid
+	}
+	filter := bson.M{"_id": objectId}
+	chain, err := s.dao.Chain(filter)
+	if err != nil && !purge {
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+
+	// 1、通知订阅器断开订阅，移除 RPC 客户端
+	if chain != nil {
+		s.subscriber.Publish(model.ChainEvent{Type: model.ChainEventDelete, Chain: chain})
+		s.rpcServer.RemoveRPCClientsByChain(*chain)
+	}
+
+	// 2、软删除
+	if !purge {
+		now := time.Now().Unix()
+		update := bson.M{"$set": bson.M{"delete_time": now, "update_time": now}}
+		if err := s.dao.Update(filter, update, nil); err != nil {
+			return exterr.NewError(exterr.ErrCodeDelete, err.Error())
+		}
+		return nil
+	}
+
+	// 3、物理删除链的数据，最后删除链本身，中途失败时可以重新 purge
+	dataFilter := bson.M{"chain_id": objectId}
+	purgers := []struct {
+		name       string
+		deleteMany func(filter interface{}) (int64, error)
+	}{
+		{"blocks", s.daos.Block.DeleteMany},
+		{"txs", s.daos.TX.DeleteMany},
+		{"contracts", s.daos.Contract.DeleteMany},
+		{"cns", s.daos.CNS.DeleteMany},
+		{"nodes", s.daos.Node.DeleteMany},
+	}
+	for _, p := range purgers {
+		cnt, err := p.deleteMany(dataFilter)
+		if err != nil {
+			return exterr.NewError(exterr.ErrCodeDelete, fmt.Sprintf("purge %s of chain[%s] err: %v", p.name, id, err))
+		}
+		logrus.Infof("purge %d %s of chain[%s]", cnt, p.name, id)
+	}
+	cnt, err := s.dao.DeleteMany(filter)
+	if err != nil {
+		return exterr.NewError(exterr.ErrCodeDelete, err.Error())
+	}
+	if cnt == 0 {
+		return exterr.NewError(exterr.ErrCodeDelete, fmt.Sprintf("chain[%s] not exist", id))
 	}
 	return nil
 }
@@ -189,7 +318,7 @@ func (s *chainService) GetSysConfigString(id string, funcName string) (string, e
 	var result string
 	contractAddr := precompile.ParameterManagementAddress
 	defaultInter := "wasm"
-	chain, _ := rpc.GetRPCClientByChainID(id)
+	chain, _ := s.rpcServer.GetRPCClientByChainID(id)
 	caller := rpc.NewMsgCaller(chain)
 
 	data := rpc.NewContractParams(contractAddr, funcName, defaultInter, nil, nil)
@@ -212,12 +341,12 @@ func (s *chainService) SetSysConfigString(id string, funcName string, funcParams
 	var err error
 	contractAddr := precompile.ParameterManagementAddress
 	defaultInter := "wasm"
-	chainid, _ := rpc.GetRPCClientByChainID(id)
+	chainid, _ := s.rpcServer.GetRPCClientByChainID(id)
 	caller := rpc.NewMsgCaller(chainid)
 
 	data := rpc.NewContractParams(contractAddr, funcName, defaultInter, nil, funcParams)
 	txParams := &rpc.TxParams{}
-	txParams.From, err = DefaultAccountService.FirstAccount(id)
+	txParams.From, err = s.services.Account.FirstAccount(id)
 	if err != nil {
 		logrus.Errorln("get first account is error")
 		return "", err
@@ -232,6 +361,20 @@ func (s *chainService) SetSysConfigString(id string, funcName string, funcParams
 	return result, nil
 }
 
+// 加密新的链配置中的敏感字段，链账户密码为掩码时沿用旧的密码
+func (s *chainService) mergeChainConfig(old map[string]interface{}, chainConfig map[string]interface{}) (map[string]interface{}, error) {
+	node, ok := chainConfig["node"].(map[string]interface{})
+	masked := ok && node["passphrase"] == model.MaskedPassphrase
+	chainConfig, err := secret.EncryptChainConfig(chainConfig)
+	if err != nil {
+		return nil, err
+	}
+	if oldNode, ok := old["node"].(map[string]interface{}); ok && masked {
+		chainConfig["node"].(map[string]interface{})["passphrase"] = oldNode["passphrase"]
+	}
+	return chainConfig, nil
+}
+
 // 构建查询条件过滤器
 func (s *chainService) buildFilterByCondition(condition model.ChainQueryCondition) (interface{}, error) {
 	filter := bson.M{}
@@ -293,3 +436,38 @@ func (s *chainService) ping(chain model.Chain) (bool, error) {
 	}
 	return rpcPing && p2pPing && wsPing, nil
 }
+
+// 链存在且未被软删除，已删除的链的区块、交易等数据不能再查询
+func checkLiveChain(chainDao dao.IChainDao, id primitive.ObjectID) error {
+	if _, err := chainDao.Chain(bson.M{"_id": id}); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return exterr.NewError(exterr.ErrCodeFind, fmt.Sprintf("chain[%s] not exist", id.Hex()))
+		}
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	return nil
+}
+
+// 链数据查询的 chain_id 条件：指定链ID时该链必须存在且未被软删除，未指定时只查询未删除的链的数据
+func liveChainCondition(chainDao dao.IChainDao, chainID string) (interface{}, error) {
+	if chainID != "" {
+		id, err := primitive.ObjectIDFromHex(chainID)
+		if err != nil {
+			return nil, exterr.ErrObjectIDInvalid
+		}
+		if err := checkLiveChain(chainDao, id); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	findOps := options.Find().SetProjection(bson.M{"_id": 1})
+	chains, err := chainDao.Chains(bson.M{}, findOps)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	ids := make(bson.A, 0, len(chains))
+	for _, chain := range chains {
+		ids = append(ids, chain.ID)
+	}
+	return bson.M{"$in": ids}, nil
+}
@@ -19,20 +19,22 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	DefaultCNSService ICNSService
-)
-
-func init() {
-	DefaultCNSService = newCNSService()
-}
-
-func newCNSService() ICNSService {
-	return &cnsService{dao: dao.DefaultCNSDao}
+func newCNSService(daos *dao.Daos, rpcServer *rpc.Server, syncManager *syncer.ChainDataSyncManager, services *Services) ICNSService {
+	return &cnsService{
+		dao:         daos.CNS,
+		chainDao:    daos.Chain,
+		rpcServer:   rpcServer,
+		syncManager: syncManager,
+		services:    services,
+	}
 }
 
 type cnsService struct {
-	dao dao.ICNSDao
+	dao         dao.ICNSDao
+	chainDao    dao.IChainDao
+	rpcServer   *rpc.Server
+	syncManager *syncer.ChainDataSyncManager
+	services    *Services
 }
 
 func (s *cnsService) CNSByID(id string) (*model.CNSVO, error) {
@@ -47,6 +49,9 @@ func (s *cnsService) CNSByID(id string) (*model.CNSVO, error) {
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
+	if err := checkLiveChain(s.chainDao, cns.ChainID); err != nil {
+		return nil, err
+	}
 	return cns.ToVO()
 }
 
@@ -55,6 +60,9 @@ func (s *cnsService) CNS(chainID string, name, address, version string) (*model.
 	if err != nil {
 		return nil, exterr.ErrObjectIDInvalid
 	}
+	if err := checkLiveChain(s.chainDao, cid); err != nil {
+		return nil, err
+	}
 	filter := bson.M{
 		"chain_id": cid,
 		"name":     bson.M{"$regex": fmt.Sprintf("^(?i)%s$", name)},
@@ -120,7 +128,7 @@ func (s *cnsService) Register(dto model.CNSRegisterDTO) (*model.ContractCallResu
 	if exist {
 		return nil, exterr.NewError(exterr.ErrCodeUpdate, "the given cns name already exists")
 	}
-	account, err := DefaultAccountService.FirstAccount(dto.ChainID)
+	account, err := s.services.Account.FirstAccount(dto.ChainID)
 	if err != nil {
 		return nil, err
 	}
@@ -132,12 +140,12 @@ func (s *cnsService) Register(dto model.CNSRegisterDTO) (*model.ContractCallResu
 		Password: "0",
 		Duration: 0,
 	}
-	unlock, err := DefaultAccountService.UnlockAccount(accountDTO)
+	unlock, err := s.services.Account.UnlockAccount(accountDTO)
 	if err != nil || !unlock {
 		return nil, err
 	}
 
-	client, err := rpc.GetRPCClientByChainID(dto.ChainID)
+	client, err := s.rpcServer.GetRPCClientByChainID(dto.ChainID)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
 	}
@@ -150,7 +158,7 @@ func (s *cnsService) Register(dto model.CNSRegisterDTO) (*model.ContractCallResu
 		return nil, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
 	}
 	logrus.Debugf("cnsRegister result：%+v", res)
-	results, err := DefaultContractService.ParseContractCallResult(dto.ChainID, res)
+	results, err := s.services.Contract.ParseContractCallResult(dto.ChainID, res)
 	if err != nil {
 		return nil, err
 	}
@@ -190,16 +198,16 @@ func (s *cnsService) isNameExist(chainID, name, version string) (bool, error) {
 }
 
 func (s *cnsService) fireCNSSync(chainID string) {
-	syncInfo := syncer.DefaultChainDataSyncManager.BuildChainSyncInfo(chainID)
+	syncInfo := s.syncManager.BuildChainSyncInfo(chainID)
 	if syncInfo.CNSDataSyncInfo != nil && syncInfo.CNSDataSyncInfo.Status == syncer.StatusSyncing {
 		logrus.Infof("this chain[%s] CNS data is syncing, don't repeat sync for it", chainID)
 		return
 	}
-	err := syncer.DefaultChainDataSyncManager.SyncCNS(chainID, true)
+	err := s.syncManager.SyncCNS(chainID, true)
 	if err != nil {
 		syncInfo.CNSDataSyncInfo.ErrMsg = err.Error()
 		syncInfo.CNSDataSyncInfo.Status = syncer.StatusError
-		syncer.DefaultChainDataSyncManager.ErrChan <- &model.SyncErrMsg{
+		s.syncManager.ErrChan <- &model.SyncErrMsg{
 			ChainID: chainID,
 			ErrType: syncer.ErrTypeBlockOrTXSync,
 			Err:     err,
@@ -246,7 +254,7 @@ func (s *cnsService) Redirect(dto model.CNSRedirectDTO) (*model.ContractCallResu
 		return nil, exterr.NewError(exterr.ErrCodeUpdate, "the given cns not find")
 	}
 
-	account, err := DefaultAccountService.FirstAccount(dto.ChainID)
+	account, err := s.services.Account.FirstAccount(dto.ChainID)
 	if err != nil {
 		return nil, err
 	}
@@ -258,12 +266,12 @@ func (s *cnsService) Redirect(dto model.CNSRedirectDTO) (*model.ContractCallResu
 		Password: "0",
 		Duration: 0,
 	}
-	unlock, err := DefaultAccountService.UnlockAccount(accountDTO)
+	unlock, err := s.services.Account.UnlockAccount(accountDTO)
 	if err != nil || !unlock {
 		return nil, err
 	}
 
-	client, err := rpc.GetRPCClientByChainID(dto.ChainID)
+	client, err := s.rpcServer.GetRPCClientByChainID(dto.ChainID)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
 	}
@@ -278,7 +286,7 @@ func (s *cnsService) Redirect(dto model.CNSRedirectDTO) (*model.ContractCallResu
 
 	logrus.Debugf("cnsRedirect result：%+v", res)
 
-	results, err := DefaultContractService.ParseContractCallResult(dto.ChainID, res)
+	results, err := s.services.Contract.ParseContractCallResult(dto.ChainID, res)
 	if err != nil {
 		return nil, err
 	}
@@ -329,13 +337,11 @@ func (s *cnsService) buildFilterByCondition(condition model.CNSQueryCondition) (
 		}
 		filter["_id"] = id
 	}
-	if !reflect.ValueOf(condition.ChainID).IsZero() {
-		chainID, err := primitive.ObjectIDFromHex(condition.ChainID)
-		if err != nil {
-			return nil, exterr.ErrObjectIDInvalid
-		}
-		filter["chain_id"] = chainID
+	chainID, err := liveChainCondition(s.chainDao, condition.ChainID)
+	if err != nil {
+		return nil, err
 	}
+	filter["chain_id"] = chainID
 	if !reflect.ValueOf(condition.Name).IsZero() {
 		filter["name"] = bson.M{"$regex": fmt.Sprintf("^(?i)%s$", condition.Name)}
 	}
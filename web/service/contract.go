@@ -23,20 +23,20 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var DefaultContractService IContractService
-
-func init() {
-	DefaultContractService = newContractService()
-}
-
-func newContractService() IContractService {
+func newContractService(daos *dao.Daos, rpcServer *rpc.Server, services *Services) IContractService {
 	return &contractService{
-		dao: dao.DefaultContractDao,
+		dao:       daos.Contract,
+		chainDao:  daos.Chain,
+		rpcServer: rpcServer,
+		services:  services,
 	}
 }
 
 type contractService struct {
-	dao dao.IContractDao
+	dao       dao.IContractDao
+	chainDao  dao.IChainDao
+	rpcServer *rpc.Server
+	services  *Services
 }
 
 func (s *contractService) OpenFireWall(fireWallParam model.FireWall) (string, error) {
@@ -44,7 +44,7 @@ func (s *contractService) OpenFireWall(fireWallParam model.FireWall) (string, er
 	var err error
 	var contractAddr = precompile.FirewallManagementAddress
 	defaultInter := "wasm"
-	chainid, _ := rpc.GetRPCClientByChainID(fireWallParam.Chainid)
+	chainid, _ := s.rpcServer.GetRPCClientByChainID(fireWallParam.Chainid)
 	caller := rpc.NewMsgCaller(chainid)
 	funcName := "__sys_FwOpen"
 	funcParams := &struct {
@@ -53,7 +53,7 @@ func (s *contractService) OpenFireWall(fireWallParam model.FireWall) (string, er
 
 	data := rpc.NewContractParams(contractAddr, funcName, defaultInter, nil, funcParams)
 	txParams := &rpc.TxParams{}
-	txParams.From, err = DefaultAccountService.FirstAccount(fireWallParam.Chainid)
+	txParams.From, err = s.services.Account.FirstAccount(fireWallParam.Chainid)
 	if err != nil {
 		logrus.Errorln("get first account is error")
 		return "", err
@@ -74,7 +74,7 @@ func (s *contractService) CloseFireWall(fireWallParam model.FireWall) (string, e
 	var err error
 	var contractAddr = precompile.FirewallManagementAddress
 	defaultInter := "wasm"
-	chainid, _ := rpc.GetRPCClientByChainID(fireWallParam.Chainid)
+	chainid, _ := s.rpcServer.GetRPCClientByChainID(fireWallParam.Chainid)
 	caller := rpc.NewMsgCaller(chainid)
 	funcName := "__sys_FwClose"
 	funcParams := &struct {
@@ -83,7 +83,7 @@ func (s *contractService) CloseFireWall(fireWallParam model.FireWall) (string, e
 
 	data := rpc.NewContractParams(contractAddr, funcName, defaultInter, nil, funcParams)
 	txParams := &rpc.TxParams{}
-	txParams.From, err = DefaultAccountService.FirstAccount(fireWallParam.Chainid)
+	txParams.From, err = s.services.Account.FirstAccount(fireWallParam.Chainid)
 	if err != nil {
 		logrus.Errorln("get first account is error")
 		return "", err
@@ -104,7 +104,7 @@ func (s *contractService) FireWallStatus(request model.FireWall) (string, error)
 	var err error
 	var contractAddr = precompile.FirewallManagementAddress
 	defaultInter := "wasm"
-	chainid, _ := rpc.GetRPCClientByChainID(request.Chainid)
+	chainid, _ := s.rpcServer.GetRPCClientByChainID(request.Chainid)
 	caller := rpc.NewMsgCaller(chainid)
 	funcName := "__sys_FwStatus"
 	funcParams := &struct {
@@ -113,7 +113,7 @@ func (s *contractService) FireWallStatus(request model.FireWall) (string, error)
 
 	data := rpc.NewContractParams(contractAddr, funcName, defaultInter, nil, funcParams)
 	txParams := &rpc.TxParams{}
-	txParams.From, err = DefaultAccountService.FirstAccount(request.Chainid)
+	txParams.From, err = s.services.Account.FirstAccount(request.Chainid)
 	if err != nil {
 		logrus.Errorln("get first account is error")
 		return "", err
@@ -166,14 +166,14 @@ func (s *contractService) Contracts(condition model.ContractQueryCondition) ([]*
 		// 按 CNS 名称过滤
 		if condition.CNSName != "" {
 			c.Name = condition.CNSName
-			cnss, err := DefaultCNSService.CNSs(c)
+			cnss, err := s.services.CNS.CNSs(c)
 			if err == nil && len(cnss) > 0 {
 				vo.CNS = cnss
 				vos = append(vos, vo)
 			}
 			continue
 		}
-		cnss, err := DefaultCNSService.CNSs(c)
+		cnss, err := s.services.CNS.CNSs(c)
 		if err == nil && len(cnss) > 0 {
 			vo.CNS = cnss
 		}
@@ -200,7 +200,7 @@ func (s *contractService) Count(condition model.ContractQueryCondition) (int64,
 				Address: strings.ToLower(contract.Address),
 				Name:    condition.CNSName,
 			}
-			cnss, err := DefaultCNSService.CNSs(c)
+			cnss, err := s.services.CNS.CNSs(c)
 			if err == nil && len(cnss) > 0 {
 				cnt++
 			}
@@ -216,6 +216,9 @@ func (s *contractService) ContractByAddress(chainID string, address string) (*mo
 	if err != nil {
 		return nil, exterr.ErrObjectIDInvalid
 	}
+	if err := checkLiveChain(s.chainDao, objectId); err != nil {
+		return nil, err
+	}
 	filter := bson.M{}
 	filter["chain_id"] = objectId
 	filter["address"] = bson.M{"$regex": fmt.Sprintf("^(?i)%s$", address)}
@@ -237,7 +240,7 @@ func (s *contractService) ContractByAddress(chainID string, address string) (*mo
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
 	vo.Content = content
-	cnss, err := DefaultCNSService.CNSs(c)
+	cnss, err := s.services.CNS.CNSs(c)
 	if err == nil && len(cnss) > 0 {
 		vo.CNS = cnss
 	}
@@ -317,13 +320,11 @@ func (s *contractService) buildFilterByCondition(condition model.ContractQueryCo
 		}
 		filter["_id"] = id
 	}
-	if !reflect.ValueOf(condition.ChainID).IsZero() {
-		chainID, err := primitive.ObjectIDFromHex(condition.ChainID)
-		if err != nil {
-			return nil, exterr.ErrObjectIDInvalid
-		}
-		filter["chain_id"] = chainID
+	chainID, err := liveChainCondition(s.chainDao, condition.ChainID)
+	if err != nil {
+		return nil, err
 	}
+	filter["chain_id"] = chainID
 	if !reflect.ValueOf(condition.TxHash).IsZero() {
 		filter["tx_hash"] = bson.M{"$regex": fmt.Sprintf("^(?i)%s$", condition.TxHash)}
 	}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"graces/config"
+	"graces/db"
+	"graces/model"
+	"graces/rpc"
+	"graces/web/dao"
+	"graces/ws"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 单条链 rpc 检查的超时时间
+const readinessRPCTimeout = 2 * time.Second
+
+func newHealthService(database *db.DB, daos *dao.Daos, subscriber *ws.WSSubscriber) IHealthService {
+	return &healthService{
+		database:   database,
+		chainDao:   daos.Chain,
+		subscriber: subscriber,
+	}
+}
+
+type healthService struct {
+	// 内存模式下为 nil
+	database   *db.DB
+	chainDao   dao.IChainDao
+	subscriber *ws.WSSubscriber
+}
+
+func (s *healthService) Readiness() (*model.ReadinessVO, error) {
+	vo := &model.ReadinessVO{
+		DB:     model.NewHealthCheckVO(s.pingDB()),
+		Chains: make([]*model.ChainStatusVO, 0),
+	}
+	vo.Ready = vo.DB.OK()
+	if !vo.Ready {
+		// 数据库不可用时无法获取链列表
+		return vo, nil
+	}
+	chains, err := s.chainDao.Chains(bson.M{}, nil)
+	if err != nil {
+		vo.DB = model.NewHealthCheckVO(err)
+		vo.Ready = false
+		return vo, nil
+	}
+
+	// 并发检查每条链，避免链较多时检查耗时过长
+	var wg sync.WaitGroup
+	for _, chain := range chains {
+		status := &model.ChainStatusVO{
+			ChainID: chain.ID.Hex(),
+			Name:    chain.Name,
+		}
+		vo.Chains = append(vo.Chains, status)
+		wg.Add(1)
+		go func(chain model.Chain, status *model.ChainStatusVO) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), readinessRPCTimeout)
+			defer cancel()
+			blockNumber, err := rpc.CheckChain(ctx, chain)
+			status.RPC = model.NewHealthCheckVO(err)
+			status.BlockNumber = blockNumber
+			status.WS = model.NewHealthCheckVO(s.checkWS(chain))
+		}(*chain, status)
+	}
+	wg.Wait()
+	for _, status := range vo.Chains {
+		vo.Ready = vo.Ready && status.RPC.OK() && status.WS.OK()
+	}
+	return vo, nil
+}
+
+func (s *healthService) pingDB() error {
+	if config.Config.DBConf.IsMemoryDB() {
+		return nil
+	}
+	if s.database == nil {
+		return errors.New("db is not initialized")
+	}
+	return s.database.Ping()
+}
+
+func (s *healthService) checkWS(chain model.Chain) error {
+	if s.subscriber == nil {
+		return errors.New("websocket subscriber is not initialized")
+	}
+	if !s.subscriber.IsSubscribed(chain) {
+		return errors.New("websocket subscriber is not connected")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"graces/cluster"
+	"graces/rpc"
+	"graces/syncer"
+	"graces/txpool"
+	"graces/web/dao"
+	"graces/ws"
+)
+
+var (
+	testDaos     *dao.Daos
+	testServices *Services
+)
+
+func TestMain(m *testing.M) {
+	daos, database, err := dao.NewDaos()
+	if err != nil {
+		daos = dao.NewMemoryDaos()
+	}
+	testDaos = daos
+	c, err := cluster.New(database)
+	if err != nil {
+		panic(err)
+	}
+	rpcServer := rpc.NewServer(daos.Chain, daos.Node, daos.Block)
+	syncManager := syncer.NewChainDataSyncManager(daos, rpcServer, c.Elector)
+	txPool := txpool.NewTxPool(daos.Chain, c.Elector)
+	wsManager := ws.NewManager(daos, rpcServer, c.Bus, txPool)
+	subscriber := ws.NewWSSubscriber(wsManager, daos, syncManager, c.Elector)
+	testServices = NewServices(database, daos, rpcServer, syncManager, txPool, wsManager, subscriber)
+	os.Exit(m.Run())
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	"graces/exterr"
 	"graces/model"
@@ -15,20 +17,16 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var DefaultNodeService INodeService
-
-func init() {
-	DefaultNodeService = newNodeService()
-}
-
-func newNodeService() INodeService {
+func newNodeService(daos *dao.Daos) INodeService {
 	return &nodeService{
-		dao: dao.DefaultNodeDao,
+		dao:      daos.Node,
+		chainDao: daos.Chain,
 	}
 }
 
 type nodeService struct {
-	dao dao.INodeDao
+	dao      dao.INodeDao
+	chainDao dao.IChainDao
 }
 
 func (s *nodeService) NodeSyncServer(node *model.NodeSyncReq) (*model.SyncNodeResult, error) {
@@ -42,39 +40,32 @@ func (s *nodeService) NodeSyncServer(node *model.NodeSyncReq) (*model.SyncNodeRe
 	if !ping {
 		return nil, exterr.NewError(exterr.ErrCodeFind, "failed to connect: connect timeout")
 	}
-	blockNumber, err := rpc.GetBlockNumber(endpoint)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cli := rpc.NewJSONRPCClient(endpoint)
+	blockNumber, err := cli.BlockNumber(ctx)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
 	res.BlockNumber = uint32(blockNumber)
 
-	isMining, err := model.GetRpcResult(endpoint, "eth_mining", []string{})
+	res.IsMining, err = cli.Mining(ctx)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
-	res.IsMining = isMining.(bool)
-	price, err := model.GetRpcResult(endpoint, "eth_gasPrice", []string{})
+	gasPrice, err := cli.GasPrice(ctx)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
-	gasprice := price.(string)
-	gasPrice, err := util.Hex2Number(gasprice)
-	if err != nil {
-		return nil, exterr.NewError(exterr.ErrCodeParameterInvalid, err.Error())
-	}
 	res.GasPrice = uint32(gasPrice)
-	pendingtx, err := model.GetRpcResult(endpoint, "eth_pendingTransactions", []string{})
+	pendingTxs, err := cli.PendingTransactions(ctx)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
-
-	pendingtxs, ok := pendingtx.([]string)
-	if ok {
-		res.PendingTx = pendingtxs
-	} else {
-		res.PendingTx = nil
+	res.PendingTx = make([]string, 0, len(pendingTxs))
+	for _, tx := range pendingTxs {
+		res.PendingTx = append(res.PendingTx, tx.Hash)
 	}
-
 	res.PendingNumber = len(res.PendingTx)
 
 	return res, nil
@@ -92,6 +83,9 @@ func (s *nodeService) NodeByID(id string) (*model.NodeVO, error) {
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
+	if err := checkLiveChain(s.chainDao, node.ChainID); err != nil {
+		return nil, err
+	}
 	vo, err := node.ToVO()
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
@@ -172,13 +166,11 @@ func (s *nodeService) buildFilterByCondition(condition model.NodeQueryCondition)
 		}
 		filter["_id"] = id
 	}
-	if !reflect.ValueOf(condition.ChainID).IsZero() {
-		chainID, err := primitive.ObjectIDFromHex(condition.ChainID)
-		if err != nil {
-			return nil, exterr.ErrObjectIDInvalid
-		}
-		filter["chain_id"] = chainID
+	chainID, err := liveChainCondition(s.chainDao, condition.ChainID)
+	if err != nil {
+		return nil, err
 	}
+	filter["chain_id"] = chainID
 	if !reflect.ValueOf(condition.Name).IsZero() {
 		filter["name"] = condition.Name
 	}
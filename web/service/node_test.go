@@ -1,24 +1,24 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
-	"graces/model"
+	"graces/rpc"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetRpcResult(t *testing.T) {
 	id := "6128b643192c48ceac3986a1"
-	chain, err := DefaultChainService.ChainByID(id)
+	chain, err := testServices.Chain.ChainByID(id)
 	if err != nil {
-		fmt.Errorf("get chain by chainid is error")
+		t.Fatal(err)
 	}
-	endpoint := fmt.Sprintf("http://%v:%v", chain.IP, chain.P2PPort)
-	//endpoint := syncer.DefaultSyncer.GetEndPointByChainID(id)
-	method := "personal_listAccounts"
-	res, err := model.GetRpcResult(endpoint, method, nil)
+	endpoint := fmt.Sprintf("http://%v:%v", chain.IP, chain.RPCPort)
+	var res []string
+	err = rpc.NewJSONRPCClient(endpoint).Call(context.Background(), &res, "personal_listAccounts")
 	if err != nil {
 		t.Fatal(err)
 	}
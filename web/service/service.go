@@ -0,0 +1,47 @@
+package service
+
+import (
+	"graces/db"
+	"graces/rpc"
+	"graces/syncer"
+	"graces/txpool"
+	"graces/web/dao"
+	"graces/ws"
+)
+
+// Services 应用使用的所有 service，service 之间相互调用时通过 Services 获取对方
+type Services struct {
+	Chain     IChainService
+	Block     IBlockService
+	TX        ITXService
+	Node      INodeService
+	CNS       ICNSService
+	Contract  IContractService
+	Account   IAccountService
+	Websocket IWebsocketService
+	User      IUserService
+	APIKey    IAPIKeyService
+	Audit     IAuditService
+	Health    IHealthService
+	TxPool    ITxPoolService
+}
+
+// NewServices 使用应用持有的组件创建所有 service，内存模式下 database 为 nil
+func NewServices(database *db.DB, daos *dao.Daos, rpcServer *rpc.Server, syncManager *syncer.ChainDataSyncManager,
+	txPool *txpool.TxPool, wsManager *ws.Manager, subscriber *ws.WSSubscriber) *Services {
+	s := &Services{}
+	s.Chain = newChainService(daos, rpcServer, subscriber, s)
+	s.Block = newBlockService(daos, s)
+	s.TX = newTXService(daos, s)
+	s.Node = newNodeService(daos)
+	s.CNS = newCNSService(daos, rpcServer, syncManager, s)
+	s.Contract = newContractService(daos, rpcServer, s)
+	s.Account = newAccountService(daos, rpcServer)
+	s.Websocket = newWebsocketService(daos, wsManager)
+	s.User = newUserService(daos)
+	s.APIKey = newAPIKeyService(daos)
+	s.Audit = newAuditService(daos)
+	s.Health = newHealthService(database, daos, subscriber)
+	s.TxPool = newTxPoolService(daos, txPool, s)
+	return s
+}
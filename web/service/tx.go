@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 
 	"graces/exterr"
 	"graces/model"
+	"graces/rpc"
 	"graces/util"
 	"graces/web/dao"
 
@@ -25,7 +27,6 @@ import (
 )
 
 var (
-	DefaultTXService     ITXService
 	InvokeContractAction = "InvokeContract"
 	DeployContractAction = "DeployContract"
 	TranslateAction      = "TransferFunds"
@@ -43,18 +44,18 @@ var SysContractList = map[string]string{
 	"0x1000000000000000000000000000000000000007": "contractDataContract",
 }
 
-func init() {
-	DefaultTXService = newTXService()
-}
-
-func newTXService() ITXService {
+func newTXService(daos *dao.Daos, services *Services) ITXService {
 	return &txService{
-		dao: dao.DefaultTXDao,
+		dao:      daos.TX,
+		chainDao: daos.Chain,
+		services: services,
 	}
 }
 
 type txService struct {
-	dao dao.ITXDao
+	dao      dao.ITXDao
+	chainDao dao.IChainDao
+	services *Services
 }
 
 func (s *txService) TXByID(id string) (*model.TXVO, error) {
@@ -70,6 +71,9 @@ func (s *txService) TXByID(id string) (*model.TXVO, error) {
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
+	if err := checkLiveChain(s.chainDao, tx.ChainID); err != nil {
+		return nil, err
+	}
 	result, err = s.TXShow(tx)
 	if err != nil {
 		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
@@ -82,6 +86,9 @@ func (s *txService) TXByHash(chainID string, hash string) (*model.TXVO, error) {
 	if err != nil {
 		return nil, exterr.ErrObjectIDInvalid
 	}
+	if err := checkLiveChain(s.chainDao, cid); err != nil {
+		return nil, err
+	}
 	filter := bson.M{
 		"chain_id": cid,
 		"hash":     bson.M{"$regex": fmt.Sprintf("^(?i)%s$", hash)},
@@ -162,7 +169,7 @@ func (s *txService) TXShow(txdata *model.TX) (*model.TXVO, error) {
 	if txdata.To == "" {
 		// 合约部署
 		res.Action = DeployContractAction
-		content, code, err := DefaultContractService.ShowContract(txdata.Input)
+		content, code, err := s.services.Contract.ShowContract(txdata.Input)
 		if err != nil {
 			return nil, err
 		}
@@ -174,11 +181,11 @@ func (s *txService) TXShow(txdata *model.TX) (*model.TXVO, error) {
 		res.Detail.Params = append(res.Detail.Params, txdata.Value)
 	} else if txdata.To == ZeroAddress && txdata.Input != "" {
 		// cns 调用
-		return ParseCnsInvoke(res, txdata)
+		return s.ParseCnsInvoke(res, txdata)
 	} else {
 		// 调用合约
 		contract, ok := SysContractList[txdata.To]
-		temp, err := ParseData(txdata.ChainID.Hex(), txdata.To, txdata.Input)
+		temp, err := s.ParseData(txdata.ChainID.Hex(), txdata.To, txdata.Input)
 		if temp == nil || err != nil {
 			res.Detail.Txtype = 0
 			res.Detail.Method = ""
@@ -200,7 +207,7 @@ func (s *txService) TXShow(txdata *model.TX) (*model.TXVO, error) {
 	return res, nil
 }
 
-func ParseCnsInvoke(res *model.TXVO, txdata *model.TX) (*model.TXVO, error) {
+func (s *txService) ParseCnsInvoke(res *model.TXVO, txdata *model.TX) (*model.TXVO, error) {
 	var functype []string
 	var cns *cmd_common.Cns
 	res.Action = "CnsInvoke"
@@ -238,7 +245,7 @@ func ParseCnsInvoke(res *model.TXVO, txdata *model.TX) (*model.TXVO, error) {
 				ChainID: txdata.ChainID.Hex(),
 				Name:    cns.Name,
 			}
-			cnsVo, _ := DefaultCNSService.CNSs(condition)
+			cnsVo, _ := s.services.CNS.CNSs(condition)
 			if cnsVo == nil {
 				return res, errors.New("[CNS] name and version is not registered in CNS")
 			}
@@ -246,10 +253,10 @@ func ParseCnsInvoke(res *model.TXVO, txdata *model.TX) (*model.TXVO, error) {
 			if cns != nil {
 				res.Detail.Contract = "(" + cns.Name + ")" + cnsAddress
 			}
-			functype = getfunctype(txdata.ChainID.Hex(), cnsAddress, res.Detail.Method)
+			functype = s.getfunctype(txdata.ChainID.Hex(), cnsAddress, res.Detail.Method)
 			if functype == nil {
 				// 处理调用系统合约的交易
-				temp, err := ParseData(txdata.ChainID.Hex(), txdata.To, txdata.Input)
+				temp, err := s.ParseData(txdata.ChainID.Hex(), txdata.To, txdata.Input)
 				if temp == nil || err != nil {
 					res.Detail.Txtype = 0
 					res.Detail.Method = ""
@@ -276,7 +283,7 @@ func ParseCnsInvoke(res *model.TXVO, txdata *model.TX) (*model.TXVO, error) {
 	return res, nil
 }
 
-func ParseData(chainid string, to string, input string) (*model.TXVO, error) {
+func (s *txService) ParseData(chainid string, to string, input string) (*model.TXVO, error) {
 	result := model.TXVO{}
 	result.Detail = &model.TxDetail{}
 	var functype []string
@@ -301,7 +308,7 @@ func ParseData(chainid string, to string, input string) (*model.TXVO, error) {
 			result.Detail.Txtype = common.BytesToInt64(v.([]byte))
 		} else if i == 1 {
 			result.Detail.Method = string(v.([]byte))
-			functype = getfunctype(chainid, to, result.Detail.Method)
+			functype = s.getfunctype(chainid, to, result.Detail.Method)
 			if functype == nil {
 				result.Detail.Extra = "the address of the contract is incorrect"
 				return &result, nil
@@ -339,14 +346,14 @@ func getParams(functype string, v interface{}) interface{} {
 	return Params
 }
 
-func getfunctype(chainid string, to string, funcName string) []string {
+func (s *txService) getfunctype(chainid string, to string, funcName string) []string {
 	var functype []string
 	var funcAbi []byte
 	_, ok := SysContractList[to]
 	if ok {
 		funcAbi = cmd_common.AbiParse("", to)
 	} else {
-		funcAbi = getFuncAbi(chainid, to)
+		funcAbi = s.getFuncAbi(chainid, to)
 		//tmp := getFuncAbi(chainid, to)
 		//funcAbi = tmp
 		if funcAbi == nil {
@@ -370,33 +377,39 @@ func getfunctype(chainid string, to string, funcName string) []string {
 	return functype
 }
 
-func getFuncAbi(id string, to string) []byte {
-	chain, err := DefaultChainService.ChainByID(id)
+func (s *txService) getFuncAbi(id string, to string) []byte {
+	chain, err := s.services.Chain.ChainByID(id)
 	if err != nil {
 		logrus.Errorf("failed to ChainByID: %s", err.Error())
 		return nil
 	}
 	endpoint := fmt.Sprintf("http://%v:%v", chain.IP, chain.RPCPort)
-	var param []string
-	param = append(param, to)
-	param = append(param, "latest")
-	code, err := model.GetRpcResult(endpoint, "eth_getCode", param)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	code, err := rpc.NewJSONRPCClient(endpoint).GetCode(ctx, to, "latest")
 	if err != nil {
-		logrus.Errorf("failed to GetRpcResult: %s", err.Error())
+		logrus.Errorf("failed to GetCode: %s", err.Error())
 		return nil
 	}
-	if code == "0x" {
+	if len(code) == 0 {
 		return nil
 	}
-	res, _ := hexutil.Decode(code.(string))
 
 	ptr := new(interface{})
-	err = rlp.Decode(bytes.NewReader(res), &ptr)
+	err = rlp.Decode(bytes.NewReader(code), &ptr)
 	if err != nil {
 		return nil
 	}
-	deref := reflect.ValueOf(ptr).Elem().Interface()
-	funcAbi := deref.([]interface{})[2].([]byte)
+	deref, ok := reflect.ValueOf(ptr).Elem().Interface().([]interface{})
+	if !ok || len(deref) < 3 {
+		logrus.Errorf("contract code of [%s] is not a rlp list with abi", to)
+		return nil
+	}
+	funcAbi, ok := deref[2].([]byte)
+	if !ok {
+		logrus.Errorf("abi of contract [%s] is not a byte array", to)
+		return nil
+	}
 	return funcAbi
 }
 
@@ -437,13 +450,11 @@ func (s *txService) buildFilterByCondition(condition model.TXQueryCondition) (in
 		}
 		filter["_id"] = id
 	}
-	if !reflect.ValueOf(condition.ChainID).IsZero() {
-		chainID, err := primitive.ObjectIDFromHex(condition.ChainID)
-		if err != nil {
-			return nil, exterr.ErrObjectIDInvalid
-		}
-		filter["chain_id"] = chainID
+	chainID, err := liveChainCondition(s.chainDao, condition.ChainID)
+	if err != nil {
+		return nil, err
 	}
+	filter["chain_id"] = chainID
 	if !reflect.ValueOf(condition.BlockID).IsZero() {
 		blockID, err := primitive.ObjectIDFromHex(condition.BlockID)
 		if err != nil {
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"graces/exterr"
+	"graces/model"
+	"graces/txpool"
+	"graces/util"
+	"graces/web/dao"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTxPoolService(daos *dao.Daos, txPool *txpool.TxPool, services *Services) ITxPoolService {
+	return &txPoolService{
+		chainDao: daos.Chain,
+		txPool:   txPool,
+		services: services,
+	}
+}
+
+type txPoolService struct {
+	chainDao dao.IChainDao
+	txPool   *txpool.TxPool
+	services *Services
+}
+
+func (s *txPoolService) TxPool(chainID string) (*model.TxPoolVO, error) {
+	objectID, err := primitive.ObjectIDFromHex(chainID)
+	if err != nil {
+		return nil, exterr.ErrObjectIDInvalid
+	}
+	if _, err = s.chainDao.Chain(bson.M{"_id": objectID}); err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+
+	vo := &model.TxPoolVO{
+		ChainID: chainID,
+		TXs:     make([]*model.PendingTXVO, 0),
+	}
+	txs, updateTime, ok := s.txPool.Pending(chainID)
+	if !ok {
+		return vo, nil
+	}
+	vo.UpdateTime = util.Timestamp2TimeStr(updateTime)
+	for _, tx := range txs {
+		vo.TXs = append(vo.TXs, s.pendingTXShow(tx))
+	}
+	vo.Count = len(vo.TXs)
+	return vo, nil
+}
+
+// 使用已打包交易的解析逻辑解析交易的操作和详细信息，解析失败时只返回交易本身
+func (s *txPoolService) pendingTXShow(tx *model.PendingTX) *model.PendingTXVO {
+	vo := &model.PendingTXVO{
+		Hash:      tx.Hash,
+		From:      tx.From,
+		To:        tx.To,
+		GasLimit:  tx.GasLimit,
+		GasPrice:  tx.GasPrice,
+		Nonce:     tx.Nonce,
+		Input:     tx.Input,
+		Value:     tx.Value,
+		FirstSeen: util.Timestamp2TimeStr(tx.FirstSeen),
+	}
+	txData, err := tx.ToTX()
+	if err != nil {
+		return vo
+	}
+	show, err := s.services.TX.TXShow(txData)
+	if err != nil || show == nil {
+		logrus.Debugf("parse pending tx[%s] err: %v", tx.Hash, err)
+		return vo
+	}
+	vo.Action = show.Action
+	vo.Detail = show.Detail
+	return vo
+}
@@ -1,6 +1,11 @@
 package service
 
-import "graces/model"
+import (
+	"io"
+
+	"graces/model"
+	"graces/secret"
+)
 
 type IWebsocketService interface {
 	// Manager 获取 Websocket Manager 信息
@@ -13,6 +18,9 @@ type IWebsocketService interface {
 	// Groups 获取所有 Websocket 组详细信息
 	Groups() ([]model.WSGroupVO, error)
 
+	// ClosedClients 获取最近关闭的 Websocket 客户端信息，包含关闭的原因
+	ClosedClients() []model.WSClientVO
+
 	// Send 向指定组的指定 Websocket 客户端发送信息
 	Send(dto model.WSMessageDTO) error
 
@@ -37,6 +45,8 @@ type IWebsocketService interface {
 type IChainService interface {
 	IsExist(chainDTO model.ChainDTO) bool
 	InsertChain(chainDTO model.ChainDTO) error
+	UpdateChain(id string, chainDTO model.ChainDTO) error
+	DeleteChain(id string, purge bool) error
 	ChainByID(id string) (*model.ChainVO, error)
 	ChainByName(name string) (*model.ChainVO, error)
 	ChainByAddress(ip string, port int64) (*model.ChainVO, error)
@@ -105,3 +115,62 @@ type IAccountService interface {
 	FirstAccount(chainID string) (string, error)
 	ListAccounts(dto model.AccountDTO) ([]*model.AccountVO, error)
 }
+
+type IUserService interface {
+	// Login 校验用户名密码并签发 token
+	Login(dto model.LoginDTO) (*model.TokenVO, error)
+	// Refresh 使用刷新 token 换取新的访问 token 和刷新 token，旧的刷新 token 随即作废
+	Refresh(refreshToken string) (*model.TokenVO, error)
+	// Logout 登出当前会话，吊销当前访问 token 和该会话的刷新 token
+	Logout(claims *secret.CustomClaims) error
+	// LogoutAll 退出用户的所有会话，该用户已签发的 token 全部失效
+	LogoutAll(userID string) error
+	// ChangePassword 校验旧密码后修改用户密码，该用户已签发的 token 全部失效
+	ChangePassword(userID string, dto model.PasswordDTO) error
+	// CheckToken 校验 token 对应的用户是否存在且 token 未被吊销
+	CheckToken(claims *secret.CustomClaims) (*model.User, error)
+	// EnsureAdmin 管理员用户不存在时使用给定的用户名密码创建为超级管理员，已存在时不做修改
+	EnsureAdmin(username string, password string) error
+	// CreateUser 创建用户
+	CreateUser(dto model.UserDTO) (*model.UserVO, error)
+	// Users 分页查询用户
+	Users(condition model.UserQueryCondition) ([]*model.UserVO, error)
+	// Count 统计用户数量
+	Count(condition model.UserQueryCondition) (int64, error)
+	// SetRole 设置用户的全局角色
+	SetRole(dto model.UserRoleDTO) error
+	// SetGrant 设置或撤销用户在某条链上的角色
+	SetGrant(dto model.UserGrantDTO) error
+}
+
+type IAPIKeyService interface {
+	// CreateAPIKey 为用户创建 API Key，授权范围不能超出用户自己的权限
+	CreateAPIKey(user *model.User, dto model.APIKeyDTO) (*model.APIKeyCreatedVO, error)
+	// APIKeys 查询用户的所有 API Key
+	APIKeys(user *model.User) ([]*model.APIKeyVO, error)
+	// RevokeAPIKey 吊销用户的 API Key
+	RevokeAPIKey(user *model.User, id string) error
+	// CheckAPIKey 校验 API Key，返回 key 所属的用户，并记录最后使用时间
+	CheckAPIKey(key string) (*model.User, *model.APIKey, error)
+}
+
+type IAuditService interface {
+	// Record 记录审计事件，参数中的敏感字段会被脱敏
+	Record(event model.AuditEvent) error
+	// AuditEvents 分页查询审计事件
+	AuditEvents(condition model.AuditQueryCondition) ([]*model.AuditEventVO, error)
+	// Count 统计审计事件数量
+	Count(condition model.AuditQueryCondition) (int64, error)
+	// ExportCSV 按条件导出审计事件为 CSV，忽略分页参数
+	ExportCSV(condition model.AuditQueryCondition, w io.Writer) error
+}
+
+type IHealthService interface {
+	// Readiness 检查数据库、每条链的 rpc 和 websocket 订阅状态
+	Readiness() (*model.ReadinessVO, error)
+}
+
+type ITxPoolService interface {
+	// TxPool 查询链交易池中等待打包的交易
+	TxPool(chainID string) (*model.TxPoolVO, error)
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"regexp"
+	"time"
+
+	"graces/config"
+	"graces/exterr"
+	"graces/model"
+	"graces/secret"
+	"graces/util"
+	"graces/web/dao"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newUserService(daos *dao.Daos) IUserService {
+	return &userService{
+		dao:        daos.User,
+		refreshDao: daos.RefreshToken,
+		revokedDao: daos.RevokedToken,
+	}
+}
+
+type userService struct {
+	dao        dao.IUserDao
+	refreshDao dao.IRefreshTokenDao
+	revokedDao dao.IRevokedTokenDao
+}
+
+func (s *userService) Login(dto model.LoginDTO) (*model.TokenVO, error) {
+	user, err := s.dao.User(bson.M{"username": dto.Username})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, exterr.ErrPasswordWrong
+		}
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(dto.Password)); err != nil {
+		return nil, exterr.ErrPasswordWrong
+	}
+	vo, err := s.issueTokens(user, secret.RandomString(16))
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("user [%s] login", user.Username)
+	vo.User = user.ToVO()
+	return vo, nil
+}
+
+func (s *userService) Refresh(refreshToken string) (*model.TokenVO, error) {
+	token, err := s.refreshDao.RefreshToken(bson.M{"token_hash": secret.HashToken(refreshToken)})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, exterr.ErrTokenInvalid
+		}
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if token.ExpiresAt < time.Now().Unix() {
+		return nil, exterr.ErrTokenExpired
+	}
+	// 只有一个请求能把刷新 token 标记为已使用，已使用的刷新 token 再次出现说明可能被盗用，作废整个会话
+	used, err := s.refreshDao.UpdateMany(bson.M{"_id": token.ID, "revoked": false}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	if used == 0 {
+		logrus.Warningf("refresh token reused, revoke session [%s] of user [%s]", token.SessionID, token.UserID.Hex())
+		if err := s.revokeRefreshTokens(bson.M{"session_id": token.SessionID}); err != nil {
+			return nil, err
+		}
+		return nil, exterr.ErrTokenInvalid
+	}
+	user, err := s.userByID(token.UserID.Hex())
+	if err != nil {
+		if err == exterr.ErrUserNotExist {
+			return nil, exterr.ErrTokenInvalid
+		}
+		return nil, err
+	}
+	return s.issueTokens(user, token.SessionID)
+}
+
+func (s *userService) Logout(claims *secret.CustomClaims) error {
+	userID, err := primitive.ObjectIDFromHex(claims.UserId)
+	if err != nil {
+		return exterr.ErrObjectIDInvalid
+	}
+	now := time.Now().Unix()
+	// 顺便清理已经过期的吊销记录
+	if _, err := s.revokedDao.DeleteMany(bson.M{"expires_at": bson.M{"$lt": now}}); err != nil {
+		logrus.Errorf("purge revoked tokens err: %v", err)
+	}
+	revoked := model.RevokedToken{
+		ID:         primitive.NewObjectID(),
+		TokenID:    claims.Id,
+		UserID:     userID,
+		ExpiresAt:  claims.ExpiresAt,
+		CreateTime: now,
+	}
+	if err := s.revokedDao.InsertRevokedToken(revoked); err != nil {
+		return exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	return s.revokeRefreshTokens(bson.M{"session_id": claims.SessionID})
+}
+
+func (s *userService) LogoutAll(userID string) error {
+	user, err := s.userByID(userID)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": user.ID}
+	update := bson.M{
+		"$inc": bson.M{"token_version": 1},
+		"$set": bson.M{"update_time": time.Now().Unix()},
+	}
+	if err := s.dao.Update(filter, update, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	if err := s.revokeRefreshTokens(bson.M{"user_id": user.ID}); err != nil {
+		return err
+	}
+	logrus.Infof("all sessions of user [%s] logged out", user.Username)
+	return nil
+}
+
+func (s *userService) ChangePassword(userID string, dto model.PasswordDTO) error {
+	user, err := s.userByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(dto.OldPassword)); err != nil {
+		return exterr.ErrPasswordWrong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(dto.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	// 修改密码后该用户已签发的 token 全部失效，需要重新登录
+	filter := bson.M{"_id": user.ID}
+	update := bson.M{
+		"$inc": bson.M{"token_version": 1},
+		"$set": bson.M{"password": string(hash), "update_time": time.Now().Unix()},
+	}
+	if err := s.dao.Update(filter, update, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	if err := s.revokeRefreshTokens(bson.M{"user_id": user.ID}); err != nil {
+		return err
+	}
+	logrus.Infof("password of user [%s] changed", user.Username)
+	return nil
+}
+
+func (s *userService) CheckToken(claims *secret.CustomClaims) (*model.User, error) {
+	user, err := s.userByID(claims.UserId)
+	if err != nil {
+		if err == exterr.ErrObjectIDInvalid {
+			return nil, exterr.ErrTokenInvalid
+		}
+		return nil, err
+	}
+	// 用户退出所有会话后令牌版本号会变化，之前签发的 token 不再有效
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, exterr.ErrTokenInvalid
+	}
+	if claims.Id != "" {
+		cnt, err := s.revokedDao.Count(bson.M{"token_id": claims.Id}, nil)
+		if err != nil {
+			return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+		}
+		if cnt > 0 {
+			return nil, exterr.ErrTokenInvalid
+		}
+	}
+	return user, nil
+}
+
+// 签发访问 token 和刷新 token，刷新 token 只保存摘要
+func (s *userService) issueTokens(user *model.User, sessionID string) (*model.TokenVO, error) {
+	claims := secret.NewDefaultCustomClaims()
+	claims.UserId = user.ID.Hex()
+	claims.Username = user.Username
+	claims.TokenVersion = user.TokenVersion
+	claims.SessionID = sessionID
+	token, err := secret.NewJWT().CreateToken(*claims)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeTokenInvalid, err.Error())
+	}
+
+	now := time.Now()
+	// 顺便清理该用户已经过期的刷新 token
+	if _, err := s.refreshDao.DeleteMany(bson.M{"user_id": user.ID, "expires_at": bson.M{"$lt": now.Unix()}}); err != nil {
+		logrus.Errorf("purge refresh tokens err: %v", err)
+	}
+	refreshToken := secret.RandomString(32)
+	refresh := model.RefreshToken{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		SessionID:  sessionID,
+		TokenHash:  secret.HashToken(refreshToken),
+		ExpiresAt:  now.Add(config.JWTRefreshExpires()).Unix(),
+		CreateTime: now.Unix(),
+	}
+	if err := s.refreshDao.InsertRefreshToken(refresh); err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	return &model.TokenVO{
+		Token:            token,
+		ExpiresAt:        claims.ExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+func (s *userService) revokeRefreshTokens(filter bson.M) error {
+	filter["revoked"] = false
+	if _, err := s.refreshDao.UpdateMany(filter, bson.M{"$set": bson.M{"revoked": true}}); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	return nil
+}
+
+func (s *userService) EnsureAdmin(username string, password string) error {
+	user, err := s.dao.User(bson.M{"username": username})
+	if err != nil && err != mongo.ErrNoDocuments {
+		return exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	// 用户已存在时不做任何修改，其角色和密码以 users 集合中的为准
+	if user != nil {
+		return nil
+	}
+	if _, err := s.CreateUser(model.UserDTO{Username: username, Password: password, Role: model.RoleSuperAdmin}); err != nil {
+		return err
+	}
+	logrus.Infof("bootstrap admin user [%s] created", username)
+	return nil
+}
+
+func (s *userService) CreateUser(dto model.UserDTO) (*model.UserVO, error) {
+	if !dto.Role.IsValid() {
+		return nil, exterr.ErrBadRole
+	}
+	count, err := s.dao.Count(bson.M{"username": dto.Username}, nil)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	if count > 0 {
+		return nil, exterr.ErrUserExisted
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	now := time.Now().Unix()
+	user := model.User{
+		ID:         primitive.NewObjectID(),
+		Username:   dto.Username,
+		Password:   string(hash),
+		Role:       dto.Role,
+		Grants:     make([]model.ChainGrant, 0),
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err := s.dao.InsertUser(user); err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeInsert, err.Error())
+	}
+	return user.ToVO(), nil
+}
+
+func (s *userService) Users(condition model.UserQueryCondition) ([]*model.UserVO, error) {
+	filter := s.buildFilterByCondition(condition)
+	findOps := util.BuildOptionsByQuery(condition.PageIndex, condition.PageSize)
+	findOps.Sort = bson.D{{"_id", -1}}
+	users, err := s.dao.Users(filter, findOps)
+	if err != nil {
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	vos := make([]*model.UserVO, 0, len(users))
+	for _, user := range users {
+		vos = append(vos, user.ToVO())
+	}
+	return vos, nil
+}
+
+func (s *userService) Count(condition model.UserQueryCondition) (int64, error) {
+	cnt, err := s.dao.Count(s.buildFilterByCondition(condition), nil)
+	if err != nil {
+		return 0, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	return cnt, nil
+}
+
+func (s *userService) buildFilterByCondition(condition model.UserQueryCondition) bson.M {
+	filter := bson.M{}
+	if condition.Username != "" {
+		filter["username"] = bson.M{"$regex": regexp.QuoteMeta(condition.Username), "$options": "i"}
+	}
+	if condition.Role != "" {
+		filter["role"] = condition.Role
+	}
+	return filter
+}
+
+func (s *userService) SetRole(dto model.UserRoleDTO) error {
+	if !dto.Role.IsValid() {
+		return exterr.ErrBadRole
+	}
+	user, err := s.userByID(dto.UserID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"role": dto.Role, "update_time": time.Now().Unix()}}
+	if err := s.dao.Update(bson.M{"_id": user.ID}, update, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	return nil
+}
+
+func (s *userService) SetGrant(dto model.UserGrantDTO) error {
+	// 超级管理员只能作为全局角色
+	if dto.Role != "" && (!dto.Role.IsValid() || dto.Role == model.RoleSuperAdmin) {
+		return exterr.ErrBadRole
+	}
+	if _, err := primitive.ObjectIDFromHex(dto.ChainID); err != nil {
+		return exterr.ErrObjectIDInvalid
+	}
+	user, err := s.userByID(dto.UserID)
+	if err != nil {
+		return err
+	}
+	grants := make([]model.ChainGrant, 0, len(user.Grants)+1)
+	for _, grant := range user.Grants {
+		if grant.ChainID != dto.ChainID {
+			grants = append(grants, grant)
+		}
+	}
+	if dto.Role != "" {
+		grants = append(grants, model.ChainGrant{ChainID: dto.ChainID, Role: dto.Role})
+	}
+	update := bson.M{"$set": bson.M{"grants": grants, "update_time": time.Now().Unix()}}
+	if err := s.dao.Update(bson.M{"_id": user.ID}, update, nil); err != nil {
+		return exterr.NewError(exterr.ErrCodeUpdate, err.Error())
+	}
+	return nil
+}
+
+func (s *userService) userByID(id string) (*model.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, exterr.ErrObjectIDInvalid
+	}
+	user, err := s.dao.User(bson.M{"_id": objectID})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, exterr.ErrUserNotExist
+		}
+		return nil, exterr.NewError(exterr.ErrCodeFind, err.Error())
+	}
+	return user, nil
+}
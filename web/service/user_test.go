@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/secret"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserService_LoginAndLogout(t *testing.T) {
+	s := newUserService(testDaos)
+	assert.True(t, s.EnsureAdmin("user-test", "123456") == nil)
+	// 已存在时不会覆盖密码
+	assert.True(t, s.EnsureAdmin("user-test", "654321") == nil)
+
+	_, err := s.Login(model.LoginDTO{Username: "user-test", Password: "654321"})
+	assert.True(t, err == exterr.ErrPasswordWrong)
+	_, err = s.Login(model.LoginDTO{Username: "nobody", Password: "123456"})
+	assert.True(t, err == exterr.ErrPasswordWrong)
+
+	token, err := s.Login(model.LoginDTO{Username: "user-test", Password: "123456"})
+	assert.True(t, err == nil)
+	assert.Equal(t, "user-test", token.User.Username)
+	other, err := s.Login(model.LoginDTO{Username: "user-test", Password: "123456"})
+	assert.True(t, err == nil)
+
+	claims, err := secret.NewJWT().ParseToken(token.Token)
+	assert.True(t, err == nil)
+	_, err = s.CheckToken(claims)
+	assert.True(t, err == nil)
+	otherClaims, err := secret.NewJWT().ParseToken(other.Token)
+	assert.True(t, err == nil)
+
+	// 登出只影响当前会话
+	assert.True(t, s.Logout(claims) == nil)
+	_, err = s.CheckToken(claims)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.Refresh(token.RefreshToken)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.CheckToken(otherClaims)
+	assert.True(t, err == nil)
+
+	// 退出所有会话
+	assert.True(t, s.LogoutAll(claims.UserId) == nil)
+	_, err = s.CheckToken(otherClaims)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.Refresh(other.RefreshToken)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+
+	token, err = s.Login(model.LoginDTO{Username: "user-test", Password: "123456"})
+	assert.True(t, err == nil)
+	claims, _ = secret.NewJWT().ParseToken(token.Token)
+	_, err = s.CheckToken(claims)
+	assert.True(t, err == nil)
+}
+
+func TestUserService_RefreshRotation(t *testing.T) {
+	s := newUserService(testDaos)
+	assert.True(t, s.EnsureAdmin("refresh-test", "123456") == nil)
+	token, err := s.Login(model.LoginDTO{Username: "refresh-test", Password: "123456"})
+	assert.True(t, err == nil)
+
+	refreshed, err := s.Refresh(token.RefreshToken)
+	assert.True(t, err == nil)
+	assert.True(t, refreshed.RefreshToken != token.RefreshToken)
+	claims, err := secret.NewJWT().ParseToken(refreshed.Token)
+	assert.True(t, err == nil)
+	_, err = s.CheckToken(claims)
+	assert.True(t, err == nil)
+
+	// 旧的刷新 token 被重复使用，整个会话作废
+	_, err = s.Refresh(token.RefreshToken)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.Refresh(refreshed.RefreshToken)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+
+	_, err = s.Refresh("unknown")
+	assert.True(t, err == exterr.ErrTokenInvalid)
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	s := newUserService(testDaos)
+	assert.True(t, s.EnsureAdmin("password-test", "123456") == nil)
+	token, err := s.Login(model.LoginDTO{Username: "password-test", Password: "123456"})
+	assert.True(t, err == nil)
+	claims, err := secret.NewJWT().ParseToken(token.Token)
+	assert.True(t, err == nil)
+
+	err = s.ChangePassword(claims.UserId, model.PasswordDTO{OldPassword: "wrong", NewPassword: "654321"})
+	assert.True(t, err == exterr.ErrPasswordWrong)
+	assert.True(t, s.ChangePassword(claims.UserId, model.PasswordDTO{OldPassword: "123456", NewPassword: "654321"}) == nil)
+
+	// 修改密码后之前的 token 全部失效
+	_, err = s.CheckToken(claims)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.Refresh(token.RefreshToken)
+	assert.True(t, err == exterr.ErrTokenInvalid)
+	_, err = s.Login(model.LoginDTO{Username: "password-test", Password: "123456"})
+	assert.True(t, err == exterr.ErrPasswordWrong)
+	_, err = s.Login(model.LoginDTO{Username: "password-test", Password: "654321"})
+	assert.True(t, err == nil)
+}
+
+func TestUserService_EnsureAdminKeepsRole(t *testing.T) {
+	s := newUserService(testDaos)
+	_, err := s.CreateUser(model.UserDTO{Username: "demoted-admin", Password: "123456", Role: model.RoleViewer})
+	assert.True(t, err == nil)
+	// 已存在的用户不会在启动时被重新提升为超级管理员
+	assert.True(t, s.EnsureAdmin("demoted-admin", "123456") == nil)
+	token, err := s.Login(model.LoginDTO{Username: "demoted-admin", Password: "123456"})
+	assert.True(t, err == nil)
+	assert.Equal(t, model.RoleViewer, token.User.Role)
+}
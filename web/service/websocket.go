@@ -3,27 +3,17 @@ package service
 import (
 	"graces/exterr"
 	"graces/model"
-	"graces/util"
 	"graces/web/dao"
 	"graces/ws"
 
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var (
-	DefaultWebsocketService IWebsocketService
-)
-
-func init() {
-	DefaultWebsocketService = newWebsocketService()
-}
-
-func newWebsocketService() IWebsocketService {
+func newWebsocketService(daos *dao.Daos, manager *ws.Manager) IWebsocketService {
 	return &websocketService{
-		dao:     dao.DefaultWSMsgDao,
-		manager: ws.DefaultWebsocketManager,
+		dao:     daos.WSMsg,
+		manager: manager,
 	}
 }
 
@@ -41,26 +31,21 @@ func (s *websocketService) Manager() model.WSManagerVO {
 		ChanMessageLen:          int64(len(s.manager.Message)),
 		ChanGroupMessageLen:     int64(len(s.manager.GroupMessage)),
 		ChanBroadCastMessageLen: int64(len(s.manager.BroadCastMessage)),
+		DroppedCnt:              int64(s.manager.DroppedCnt()),
+		DisconnectCnt:           int64(s.manager.DisconnectCnt()),
 	}
 	return vo
 }
 
 func (s *websocketService) Group(name string) (model.WSGroupVO, error) {
 	vo := model.WSGroupVO{}
-	group, ok := s.manager.Group[name]
-	if !ok {
+	group := s.manager.GroupClients(name)
+	if group == nil {
 		return vo, exterr.ErrWebsocketGroupInvalid
 	}
 	clients := make([]model.WSClientVO, 0)
-	for k, v := range group {
-		client := model.WSClientVO{}
-		err := util.SimpleCopyProperties(&client, v)
-		if err != nil {
-			logrus.Errorln(err)
-			return vo, exterr.ErrConvert
-		}
-		client.ID = k
-		clients = append(clients, client)
+	for _, v := range group {
+		clients = append(clients, v.ToVO())
 	}
 	vo.Name = name
 	vo.Clients = clients
@@ -70,27 +55,21 @@ func (s *websocketService) Group(name string) (model.WSGroupVO, error) {
 
 func (s *websocketService) Groups() ([]model.WSGroupVO, error) {
 	vos := make([]model.WSGroupVO, 0)
-	groups := s.manager.Group
-	for gk, gv := range groups {
-		group := model.WSGroupVO{}
-		clients := make([]model.WSClientVO, 0)
-		for k, v := range gv {
-			client := model.WSClientVO{}
-			err := util.SimpleCopyProperties(&client, v)
-			if err != nil {
-				logrus.Errorln(err)
-				return vos, exterr.ErrConvert
-			}
-			client.ID = k
-			clients = append(clients, client)
+	for _, name := range s.manager.GroupNames() {
+		group, err := s.Group(name)
+		if err != nil {
+			// 组在遍历期间被删除
+			continue
 		}
-		group.Name = gk
-		group.Clients = clients
 		vos = append(vos, group)
 	}
 	return vos, nil
 }
 
+func (s *websocketService) ClosedClients() []model.WSClientVO {
+	return s.manager.ClosedClients()
+}
+
 func (s *websocketService) Send(dto model.WSMessageDTO) error {
 	if !s.isGroupExist(dto.Group) {
 		return exterr.ErrWebsocketGroupNotExist
@@ -126,13 +105,7 @@ func (s *websocketService) Dial(dto model.WSDialDTO) (model.WSClientVO, error) {
 		}
 		return vo, exterr.ErrWebsocketDial
 	}
-	err = util.SimpleCopyProperties(&vo, client)
-	vo.ID = client.Id
-	if err != nil {
-		logrus.Errorln(err)
-		return vo, exterr.ErrConvert
-	}
-	return vo, nil
+	return client.ToVO(), nil
 }
 
 func (s *websocketService) ClientSend(dto model.WSMessageDTO) error {
@@ -146,7 +119,7 @@ func (s *websocketService) ClientSend(dto model.WSMessageDTO) error {
 	if !client.IsDial {
 		return exterr.ErrWebsocketClientSend
 	}
-	client.Message <- []byte(dto.Message)
+	client.Send([]byte(dto.Message))
 	return nil
 }
 
@@ -189,28 +162,15 @@ func (s *websocketService) UpdateMessageHash(msgID string, msgHash string) error
 
 // 判断组是否存在
 func (s *websocketService) isGroupExist(group string) bool {
-	_, ok := s.manager.Group[group]
-	return ok
+	return s.manager.GroupClients(group) != nil
 }
 
 // 判断客户端是否存在
 func (s *websocketService) isClientExist(clientId string) bool {
-	groups := s.manager.Group
-	for _, group := range groups {
-		_, ok := group[clientId]
-		if ok {
-			return true
-		}
-	}
-	return false
+	return s.manager.GetClientById(clientId) != nil
 }
 
 // 判断指定客户端是否在指定的组中
 func (s *websocketService) isClientInGroup(clientId string, group string) bool {
-	if !s.isGroupExist(group) {
-		return false
-	}
-	g := s.manager.Group[group]
-	_, ok := g[clientId]
-	return ok
+	return s.manager.GetClient(group, clientId) != nil
 }
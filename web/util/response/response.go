@@ -113,7 +113,7 @@ func handlerUserErr(ctx *gin.Context, err exterr.ExtError) {
 	switch err.Code {
 	case exterr.ErrCodeUnauthorized:
 		httpCode = http.StatusUnauthorized
-	case exterr.ErrCodeBadRole | exterr.ErrCodeUserHasNoPermission:
+	case exterr.ErrCodeBadRole, exterr.ErrCodeUserHasNoPermission:
 		httpCode = http.StatusForbidden
 	default:
 		httpCode = http.StatusBadRequest
@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"graces/exterr"
+	"graces/model"
+	"graces/secret"
+	"graces/util"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// AuthKey 认证通过后 websocket 连接的认证信息在 gin.Context 中的键
+	AuthKey = "wsAuth"
+	// TokenQuery 携带 token 的查询参数
+	TokenQuery = "token"
+	// 通过 Sec-WebSocket-Protocol 携带 token 时可以使用的前缀，如 ["bearer", "<token>"]
+	tokenProtocol = "bearer"
+)
+
+// 命令所需的权限，以及命令实际使用的链ID字段
+type commandPerm struct {
+	perm       model.Permission
+	chainField string
+}
+
+// 部署和节点启停命令所需的权限，其他命令只需要建立连接时的查看权限
+var commandPerms = map[string]commandPerm{
+	"deploy":      {perm: model.PermSystemAdmin},
+	"create":      {perm: model.PermChainAdmin, chainField: "chainID"},
+	"startNode":   {perm: model.PermChainOperate, chainField: "chainID"},
+	"stopNode":    {perm: model.PermChainOperate, chainField: "chainID"},
+	"restartNode": {perm: model.PermChainOperate, chainField: "chainID"},
+}
+
+// Auth websocket 连接建立时的认证信息
+type Auth struct {
+	Claims *secret.CustomClaims
+	User   *model.User
+}
+
+// TokenFromRequest 从 Sec-WebSocket-Protocol 请求头或 token 查询参数中获取不带前缀的 JWT，
+// 浏览器建立 websocket 连接时无法携带 Authorization 请求头
+func TokenFromRequest(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if strings.EqualFold(protocol, tokenProtocol) {
+			if i+1 < len(protocols) {
+				return protocols[i+1]
+			}
+			return ""
+		}
+	}
+	if len(protocols) == 1 {
+		return protocols[0]
+	}
+	// 查询参数中的 token 可以带有登录时返回的前缀
+	fields := strings.Fields(r.URL.Query().Get(TokenQuery))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// 校验连接是否可以执行命令：需要登录用户在命令所属的链上拥有相应的权限，且 token 没有过期
+func (c *Client) authorize(msgType string, data map[string]interface{}) error {
+	cmd, ok := commandPerms[msgType]
+	if !ok {
+		return nil
+	}
+	if c.auth == nil || c.auth.User == nil {
+		return exterr.ErrUnauthorized
+	}
+	if claims := c.auth.Claims; claims != nil && claims.ExpiresAt > 0 && claims.ExpiresAt < time.Now().Unix() {
+		return exterr.ErrTokenExpired
+	}
+	chainID, ok := commandChainID(msgType, data)
+	if !ok {
+		return exterr.NewError(exterr.ErrCodeParameterInvalid, "ambiguous chain id")
+	}
+	if !c.auth.User.HasPermission(cmd.perm, chainID) {
+		return exterr.ErrUserHasNoPermission
+	}
+	return nil
+}
+
+// 命令实际使用的链ID，只取命令读取的字段；参数中有多个链ID字段时 ok 为 false
+func commandChainID(msgType string, data map[string]interface{}) (string, bool) {
+	if _, ok := util.ChainIDField(data, ""); !ok {
+		return "", false
+	}
+	field := commandPerms[msgType].chainField
+	if field == "" {
+		return "", true
+	}
+	id, _ := data[field].(string)
+	return id, true
+}
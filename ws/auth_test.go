@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"testing"
+
+	"graces/exterr"
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_AuthorizeChainField(t *testing.T) {
+	chainA := "61bc0c6e5a9f8b1f2c3d4e5a"
+	chainB := "61bc0c6e5a9f8b1f2c3d4e5b"
+	client := &Client{
+		Id: "auth-client",
+		auth: &Auth{User: &model.User{
+			Username: "operator-a",
+			Role:     model.RoleViewer,
+			Grants:   []model.ChainGrant{{ChainID: chainA, Role: model.RoleOperator}},
+		}},
+	}
+
+	assert.True(t, client.authorize("stopNode", map[string]interface{}{"chainID": chainA, "nodeID": "0"}) == nil)
+	assert.Equal(t, exterr.ErrUserHasNoPermission, client.authorize("stopNode", map[string]interface{}{"chainID": chainB}))
+	// 只按命令实际使用的 chainID 字段校验权限
+	assert.Equal(t, exterr.ErrUserHasNoPermission, client.authorize("startNode", map[string]interface{}{"chain_id": chainA}))
+	// 同时带有多个链ID字段的命令被拒绝
+	err := client.authorize("restartNode", map[string]interface{}{"chainID": chainB, "chain_id": chainA})
+	assert.Equal(t, exterr.ErrCodeParameterInvalid, err.(*exterr.ExtError).Code)
+	// deploy 只看全局角色
+	assert.Equal(t, exterr.ErrUserHasNoPermission, client.authorize("deploy", map[string]interface{}{"chainID": chainA}))
+}
@@ -2,40 +2,65 @@ package ws
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"graces/config"
+	"graces/exterr"
+	"graces/model"
+	"graces/util"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// 读信息，从 websocket 连接直接读取数据
+// 读信息，从 websocket 连接直接读取数据，超过 pong 超时时间没有收到数据或 pong 帧时断开连接
 func (c *Client) Read() {
 	defer func() {
-		c.IsAlive = false
-		DefaultWebsocketManager.UnRegister <- c
-		logrus.Infof("client [%s] disconnect", c.Id)
+		atomic.StoreInt32(&c.alive, 0)
+		c.manager.UnRegisterClient(c)
+		logrus.Infof("client [%s] disconnect: %s", c.Id, c.CloseReason())
 		if err := c.Socket.Close(); err != nil {
 			logrus.Errorf("client [%s] disconnect err: %s", c.Id, err)
 		}
+		if c.IsDial && c.onLost != nil && !c.isClosed() {
+			go c.onLost(c)
+		}
 	}()
 
+	pongWait := config.Config.WSConf.PongWait()
+	_ = c.Socket.SetReadDeadline(time.Now().Add(pongWait))
+	c.Socket.SetPongHandler(func(string) error {
+		return c.Socket.SetReadDeadline(time.Now().Add(pongWait))
+	})
 	for {
 		messageType, message, err := c.Socket.ReadMessage()
-		if err != nil || messageType == websocket.CloseMessage {
+		if err != nil {
+			c.setCloseReason(readCloseReason(err))
 			break
 		}
+		if messageType == websocket.CloseMessage {
+			c.setCloseReason("closed by peer")
+			break
+		}
+		_ = c.Socket.SetReadDeadline(time.Now().Add(pongWait))
+		c.touch()
 		msg := string(message)
 		logrus.Debugf("client [%s] receive message: %s", c.Id, msg)
 		go c.readMessageProcessor(msg)
 	}
 }
 
-// 写信息，从 channel 变量 Send 中读取数据写入 websocket 连接
+// 写信息，从 channel 变量 Send 中读取数据写入 websocket 连接，并定时发送 ping 帧，前端连接空闲超时后断开
 func (c *Client) Write() {
+	ticker := time.NewTicker(config.Config.WSConf.PingPeriod())
 	defer func() {
-		c.IsAlive = false
+		ticker.Stop()
+		atomic.StoreInt32(&c.alive, 0)
 		logrus.Infof("client [%s] disconnect", c.Id)
 		if err := c.Socket.Close(); err != nil {
 			if strings.Contains(err.Error(), "use of closed network connection") {
@@ -45,22 +70,204 @@ func (c *Client) Write() {
 		}
 	}()
 
+	writeWait := config.Config.WSConf.WriteWait()
+	idleWait := config.Config.WSConf.IdleWait()
 	for {
 		select {
 		case message, ok := <-c.Message:
 			if !ok {
-				_ = c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
+				_ = c.Socket.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(writeWait))
 				return
 			}
 			logrus.Debugf("client [%s] write message: %s", c.Id, string(message))
+			_ = c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
 			err := c.Socket.WriteMessage(websocket.BinaryMessage, message)
 			if err != nil {
 				logrus.Errorf("client [%s] writemessage err: %s", c.Id, err)
+				c.setCloseReason("write error: " + err.Error())
+				return
+			}
+			c.touch()
+		case <-ticker.C:
+			if !c.IsDial && idleWait > 0 && c.idleTime() > idleWait {
+				c.setCloseReason("idle timeout")
+				closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout")
+				_ = c.Socket.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+				return
+			}
+			if err := c.Socket.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.setCloseReason("ping error: " + err.Error())
+				return
 			}
 		}
 	}
 }
 
+// 读取出错时的关闭原因
+func readCloseReason(err error) string {
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		return fmt.Sprintf("closed by peer: %d %s", closeErr.Code, closeErr.Text)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "pong timeout"
+	}
+	return "read error: " + err.Error()
+}
+
+// 记录收发数据消息的时间
+func (c *Client) touch() {
+	atomic.StoreInt64(&c.lastActive, time.Now().UnixNano())
+}
+
+// 距离最近一次收发数据消息的时长
+func (c *Client) idleTime() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&c.lastActive)))
+}
+
+// 记录连接关闭的原因，只保留第一次的原因
+func (c *Client) setCloseReason(reason string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closeReason != "" {
+		return
+	}
+	c.closeReason = reason
+	c.closeTime = time.Now().Unix()
+}
+
+// CloseReason 连接关闭的原因，连接未关闭时为空
+func (c *Client) CloseReason() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closeReason
+}
+
+// Send 把消息写入待发送队列，不会阻塞。队列已满时按连接的策略丢弃最早的消息或断开连接，连接注销后写入的消息会被丢弃
+func (c *Client) Send(message []byte) {
+	c.msgLock.Lock()
+	defer c.msgLock.Unlock()
+	if c.stopped || c.disconnecting {
+		return
+	}
+	select {
+	case c.Message <- message:
+		return
+	default:
+	}
+
+	c.countDropped()
+	if c.dropPolicy == DropPolicyDisconnect {
+		// 只关闭底层连接，由读协程注销；拨号连接会按意外断开处理并重连
+		logrus.Warningf("client [%s] is too slow to consume messages, disconnect", c.Id)
+		c.setCloseReason("slow consumer")
+		if c.manager != nil {
+			atomic.AddUint64(&c.manager.disconnectCnt, 1)
+		}
+		c.disconnecting = true
+		if c.Socket != nil {
+			_ = c.Socket.Close()
+		}
+		return
+	}
+	logrus.Debugf("client [%s] message buffer is full, drop the oldest message", c.Id)
+	select {
+	case <-c.Message:
+	default:
+	}
+	select {
+	case c.Message <- message:
+	default:
+	}
+}
+
+// 停止写入并关闭待发送队列，写协程发送关闭帧后退出，只能由管理器在注销时调用
+func (c *Client) stop() {
+	c.msgLock.Lock()
+	defer c.msgLock.Unlock()
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	if c.Message != nil {
+		close(c.Message)
+	}
+}
+
+// IsAlive 连接是否存活
+func (c *Client) IsAlive() bool {
+	return atomic.LoadInt32(&c.alive) == 1
+}
+
+// DroppedCnt 因缓冲队列已满被丢弃的消息数
+func (c *Client) DroppedCnt() uint64 {
+	return atomic.LoadUint64(&c.droppedCnt)
+}
+
+func (c *Client) countDropped() {
+	atomic.AddUint64(&c.droppedCnt, 1)
+	if c.manager != nil {
+		atomic.AddUint64(&c.manager.droppedCnt, 1)
+	}
+}
+
+func (c *Client) setRetryCnt(retry int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.RetryCnt = retry
+}
+
+// ToVO 连接信息
+func (c *Client) ToVO() model.WSClientVO {
+	c.lock.Lock()
+	retry, closeReason, closeTime := c.RetryCnt, c.closeReason, c.closeTime
+	c.lock.Unlock()
+	vo := model.WSClientVO{
+		ID:          c.Id,
+		Group:       c.Group,
+		LocalAddr:   c.LocalAddr,
+		RemoteAddr:  c.RemoteAddr,
+		Path:        c.Path,
+		IsAlive:     c.IsAlive(),
+		IsDial:      c.IsDial,
+		RetryCnt:    retry,
+		DropPolicy:  c.dropPolicy,
+		DroppedCnt:  c.DroppedCnt(),
+		CloseReason: closeReason,
+		CloseTime:   util.Timestamp2TimeStr(closeTime),
+	}
+	if c.auth != nil && c.auth.User != nil {
+		vo.Username = c.auth.User.Username
+	}
+	return vo
+}
+
+// Close 主动关闭连接，主动关闭的拨号连接不会重连
+func (c *Client) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	c.setCloseReason("closed by server")
+	return c.Socket.Close()
+}
+
+func (c *Client) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// 记录通过该连接发送的订阅消息
+func (c *Client) addSubMsgID(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.subMsgIDs = append(c.subMsgIDs, id)
+}
+
+// SubMsgIDs 通过该连接发送的订阅消息记录ID
+func (c *Client) SubMsgIDs() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ids := make([]string, len(c.subMsgIDs))
+	copy(ids, c.subMsgIDs)
+	return ids
+}
+
 func (c *Client) readMessageProcessor(message string) {
 	if message == "ping" {
 		msgProcessor := NewMsgProcessor(c, NewStringMsgProcessor())
@@ -77,9 +284,16 @@ func (c *Client) readMessageProcessor(message string) {
 		logrus.Errorln("[readMessageProcessor] the message [%s] can't unmarshal to map[string]interface{}， err: %v", message, err)
 		return
 	}
+	// 前端发送的结构化消息
+	if _, ok := data[EnvelopeQuery]; ok && !c.IsDial {
+		if err = c.envelopeMsgProcessor(message); err != nil {
+			logrus.Errorln(err)
+		}
+		return
+	}
 	// 处理作为客户端主动发送消息给服务端后收到回复的消息类型
 	_, ok := data["id"].(string)
-	if ok {
+	if ok && c.IsDial {
 		err = c.sendTypeMsgProcessor(data)
 		if err != nil {
 			logrus.Errorln(err)
@@ -109,30 +323,95 @@ func (c *Client) sendTypeMsgProcessor(data map[string]interface{}) error {
 
 // 处理连接建立后，作为服务端被动收到客户端请求，或作为客户端被动收到服务端推送的消息类型
 func (c *Client) receiveTypeMsgProcessor(msgType string, data map[string]interface{}) error {
+	return c.processCommand(msgType, data, cmdReply{client: c, cmd: msgType})
+}
+
+// 执行命令：校验权限后回复 ack，执行结束后回复 result，旧格式的消息按原有的方式回复
+func (c *Client) processCommand(msgType string, data map[string]interface{}, reply cmdReply) error {
+	if err := c.authorize(msgType, data); err != nil {
+		c.audit(msgType, data, err)
+		reply.reject(err)
+		return err
+	}
 	switch msgType {
 	case config.Config.WSConf.WsMsgTypesConf.Sub.ReceiveType:
-		msgProcessor := NewMsgProcessor(c, NewSubMsgProcessor())
+		msgProcessor := NewMsgProcessor(c, NewSubMsgProcessor(c.manager))
 		err := msgProcessor.Process(data)
 		if err != nil {
 			return err
 		}
+	case MsgTypeSubscribe:
+		reply.ack("")
+		return c.subscribeTopic(data, reply)
+	case MsgTypeUnsubscribe:
+		reply.ack("")
+		return c.unsubscribeTopic(data, reply)
+	case EnvelopeTypePing:
+		reply.ack("")
+		reply.done("", "pong", nil)
 	case "deploy":
-		c.Message <- []byte("Start deploy! Please wait...")
-		DefaultDeploy.DeployNewChain(data, c.Message)
+		reply.ack("Start deploy! Please wait...")
+		return c.runCommand(msgType, data, reply, c.manager.deploy.DeployNewChain)
 	case "create":
-		c.Message <- []byte("Start create node! Please wait...")
-		DefaultDeploy.DeployNewNode(data, c.Message)
+		reply.ack("Start create node! Please wait...")
+		return c.runCommand(msgType, data, reply, c.manager.deploy.DeployNewNode)
 	case "startNode":
-		c.Message <- []byte("Start node!")
-		DefaultDeploy.StartNode(data, c.Message)
+		reply.ack("Start node!")
+		return c.runCommand(msgType, data, reply, c.manager.deploy.StartNode)
 	case "stopNode":
-		c.Message <- []byte("Stop node!")
-		DefaultDeploy.StopNode(data, c.Message)
+		reply.ack("Stop node!")
+		return c.runCommand(msgType, data, reply, c.manager.deploy.StopNode)
 	case "restartNode":
-		c.Message <- []byte("Restart node!")
-		DefaultDeploy.RestartNode(data, c.Message)
+		reply.ack("Restart node!")
+		return c.runCommand(msgType, data, reply, c.manager.deploy.RestartNode)
 	default:
-		logrus.Errorf("unknown msgType[%v]", msgType)
+		if !reply.enveloped {
+			logrus.Errorf("unknown msgType[%v]", msgType)
+			return nil
+		}
+		err := exterr.NewError(exterr.ErrCodeWebsocketMessageInvalid, fmt.Sprintf("unknown message type [%s]", msgType))
+		reply.reject(err)
+		return err
 	}
 	return nil
 }
+
+// 执行部署和节点启停命令，命令执行结束后记录审计日志并回复结果
+func (c *Client) runCommand(msgType string, data map[string]interface{}, reply cmdReply,
+	cmd func(info interface{}, c func(msg []byte)) error) error {
+	err := cmd(data, reply.output)
+	c.audit(msgType, data, err)
+	reply.done("", nil, err)
+	return err
+}
+
+// 记录部署和节点启停命令的审计日志，命令执行结束后记录，失败时带上失败原因
+func (c *Client) audit(msgType string, data map[string]interface{}, err error) {
+	params := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k != "method" {
+			params[k] = v
+		}
+	}
+	chainID, _ := commandChainID(msgType, params)
+	event := model.AuditEvent{
+		ID:         primitive.NewObjectID(),
+		Source:     model.AuditSourceWebsocket,
+		ClientIP:   c.RemoteAddr,
+		ChainID:    chainID,
+		Operation:  "ws." + msgType,
+		Params:     util.RedactParams(params),
+		Success:    err == nil,
+		CreateTime: time.Now().Unix(),
+	}
+	if c.auth != nil && c.auth.User != nil {
+		event.UserID = c.auth.User.ID.Hex()
+		event.Username = c.auth.User.Username
+	}
+	if err != nil {
+		event.ErrMsg = err.Error()
+	}
+	if err := c.manager.daos.Audit.InsertAuditEvent(event); err != nil {
+		logrus.Errorf("client [%s] record audit event [%s] err: %v", c.Id, event.Operation, err)
+	}
+}
@@ -14,11 +14,14 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"graces/exterr"
 	"graces/model"
 	"graces/rpc"
+	"graces/secret"
 	"graces/syncer"
 	"graces/web/dao"
 
@@ -29,28 +32,134 @@ import (
 	"golang.org/x/net/context"
 )
 
-const defaultKeyfile = "./keystore"
+const (
+	defaultKeyfile = "./keystore"
+	// 停机时发送 SIGTERM 或 kill 后等待部署脚本退出的时间
+	killTimeout = 5 * time.Second
+)
+
+var errDeployShutdown = errors.New("deploy is shutting down")
 
 var (
-	DefaultDeploy              *deploy
 	DefaultContractInterpreter = "wasm"
 	DefaultCover               = " --cover"
 	DefaultNoCover             = ""
 	DefaultDir                 = "deploy/release/linux/scripts/"
 )
 
-func init() {
-	DefaultDeploy = newDeploy()
+// NewDeploy 创建合约和链的部署器，新部署的链写入 daos，并通过 syncManager 同步数据、通过 subscriber 订阅 topics
+func NewDeploy(daos *dao.Daos, syncManager *syncer.ChainDataSyncManager, subscriber *WSSubscriber) *Deploy {
+	return &Deploy{
+		chainDao:    daos.Chain,
+		nodeDao:     daos.Node,
+		syncManager: syncManager,
+		subscriber:  subscriber,
+		procs:       make(map[*exec.Cmd]struct{}),
+	}
+}
+
+// Deploy 部署器，执行部署合约、部署链和节点启停的脚本
+type Deploy struct {
+	chainDao    dao.IChainDao
+	nodeDao     dao.INodeDao
+	syncManager *syncer.ChainDataSyncManager
+	subscriber  *WSSubscriber
+
+	// 正在运行的部署脚本，停机时等待或终止
+	lock    sync.Mutex
+	procs   map[*exec.Cmd]struct{}
+	jobs    sync.WaitGroup
+	closing bool
+}
+
+// Shutdown 拒绝新的部署脚本并等待正在运行的脚本结束，
+// ctx 超时后先向脚本的进程组发送 SIGTERM，再等待 killTimeout 后强制 kill，脚本启动的子进程一并终止
+func (d *Deploy) Shutdown(ctx context.Context) error {
+	d.lock.Lock()
+	d.closing = true
+	d.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	logrus.Warningf("deploy scripts still running after grace period, terminate them")
+	d.signalAll(syscall.SIGTERM)
+	select {
+	case <-done:
+		return nil
+	case <-time.After(killTimeout):
+	}
+	d.signalAll(syscall.SIGKILL)
+	select {
+	case <-done:
+		return nil
+	case <-time.After(killTimeout):
+		return errors.New("deploy scripts did not exit after kill")
+	}
+}
+
+// 向所有部署脚本的进程组发送信号
+func (d *Deploy) signalAll(sig syscall.Signal) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for cmd := range d.procs {
+		if cmd.Process == nil {
+			continue
+		}
+		if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+			logrus.Warningf("signal deploy script [%d] err: %v", cmd.Process.Pid, err)
+		}
+	}
+}
+
+// 启动并登记一个部署脚本，停机过程中不再启动新的脚本。
+// 脚本在独立的进程组中运行，停机时可以连同它启动的子进程一起终止
+func (d *Deploy) start(cmd *exec.Cmd) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closing {
+		return errDeployShutdown
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	d.procs[cmd] = struct{}{}
+	d.jobs.Add(1)
+	return nil
 }
 
-func newDeploy() *deploy {
-	return &deploy{}
+// 回收部署脚本进程并取消登记，返回脚本的退出状态
+func (d *Deploy) finish(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	if err != nil {
+		logrus.Warningf("deploy script exit: %v", err)
+	}
+	d.lock.Lock()
+	delete(d.procs, cmd)
+	d.lock.Unlock()
+	d.jobs.Done()
+	return err
 }
 
-type deploy struct {
+func (d *Deploy) running() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.procs)
 }
 
-func (d *deploy) DeployContract(chainID string, account string, files []*multipart.FileHeader) (interface{}, error) {
+func (d *Deploy) DeployContract(chainID string, account string, files []*multipart.FileHeader) (interface{}, error) {
 	logrus.Debugf("Contract deployment start.")
 	//defer logrus.Debugf("Contract deployment finished.")
 	// 1、通过 chainID 获取链信息
@@ -62,7 +171,7 @@ func (d *deploy) DeployContract(chainID string, account string, files []*multipa
 	filter := bson.M{
 		"_id": id,
 	}
-	chain, err := dao.DefaultChainDao.Chain(filter)
+	chain, err := d.chainDao.Chain(filter)
 	if err != nil {
 		return "", err
 	}
@@ -138,7 +247,7 @@ func (d *deploy) DeployContract(chainID string, account string, files []*multipa
 	return deployedContract, nil
 }
 
-func (d *deploy) buildDeployContractsParams(interpreter string, contractParams *model.DeployInfo) *rpc.ContractParams {
+func (d *Deploy) buildDeployContractsParams(interpreter string, contractParams *model.DeployInfo) *rpc.ContractParams {
 	if interpreter == "" {
 		interpreter = DefaultContractInterpreter
 	}
@@ -162,7 +271,7 @@ func (d *deploy) buildDeployContractsParams(interpreter string, contractParams *
 	return contract
 }
 
-func (d *deploy) getRPCClientByChain(chain model.Chain) (*rpc.Client, error) {
+func (d *Deploy) getRPCClientByChain(chain model.Chain) (*rpc.Client, error) {
 	host := fmt.Sprintf("%v:%v", chain.IP, chain.RPCPort)
 	uri := url.URL{
 		Scheme: "http",
@@ -175,7 +284,10 @@ func (d *deploy) getRPCClientByChain(chain model.Chain) (*rpc.Client, error) {
 	}
 
 	keyfilePath := nodeConfig["keyfile_path"].(string)
-	passphrase := nodeConfig["passphrase"].(string)
+	passphrase, err := secret.Decrypt(nodeConfig["passphrase"].(string))
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
 	client, err := rpc.NewClient(ctx, uri.String(), passphrase, keyfilePath)
@@ -186,44 +298,42 @@ func (d *deploy) getRPCClientByChain(chain model.Chain) (*rpc.Client, error) {
 	return client, nil
 }
 
-func (d *deploy) DeployNewNode(chainInfo interface{}, c chan []byte) {
+// DeployNewNode 为已有的链部署新节点，所有节点部署完成或出错后返回
+func (d *Deploy) DeployNewNode(chainInfo interface{}, c func(msg []byte)) (err error) {
 	//projectName := chainInfo.(map[string]interface{})["projectName"].(string)
 	//filter := bson.M{"name": projectName}
-	//chain, err := dao.DefaultChainDao.Chain(filter)
+	//chain, err := d.chainDao.Chain(filter)
 	//if err != nil {
 	//	return
 	//}
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when deploying node. Error: %s", err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when deploying node. Error: %s", r)
+			err = fmt.Errorf("deploy node: %v", r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
 	chainIDStr := chainInfo.(map[string]interface{})["chainID"].(string)
 	chainID, err := primitive.ObjectIDFromHex(chainIDStr)
 	if err != nil {
 		logrus.Errorln(err)
-		return
+		return exterr.ErrObjectIDInvalid
 	}
 
 	filter := bson.M{"_id": chainID}
-	chain, err := dao.DefaultChainDao.Chain(filter)
+	chain, err := d.chainDao.Chain(filter)
 	if err != nil {
 		logrus.Errorln(err)
-		return
+		return err
 	}
 
 	filter = bson.M{}
-	if err != nil {
-		return
-	}
 	filter["chain_id"] = chainID
 	findOps := options.Count()
-	nodeCount, err := dao.DefaultNodeDao.Count(filter, findOps)
+	nodeCount, err := d.nodeDao.Count(filter, findOps)
 	if err != nil {
 		logrus.Error(err)
-		return
+		return err
 	}
 
 	remoteIP := chainInfo.(map[string]interface{})["remoteIP"].(string)
@@ -234,72 +344,92 @@ func (d *deploy) DeployNewNode(chainInfo interface{}, c chan []byte) {
 
 	for i := 0; i < deployCount; i++ {
 		//todo 改用context来判读prepare中，go协程是否结束，或者waitgroup?
-		d.Prepare(chain.Name, remoteIP, userName, DefaultDir, DefaultNoCover, strconv.FormatInt(nodeCount+int64(i), 10), c)
+		err = d.Prepare(chain.Name, remoteIP, userName, DefaultDir, DefaultNoCover, strconv.FormatInt(nodeCount+int64(i), 10), c)
+		if err != nil {
+			return err
+		}
 		//time.Sleep(time.Duration(2) * time.Second)
 		//d.Start(*chain, DefaultDir, nodeID, c)
-		d.DeployNode(*chain, DefaultDir, strconv.FormatInt(nodeCount+int64(i), 10), c)
+		err = d.DeployNode(*chain, DefaultDir, strconv.FormatInt(nodeCount+int64(i), 10), c)
+		if err != nil {
+			return err
+		}
 	}
 
 	//todo 返回部署成功的节点数量
-	return
+	return nil
 }
 
-func (d *deploy) DeployNode(chainInfo model.Chain, dir string, nodeID string, c chan []byte) {
+func (d *Deploy) DeployNode(chainInfo model.Chain, dir string, nodeID string, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when deploying node for chain %s. Error: %s", chainInfo.Name, err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when deploying node for chain %s. Error: %s", chainInfo.Name, r)
+			err = fmt.Errorf("deploy node for chain %s: %v", chainInfo.Name, r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
-	c <- []byte("Start new node for " + chainInfo.Name + "!")
+	c([]byte("Start new node for " + chainInfo.Name + "!"))
 	//todo 根据chaininfo.name来查找对应的链信息，然后创建节点model，作链id与节点id的对应。然后insertNode, 然后return（不用做后续操作，因为链信息已入库。
 	filter := bson.M{"name": chainInfo.Name}
-	chain, err := dao.DefaultChainDao.Chain(filter)
+	chain, err := d.chainDao.Chain(filter)
 	if err != nil {
-		return
+		return err
 	}
 
 	chainVO, err := chain.ToVO()
 	if err != nil {
-		return
+		return err
+	}
+	cmdDeployNewNode := scriptCommand(dir, "deploy.sh", "-p", chainInfo.Name, "-n", nodeID)
+	if err = d.ShellCall(cmdDeployNewNode, nil, c); err != nil {
+		return err
 	}
-	//todo optimize it
-	cmd := "./deploy.sh -p " + chainInfo.Name + " -n " + nodeID
-	cmdDeployNewNode := exec.Command("sh", "-c", cmd)
-	cmdDeployNewNode.Dir = dir
-	d.ShellCall(cmdDeployNewNode, nil, c)
 
-	syncInfo := syncer.DefaultChainDataSyncManager.BuildChainSyncInfo(chainVO.ID)
+	syncInfo := d.syncManager.BuildChainSyncInfo(chainVO.ID)
 	if syncInfo.NodeDataSyncInfo != nil && syncInfo.NodeDataSyncInfo.Status == syncer.StatusSyncing {
 		logrus.Infof("this chain[%s] node data is syncing, don't repeat sync for it", chainVO.ID)
-		return
+		return nil
 	}
 
-	err = syncer.DefaultChainDataSyncManager.SyncNode(chainVO.ID, false)
+	err = d.syncManager.SyncNode(chainVO.ID, false)
 	if err != nil {
 		syncInfo.NodeDataSyncInfo.ErrMsg = err.Error()
 		syncInfo.NodeDataSyncInfo.Status = syncer.StatusError
-		syncer.DefaultChainDataSyncManager.ErrChan <- &model.SyncErrMsg{
+		d.syncManager.ErrChan <- &model.SyncErrMsg{
 			ChainID: chainVO.ID,
 			ErrType: syncer.ErrTypeBlockOrTXSync,
 			Err:     err,
 		}
 
-		return
+		return err
 	}
 
 	logrus.Debugf("chain[%v] data sync success", chainVO.ID)
-	return
+	return nil
+}
+
+// 构建部署脚本命令，参数直接传给脚本，不经过 shell 解析
+func scriptCommand(dir string, script string, args ...string) *exec.Cmd {
+	cmd := exec.Command("./"+script, args...)
+	cmd.Dir = dir
+	return cmd
 }
 
-func (d *deploy) nameByChainID(chainID string) (string, error) {
+// 校验前端传入的节点ID，节点ID为节点在链中的序号
+func checkNodeID(nodeID string) error {
+	if _, err := strconv.ParseUint(nodeID, 10, 32); err != nil {
+		return exterr.NewError(exterr.ErrCodeParameterInvalid, fmt.Sprintf("invalid node id [%s]", nodeID))
+	}
+	return nil
+}
+
+func (d *Deploy) nameByChainID(chainID string) (string, error) {
 	objectId, err := primitive.ObjectIDFromHex(chainID)
 	if err != nil {
 		return "", exterr.ErrObjectIDInvalid
 	}
 	filter := bson.M{"_id": objectId}
-	chain, err := dao.DefaultChainDao.Chain(filter)
+	chain, err := d.chainDao.Chain(filter)
 	if err != nil {
 		return "", exterr.NewError(exterr.ErrCodeFind, err.Error())
 	}
@@ -307,18 +437,21 @@ func (d *deploy) nameByChainID(chainID string) (string, error) {
 	return chain.Name, nil
 }
 
-func (d *deploy) StopNode(info interface{}, c chan []byte) error {
+func (d *Deploy) StopNode(info interface{}, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when stoping node. Error: %s", err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when stoping node. Error: %s", r)
+			err = fmt.Errorf("stop node: %v", r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
 	//projectName := info.(map[string]interface{})["projectName"].(string)
 	chainID := info.(map[string]interface{})["chainID"].(string)
 	nodeID := info.(map[string]interface{})["nodeID"].(string)
 
+	if err = checkNodeID(nodeID); err != nil {
+		return err
+	}
 	chainName, err := d.nameByChainID(chainID)
 	if err != nil {
 		return err
@@ -326,190 +459,176 @@ func (d *deploy) StopNode(info interface{}, c chan []byte) error {
 
 	//todo 判断该id对应的节点是否已经部署，若没部署，则返回err
 
-	cmd := "./clear.sh -p " + chainName + " -n " + nodeID + " -m stop"
-	cmdStop := exec.Command("sh", "-c", cmd)
-	cmdStop.Dir = DefaultDir
-
-	go d.ShellCall(cmdStop, nil, c)
-
-	return nil
+	cmdStop := scriptCommand(DefaultDir, "clear.sh", "-p", chainName, "-n", nodeID, "-m", "stop")
+	return d.ShellCall(cmdStop, nil, c)
 }
 
-func (d *deploy) StartNode(info interface{}, c chan []byte) error {
+func (d *Deploy) StartNode(info interface{}, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when starting node. Error: %s", err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when starting node. Error: %s", r)
+			err = fmt.Errorf("start node: %v", r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
 	//projectName := info.(map[string]interface{})["projectName"].(string)
 	chainID := info.(map[string]interface{})["chainID"].(string)
 	nodeID := info.(map[string]interface{})["nodeID"].(string)
 
+	if err = checkNodeID(nodeID); err != nil {
+		return err
+	}
 	chainName, err := d.nameByChainID(chainID)
 	if err != nil {
 		return err
 	}
 
-	cmd := "./start.sh -p " + chainName + " -n " + nodeID
-	cmdStart := exec.Command("sh", "-c", cmd)
-	cmdStart.Dir = DefaultDir
-
-	go d.ShellCall(cmdStart, nil, c)
-
-	return nil
+	cmdStart := scriptCommand(DefaultDir, "start.sh", "-p", chainName, "-n", nodeID)
+	return d.ShellCall(cmdStart, nil, c)
 }
 
-func (d *deploy) RestartNode(info interface{}, c chan []byte) error {
+func (d *Deploy) RestartNode(info interface{}, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when restarting node. Error: %s", err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when restarting node. Error: %s", r)
+			err = fmt.Errorf("restart node: %v", r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
 	//projectName := info.(map[string]interface{})["projectName"].(string)
 	chainID := info.(map[string]interface{})["chainID"].(string)
 	nodeID := info.(map[string]interface{})["nodeID"].(string)
 
+	if err = checkNodeID(nodeID); err != nil {
+		return err
+	}
 	chainName, err := d.nameByChainID(chainID)
 	if err != nil {
 		return err
 	}
 
-	cmdc := "./clear.sh -p " + chainName + " -n " + nodeID + " -m stop"
-	cmdStop := exec.Command("sh", "-c", cmdc)
-	cmdStop.Dir = DefaultDir
-
-	cmds := "./start.sh -p " + chainName + " -n " + nodeID
-	cmdStart := exec.Command("sh", "-c", cmds)
-	cmdStart.Dir = DefaultDir
-
-	go func() {
-		d.ShellCall(cmdStop, nil, c)
-		d.ShellCall(cmdStart, nil, c)
-	}()
+	cmdStop := scriptCommand(DefaultDir, "clear.sh", "-p", chainName, "-n", nodeID, "-m", "stop")
+	cmdStart := scriptCommand(DefaultDir, "start.sh", "-p", chainName, "-n", nodeID)
 
-	return nil
+	if err = d.ShellCall(cmdStop, nil, c); err != nil {
+		return err
+	}
+	return d.ShellCall(cmdStart, nil, c)
 }
 
-func (d *deploy) DeployNewChain(chainInfo interface{}, c chan []byte) {
+// DeployNewChain 部署新链，部署完成或出错后返回
+func (d *Deploy) DeployNewChain(chainInfo interface{}, c func(msg []byte)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when deploying chain. Error: %s", r)
+			err = fmt.Errorf("deploy chain: %v", r)
+		}
+	}()
 	projectName := chainInfo.(map[string]interface{})["projectName"].(string)
 	remoteIP := chainInfo.(map[string]interface{})["remoteIP"].(string)
 	userName := chainInfo.(map[string]interface{})["remoteName"].(string)
 
-	//异步部署新链
-	go d.Prepare(projectName, remoteIP, userName, DefaultDir, DefaultCover, "", c)
-
-	//todo 返回调用成功信息给前端？
-	return
+	return d.Prepare(projectName, remoteIP, userName, DefaultDir, DefaultCover, "", c)
 }
 
 //todo prepare, transfer, init三个函数相同代码块比较多，待优化。
-func (d *deploy) Prepare(projectName string, remoteIp string, userName string, dir string, cover string, nodeID string, c chan []byte) {
+func (d *Deploy) Prepare(projectName string, remoteIp string, userName string, dir string, cover string, nodeID string, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when preparing files for node. Error: %s", err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when preparing files for node. Error: %s", r)
+			err = fmt.Errorf("prepare files for %s: %v", projectName, r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
-	cmd := "./prepare.sh -p " + projectName + " -a " + userName + "@" + remoteIp
+	args := []string{"-p", projectName, "-a", userName + "@" + remoteIp}
 	if cover == DefaultCover {
-		cmd += cover
+		args = append(args, strings.TrimSpace(cover))
 	}
-	cmdPrepare := exec.Command("sh", "-c", cmd)
-	cmdPrepare.Dir = dir
+	cmdPrepare := scriptCommand(dir, "prepare.sh", args...)
 
 	if nodeID != "" { // nodeID != "" 则为现有链创建新节点
 		stdin, err := cmdPrepare.StdinPipe()
 		if err != nil {
-			c <- []byte("Failed to create stdin pipe.")
+			c([]byte("Failed to create stdin pipe."))
 			log.Fatalf("failed to create stdin pipe: %v", err)
-			return
+			return err
 		}
-		c <- []byte("Prepare create new node for " + projectName)
-		d.ShellCall(cmdPrepare, stdin, c) //no cover -- enter "n" to stdin for "yesOrNo"
-	} else {
-		d.ShellCall(cmdPrepare, nil, c)
-		c <- []byte("Prepare files for " + projectName + " success!")
-
-		d.Transfer(model.Chain{
-			Name:     projectName,
-			IP:       remoteIp,
-			Username: userName,
-		}, dir, cover, c)
+		c([]byte("Prepare create new node for " + projectName))
+		return d.ShellCall(cmdPrepare, stdin, c) //no cover -- enter "n" to stdin for "yesOrNo"
 	}
+	if err = d.ShellCall(cmdPrepare, nil, c); err != nil {
+		return err
+	}
+	c([]byte("Prepare files for " + projectName + " success!"))
 	//ws.Socket.WriteMessage(websocket.BinaryMessage, []byte("Prepare success!"))
 
-	return
+	return d.Transfer(model.Chain{
+		Name:     projectName,
+		IP:       remoteIp,
+		Username: userName,
+	}, dir, cover, c)
 }
 
-func (d *deploy) Transfer(chaininfo model.Chain, dir string, cover string, c chan []byte) {
+func (d *Deploy) Transfer(chaininfo model.Chain, dir string, cover string, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when transfering files for chain %s. Error: %s", chaininfo.Name, err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when transfering files for chain %s. Error: %s", chaininfo.Name, r)
+			err = fmt.Errorf("transfer files for chain %s: %v", chaininfo.Name, r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
-	cmd := "./transfer.sh -p " + chaininfo.Name
-	cmdTransfer := exec.Command("sh", "-c", cmd)
-	cmdTransfer.Dir = dir
+	cmdTransfer := scriptCommand(dir, "transfer.sh", "-p", chaininfo.Name)
 
-	d.ShellCall(cmdTransfer, nil, c)
+	if err = d.ShellCall(cmdTransfer, nil, c); err != nil {
+		return err
+	}
 	//ws.Socket.WriteMessage(websocket.BinaryMessage, []byte("Transfer success!"))
 	if cover == "" {
-		c <- []byte("Transfer new node files for " + chaininfo.Name + "!")
+		c([]byte("Transfer new node files for " + chaininfo.Name + "!"))
 	} else {
-		c <- []byte("Transfer files for " + chaininfo.Name + "!")
+		c([]byte("Transfer files for " + chaininfo.Name + "!"))
 	}
 
-	d.Init(chaininfo, dir, cover, c)
-
-	return
+	return d.Init(chaininfo, dir, cover, c)
 }
 
-func (d *deploy) Init(chaininfo model.Chain, dir string, cover string, c chan []byte) {
+func (d *Deploy) Init(chaininfo model.Chain, dir string, cover string, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when initializing chain %s. Error: %s", chaininfo.Name, err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when initializing chain %s. Error: %s", chaininfo.Name, r)
+			err = fmt.Errorf("initialize chain %s: %v", chaininfo.Name, r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
-	cmd := "./init.sh -p " + chaininfo.Name
-	cmdInit := exec.Command("sh", "-c", cmd)
-	cmdInit.Dir = dir
+	cmdInit := scriptCommand(dir, "init.sh", "-p", chaininfo.Name)
 
-	d.ShellCall(cmdInit, nil, c)
+	if err = d.ShellCall(cmdInit, nil, c); err != nil {
+		return err
+	}
 	//ws.Socket.WriteMessage(websocket.BinaryMessage, []byte("Init success!"))
 	if cover == "" {
-		c <- []byte("Initializing new node for " + chaininfo.Name + "!")
+		c([]byte("Initializing new node for " + chaininfo.Name + "!"))
 	} else {
-		c <- []byte("Initializing " + chaininfo.Name + "!")
+		c([]byte("Initializing " + chaininfo.Name + "!"))
 	}
 
-	d.Start(chaininfo, dir, cover, c)
-
-	return
+	return d.Start(chaininfo, dir, cover, c)
 }
 
-func (d *deploy) Start(chaininfo model.Chain, dir string, cover string, c chan []byte) error {
+func (d *Deploy) Start(chaininfo model.Chain, dir string, cover string, c func(msg []byte)) (err error) {
 	defer func() {
-		if err := recover(); err != nil {
-			logrus.Errorf("Something error when starting chain %s. Error: %s", chaininfo.Name, err)
+		if r := recover(); r != nil {
+			logrus.Errorf("Something error when starting chain %s. Error: %s", chaininfo.Name, r)
+			err = fmt.Errorf("start chain %s: %v", chaininfo.Name, r)
 		}
-		//todo 往channel里面写东西（成功时
 	}()
 
-	cmd := "./start.sh -p " + chaininfo.Name
-	cmdStart := exec.Command("sh", "-c", cmd)
-	cmdStart.Dir = dir
+	cmdStart := scriptCommand(dir, "start.sh", "-p", chaininfo.Name)
 
-	d.ShellCall(cmdStart, nil, c)
-	//c <- []byte("Start " + chaininfo.Name + " success!")
+	if err = d.ShellCall(cmdStart, nil, c); err != nil {
+		return err
+	}
+	//c([]byte("Start " + chaininfo.Name + " success!"))
 
 	fi, err := os.Open("deploy/release/deployment_conf/" + chaininfo.Name + "/deploy_node-0.conf")
 	logrus.Info("deploy/release/deployment_conf/" + chaininfo.Name + "/deploy_node-0.conf")
@@ -582,36 +701,48 @@ func (d *deploy) Start(chaininfo model.Chain, dir string, cover string, c chan [
 		RPCPort:    int(chaininfo.RPCPort),
 		P2PPort:    p2pPort,
 	}
-	err = dao.DefaultNodeDao.InsertNode(primaryNode)
+	err = d.nodeDao.InsertNode(primaryNode)
 	if err != nil {
-		c <- []byte("Insert Node err!")
+		c([]byte("Insert Node err!"))
 		return err
 	}
 
-	err = dao.DefaultChainDao.InsertChain(chaininfo)
+	chaininfo.ChainConfig, err = secret.EncryptChainConfig(chaininfo.ChainConfig)
 	if err != nil {
-		c <- []byte("Insert Node err!")
+		c([]byte("Insert Node err!"))
 		return err
 	}
-
-	err = DefaultWSSubscriber.SubTopicsForChain(&chaininfo)
+	err = d.chainDao.InsertChain(chaininfo)
 	if err != nil {
-		c <- []byte("Insert Node err!")
+		c([]byte("Insert Node err!"))
 		return err
 	}
 
-	c <- []byte("Start " + chaininfo.Name + " success!")
+	d.subscriber.Publish(model.ChainEvent{Type: model.ChainEventInsert, Chain: &chaininfo})
+
+	c([]byte("Start " + chaininfo.Name + " success!"))
 
 	return nil
 }
 
-func (d *deploy) ShellCall(cmd *exec.Cmd, stdin io.WriteCloser, c chan []byte) error {
+// ShellCall 执行部署脚本并把输出逐行交给 c，脚本退出后返回，脚本执行失败时返回其退出状态
+func (d *Deploy) ShellCall(cmd *exec.Cmd, stdin io.WriteCloser, c func(msg []byte)) (err error) {
 	stdout, _ := cmd.StdoutPipe()
 	reader := bufio.NewReader(stdout)
-	err := cmd.Start()
+	err = d.start(cmd)
+	if err == errDeployShutdown {
+		c([]byte("Server is shutting down, deploy canceled!"))
+		return err
+	}
 	if err != nil {
-		log.Fatalf("failed to call Run(): %v", err)
+		logrus.Errorf("failed to start deploy script: %v", err)
+		return err
 	}
+	defer func() {
+		if waitErr := d.finish(cmd); err == nil {
+			err = waitErr
+		}
+	}()
 
 	if stdin != nil {
 		_, err := io.WriteString(stdin, "n\n")
@@ -626,7 +757,7 @@ func (d *deploy) ShellCall(cmd *exec.Cmd, stdin io.WriteCloser, c chan []byte) e
 	for {
 		line, err := reader.ReadString('\n')
 		line = strings.TrimSuffix(line, "\n")
-		c <- []byte(line)
+		c([]byte(line))
 
 		if err != nil || io.EOF == err {
 			logPrint, complete := d.HandleLogs(tempLine)
@@ -642,7 +773,7 @@ func (d *deploy) ShellCall(cmd *exec.Cmd, stdin io.WriteCloser, c chan []byte) e
 	return err
 }
 
-func (d *deploy) HandleLogs(log string) (string, bool) {
+func (d *Deploy) HandleLogs(log string) (string, bool) {
 	if strings.Contains(log, "completed") {
 		logSplit := strings.Split(log, ":")
 		return strings.TrimSpace(logSplit[1]), true
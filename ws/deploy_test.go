@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"graces/exterr"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeploy_ShutdownTerminatesScripts(t *testing.T) {
+	d := NewDeploy(testDaos, nil, nil)
+	c := func(msg []byte) {}
+	done := make(chan error, 1)
+	go func() {
+		done <- d.ShellCall(exec.Command("sh", "-c", "sleep 30"), nil, c)
+	}()
+	for i := 0; i < 50 && d.running() == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.True(t, d.Shutdown(ctx) == nil)
+	assert.True(t, time.Since(start) < killTimeout)
+	<-done
+
+	err := d.ShellCall(exec.Command("sh", "-c", "echo skipped"), nil, c)
+	assert.True(t, err == errDeployShutdown)
+}
+
+func TestDeploy_ShutdownKillsProcessGroup(t *testing.T) {
+	d := NewDeploy(testDaos, nil, nil)
+	pids := make(chan string, 2)
+	c := func(msg []byte) {
+		if len(msg) > 0 {
+			pids <- string(msg)
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		// 脚本启动的子进程继承 stdout，只终止脚本本身时 ShellCall 会一直等待
+		done <- d.ShellCall(exec.Command("sh", "-c", "sleep 30 & echo $!; wait"), nil, c)
+	}()
+	pid, err := strconv.Atoi(<-pids)
+	assert.True(t, err == nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.True(t, d.Shutdown(ctx) == nil)
+	<-done
+	assert.False(t, processAlive(pid))
+}
+
+// 进程是否还在运行，已退出但未被回收的进程视为不在运行
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestDeploy_ShellCallExitStatus(t *testing.T) {
+	d := NewDeploy(testDaos, nil, nil)
+	var output []string
+	c := func(msg []byte) {
+		output = append(output, string(msg))
+	}
+	assert.True(t, d.ShellCall(exec.Command("sh", "-c", "echo done"), nil, c) == nil)
+	assert.Equal(t, "done", output[0])
+
+	// 脚本执行失败时返回退出状态
+	err := d.ShellCall(exec.Command("sh", "-c", "echo failed; exit 3"), nil, c)
+	assert.True(t, err != nil)
+	assert.Equal(t, "failed", output[2])
+}
+
+func TestDeploy_NodeCommandArgs(t *testing.T) {
+	d := NewDeploy(testDaos, nil, nil)
+	c := func(msg []byte) {}
+	// 节点ID只能是序号，不会被 shell 解析
+	for _, cmd := range []func(interface{}, func([]byte)) error{d.StartNode, d.StopNode, d.RestartNode} {
+		err := cmd(map[string]interface{}{"chainID": "61bc0c6e5a9f8b1f2c3d4e5f", "nodeID": "0; touch /tmp/graces"}, c)
+		assert.Equal(t, exterr.ErrCodeParameterInvalid, err.(*exterr.ExtError).Code)
+	}
+	assert.Equal(t, 0, d.running())
+
+	cmd := scriptCommand(DefaultDir, "clear.sh", "-p", "chain; id", "-n", "0", "-m", "stop")
+	assert.Equal(t, []string{"./clear.sh", "-p", "chain; id", "-n", "0", "-m", "stop"}, cmd.Args)
+	assert.Equal(t, DefaultDir, cmd.Dir)
+}
@@ -0,0 +1,190 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"graces/exterr"
+	"graces/model"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// EnvelopeVersion 结构化消息的协议版本
+	EnvelopeVersion = 1
+	// EnvelopeQuery 建立连接时声明使用结构化消息的查询参数，如 ?v=1，也可以直接发送结构化消息切换
+	EnvelopeQuery = "v"
+)
+
+// 结构化消息中服务端应答的消息类型
+const (
+	// 命令已通过校验，开始执行
+	EnvelopeTypeAck = "ack"
+	// 命令执行过程中的输出，如部署脚本的日志
+	EnvelopeTypeOutput = "output"
+	// 命令执行结束，每个请求有且只有一条，成功时带 payload，失败时带 error
+	EnvelopeTypeResult = "result"
+	// 心跳请求，结果为 pong
+	EnvelopeTypePing = "ping"
+)
+
+// 前端连接是否使用结构化消息
+func (c *Client) envelopeMode() bool {
+	return atomic.LoadInt32(&c.envelope) == 1
+}
+
+// 切换为结构化消息，之后的推送和通知都使用结构化消息
+func (c *Client) useEnvelope() {
+	atomic.StoreInt32(&c.envelope, 1)
+}
+
+// 建立连接时的 v 参数是否声明使用结构化消息
+func envelopeFlag(v string) int32 {
+	if v == fmt.Sprint(EnvelopeVersion) {
+		return 1
+	}
+	return 0
+}
+
+// 发送结构化消息，消息ID由服务端生成
+func (c *Client) sendEnvelope(env model.WSEnvelope) {
+	env.V = EnvelopeVersion
+	env.ID = uuid.NewV4().String()
+	msg, err := json.Marshal(env)
+	if err != nil {
+		logrus.Errorf("client [%s] marshal envelope [%s] err: %v", c.Id, env.Type, err)
+		return
+	}
+	c.Send(msg)
+}
+
+// 处理前端发送的结构化消息，转换为与旧格式相同的请求后执行
+func (c *Client) envelopeMsgProcessor(message string) error {
+	c.useEnvelope()
+	var env model.WSEnvelope
+	if err := json.Unmarshal([]byte(message), &env); err != nil {
+		err = exterr.NewError(exterr.ErrCodeWebsocketMessageInvalid, fmt.Sprintf("invalid envelope: %v", err))
+		cmdReply{client: c, enveloped: true}.reject(err)
+		return err
+	}
+	reply := cmdReply{client: c, id: env.ID, cmd: env.Type, enveloped: true}
+	var errMsg string
+	payload, ok := env.Payload.(map[string]interface{})
+	switch {
+	case env.V != EnvelopeVersion:
+		errMsg = fmt.Sprintf("unsupported version [%d]", env.V)
+	case env.ID == "":
+		errMsg = "message without [id] cannot be processed"
+	case env.Type == "":
+		errMsg = "message without [type] cannot be processed"
+	case env.Payload != nil && !ok:
+		errMsg = "[payload] must be an object"
+	}
+	if errMsg != "" {
+		err := exterr.NewError(exterr.ErrCodeWebsocketMessageInvalid, errMsg)
+		reply.reject(err)
+		return err
+	}
+
+	data := map[string]interface{}{"method": env.Type}
+	if env.Type == MsgTypeSubscribe || env.Type == MsgTypeUnsubscribe {
+		data["params"] = payload
+	} else {
+		for k, v := range payload {
+			if k != "method" {
+				data[k] = v
+			}
+		}
+	}
+	return c.processCommand(env.Type, data, reply)
+}
+
+// 前端命令的应答方式：结构化消息按请求ID回复 ack、output 和 result，旧格式的消息按原有的字符串和 topic 消息回复
+type cmdReply struct {
+	client *Client
+	// 请求ID和命令名
+	id, cmd   string
+	enveloped bool
+}
+
+// 命令开始执行，旧格式回复 legacyMsg，为空时不回复
+func (r cmdReply) ack(legacyMsg string) {
+	if r.enveloped {
+		r.client.sendEnvelope(model.WSEnvelope{Type: EnvelopeTypeAck, CorrelationID: r.id})
+		return
+	}
+	if legacyMsg != "" {
+		r.client.Send([]byte(legacyMsg))
+	}
+}
+
+// 命令执行过程中的输出
+func (r cmdReply) output(msg []byte) {
+	if r.enveloped {
+		r.client.sendEnvelope(model.WSEnvelope{Type: EnvelopeTypeOutput, CorrelationID: r.id, Payload: string(msg)})
+		return
+	}
+	r.client.Send(msg)
+}
+
+// 命令未被接受，如消息格式错误或没有权限，结构化消息只回复 result 不回复 ack
+func (r cmdReply) reject(err error) {
+	if r.enveloped {
+		r.client.sendEnvelope(model.WSEnvelope{Type: EnvelopeTypeResult, CorrelationID: r.id, Error: r.extError(err)})
+		return
+	}
+	r.client.Send([]byte(err.Error()))
+}
+
+// 命令执行结束，旧格式在 legacyType 不为空时回复 topic 消息，出错时回复 error 消息
+func (r cmdReply) done(legacyType string, payload interface{}, err error) {
+	if r.enveloped {
+		env := model.WSEnvelope{Type: EnvelopeTypeResult, CorrelationID: r.id}
+		if err != nil {
+			env.Error = r.extError(err)
+		} else {
+			env.Payload = payload
+		}
+		r.client.sendEnvelope(env)
+		return
+	}
+	if legacyType == "" {
+		return
+	}
+	if err != nil {
+		r.client.replyTopic(MsgTypeError, err.Error())
+		return
+	}
+	r.client.replyTopic(legacyType, payload)
+}
+
+// 转换为带错误码的错误，订阅命令的错误码为 ErrCodeWebsocketSubscription，其他命令为 ErrCodeWebsocketCommand
+func (r cmdReply) extError(err error) *exterr.ExtError {
+	var extErr *exterr.ExtError
+	if errors.As(err, &extErr) {
+		return extErr
+	}
+	code := exterr.ErrCodeWebsocketCommand
+	if r.cmd == MsgTypeSubscribe || r.cmd == MsgTypeUnsubscribe {
+		code = exterr.ErrCodeWebsocketSubscription
+	}
+	return exterr.NewError(code, err.Error())
+}
+
+// 按连接的消息格式编码推送消息
+func encodePush(dto model.WSSubMsgDTO, enveloped bool) ([]byte, error) {
+	if !enveloped {
+		return json.Marshal(dto)
+	}
+	return json.Marshal(model.WSEnvelope{
+		V:       EnvelopeVersion,
+		Type:    dto.Type,
+		ID:      uuid.NewV4().String(),
+		Cursor:  dto.Cursor,
+		Payload: dto.Content,
+	})
+}
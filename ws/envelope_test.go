@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"graces/exterr"
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_EnvelopeCommand(t *testing.T) {
+	client := &Client{
+		Id:      "envelope-client",
+		Group:   "envelope-group",
+		Message: make(chan []byte, 10),
+	}
+	reply := func() model.WSEnvelope {
+		var env model.WSEnvelope
+		assert.True(t, json.Unmarshal(<-client.Message, &env) == nil)
+		assert.Equal(t, EnvelopeVersion, env.V)
+		assert.True(t, env.ID != "")
+		return env
+	}
+
+	// 每个被接受的命令先回复 ack 再回复 result
+	err := client.envelopeMsgProcessor(`{"v":1,"type":"subscribe","id":"req-1","payload":{"topic":"blocks"}}`)
+	assert.True(t, err == nil)
+	assert.True(t, client.envelopeMode())
+	ack := reply()
+	assert.Equal(t, EnvelopeTypeAck, ack.Type)
+	assert.Equal(t, "req-1", ack.CorrelationID)
+	result := reply()
+	assert.Equal(t, EnvelopeTypeResult, result.Type)
+	assert.Equal(t, "req-1", result.CorrelationID)
+	assert.True(t, result.Error == nil)
+	assert.Equal(t, TopicBlocks, result.Payload.(map[string]interface{})["topic"])
+
+	// 执行失败的命令在 result 中带上错误码
+	err = client.envelopeMsgProcessor(`{"v":1,"type":"unsubscribe","id":"req-2","payload":{"subscription":"unknown"}}`)
+	assert.True(t, err != nil)
+	assert.Equal(t, EnvelopeTypeAck, reply().Type)
+	result = reply()
+	assert.Equal(t, "req-2", result.CorrelationID)
+	assert.Equal(t, exterr.ErrCodeWebsocketSubscription, result.Error.Code)
+
+	// 未知的命令和格式错误的消息只回复 result
+	err = client.envelopeMsgProcessor(`{"v":1,"type":"unknown","id":"req-3"}`)
+	assert.True(t, err != nil)
+	result = reply()
+	assert.Equal(t, EnvelopeTypeResult, result.Type)
+	assert.Equal(t, "req-3", result.CorrelationID)
+	assert.Equal(t, exterr.ErrCodeWebsocketMessageInvalid, result.Error.Code)
+	for _, msg := range []string{
+		`{"v":2,"type":"ping","id":"req-4"}`,
+		`{"v":1,"type":"ping"}`,
+		`{"v":1,"type":"ping","id":"req-5","payload":[1]}`,
+	} {
+		assert.True(t, client.envelopeMsgProcessor(msg) != nil)
+		assert.Equal(t, exterr.ErrCodeWebsocketMessageInvalid, reply().Error.Code)
+	}
+	assert.Equal(t, 0, len(client.Message))
+
+	// 推送消息使用结构化消息
+	data, err := encodePush(model.WSSubMsgDTO{ID: client.Group, Type: "newBlock", Cursor: "5-0", Content: 5}, true)
+	assert.True(t, err == nil)
+	var push model.WSEnvelope
+	assert.True(t, json.Unmarshal(data, &push) == nil)
+	assert.Equal(t, "newBlock", push.Type)
+	assert.Equal(t, "5-0", push.Cursor)
+	assert.Equal(t, float64(5), push.Payload)
+}
@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"os"
+	"testing"
+
+	"graces/cluster"
+	"graces/rpc"
+	"graces/syncer"
+	"graces/txpool"
+	"graces/web/dao"
+)
+
+var (
+	testDaos        *dao.Daos
+	testCluster     *cluster.Cluster
+	testRPCServer   *rpc.Server
+	testTxPool      *txpool.TxPool
+	testSyncManager *syncer.ChainDataSyncManager
+	testManager     *Manager
+)
+
+func TestMain(m *testing.M) {
+	daos, _, err := dao.NewDaos()
+	if err != nil {
+		daos = dao.NewMemoryDaos()
+	}
+	testDaos = daos
+	if testCluster, err = cluster.New(nil); err != nil {
+		panic(err)
+	}
+	testRPCServer = rpc.NewServer(testDaos.Chain, testDaos.Node, testDaos.Block)
+	testTxPool = txpool.NewTxPool(testDaos.Chain, testCluster.Elector)
+	testSyncManager = syncer.NewChainDataSyncManager(testDaos, testRPCServer, testCluster.Elector)
+	testManager = newTestManager()
+	os.Exit(m.Run())
+}
+
+// 使用测试的 DAO 和本地集群组件创建 websocket 管理器
+func newTestManager() *Manager {
+	manager := NewManager(testDaos, testRPCServer, testCluster.Bus, testTxPool)
+	manager.SetDeploy(NewDeploy(testDaos, testSyncManager, nil))
+	return manager
+}
@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
+	"graces/cluster"
 	"graces/config"
+	"graces/model"
+	"graces/rpc"
+	"graces/txpool"
+	"graces/web/dao"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -15,20 +21,21 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	DefaultWebsocketManager *Manager
+const (
+	// 停机时发送关闭帧的超时时间
+	closeFrameTimeout = 3 * time.Second
+	// 保留的最近关闭的连接数
+	maxClosedClients = 100
 )
 
-func init() {
-	DefaultWebsocketManager = newManager()
-	go DefaultWebsocketManager.Start()
-	go DefaultWebsocketManager.SendService()
-	go DefaultWebsocketManager.SendGroupService()
-	go DefaultWebsocketManager.SendAllService()
-}
-
-func newManager() *Manager {
+// NewManager 创建 websocket 管理器，需要调用 Run 才会开始处理消息。
+// 拨号连接收到的链上新区块通过 rpcServer 拉取后写入 daos，推送消息通过 bus 转发，同时刷新 txPool
+func NewManager(daos *dao.Daos, rpcServer *rpc.Server, bus cluster.IBus, txPool *txpool.TxPool) *Manager {
 	return &Manager{
+		daos:             daos,
+		rpcServer:        rpcServer,
+		bus:              bus,
+		txPool:           txPool,
 		Group:            make(map[string]map[string]*Client),
 		Register:         make(chan *Client, config.Config.WSConf.BuffSize),
 		UnRegister:       make(chan *Client, config.Config.WSConf.BuffSize),
@@ -37,14 +44,58 @@ func newManager() *Manager {
 		BroadCastMessage: make(chan *BroadCastMessageData, config.Config.WSConf.BuffSize),
 		groupCount:       0,
 		clientCount:      0,
+		done:             make(chan struct{}),
 	}
 }
 
+// SetDeploy 设置执行前端连接的部署和节点启停命令的部署器，需要在 Run 之前调用
+func (manager *Manager) SetDeploy(deploy *Deploy) {
+	manager.deploy = deploy
+}
+
+// Run 启动 websocket 管理器的注册、注销和消息分发协程
+func (manager *Manager) Run() {
+	go manager.Start()
+	go manager.SendService()
+	go manager.SendGroupService()
+	go manager.SendAllService()
+}
+
+// Stop 向所有连接发送关闭帧并断开连接，然后停止 websocket 管理器的所有协程
+func (manager *Manager) Stop(ctx context.Context) {
+	manager.stopOnce.Do(func() {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(closeFrameTimeout)
+		}
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+		manager.Lock.Lock()
+		for _, group := range manager.Group {
+			for _, client := range group {
+				if client.Socket == nil {
+					continue
+				}
+				client.setCloseReason("server shutdown")
+				if err := client.Socket.WriteControl(websocket.CloseMessage, closeMsg, deadline); err != nil {
+					logrus.Debugf("client [%s] write close frame err: %v", client.Id, err)
+				}
+				_ = client.Close()
+			}
+		}
+		manager.Lock.Unlock()
+		close(manager.done)
+		logrus.Infof("websocket manager stopped")
+	})
+}
+
 // Start 启动 websocket 管理器
 func (manager *Manager) Start() {
 	logrus.Infof("websocket manage start")
 	for {
 		select {
+		case <-manager.done:
+			logrus.Infof("websocket manage stop")
+			return
 		// 注册
 		case client := <-manager.Register:
 			logrus.Infof("client [%s] connect", client.Id)
@@ -65,7 +116,8 @@ func (manager *Manager) Start() {
 			manager.Lock.Lock()
 			if _, ok := manager.Group[client.Group]; ok {
 				if _, ok := manager.Group[client.Group][client.Id]; ok {
-					close(client.Message)
+					client.stop()
+					manager.recordClosed(client)
 					delete(manager.Group[client.Group], client.Id)
 					manager.clientCount -= 1
 					if len(manager.Group[client.Group]) == 0 {
@@ -84,12 +136,14 @@ func (manager *Manager) Start() {
 func (manager *Manager) SendService() {
 	for {
 		select {
+		case <-manager.done:
+			return
 		case data := <-manager.Message:
-			if groupMap, ok := manager.Group[data.Group]; ok {
-				if conn, ok := groupMap[data.Id]; ok {
-					conn.Message <- data.Message
-				}
+			manager.Lock.Lock()
+			if conn, ok := manager.Group[data.Group][data.Id]; ok {
+				conn.Send(data.Message)
 			}
+			manager.Lock.Unlock()
 		}
 	}
 }
@@ -98,13 +152,15 @@ func (manager *Manager) SendService() {
 func (manager *Manager) SendGroupService() {
 	for {
 		select {
+		case <-manager.done:
+			return
 		// 发送广播数据到某个组的 channel 变量 Send 中
 		case data := <-manager.GroupMessage:
-			if groupMap, ok := manager.Group[data.Group]; ok {
-				for _, conn := range groupMap {
-					conn.Message <- data.Message
-				}
+			manager.Lock.Lock()
+			for _, conn := range manager.Group[data.Group] {
+				conn.Send(data.Message)
 			}
+			manager.Lock.Unlock()
 		}
 	}
 }
@@ -113,24 +169,31 @@ func (manager *Manager) SendGroupService() {
 func (manager *Manager) SendAllService() {
 	for {
 		select {
+		case <-manager.done:
+			return
 		case data := <-manager.BroadCastMessage:
+			manager.Lock.Lock()
 			for _, v := range manager.Group {
 				for _, conn := range v {
-					conn.Message <- data.Message
+					conn.Send(data.Message)
 				}
 			}
+			manager.Lock.Unlock()
 		}
 	}
 }
 
-// Send 向指定的 client 发送数据
+// Send 向指定的 client 发送数据，管理器停止后直接丢弃
 func (manager *Manager) Send(id string, group string, message []byte) {
 	data := &MessageData{
 		Id:      id,
 		Group:   group,
 		Message: message,
 	}
-	manager.Message <- data
+	select {
+	case manager.Message <- data:
+	case <-manager.done:
+	}
 }
 
 // SendGroup 向指定的 Group 广播
@@ -139,7 +202,10 @@ func (manager *Manager) SendGroup(group string, message []byte) {
 		Group:   group,
 		Message: message,
 	}
-	manager.GroupMessage <- data
+	select {
+	case manager.GroupMessage <- data:
+	case <-manager.done:
+	}
 }
 
 // SendAll 向所有分组广播
@@ -147,26 +213,65 @@ func (manager *Manager) SendAll(message []byte) {
 	data := &BroadCastMessageData{
 		Message: message,
 	}
-	manager.BroadCastMessage <- data
+	select {
+	case manager.BroadCastMessage <- data:
+	case <-manager.done:
+	}
 }
 
 // RegisterClient 注册
 func (manager *Manager) RegisterClient(client *Client) {
-	manager.Register <- client
+	select {
+	case manager.Register <- client:
+	case <-manager.done:
+	}
 }
 
-// UnRegisterClient 注销
+// UnRegisterClient 注销，管理器停止后不再阻塞
 func (manager *Manager) UnRegisterClient(client *Client) {
-	manager.UnRegister <- client
+	select {
+	case manager.UnRegister <- client:
+	case <-manager.done:
+	}
+}
+
+// GroupClients 获取指定组的所有 client，组不存在时返回 nil
+func (manager *Manager) GroupClients(group string) []*Client {
+	manager.Lock.Lock()
+	defer manager.Lock.Unlock()
+	groupMap, ok := manager.Group[group]
+	if !ok {
+		return nil
+	}
+	clients := make([]*Client, 0, len(groupMap))
+	for _, client := range groupMap {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// GroupNames 获取所有组的名称
+func (manager *Manager) GroupNames() []string {
+	manager.Lock.Lock()
+	defer manager.Lock.Unlock()
+	names := make([]string, 0, len(manager.Group))
+	for name := range manager.Group {
+		names = append(names, name)
+	}
+	return names
 }
 
 // LenGroup 当前组个数
 func (manager *Manager) LenGroup() uint {
+	manager.Lock.Lock()
+	defer manager.Lock.Unlock()
 	return manager.groupCount
 }
 
 // LenClient 当前连接个数
 func (manager *Manager) LenClient() uint {
+	manager.Lock.Lock()
+	defer manager.Lock.Unlock()
 	return manager.clientCount
 }
 
@@ -180,21 +285,50 @@ func (manager *Manager) Info() map[string]interface{} {
 	managerInfo["chanMessageLen"] = len(manager.Message)
 	managerInfo["chanGroupMessageLen"] = len(manager.GroupMessage)
 	managerInfo["chanBroadCastMessageLen"] = len(manager.BroadCastMessage)
+	managerInfo["droppedCnt"] = manager.DroppedCnt()
+	managerInfo["disconnectCnt"] = manager.DisconnectCnt()
 	return managerInfo
 }
 
+// 记录已关闭的连接，调用方需要持有 Lock
+func (manager *Manager) recordClosed(client *Client) {
+	manager.closedClients = append(manager.closedClients, client.ToVO())
+	if len(manager.closedClients) > maxClosedClients {
+		manager.closedClients = manager.closedClients[len(manager.closedClients)-maxClosedClients:]
+	}
+}
+
+// ClosedClients 最近关闭的连接信息，按关闭的先后排列，最多保留 100 个
+func (manager *Manager) ClosedClients() []model.WSClientVO {
+	manager.Lock.Lock()
+	defer manager.Lock.Unlock()
+	clients := make([]model.WSClientVO, len(manager.closedClients))
+	copy(clients, manager.closedClients)
+	return clients
+}
+
+// DroppedCnt 因客户端缓冲队列已满被丢弃的消息总数
+func (manager *Manager) DroppedCnt() uint64 {
+	return atomic.LoadUint64(&manager.droppedCnt)
+}
+
+// DisconnectCnt 因客户端消费过慢被断开的连接总数
+func (manager *Manager) DisconnectCnt() uint64 {
+	return atomic.LoadUint64(&manager.disconnectCnt)
+}
+
 // WsClient gin 处理 websocket handler
 func (manager *Manager) WsClient(ctx *gin.Context) {
 	group := ctx.Param("group")
 
 	// 创建 websocket 升级器
 	upGrader := websocket.Upgrader{
-		// cross origin domain
+		// 跨域校验与 HTTP 接口的跨域配置保持一致
 		CheckOrigin: func(r *http.Request) bool {
-			return true
+			return config.CorsPolicy().AllowsWebsocketOrigin(r.Header.Get("Origin"), r.Host)
 		},
-		// 处理 Sec-WebSocket-Protocol Header
-		Subprotocols: []string{ctx.GetHeader("Sec-WebSocket-Protocol")},
+		// 回应客户端请求的第一个 Sec-WebSocket-Protocol，携带 token 的客户端需要服务端回应子协议
+		Subprotocols: websocket.Subprotocols(ctx.Request),
 	}
 	// 将 http 升级为 websocket
 	conn, err := upGrader.Upgrade(ctx.Writer, ctx.Request, nil)
@@ -210,24 +344,56 @@ func (manager *Manager) WsClient(ctx *gin.Context) {
 		RemoteAddr: conn.RemoteAddr().String(),
 		Path:       ctx.Request.URL.String(),
 		Socket:     conn,
-		IsAlive:    true,
 		IsDial:     false,
 		RetryCnt:   0,
 		Message:    make(chan []byte, config.Config.WSConf.BuffSize),
+		alive:      1,
+		lastActive: time.Now().UnixNano(),
+		dropPolicy: clientDropPolicy(ctx.Query("drop_policy")),
+		envelope:   envelopeFlag(ctx.Query(EnvelopeQuery)),
+		manager:    manager,
+	}
+	if auth, ok := ctx.Get(AuthKey); ok {
+		client.auth, _ = auth.(*Auth)
+	}
+	// 断线重连时带上最后收到的事件游标，先回放错过的区块、交易和日志再继续实时推送
+	lastCursor := ctx.Query("last_cursor")
+	var cursor eventCursor
+	if lastCursor != "" {
+		cursor, err = parseCursor(lastCursor)
+		if err != nil {
+			client.replyTopic(MsgTypeError, err.Error())
+			lastCursor = ""
+		} else {
+			client.beginReplay()
+		}
 	}
 	manager.RegisterClient(client)
 	go client.Read()
 	go client.Write()
+	if lastCursor != "" {
+		go client.replay(cursor, client.wants)
+	}
+}
+
+// 前端连接的缓冲队列已满时的处理策略，连接参数 drop_policy 优先，其次为配置的 ws.drop_policy
+func clientDropPolicy(policy string) string {
+	if policy == DropPolicyDropOldest || policy == DropPolicyDisconnect {
+		return policy
+	}
+	if config.Config.WSConf.DropPolicy != "" {
+		return config.Config.WSConf.DropPolicy
+	}
+	return DropPolicyDropOldest
 }
 
 // Dial 作为 websocket 客户端拨号去连接其他 websocket 服务端
 func (manager *Manager) Dial(ip string, port int64, path string, group string) (*Client, error) {
-	host := fmt.Sprintf("%s:%v", ip, port)
-	uri := url.URL{
-		Scheme: "ws",
-		Host:   host,
-		Path:   path,
-	}
+	return manager.dial(dialURL(ip, port, path), group, nil)
+}
+
+// 拨号连接 websocket 服务端，onLost 不为空时在连接意外断开后回调
+func (manager *Manager) dial(uri url.URL, group string, onLost func(c *Client)) (*Client, error) {
 	ctx, _ := context.WithTimeout(context.Background(), config.Config.WSConf.Timeout*time.Second)
 	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, uri.String(), nil)
 	if err != nil {
@@ -242,12 +408,18 @@ func (manager *Manager) Dial(ip string, port int64, path string, group string) (
 		Group:      group,
 		LocalAddr:  conn.LocalAddr().String(),
 		RemoteAddr: conn.RemoteAddr().String(),
-		Path:       path,
+		Path:       uri.Path,
 		Socket:     conn,
-		IsAlive:    true,
 		IsDial:     true,
 		RetryCnt:   0,
 		Message:    make(chan []byte, config.Config.WSConf.BuffSize),
+		alive:      1,
+		lastActive: time.Now().UnixNano(),
+		// 拨号连接消费过慢时断开重连，重连后会补齐错过的区块
+		dropPolicy: DropPolicyDisconnect,
+		manager:    manager,
+		dialURL:    uri.String(),
+		onLost:     onLost,
 	}
 	manager.RegisterClient(client)
 	go client.Read()
@@ -255,6 +427,26 @@ func (manager *Manager) Dial(ip string, port int64, path string, group string) (
 	return client, nil
 }
 
+// 等待指定的时长，期间管理器被停止则返回 false
+func (manager *Manager) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-manager.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func dialURL(ip string, port int64, path string) url.URL {
+	return url.URL{
+		Scheme: "ws",
+		Host:   fmt.Sprintf("%s:%v", ip, port),
+		Path:   path,
+	}
+}
+
 func (manager *Manager) GetClientById(id string) *Client {
 	manager.Lock.Lock()
 	defer manager.Lock.Unlock()
@@ -1,16 +1,23 @@
 package ws
 
 import (
+	"context"
 	"log"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"graces/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestManager_RegisterClient(t *testing.T) {
-	go DefaultWebsocketManager.Start()
+	go testManager.Start()
 	client := &Client{
 		Id:      uuid.NewV4().String(),
 		Group:   "test",
@@ -18,13 +25,13 @@ func TestManager_RegisterClient(t *testing.T) {
 		Message: nil,
 	}
 	time.Sleep(2 * time.Second)
-	DefaultWebsocketManager.RegisterClient(client)
-	log.Println(DefaultWebsocketManager.Info())
-	assert.True(t, DefaultWebsocketManager.LenClient() > 0)
+	testManager.RegisterClient(client)
+	log.Println(testManager.Info())
+	assert.True(t, testManager.LenClient() > 0)
 }
 
 func TestManager_UnRegisterClient(t *testing.T) {
-	go DefaultWebsocketManager.Start()
+	go testManager.Start()
 	client := &Client{
 		Id:      uuid.NewV4().String(),
 		Group:   "test",
@@ -32,13 +39,13 @@ func TestManager_UnRegisterClient(t *testing.T) {
 		Message: nil,
 	}
 	time.Sleep(2 * time.Second)
-	DefaultWebsocketManager.RegisterClient(client)
-	log.Println(DefaultWebsocketManager.Info())
-	assert.True(t, DefaultWebsocketManager.LenClient() > 0)
+	testManager.RegisterClient(client)
+	log.Println(testManager.Info())
+	assert.True(t, testManager.LenClient() > 0)
 	time.Sleep(2 * time.Second)
-	DefaultWebsocketManager.UnRegisterClient(client)
-	log.Println(DefaultWebsocketManager.Info())
-	assert.True(t, DefaultWebsocketManager.LenClient() == 0)
+	testManager.UnRegisterClient(client)
+	log.Println(testManager.Info())
+	assert.True(t, testManager.LenClient() == 0)
 }
 
 func TestManager_Dial(t *testing.T) {
@@ -46,7 +53,181 @@ func TestManager_Dial(t *testing.T) {
 	port := 26791
 	group := "Venachain"
 	path := ""
-	go DefaultWebsocketManager.Start()
-	client, err := DefaultWebsocketManager.Dial(ip, int64(port), path, group)
+	go testManager.Start()
+	client, err := testManager.Dial(ip, int64(port), path, group)
 	assert.True(t, client != nil && err == nil)
 }
+
+func TestManager_StopSendsCloseFrame(t *testing.T) {
+	manager := newTestManager()
+	manager.Run()
+	router := gin.New()
+	router.GET("/:group", manager.WsClient)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/test", nil)
+	assert.True(t, err == nil)
+	defer conn.Close()
+	for i := 0; i < 50 && manager.LenClient() == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.True(t, manager.LenClient() == 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	manager.Stop(ctx)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
+
+func TestManager_SendAfterStop(t *testing.T) {
+	manager := newTestManager()
+	manager.Run()
+	manager.Stop(context.Background())
+
+	// 停止后没有协程消费队列，发送和注销不能阻塞调用方
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < cap(manager.Message)+1; i++ {
+			manager.Send("id", "test", []byte("msg"))
+			manager.SendGroup("test", []byte("msg"))
+			manager.SendAll([]byte("msg"))
+			manager.UnRegisterClient(&Client{Id: "id", Group: "test"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send after stop blocked")
+	}
+}
+
+func TestClient_SendDropPolicy(t *testing.T) {
+	manager := newTestManager()
+	oldest := &Client{
+		Id:         uuid.NewV4().String(),
+		Message:    make(chan []byte, 2),
+		dropPolicy: DropPolicyDropOldest,
+		manager:    manager,
+	}
+	for _, msg := range []string{"1", "2", "3"} {
+		oldest.Send([]byte(msg))
+	}
+	assert.Equal(t, uint64(1), oldest.DroppedCnt())
+	assert.Equal(t, "2", string(<-oldest.Message))
+	assert.Equal(t, "3", string(<-oldest.Message))
+
+	slow := &Client{
+		Id:         uuid.NewV4().String(),
+		Message:    make(chan []byte, 1),
+		dropPolicy: DropPolicyDisconnect,
+		manager:    manager,
+	}
+	for _, msg := range []string{"1", "2", "3"} {
+		slow.Send([]byte(msg))
+	}
+	// 断开后不再接收消息
+	assert.Equal(t, uint64(1), slow.DroppedCnt())
+	assert.Equal(t, uint64(2), manager.DroppedCnt())
+	assert.Equal(t, uint64(1), manager.DisconnectCnt())
+
+	// 注销后写入的消息被丢弃
+	slow.stop()
+	slow.Send([]byte("4"))
+	assert.Equal(t, "1", string(<-slow.Message))
+	_, ok := <-slow.Message
+	assert.True(t, !ok)
+}
+
+func TestManager_SlowClientDoesNotBlockGroup(t *testing.T) {
+	manager := newTestManager()
+	manager.Run()
+	defer manager.Stop(context.Background())
+
+	group := "backpressure"
+	// 没有写协程消费的连接
+	slow := &Client{Id: uuid.NewV4().String(), Group: group, Message: make(chan []byte, 1), dropPolicy: DropPolicyDropOldest, manager: manager}
+	fast := &Client{Id: uuid.NewV4().String(), Group: group, Message: make(chan []byte, 16), dropPolicy: DropPolicyDropOldest, manager: manager}
+	manager.RegisterClient(slow)
+	manager.RegisterClient(fast)
+	for i := 0; i < 50 && manager.LenClient() < 2; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	received := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			<-fast.Message
+		}
+		close(received)
+	}()
+	for i := 0; i < 10; i++ {
+		manager.SendGroup(group, []byte("msg"))
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow client blocks group delivery")
+	}
+	assert.True(t, slow.DroppedCnt() > 0)
+
+	// 注销与发送并发进行
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			manager.SendGroup(group, []byte("msg"))
+			_ = manager.GroupClients(group)
+		}
+		close(done)
+	}()
+	manager.UnRegisterClient(slow)
+	manager.UnRegisterClient(fast)
+	<-done
+}
+
+func TestManager_PongTimeout(t *testing.T) {
+	pingInterval, pongTimeout := config.Config.WSConf.PingInterval, config.Config.WSConf.PongTimeout
+	config.Config.WSConf.PingInterval, config.Config.WSConf.PongTimeout = 1, 1
+	defer func() {
+		config.Config.WSConf.PingInterval, config.Config.WSConf.PongTimeout = pingInterval, pongTimeout
+	}()
+	manager := newTestManager()
+	manager.Run()
+	defer manager.Stop(context.Background())
+	router := gin.New()
+	router.GET("/:group", manager.WsClient)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// 正常响应 ping 的连接保持存活
+	alive, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/pong", nil)
+	assert.True(t, err == nil)
+	defer alive.Close()
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	// 不读取数据的连接无法响应 ping
+	halfOpen, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/pong", nil)
+	assert.True(t, err == nil)
+	defer halfOpen.Close()
+
+	for i := 0; i < 50 && manager.LenClient() < 2; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.True(t, manager.LenClient() == 2)
+	for i := 0; i < 200 && manager.LenClient() > 1; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.True(t, manager.LenClient() == 1)
+	closed := manager.ClosedClients()
+	assert.True(t, len(closed) == 1)
+	assert.Equal(t, "pong timeout", closed[0].CloseReason)
+}
@@ -0,0 +1,215 @@
+package ws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"graces/config"
+	"graces/model"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 事件回放的消息类型
+const (
+	// 错过的区块超过 ws.max_replay_blocks，只回放最近的区块
+	MsgTypeReplayTruncated = "replayTruncated"
+	// 回放结束，之后的消息为实时推送
+	MsgTypeReplayed = "replayed"
+)
+
+// 事件游标，格式为 "区块高度-序号"，区块的序号为 0，交易的序号为其在区块中的序号加 1，日志与所属交易相同
+type eventCursor struct {
+	height uint64
+	index  uint64
+}
+
+func (cursor eventCursor) String() string {
+	return fmt.Sprintf("%d-%d", cursor.height, cursor.index)
+}
+
+func parseCursor(s string) (eventCursor, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return eventCursor{}, fmt.Errorf("invalid cursor [%s]", s)
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return eventCursor{}, fmt.Errorf("invalid cursor [%s]", s)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return eventCursor{}, fmt.Errorf("invalid cursor [%s]", s)
+	}
+	return eventCursor{height: height, index: index}, nil
+}
+
+// 推送消息的去重键，同一笔交易的多条日志按日志序号区分
+func eventKey(dto model.WSSubMsgDTO) string {
+	if dto.Cursor == "" {
+		return ""
+	}
+	key := dto.Type + "/" + dto.Cursor
+	if log, ok := dto.Content.(*model.TXLogVO); ok {
+		key += "/" + log.LogIndex
+	}
+	return key
+}
+
+// 回放期间暂存的实时推送消息
+type pendingMsg struct {
+	key string
+	msg []byte
+}
+
+// 发送实时推送的消息，正在回放时先暂存，回放结束后跳过已回放的消息再发送
+func (c *Client) deliver(key string, msg []byte) {
+	c.replayLock.Lock()
+	defer c.replayLock.Unlock()
+	if c.replaying == 0 {
+		c.Send(msg)
+		return
+	}
+	if int64(len(c.pending)) >= config.Config.WSConf.BuffSize {
+		// 暂存的消息过多时与缓冲队列已满的处理一致，丢弃最早的消息
+		c.pending = c.pending[1:]
+		c.countDropped()
+	}
+	c.pending = append(c.pending, pendingMsg{key: key, msg: msg})
+}
+
+// 开始回放，需要在回放的消息发送之前同步调用，之后的实时推送会被暂存
+func (c *Client) beginReplay() {
+	c.replayLock.Lock()
+	defer c.replayLock.Unlock()
+	if c.replaying == 0 {
+		c.replayed = make(map[string]bool)
+	}
+	c.replaying++
+}
+
+// 结束回放，所有回放都结束后发送暂存的实时推送
+func (c *Client) finishReplay() {
+	c.replayLock.Lock()
+	defer c.replayLock.Unlock()
+	c.replaying--
+	if c.replaying > 0 {
+		return
+	}
+	for _, pending := range c.pending {
+		if pending.key != "" && c.replayed[pending.key] {
+			continue
+		}
+		c.Send(pending.msg)
+	}
+	c.pending = nil
+	c.replayed = nil
+}
+
+// 回放游标之后的区块、交易和日志，want 决定是否需要回放该事件，调用前需要先调用 beginReplay
+func (c *Client) replay(from eventCursor, want func(event *topicEvent) bool) {
+	defer c.finishReplay()
+	last, err := c.replayFrom(from, want)
+	if err != nil {
+		logrus.Errorf("client [%s] replay from [%s] err: %v", c.Id, from, err)
+		c.replyTopic(MsgTypeError, fmt.Sprintf("replay from [%s] failed: %v", from, err))
+		return
+	}
+	c.replyTopic(MsgTypeReplayed, map[string]interface{}{"cursor": last.String()})
+}
+
+func (c *Client) replayFrom(from eventCursor, want func(event *topicEvent) bool) (eventCursor, error) {
+	chainID, err := primitive.ObjectIDFromHex(c.Group)
+	if err != nil {
+		return from, fmt.Errorf("group [%s] is not a chain", c.Group)
+	}
+	filter := bson.M{"chain_id": chainID, "height": bson.M{"$gt": from.height}}
+	missed, err := c.manager.daos.Block.Count(filter, nil)
+	if err != nil {
+		return from, err
+	}
+
+	var blocks []*model.Block
+	max := config.Config.WSConf.MaxReplayBlocks()
+	if missed > max {
+		// 只回放最近的区块，游标所在区块中剩余的交易不再回放
+		findOps := options.Find().SetSort(bson.D{{"height", -1}}).SetLimit(max)
+		blocks, err = c.manager.daos.Block.Blocks(filter, findOps)
+		if err != nil {
+			return from, err
+		}
+		for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+		}
+		c.replyTopic(MsgTypeReplayTruncated, map[string]interface{}{
+			"cursor": from.String(),
+			"missed": missed,
+			"replay": len(blocks),
+		})
+	} else {
+		filter["height"] = bson.M{"$gte": from.height}
+		findOps := options.Find().SetSort(bson.D{{"height", 1}})
+		blocks, err = c.manager.daos.Block.Blocks(filter, findOps)
+		if err != nil {
+			return from, err
+		}
+	}
+
+	last := from
+	for _, block := range blocks {
+		if block.Height > from.height {
+			dto, err := blockMsg(c.Group, block)
+			if err != nil {
+				return last, err
+			}
+			c.replayEvent(dto, want)
+			last = eventCursor{height: block.Height}
+		}
+		findOps := options.Find().SetSort(bson.D{{"_id", 1}})
+		txs, err := c.manager.daos.TX.TXs(bson.M{"block_id": block.ID}, findOps)
+		if err != nil {
+			return last, err
+		}
+		for i, tx := range txs {
+			cursor := eventCursor{height: block.Height, index: uint64(i) + 1}
+			if block.Height == from.height && cursor.index <= from.index {
+				continue
+			}
+			dto, err := txMsg(c.Group, tx, i)
+			if err != nil {
+				return last, err
+			}
+			c.replayEvent(dto, want)
+			logs, err := logMsgs(c.Group, tx, i)
+			if err != nil {
+				return last, err
+			}
+			for _, log := range logs {
+				c.replayEvent(log, want)
+			}
+			last = cursor
+		}
+	}
+	return last, nil
+}
+
+// 发送单个回放的事件，并记录下来以便跳过回放期间暂存的相同事件
+func (c *Client) replayEvent(dto model.WSSubMsgDTO, want func(event *topicEvent) bool) {
+	event := newTopicEvent(dto)
+	if event == nil || !want(event) {
+		return
+	}
+	msg, err := encodePush(dto, c.envelopeMode())
+	if err != nil {
+		logrus.Errorf("client [%s] marshal replay message err: %v", c.Id, err)
+		return
+	}
+	c.replayLock.Lock()
+	c.replayed[eventKey(dto)] = true
+	c.replayLock.Unlock()
+	c.Send(msg)
+}
@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestClient_ReplayFromCursor(t *testing.T) {
+	chainID := primitive.NewObjectID()
+	for height := uint64(1); height <= 3; height++ {
+		block := model.Block{
+			ID:      primitive.NewObjectID(),
+			ChainID: chainID,
+			Height:  height,
+			Head:    &model.BLockHead{Height: height},
+		}
+		assert.True(t, testDaos.Block.InsertBlock(block) == nil)
+		for i := 0; i < 2; i++ {
+			tx := model.TX{
+				ID:      primitive.NewObjectID(),
+				ChainID: chainID,
+				BlockID: block.ID,
+				Height:  height,
+				Receipt: &model.Receipt{},
+			}
+			assert.True(t, testDaos.TX.InsertTX(tx) == nil)
+		}
+	}
+	client := &Client{
+		Id:      "replay-client",
+		Group:   chainID.Hex(),
+		Message: make(chan []byte, 20),
+		manager: testManager,
+	}
+	cursor, err := parseCursor("1-1")
+	assert.True(t, err == nil)
+	_, err = parseCursor("1")
+	assert.True(t, err != nil)
+
+	// 回放期间的实时推送暂存到回放结束，已回放的事件不会重复发送
+	client.beginReplay()
+	live := func(dto model.WSSubMsgDTO) {
+		msg, _ := json.Marshal(dto)
+		client.deliver(eventKey(dto), msg)
+	}
+	block3, _ := blockMsg(client.Group, &model.Block{Height: 3, Head: &model.BLockHead{}})
+	block4, _ := blockMsg(client.Group, &model.Block{Height: 4, Head: &model.BLockHead{}})
+	live(block3)
+	live(block4)
+	assert.Equal(t, 0, len(client.Message))
+	client.replay(cursor, client.wants)
+
+	var cursors []string
+	for len(client.Message) > 0 {
+		var dto model.WSSubMsgDTO
+		assert.True(t, json.Unmarshal(<-client.Message, &dto) == nil)
+		if dto.Type == MsgTypeReplayed {
+			cursors = append(cursors, MsgTypeReplayed)
+			continue
+		}
+		cursors = append(cursors, dto.Cursor)
+	}
+	assert.Equal(t, []string{"1-2", "2-0", "2-1", "2-2", "3-0", "3-1", "3-2", MsgTypeReplayed, "4-0"}, cursors)
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"graces/util"
-	"graces/web/dao"
 
 	"github.com/sirupsen/logrus"
 )
@@ -67,7 +66,7 @@ func (s *SendReplyMsgProcessor) Subscription(ctx *MsgProcessorContext, msg inter
 	id := fields[2]
 	hash := data["result"].(string)
 	// 将订阅成功后返回的订阅哈希值设置到消息记录中
-	if err := dao.DefaultWSMsgDao.UpdateWSMsgHash(id, topic, hash); err != nil {
+	if err := ctx.client.manager.daos.WSMsg.UpdateWSMsgHash(id, topic, hash); err != nil {
 		return err
 	}
 	logrus.Infof("topic[%v] subscribe success, update subMsg[%v] hash to [%v]", topic, id, hash)
@@ -55,6 +55,6 @@ func (s *StringMsgProcessor) Ping(ctx *MsgProcessorContext, msg string) error {
 		errStr := fmt.Sprintf("can't response the msg: %v, it's not [ping]", msg)
 		return errors.New(errStr)
 	}
-	ctx.client.Message <- []byte("pong")
+	ctx.client.Send([]byte("pong"))
 	return nil
 }
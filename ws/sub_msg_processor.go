@@ -6,12 +6,12 @@ import (
 	"fmt"
 	"reflect"
 
+	"graces/cluster"
 	"graces/config"
 	"graces/exterr"
 	"graces/model"
 	"graces/rpc"
 	"graces/util"
-	"graces/web/dao"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,12 +19,14 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func NewSubMsgProcessor() *SubMsgProcessor {
-	return &SubMsgProcessor{}
+func NewSubMsgProcessor(manager *Manager) *SubMsgProcessor {
+	return &SubMsgProcessor{manager: manager}
 }
 
 // SubMsgProcessor 订阅消息处理
-type SubMsgProcessor struct{}
+type SubMsgProcessor struct {
+	manager *Manager
+}
 
 func (s *SubMsgProcessor) Process(ctx *MsgProcessorContext, msg interface{}) error {
 	logrus.Debugf("websocket subscription message process [start]:\n%+v", msg)
@@ -46,7 +48,7 @@ func (s *SubMsgProcessor) Process(ctx *MsgProcessorContext, msg interface{}) err
 		return errors.New(errStr)
 	}
 	// 通过哈希拿到存储的消息数据
-	wsMsg, err := dao.DefaultWSMsgDao.WSMsg(bson.M{"hash": subHash})
+	wsMsg, err := s.manager.daos.WSMsg.WSMsg(bson.M{"hash": subHash})
 	if err != nil {
 		return err
 	}
@@ -109,20 +111,20 @@ func (s *SubMsgProcessor) NewHeads(client *Client, chainID string, params map[st
 	logrus.Debugf("block[%s] sync start...", blockHash)
 	defer logrus.Debugf("block[%s] sync completed", blockHash)
 
-	head, err := rpc.GetBlockHeadByHash(chainID, blockHash)
+	head, err := s.manager.rpcServer.GetBlockHeadByHash(chainID, blockHash)
 	if err != nil {
 		return err
 	}
-	block, err := rpc.GetBlockByHash(chainID, blockHash)
+	block, err := s.manager.rpcServer.GetBlockByHash(chainID, blockHash)
 	if err != nil {
 		return err
 	}
 	block.Head = head
-	err = dao.DefaultBlockDao.InsertBlock(*block)
+	err = s.manager.daos.Block.InsertBlock(*block)
 	if err != nil {
 		return err
 	}
-	txs, err := rpc.GetTXDataByBlockHash(chainID, block.ID.Hex(), blockHash)
+	txs, err := s.manager.rpcServer.GetTXDataByBlockHash(chainID, block.ID.Hex(), blockHash)
 	if err != nil {
 		return err
 	}
@@ -130,26 +132,32 @@ func (s *SubMsgProcessor) NewHeads(client *Client, chainID string, params map[st
 		// 保存合约
 		if tx.To == "" {
 			contract := tx.ToContract()
-			err = dao.DefaultContractDao.InsertContract(*contract)
+			err = s.manager.daos.Contract.InsertContract(*contract)
 			if err != nil {
 				return err
 			}
 		}
 
-		err = dao.DefaultTXDao.InsertTX(*tx)
+		err = s.manager.daos.TX.InsertTX(*tx)
 		if err != nil {
 			logrus.Errorln(err)
 		}
 	}
 	logrus.Infof("sync success block[%v][%v]", block.Height, block.Hash)
+	// 新区块打包的交易离开交易池，立即刷新
+	s.manager.txPool.Refresh(chainID)
 
 	// 把从链上接收到的新数据转发到订阅该事件的 ws 前端客户端
 	err = s.forwardBlock(chainID, block)
 	if err != nil {
 		return err
 	}
-	for _, tx := range txs {
-		err = s.forwardTX(chainID, tx)
+	for i, tx := range txs {
+		err = s.forwardTX(chainID, tx, i)
+		if err != nil {
+			return err
+		}
+		err = s.forwardLogs(chainID, tx, i)
 		if err != nil {
 			return err
 		}
@@ -174,47 +182,119 @@ func (s *SubMsgProcessor) NewHeads(client *Client, chainID string, params map[st
 }
 
 func (s *SubMsgProcessor) forwardBlock(group string, block *model.Block) error {
-	if block == nil {
-		errStr := "can not to forward nil block"
-		return exterr.NewError(exterr.ErrCodeWebsocketSubMsgProcess, errStr)
-	}
-	vo, err := block.ToVO()
+	dto, err := blockMsg(group, block)
 	if err != nil {
 		return err
 	}
-	dto := model.WSSubMsgDTO{
-		ID:      group,
-		Type:    config.Config.WSConf.WsMsgTypesConf.Pub.BlockType,
-		Content: vo,
+	// 对该组的客户端进行广播
+	return s.Forward("", group, dto)
+}
+
+// index 为交易在区块中的序号，从 0 开始
+func (s *SubMsgProcessor) forwardTX(group string, tx *model.TX, index int) error {
+	dto, err := txMsg(group, tx, index)
+	if err != nil {
+		return err
 	}
 	// 对该组的客户端进行广播
-	err = s.Forward("", group, dto)
+	return s.Forward("", group, dto)
+}
+
+func (s *SubMsgProcessor) forwardLogs(group string, tx *model.TX, index int) error {
+	dtos, err := logMsgs(group, tx, index)
 	if err != nil {
 		return err
 	}
+	for _, dto := range dtos {
+		// 对该组订阅了日志的客户端进行转发
+		if err := s.Forward("", group, dto); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *SubMsgProcessor) forwardTX(group string, tx *model.TX) error {
+// 区块的推送消息，游标的序号为 0
+func blockMsg(group string, block *model.Block) (model.WSSubMsgDTO, error) {
+	if block == nil {
+		errStr := "can not to forward nil block"
+		return model.WSSubMsgDTO{}, exterr.NewError(exterr.ErrCodeWebsocketSubMsgProcess, errStr)
+	}
+	vo, err := block.ToVO()
+	if err != nil {
+		return model.WSSubMsgDTO{}, err
+	}
+	return model.WSSubMsgDTO{
+		ID:      group,
+		Type:    config.Config.WSConf.WsMsgTypesConf.Pub.BlockType,
+		Cursor:  eventCursor{height: block.Height}.String(),
+		Content: vo,
+	}, nil
+}
+
+// 交易的推送消息，游标的序号为交易在区块中的序号加 1
+func txMsg(group string, tx *model.TX, index int) (model.WSSubMsgDTO, error) {
 	if tx == nil {
 		errStr := "can not to forward nil tx"
-		return exterr.NewError(exterr.ErrCodeWebsocketSubMsgProcess, errStr)
+		return model.WSSubMsgDTO{}, exterr.NewError(exterr.ErrCodeWebsocketSubMsgProcess, errStr)
 	}
 	vo, err := tx.ToVO()
 	if err != nil {
-		return err
+		return model.WSSubMsgDTO{}, err
 	}
-	dto := model.WSSubMsgDTO{
+	return model.WSSubMsgDTO{
 		ID:      group,
 		Type:    config.Config.WSConf.WsMsgTypesConf.Pub.TXType,
+		Cursor:  eventCursor{height: tx.Height, index: uint64(index) + 1}.String(),
 		Content: vo,
+	}, nil
+}
+
+// 收据中的合约日志
+type receiptLog struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	LogIndex string   `json:"logIndex"`
+}
+
+// 交易中合约日志的推送消息，与所属交易使用相同的游标
+func logMsgs(group string, tx *model.TX, index int) ([]model.WSSubMsgDTO, error) {
+	if tx == nil {
+		errStr := "can not to forward logs of nil tx"
+		return nil, exterr.NewError(exterr.ErrCodeWebsocketSubMsgProcess, errStr)
 	}
-	// 对该组的客户端进行广播
-	err = s.Forward("", group, dto)
-	if err != nil {
-		return err
+	if tx.Receipt == nil || tx.Receipt.Event == "" {
+		return nil, nil
 	}
-	return nil
+	var logs []*receiptLog
+	if err := json.Unmarshal([]byte(tx.Receipt.Event), &logs); err != nil {
+		return nil, err
+	}
+	method := txMethod(tx.To, tx.Input)
+	cursor := eventCursor{height: tx.Height, index: uint64(index) + 1}.String()
+	dtos := make([]model.WSSubMsgDTO, 0, len(logs))
+	for _, log := range logs {
+		if log == nil {
+			continue
+		}
+		dtos = append(dtos, model.WSSubMsgDTO{
+			ID:     group,
+			Type:   config.Config.WSConf.WsMsgTypesConf.Pub.LogType,
+			Cursor: cursor,
+			Content: &model.TXLogVO{
+				ChainID:  tx.ChainID.Hex(),
+				TxHash:   tx.Hash,
+				Height:   tx.Height,
+				Address:  log.Address,
+				Topics:   log.Topics,
+				Data:     log.Data,
+				LogIndex: log.LogIndex,
+				Method:   method,
+			},
+		})
+	}
+	return dtos, nil
 }
 
 func (s *SubMsgProcessor) forwardStats(group string, stats *model.StatsVO) error {
@@ -264,13 +344,105 @@ func (s *SubMsgProcessor) Forward(clientID string, group string, dto model.WSSub
 		return err
 	}
 	if clientID == "" {
-		DefaultWebsocketManager.SendGroup(group, jsonMsg)
-		return nil
+		// 通过总线广播，多实例部署时每个实例都转发给自己的前端连接
+		return s.manager.bus.Publish(group, jsonMsg)
 	}
-	DefaultWebsocketManager.Send(clientID, group, jsonMsg)
+	s.manager.Send(clientID, group, jsonMsg)
 	return nil
 }
 
+// ForwardBusMessage 把总线上的推送消息转发给当前实例中需要该事件的前端连接
+func (manager *Manager) ForwardBusMessage(msg *cluster.BusMessage) {
+	dto, err := decodeSubMsg(msg.Data)
+	if err != nil {
+		logrus.Errorf("group [%s] decode bus message from instance [%s] err: %v", msg.Group, msg.Instance, err)
+		return
+	}
+	event := newTopicEvent(dto)
+	if event == nil {
+		manager.SendGroup(msg.Group, msg.Data)
+		return
+	}
+	// 只转发给需要该事件的前端连接，正在回放的连接会在回放结束后收到
+	key := eventKey(dto)
+	var envelope []byte
+	for _, client := range manager.GroupClients(msg.Group) {
+		if client.IsDial || !client.wants(event) {
+			continue
+		}
+		if !client.envelopeMode() {
+			client.deliver(key, msg.Data)
+			continue
+		}
+		// 使用结构化消息的连接共用同一条编码后的消息
+		if envelope == nil {
+			if envelope, err = encodePush(dto, true); err != nil {
+				logrus.Errorf("group [%s] encode envelope err: %v", msg.Group, err)
+				return
+			}
+		}
+		client.deliver(key, envelope)
+	}
+}
+
+// 解析推送消息，交易和日志的内容解析为对应的类型以便按订阅条件过滤
+func decodeSubMsg(data []byte) (model.WSSubMsgDTO, error) {
+	var msg struct {
+		model.WSSubMsgDTO
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return model.WSSubMsgDTO{}, err
+	}
+	dto := msg.WSSubMsgDTO
+	pub := config.Config.WSConf.WsMsgTypesConf.Pub
+	switch dto.Type {
+	case pub.TXType:
+		dto.Content = &model.TXVO{}
+	case pub.LogType:
+		dto.Content = &model.TXLogVO{}
+	case pub.PendingTXType:
+		dto.Content = &model.TxPoolEvent{}
+	default:
+		dto.Content = msg.Content
+		return dto, nil
+	}
+	if err := json.Unmarshal(msg.Content, dto.Content); err != nil {
+		return model.WSSubMsgDTO{}, err
+	}
+	return dto, nil
+}
+
+// ForwardTxPoolEvent 把交易池中交易的增加或移除转发到订阅了 pending_txs 的前端连接
+func (manager *Manager) ForwardTxPoolEvent(chainID string, event *model.TxPoolEvent) {
+	if event == nil {
+		return
+	}
+	dto := model.WSSubMsgDTO{
+		ID:      chainID,
+		Type:    config.Config.WSConf.WsMsgTypesConf.Pub.PendingTXType,
+		Content: event,
+	}
+	if err := NewSubMsgProcessor(manager).Forward("", chainID, dto); err != nil {
+		logrus.Errorf("chain[%s] forward txpool event err: %v", chainID, err)
+	}
+}
+
+// ForwardSyncProgress 把链数据同步进度转发到订阅了 sync 的前端连接
+func (manager *Manager) ForwardSyncProgress(info *model.ChainDataSyncInfoVO) {
+	if info == nil {
+		return
+	}
+	dto := model.WSSubMsgDTO{
+		ID:      info.ChainID,
+		Type:    config.Config.WSConf.WsMsgTypesConf.Pub.SyncType,
+		Content: info,
+	}
+	if err := NewSubMsgProcessor(manager).Forward("", info.ChainID, dto); err != nil {
+		logrus.Errorf("chain[%s] forward sync progress err: %v", info.ChainID, err)
+	}
+}
+
 // 获取统计数据
 func (s *SubMsgProcessor) getStat(chainID string) *model.StatsVO {
 	cid, err := primitive.ObjectIDFromHex(chainID)
@@ -281,10 +453,10 @@ func (s *SubMsgProcessor) getStat(chainID string) *model.StatsVO {
 	var result model.StatsVO
 	filter := bson.M{"chain_id": cid}
 	findOps := options.Count()
-	txCnt, _ := dao.DefaultTXDao.Count(filter, findOps)
-	contractCnt, _ := dao.DefaultContractDao.Count(filter, findOps)
-	nodeCnt, _ := dao.DefaultNodeDao.Count(filter, findOps)
-	latestBlock, _ := dao.DefaultBlockDao.LatestBlock(cid)
+	txCnt, _ := s.manager.daos.TX.Count(filter, findOps)
+	contractCnt, _ := s.manager.daos.Contract.Count(filter, findOps)
+	nodeCnt, _ := s.manager.daos.Node.Count(filter, findOps)
+	latestBlock, _ := s.manager.daos.Block.LatestBlock(cid)
 
 	result.TotalTx = txCnt
 	result.TotalContract = contractCnt
@@ -301,7 +473,7 @@ func (s *SubMsgProcessor) getNodes(chainID string) ([]*model.NodeVO, error) {
 	}
 	filter := bson.M{"chain_id": cid}
 	findOps := util.BuildOptionsByQuery(1, 10)
-	nodes, err := dao.DefaultNodeDao.Nodes(filter, findOps)
+	nodes, err := s.manager.daos.Node.Nodes(filter, findOps)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,327 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"graces/config"
+	"graces/model"
+
+	"github.com/Venachain/Venachain/common/hexutil"
+	"github.com/Venachain/Venachain/rlp"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// 前端 ws 连接可以订阅的 topic
+const (
+	TopicBlocks = "blocks"
+	TopicTXs    = "txs"
+	TopicLogs   = "logs"
+	TopicStats  = "stats"
+	TopicNodes  = "nodes"
+	TopicSync   = "sync"
+	// 交易池中的交易增加或移除
+	TopicPendingTXs = "pending_txs"
+)
+
+// 前端 ws 连接订阅 topic 的消息类型
+const (
+	// 前端发送的订阅、取消订阅请求
+	MsgTypeSubscribe   = "subscribe"
+	MsgTypeUnsubscribe = "unsubscribe"
+	// 服务端回复的订阅成功、取消订阅成功、请求出错
+	MsgTypeSubscribed   = "subscribed"
+	MsgTypeUnsubscribed = "unsubscribed"
+	MsgTypeError        = "error"
+)
+
+// cns 调用交易的目标地址
+const cnsInvokeAddress = "0x0000000000000000000000000000000000000000"
+
+var (
+	// 所有可订阅的 topic
+	topics = map[string]bool{
+		TopicBlocks:     true,
+		TopicTXs:        true,
+		TopicLogs:       true,
+		TopicStats:      true,
+		TopicNodes:      true,
+		TopicSync:       true,
+		TopicPendingTXs: true,
+	}
+	// 从未订阅过的前端连接默认接收的 topic，与订阅协议出现之前的推送保持一致
+	legacyTopics = map[string]bool{
+		TopicBlocks: true,
+		TopicTXs:    true,
+		TopicStats:  true,
+		TopicNodes:  true,
+	}
+)
+
+// 前端 ws 连接的单个订阅
+type topicSubscription struct {
+	id     string
+	topic  string
+	filter model.WSTopicFilter
+}
+
+// 待转发的事件，包含过滤所需的信息
+type topicEvent struct {
+	topic string
+	// 事件涉及的地址
+	addresses []string
+	// 事件涉及的合约地址
+	contracts []string
+	// 事件涉及的合约方法
+	method string
+	// 交易数额
+	value uint64
+	// 是否可以按过滤条件过滤
+	filterable bool
+}
+
+// 根据推送消息的类型构建待转发的事件，未知类型返回 nil
+func newTopicEvent(dto model.WSSubMsgDTO) *topicEvent {
+	pub := config.Config.WSConf.WsMsgTypesConf.Pub
+	switch dto.Type {
+	case pub.BlockType:
+		return &topicEvent{topic: TopicBlocks}
+	case pub.StatsType:
+		return &topicEvent{topic: TopicStats}
+	case pub.NodeInfoType:
+		return &topicEvent{topic: TopicNodes}
+	case pub.SyncType:
+		return &topicEvent{topic: TopicSync}
+	case pub.TXType:
+		event := &topicEvent{topic: TopicTXs, filterable: true}
+		tx, ok := dto.Content.(*model.TXVO)
+		if !ok {
+			return event
+		}
+		contract := tx.To
+		if tx.To == "" && tx.Receipt != nil {
+			contract = tx.Receipt.ContractAddress
+		}
+		event.addresses = []string{tx.From, tx.To, contract}
+		event.contracts = []string{contract}
+		event.method = txMethod(tx.To, tx.Input)
+		event.value = tx.Value
+		return event
+	case pub.LogType:
+		event := &topicEvent{topic: TopicLogs, filterable: true}
+		log, ok := dto.Content.(*model.TXLogVO)
+		if !ok {
+			return event
+		}
+		event.addresses = []string{log.Address}
+		event.contracts = []string{log.Address}
+		event.method = log.Method
+		return event
+	case pub.PendingTXType:
+		event := &topicEvent{topic: TopicPendingTXs, filterable: true}
+		txPoolEvent, ok := dto.Content.(*model.TxPoolEvent)
+		if !ok || txPoolEvent.TX == nil {
+			return event
+		}
+		tx := txPoolEvent.TX
+		event.addresses = []string{tx.From, tx.To}
+		event.contracts = []string{tx.To}
+		event.method = txMethod(tx.To, tx.Input)
+		event.value = tx.Value
+		return event
+	}
+	return nil
+}
+
+// 判断事件是否满足过滤条件，为空的条件不参与过滤
+func (event *topicEvent) match(filter model.WSTopicFilter) bool {
+	if !event.filterable {
+		return true
+	}
+	if filter.Address != "" && !containsAddress(event.addresses, filter.Address) {
+		return false
+	}
+	if filter.Contract != "" && !containsAddress(event.contracts, filter.Contract) {
+		return false
+	}
+	if filter.Method != "" && filter.Method != event.method {
+		return false
+	}
+	if (event.topic == TopicTXs || event.topic == TopicPendingTXs) && event.value < filter.MinValue {
+		return false
+	}
+	return true
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, addr := range addresses {
+		if addr != "" && strings.EqualFold(addr, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析交易调用的合约方法，input 为 rlp 编码的调用数据，部署合约和转账交易返回空字符串
+func txMethod(to string, input string) string {
+	input = strings.TrimPrefix(input, "0x")
+	if to == "" || input == "" {
+		return ""
+	}
+	data, err := hexutil.Decode("0x" + input)
+	if err != nil {
+		return ""
+	}
+	ptr := new(interface{})
+	if err = rlp.Decode(bytes.NewReader(data), &ptr); err != nil {
+		return ""
+	}
+	items, ok := reflect.ValueOf(ptr).Elem().Interface().([]interface{})
+	if !ok {
+		return ""
+	}
+	// 普通合约调用第二个参数为方法名，cns 调用第二个参数为 cns 名称，第三个参数为方法名
+	index := 1
+	if to == cnsInvokeAddress {
+		index = 2
+	}
+	if len(items) <= index {
+		return ""
+	}
+	method, ok := items[index].([]byte)
+	if !ok {
+		return ""
+	}
+	return string(method)
+}
+
+// 处理前端的订阅请求，订阅成功后该连接只接收已订阅的 topic
+func (c *Client) subscribeTopic(data map[string]interface{}, reply cmdReply) error {
+	var dto model.WSTopicSubscribeDTO
+	if err := decodeParams(data, &dto); err != nil {
+		reply.done(MsgTypeSubscribed, nil, err)
+		return err
+	}
+	if !topics[dto.Topic] {
+		err := fmt.Errorf("unknown topic [%s]", dto.Topic)
+		reply.done(MsgTypeSubscribed, nil, err)
+		return err
+	}
+	var cursor eventCursor
+	if dto.LastCursor != "" {
+		var err error
+		if cursor, err = parseCursor(dto.LastCursor); err != nil {
+			reply.done(MsgTypeSubscribed, nil, err)
+			return err
+		}
+	}
+	sub := &topicSubscription{
+		id:     uuid.NewV4().String(),
+		topic:  dto.Topic,
+		filter: dto.Filter,
+	}
+	if dto.LastCursor != "" {
+		// 订阅生效前开始回放，避免漏掉回放期间的实时推送
+		c.beginReplay()
+	}
+	c.lock.Lock()
+	if c.topicSubs == nil {
+		c.topicSubs = make(map[string]*topicSubscription)
+	}
+	c.topicSubs[sub.id] = sub
+	c.topicMode = true
+	c.lock.Unlock()
+
+	logrus.Infof("client [%s] subscribe topic [%s], subscription [%s]", c.Id, sub.topic, sub.id)
+	reply.done(MsgTypeSubscribed, model.WSTopicSubscriptionVO{
+		Subscription: sub.id,
+		Topic:        sub.topic,
+		Filter:       sub.filter,
+	}, nil)
+	if dto.LastCursor != "" {
+		go c.replay(cursor, func(event *topicEvent) bool {
+			return sub.topic == event.topic && event.match(sub.filter)
+		})
+	}
+	return nil
+}
+
+// 处理前端的取消订阅请求
+func (c *Client) unsubscribeTopic(data map[string]interface{}, reply cmdReply) error {
+	var dto model.WSTopicUnsubscribeDTO
+	if err := decodeParams(data, &dto); err != nil {
+		reply.done(MsgTypeUnsubscribed, nil, err)
+		return err
+	}
+	c.lock.Lock()
+	sub, ok := c.topicSubs[dto.Subscription]
+	delete(c.topicSubs, dto.Subscription)
+	c.lock.Unlock()
+	if !ok {
+		err := fmt.Errorf("subscription [%s] not found", dto.Subscription)
+		reply.done(MsgTypeUnsubscribed, nil, err)
+		return err
+	}
+
+	logrus.Infof("client [%s] unsubscribe topic [%s], subscription [%s]", c.Id, sub.topic, sub.id)
+	reply.done(MsgTypeUnsubscribed, model.WSTopicSubscriptionVO{
+		Subscription: sub.id,
+		Topic:        sub.topic,
+		Filter:       sub.filter,
+	}, nil)
+	return nil
+}
+
+// 该连接是否需要接收事件：从未订阅过的连接接收默认的 topic，否则只接收满足过滤条件的已订阅 topic
+func (c *Client) wants(event *topicEvent) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.topicMode {
+		return legacyTopics[event.topic]
+	}
+	for _, sub := range c.topicSubs {
+		if sub.topic == event.topic && event.match(sub.filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// 回复前端的订阅请求，或发送回放进度等通知，使用结构化消息的连接发送 type 为 msgType 的结构化消息
+func (c *Client) replyTopic(msgType string, content interface{}) {
+	if c.envelopeMode() {
+		c.sendEnvelope(model.WSEnvelope{Type: msgType, Payload: content})
+		return
+	}
+	dto := model.WSSubMsgDTO{
+		ID:      c.Group,
+		Type:    msgType,
+		Content: content,
+	}
+	msg, err := json.Marshal(dto)
+	if err != nil {
+		logrus.Errorf("client [%s] marshal reply err: %v", c.Id, err)
+		return
+	}
+	c.Send(msg)
+}
+
+// 把请求中的 params 解析到 dto
+func decodeParams(data map[string]interface{}, dto interface{}) error {
+	params, ok := data["params"]
+	if !ok {
+		return fmt.Errorf("message without [params] property cannot be processed")
+	}
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(bs, dto); err != nil {
+		return fmt.Errorf("invalid params: %v", err)
+	}
+	return nil
+}
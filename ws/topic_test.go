@@ -0,0 +1,158 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"graces/cluster"
+	"graces/config"
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_TopicSubscribe(t *testing.T) {
+	client := &Client{
+		Id:      "topic-client",
+		Group:   "topic-group",
+		Message: make(chan []byte, 10),
+	}
+	pub := config.Config.WSConf.WsMsgTypesConf.Pub
+	contract := "0x1000000000000000000000000000000000000001"
+	tx := func(to string, value uint64) *topicEvent {
+		return newTopicEvent(model.WSSubMsgDTO{
+			Type:    pub.TXType,
+			Content: &model.TXVO{From: "0x2000000000000000000000000000000000000002", To: to, Value: value},
+		})
+	}
+	blocks := newTopicEvent(model.WSSubMsgDTO{Type: pub.BlockType})
+	logs := newTopicEvent(model.WSSubMsgDTO{Type: pub.LogType, Content: &model.TXLogVO{Address: contract}})
+
+	// 从未订阅过的连接接收默认的 topic
+	assert.True(t, client.wants(blocks))
+	assert.True(t, client.wants(tx(contract, 0)))
+	assert.True(t, !client.wants(logs))
+
+	reply := func() model.WSSubMsgDTO {
+		var dto model.WSSubMsgDTO
+		assert.True(t, json.Unmarshal(<-client.Message, &dto) == nil)
+		return dto
+	}
+	err := client.receiveTypeMsgProcessor(MsgTypeSubscribe, map[string]interface{}{
+		"method": MsgTypeSubscribe,
+		"params": map[string]interface{}{"topic": TopicTXs, "filter": map[string]interface{}{"contract": contract, "min_value": 10}},
+	})
+	assert.True(t, err == nil)
+	dto := reply()
+	assert.Equal(t, MsgTypeSubscribed, dto.Type)
+	assert.Equal(t, client.Group, dto.ID)
+	subID := dto.Content.(map[string]interface{})["subscription"].(string)
+	assert.True(t, subID != "")
+
+	// 订阅后只接收满足过滤条件的已订阅 topic
+	assert.True(t, !client.wants(blocks))
+	assert.True(t, !client.wants(logs))
+	assert.True(t, client.wants(tx(contract, 10)))
+	assert.True(t, !client.wants(tx(contract, 9)))
+	assert.True(t, !client.wants(tx("0x3000000000000000000000000000000000000003", 10)))
+
+	// 未知的 topic 和订阅ID
+	err = client.receiveTypeMsgProcessor(MsgTypeSubscribe, map[string]interface{}{
+		"params": map[string]interface{}{"topic": "unknown"},
+	})
+	assert.True(t, err != nil)
+	assert.Equal(t, MsgTypeError, reply().Type)
+	err = client.receiveTypeMsgProcessor(MsgTypeUnsubscribe, map[string]interface{}{
+		"params": map[string]interface{}{"subscription": "unknown"},
+	})
+	assert.True(t, err != nil)
+	assert.Equal(t, MsgTypeError, reply().Type)
+
+	err = client.receiveTypeMsgProcessor(MsgTypeUnsubscribe, map[string]interface{}{
+		"params": map[string]interface{}{"subscription": subID},
+	})
+	assert.True(t, err == nil)
+	assert.Equal(t, MsgTypeUnsubscribed, reply().Type)
+	// 取消所有订阅后不会恢复默认的 topic
+	assert.True(t, !client.wants(tx(contract, 10)))
+}
+
+func TestForwardBusMessage(t *testing.T) {
+	group := "bus-group"
+	client := &Client{
+		Id:      "bus-client",
+		Group:   group,
+		Message: make(chan []byte, 10),
+	}
+	testManager.Lock.Lock()
+	testManager.Group[group] = map[string]*Client{client.Id: client}
+	testManager.Lock.Unlock()
+	defer func() {
+		testManager.Lock.Lock()
+		delete(testManager.Group, group)
+		testManager.Lock.Unlock()
+	}()
+
+	contract := "0x1000000000000000000000000000000000000001"
+	err := client.receiveTypeMsgProcessor(MsgTypeSubscribe, map[string]interface{}{
+		"params": map[string]interface{}{"topic": TopicTXs, "filter": map[string]interface{}{"contract": contract}},
+	})
+	assert.True(t, err == nil)
+	<-client.Message
+
+	// 其他实例发布的交易按订阅条件过滤后转发
+	publish := func(to string, index int) {
+		dto, err := txMsg(group, &model.TX{Height: 5, To: to, Receipt: &model.Receipt{}}, index)
+		assert.True(t, err == nil)
+		data, err := json.Marshal(dto)
+		assert.True(t, err == nil)
+		testManager.ForwardBusMessage(&cluster.BusMessage{Instance: "other-instance", Group: group, Data: data})
+	}
+	publish("0x3000000000000000000000000000000000000003", 0)
+	publish(contract, 1)
+	assert.Equal(t, 1, len(client.Message))
+	var dto model.WSSubMsgDTO
+	assert.True(t, json.Unmarshal(<-client.Message, &dto) == nil)
+	assert.Equal(t, "5-2", dto.Cursor)
+	assert.Equal(t, contract, dto.Content.(map[string]interface{})["to"])
+}
+
+func TestForwardBusMessage_PendingTXs(t *testing.T) {
+	group := "pending-group"
+	legacy := &Client{Id: "pending-legacy", Group: group, Message: make(chan []byte, 10)}
+	client := &Client{Id: "pending-client", Group: group, Message: make(chan []byte, 10)}
+	testManager.Lock.Lock()
+	testManager.Group[group] = map[string]*Client{legacy.Id: legacy, client.Id: client}
+	testManager.Lock.Unlock()
+	defer func() {
+		testManager.Lock.Lock()
+		delete(testManager.Group, group)
+		testManager.Lock.Unlock()
+	}()
+
+	from := "0x2000000000000000000000000000000000000002"
+	err := client.receiveTypeMsgProcessor(MsgTypeSubscribe, map[string]interface{}{
+		"params": map[string]interface{}{"topic": TopicPendingTXs, "filter": map[string]interface{}{"address": from}},
+	})
+	assert.True(t, err == nil)
+	<-client.Message
+
+	// 交易池事件只转发给订阅了 pending_txs 且满足过滤条件的连接
+	publish := func(action string, from string) {
+		dto := model.WSSubMsgDTO{
+			ID:      group,
+			Type:    config.Config.WSConf.WsMsgTypesConf.Pub.PendingTXType,
+			Content: &model.TxPoolEvent{Action: action, TX: &model.PendingTX{Hash: "0x01", From: from}},
+		}
+		data, err := json.Marshal(dto)
+		assert.True(t, err == nil)
+		testManager.ForwardBusMessage(&cluster.BusMessage{Instance: "other-instance", Group: group, Data: data})
+	}
+	publish(model.TxPoolActionAdd, "0x3000000000000000000000000000000000000003")
+	publish(model.TxPoolActionRemove, from)
+	assert.Equal(t, 0, len(legacy.Message))
+	assert.Equal(t, 1, len(client.Message))
+	var dto model.WSSubMsgDTO
+	assert.True(t, json.Unmarshal(<-client.Message, &dto) == nil)
+	assert.Equal(t, model.TxPoolActionRemove, dto.Content.(map[string]interface{})["action"])
+}
@@ -3,11 +3,28 @@ package ws
 import (
 	"sync"
 
+	"graces/cluster"
+	"graces/model"
+	"graces/rpc"
+	"graces/txpool"
+	"graces/web/dao"
+
 	"github.com/gorilla/websocket"
 )
 
-// Manager 所有 websocket 信息
+// 客户端消息缓冲队列已满时的处理策略
+const (
+	// 丢弃最早的一条消息
+	DropPolicyDropOldest = "drop_oldest"
+	// 断开连接，拨号连接断开后会重连并补齐错过的区块
+	DropPolicyDisconnect = "disconnect"
+)
+
+// Manager 所有 websocket 信息，Group 需要持有 Lock 才能访问
 type Manager struct {
+	// 因缓冲队列已满被丢弃的消息数，以及因此被断开的连接数，需要原子操作
+	droppedCnt, disconnectCnt uint64
+
 	Group                   map[string]map[string]*Client
 	groupCount, clientCount uint
 	Lock                    sync.Mutex
@@ -15,19 +32,69 @@ type Manager struct {
 	Message                 chan *MessageData
 	GroupMessage            chan *GroupMessageData
 	BroadCastMessage        chan *BroadCastMessageData
+	done                    chan struct{}
+	stopOnce                sync.Once
+	// 最近关闭的连接信息，用于排查连接断开的原因
+	closedClients []model.WSClientVO
+
+	// 处理连接收到的消息时使用的组件：拨号连接收到的链上事件入库并通过总线转发，前端连接的命令由 deploy 执行
+	daos      *dao.Daos
+	rpcServer *rpc.Server
+	bus       cluster.IBus
+	txPool    *txpool.TxPool
+	deploy    *Deploy
 }
 
 // Client 单个 websocket 信息
 type Client struct {
+	// 因缓冲队列已满被丢弃的消息数，最近一次收发数据消息的时间（纳秒），需要原子操作
+	droppedCnt uint64
+	lastActive int64
+
 	Id, Group  string
 	LocalAddr  string
 	RemoteAddr string
 	Path       string
 	Socket     *websocket.Conn
-	IsAlive    bool
 	IsDial     bool
-	RetryCnt   int64
-	Message    chan []byte
+	// 连接断线后已重试连接的次数，需要持有 lock 才能访问
+	RetryCnt int64
+	// 待发送的消息，需要通过 Send 写入
+	Message chan []byte
+
+	alive int32
+	// 是否使用结构化消息，建立连接时带 v=1 或发送过结构化消息后为 1，需要原子操作
+	envelope int32
+	// 前端连接建立时的认证信息，拨号连接为 nil
+	auth *Auth
+	// 缓冲队列已满时的处理策略
+	dropPolicy string
+	manager    *Manager
+	// 保护 Message 的写入和关闭，stopped 为 Message 已关闭，disconnecting 为因消费过慢正在断开
+	msgLock       sync.Mutex
+	stopped       bool
+	disconnecting bool
+
+	// 拨号连接的地址，意外断开后按该地址重连
+	dialURL string
+	// 拨号连接意外断开后的回调，主动关闭的连接不会回调
+	onLost func(c *Client)
+	closed int32
+	lock   sync.Mutex
+	// 通过该连接发送的订阅消息记录ID，重连后据此重新订阅
+	subMsgIDs []string
+	// 连接关闭的原因和时间，只记录第一次的原因
+	closeReason string
+	closeTime   int64
+	// 前端连接订阅的 topic，key 为订阅ID
+	topicSubs map[string]*topicSubscription
+	// 是否订阅过 topic，订阅过的连接只接收已订阅的 topic
+	topicMode bool
+	// 正在进行的回放数，回放期间暂存的实时推送和已回放事件的去重键，需要持有 replayLock 才能访问
+	replayLock sync.Mutex
+	replaying  int
+	pending    []pendingMsg
+	replayed   map[string]bool
 }
 
 // MessageData 单个客户端发送数据信息
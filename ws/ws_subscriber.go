@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
+	"sync"
 	"time"
 
+	"graces/cluster"
 	"graces/config"
 	"graces/model"
+	"graces/syncer"
 	"graces/util"
 	"graces/web/dao"
 
@@ -18,35 +22,96 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	// DefaultWSSubscriber 默认的 websocket 订阅器
-	DefaultWSSubscriber *wsSubscriber
-)
+// 订阅连接重连的最长等待时间
+const maxRetryBackoff = 5 * time.Minute
+
+var errReconnectCanceled = errors.New("websocket reconnect canceled")
 
-func init() {
-	logrus.Debugf("DefaultWSSubscriber init [start]")
-	DefaultWSSubscriber = newWSSubscriber()
-	logrus.Debugf("DefaultWSSubscriber init [end]")
+// WSSubscriber websocket 订阅器，为每条链建立拨号连接并订阅链上的 topics
+type WSSubscriber struct {
+	chainDao    dao.IChainDao
+	wsDao       dao.IWSMsgDao
+	wsManager   *Manager
+	syncManager *syncer.ChainDataSyncManager
+	elector     cluster.IElector
+	// 链变更事件，由 Run 启动的协程按顺序处理
+	events chan *model.ChainEvent
+	// lock 保护 chains 和 infos，同时保证同一时刻只有一个订阅或取消订阅操作
+	lock   sync.Mutex
+	chains map[string]*model.Chain
+	infos  map[string]*model.ChainSubscriptionInfo
+}
+
+// NewWSSubscriber 创建 websocket 订阅器，多实例部署时只订阅本实例当选 leader 的链，leader 变化时重新订阅
+func NewWSSubscriber(wsManager *Manager, daos *dao.Daos, syncManager *syncer.ChainDataSyncManager, elector cluster.IElector) *WSSubscriber {
+	s := &WSSubscriber{
+		chainDao:    daos.Chain,
+		wsDao:       daos.WSMsg,
+		wsManager:   wsManager,
+		syncManager: syncManager,
+		elector:     elector,
+		events:      make(chan *model.ChainEvent, config.Config.WSConf.BuffSize),
+		chains:      make(map[string]*model.Chain),
+		infos:       make(map[string]*model.ChainSubscriptionInfo),
+	}
+	elector.OnChange(s.onLeaderChange)
+	return s
 }
 
-type wsSubscriber struct {
-	chainDao   dao.IChainDao
-	wsDao      dao.IWSMsgDao
-	wsManager  *Manager
-	chains     map[string]*model.Chain
-	maxChainID string
+// Run 启动链变更事件的处理协程，多实例部署时还会定时发现其他实例新增或删除的链，websocket 管理器停止时退出
+func (s *WSSubscriber) Run() {
+	go s.loopChainEvents()
+	if config.Config.ClusterConf.IsEnabled() {
+		go s.loopDiscoverChains()
+	}
 }
 
-func newWSSubscriber() *wsSubscriber {
-	return &wsSubscriber{
-		chainDao:  dao.DefaultChainDao,
-		wsDao:     dao.DefaultWSMsgDao,
-		wsManager: DefaultWebsocketManager,
+// Publish 发布链变更事件，订阅器收到事件后立即订阅或取消订阅该链的 topics，websocket 管理器停止后丢弃事件
+func (s *WSSubscriber) Publish(event model.ChainEvent) {
+	select {
+	case s.events <- &event:
+	case <-s.wsManager.done:
+		logrus.Warningf("websocket manager stopped, drop chain event [%s]", event.Type)
+	}
+}
+
+func (s *WSSubscriber) loopChainEvents() {
+	for {
+		select {
+		case <-s.wsManager.done:
+			return
+		case event := <-s.events:
+			s.handleChainEvent(event)
+		}
+	}
+}
+
+func (s *WSSubscriber) handleChainEvent(event *model.ChainEvent) {
+	defer func() {
+		if err := recover(); err != nil {
+			logrus.Errorf("unknown panic，handle chain event [%s]：%+v", event.Type, err)
+		}
+	}()
+	logrus.Debugf("handle chain event [%s] for chain[%v]", event.Type, event.Chain.Name)
+	var err error
+	switch event.Type {
+	case model.ChainEventInsert:
+		err = s.SubTopicsForChain(event.Chain)
+	case model.ChainEventUpdate:
+		err = s.ResubTopicsForChain(event.Old, event.Chain)
+	case model.ChainEventDelete:
+		s.UnsubTopicsForChain(event.Chain)
+		s.elector.Resign(cluster.ChainKey(event.Chain.ID.Hex()))
+	default:
+		err = fmt.Errorf("unknown chain event type: %v", event.Type)
+	}
+	if err != nil {
+		logrus.Errorf("handle chain event [%s] for chain[%v] err: %v", event.Type, event.Chain.Name, err)
 	}
 }
 
 // ChainWSTopicAutoSubDelayStart 延迟启动
-func (s *wsSubscriber) ChainWSTopicAutoSubDelayStart(delay time.Duration) {
+func (s *WSSubscriber) ChainWSTopicAutoSubDelayStart(delay time.Duration) {
 	if delay < 0 {
 		delay = 0
 	}
@@ -59,19 +124,20 @@ func (s *wsSubscriber) ChainWSTopicAutoSubDelayStart(delay time.Duration) {
 	}
 }
 
-// ChainWSTopicAutoSubStart 立即启动
-func (s *wsSubscriber) ChainWSTopicAutoSubStart() {
+// ChainWSTopicAutoSubStart 立即启动，为数据库中的所有链订阅 topics，已经通过链变更事件订阅的链会被跳过
+func (s *WSSubscriber) ChainWSTopicAutoSubStart() {
 	logrus.Debugf("websocket subscribe [strat]")
 	defer logrus.Debugf("websocket subscribe [end]")
-	s.initChains()
-	err := s.subTopicsForEveryChain()
+	chains, err := s.loadChainsFromDB()
 	if err != nil {
-		logrus.Errorf("sycer subNewHeads error: %+v", err)
+		logrus.Errorf("load chains from DB error: %+v", err)
+		return
 	}
+	s.subTopicsForEveryChain(chains)
 	logrus.Info("chain websocket topic auto subscribe success")
 }
 
-func (s *wsSubscriber) loadChainsFromDB() ([]*model.Chain, error) {
+func (s *WSSubscriber) loadChainsFromDB() ([]*model.Chain, error) {
 	filter := bson.M{}
 	findOps := options.Find()
 	// 按 _id 逆序
@@ -85,80 +151,248 @@ func (s *wsSubscriber) loadChainsFromDB() ([]*model.Chain, error) {
 	return chains, nil
 }
 
-func (s *wsSubscriber) initChains() {
-	logrus.Debugf("load chains [start]")
-	defer logrus.Debugf("load chains [end]")
-	chains, err := s.loadChainsFromDB()
-	if err != nil {
-		logrus.Errorf("syncer init error: %+v", err)
-		return
-	}
-	chainMap := make(map[string]*model.Chain, len(chains))
-	for _, chain := range chains {
-		chainMap[chain.ID.Hex()] = chain
-	}
-	s.chains = chainMap
-	if len(chains) > 0 {
-		s.maxChainID = chains[0].ID.Hex()
-	}
-	logrus.Infof("load chains success")
-}
-
-func (s *wsSubscriber) subTopicsForEveryChain() error {
+func (s *WSSubscriber) subTopicsForEveryChain(chains []*model.Chain) {
 	logrus.Debugf("subscribe topics from websocket for every chain [start]")
 	defer logrus.Debugf("subscribe topics from websocket for every chain [end]")
-	if len(s.chains) == 0 {
+	if len(chains) == 0 {
 		logrus.Infof("no chains need to subscribe topics from websocket")
 	}
-	for _, chain := range s.chains {
+	for _, chain := range chains {
 		err := s.SubTopicsForChain(chain)
 		if err != nil {
 			logrus.Errorln(err)
 		}
 	}
 	logrus.Info("subscribe topics from websocket for every chain success")
-	return nil
 }
 
-// SubTopicsForChain 为指定的链订阅它所配置的所有 websocket topics
-func (s *wsSubscriber) SubTopicsForChain(chain *model.Chain) error {
+// SubTopicsForChain 为指定的链订阅它所配置的所有 websocket topics，已经订阅过且未失败的链不会重复订阅
+func (s *WSSubscriber) SubTopicsForChain(chain *model.Chain) error {
 	if chain == nil {
 		return errors.New("can't subscribe topics for nil chain")
 	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	id := chain.ID.Hex()
+	if info, ok := s.infos[id]; ok && s.chains[id] != nil && info.Status != model.ChainSubStatusFailed &&
+		info.Status != model.ChainSubStatusStandby {
+		logrus.Debugf("chain[%v] topics already subscribed", chain.Name)
+		return nil
+	}
+	s.chains[id] = chain
+	// 多实例部署时只有链的 leader 订阅，其他实例成为 leader 后再订阅
+	if !s.elector.Campaign(cluster.ChainKey(id)) {
+		s.setStandby(chain)
+		logrus.Infof("chain[%v] topics are subscribed by other instance, standby", chain.Name)
+		return nil
+	}
+	topics, err := s.subTopics(chain)
+	s.setInfo(chain, topics, err)
+	return err
+}
 
+func (s *WSSubscriber) subTopics(chain *model.Chain) ([]string, error) {
 	// 1、获取 websocket 客户端连接
 	client, err := s.getWSClientByChain(*chain)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 2、提取当前链订阅的 topics 配置信息
-	topics, ok := chain.ChainConfig["ws"].(map[string]interface{})["topics"].(map[string]interface{})
-	logrus.Debug(topics)
+	topics, ok, err := parseTopicConfigs(chain.ChainConfig)
+	if err != nil {
+		return nil, fmt.Errorf("chain[%s][%s:%v] invalid websocket subscription config: %v",
+			chain.Name, chain.IP, chain.RPCPort, err)
+	}
 	if !ok {
 		msg := fmt.Sprintf("chain[%s][%s:%v] lost websocket subscription config, can't subscribe websocket topics for it",
 			chain.Name, chain.IP, chain.RPCPort)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
 
 	// 3 处理 topics 订阅
-	for k, topic := range topics {
-		if !ok {
-			logrus.Warningf("topic[%v] lost config, can't subscribe it from websocket", k)
-			continue
-		}
-		topicName := topic.(map[string]interface{})["name"]
-		topicParams := topic.(map[string]interface{})["params"]
-		err := s.wsSubTopicProcessor(*chain, client, topicName.(string), topicParams.(string))
+	subscribed := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		err := s.wsSubTopicProcessor(*chain, client, topic.name, topic.params)
 		if err != nil {
 			logrus.Warningln(err)
+			continue
 		}
+		subscribed = append(subscribed, topic.name)
 	}
-	return nil
+	return subscribed, nil
+}
+
+// UnsubTopicsForChain 断开为指定链订阅 topics 的 websocket 连接，链被更新或删除时调用
+func (s *WSSubscriber) UnsubTopicsForChain(chain *model.Chain) {
+	if chain == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.wsManager.Lock.Lock()
+	clients := make([]*Client, 0)
+	for _, client := range s.wsManager.Group[chain.Name] {
+		if client.IsDial {
+			clients = append(clients, client)
+		}
+	}
+	s.wsManager.Lock.Unlock()
+	// 关闭连接后由 client 的读协程负责从管理器中注销
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			logrus.Warningf("chain[%s] close websocket client [%s] err: %v", chain.Name, client.Id, err)
+		}
+	}
+	id := chain.ID.Hex()
+	delete(s.chains, id)
+	if info, ok := s.infos[id]; ok {
+		info.Status = model.ChainSubStatusUnsubscribed
+		info.Topics = nil
+		info.ErrMsg = ""
+		info.UpdateTime = time.Now().Unix()
+	}
+	logrus.Infof("unsubscribe topics from websocket for chain[%v] [success]", chain.Name)
+}
+
+// ResubTopicsForChain 断开旧链配置的订阅，并按新的链配置重新订阅
+func (s *WSSubscriber) ResubTopicsForChain(old *model.Chain, chain *model.Chain) error {
+	s.UnsubTopicsForChain(old)
+	return s.SubTopicsForChain(chain)
+}
+
+// SubscriptionInfo 查询链的 websocket 订阅状态，没有订阅过的链返回 false
+func (s *WSSubscriber) SubscriptionInfo(chainID string) (*model.ChainSubscriptionInfo, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	info, ok := s.infos[chainID]
+	if !ok {
+		return nil, false
+	}
+	result := *info
+	if chain, ok := s.chains[chainID]; ok {
+		result.Connected = s.IsSubscribed(*chain)
+	}
+	return &result, true
+}
+
+// 记录订阅结果，调用方需要持有 s.lock
+func (s *WSSubscriber) setInfo(chain *model.Chain, topics []string, err error) {
+	info := &model.ChainSubscriptionInfo{
+		ChainID:    chain.ID.Hex(),
+		Name:       chain.Name,
+		Status:     model.ChainSubStatusSubscribed,
+		Topics:     topics,
+		UpdateTime: time.Now().Unix(),
+	}
+	if err != nil {
+		info.Status = model.ChainSubStatusFailed
+		info.ErrMsg = err.Error()
+	}
+	s.infos[info.ChainID] = info
+}
+
+// 记录当前实例不是链的 leader，由其他实例订阅，调用方需要持有 s.lock
+func (s *WSSubscriber) setStandby(chain *model.Chain) {
+	s.setInfo(chain, nil, nil)
+	s.infos[chain.ID.Hex()].Status = model.ChainSubStatusStandby
+}
+
+// 链的 leader 身份变化后订阅或断开订阅，监听器可能在订阅时持有 s.lock 的情况下被调用，需要在新的协程中处理
+func (s *WSSubscriber) onLeaderChange(key string, leader bool) {
+	chainID, ok := cluster.ChainIDFromKey(key)
+	if !ok {
+		return
+	}
+	go func() {
+		s.lock.Lock()
+		chain := s.chains[chainID]
+		s.lock.Unlock()
+		if chain == nil {
+			return
+		}
+		if leader {
+			if err := s.SubTopicsForChain(chain); err != nil {
+				logrus.Errorf("chain[%v] subscribe topics after becoming leader err: %v", chain.Name, err)
+			}
+			// 补齐接替之前错过的区块
+			s.syncManager.IncrSyncStart(chainID, true)
+			return
+		}
+		s.UnsubTopicsForChain(chain)
+		s.lock.Lock()
+		s.chains[chainID] = chain
+		s.setStandby(chain)
+		s.lock.Unlock()
+	}()
+}
+
+// 定时从数据库加载链，为其他实例新增的链参与竞选，并放弃其他实例删除的链
+func (s *WSSubscriber) loopDiscoverChains() {
+	ticker := time.NewTicker(config.Config.ClusterConf.LeaseDuration())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.wsManager.done:
+			return
+		case <-ticker.C:
+			chains, err := s.loadChainsFromDB()
+			if err != nil {
+				logrus.Errorf("load chains from DB error: %+v", err)
+				continue
+			}
+			exists := make(map[string]bool, len(chains))
+			for _, chain := range chains {
+				id := chain.ID.Hex()
+				exists[id] = true
+				s.lock.Lock()
+				known := s.chains[id] != nil
+				s.lock.Unlock()
+				if !known {
+					s.Publish(model.ChainEvent{Type: model.ChainEventInsert, Chain: chain})
+				}
+			}
+			s.lock.Lock()
+			removed := make([]*model.Chain, 0)
+			for id, chain := range s.chains {
+				if !exists[id] {
+					removed = append(removed, chain)
+				}
+			}
+			s.lock.Unlock()
+			for _, chain := range removed {
+				s.Publish(model.ChainEvent{Type: model.ChainEventDelete, Chain: chain})
+			}
+		}
+	}
+}
+
+// IsSubscribed 指定的链是否有存活的 websocket 订阅连接，没有配置 websocket 订阅的链，
+// 以及多实例部署时由其他实例订阅的链视为已订阅
+func (s *WSSubscriber) IsSubscribed(chain model.Chain) bool {
+	return s.isSubscribedExcept(chain, nil)
+}
+
+// 除 except 以外是否还有存活的订阅连接
+func (s *WSSubscriber) isSubscribedExcept(chain model.Chain, except *Client) bool {
+	if _, ok := chain.ChainConfig["ws"].(map[string]interface{}); !ok {
+		return true
+	}
+	if !s.elector.IsLeader(cluster.ChainKey(chain.ID.Hex())) {
+		return true
+	}
+	s.wsManager.Lock.Lock()
+	defer s.wsManager.Lock.Unlock()
+	for _, client := range s.wsManager.Group[chain.Name] {
+		if client.IsDial && client != except && client.IsAlive() {
+			return true
+		}
+	}
+	return false
 }
 
 // topic 订阅处理器
-func (s *wsSubscriber) wsSubTopicProcessor(chain model.Chain, client *Client, topic string, params string) error {
+func (s *WSSubscriber) wsSubTopicProcessor(chain model.Chain, client *Client, topic string, params string) error {
 	// 获取到该链所配置订阅的所有 topic
 	topics, err := s.getWSTopicsByChain(chain)
 	if err != nil {
@@ -198,7 +432,7 @@ func (s *wsSubscriber) wsSubTopicProcessor(chain model.Chain, client *Client, to
 }
 
 // NewHeads newHeads 事件的订阅处理
-func (s *wsSubscriber) NewHeads(chain model.Chain, client *Client, topic string, params string) error {
+func (s *WSSubscriber) NewHeads(chain model.Chain, client *Client, topic string, params string) error {
 	logrus.Debugf("subscribe topic[newHead] from websocket for chain[%v] [start]", chain.Name)
 	defer logrus.Debugf("subscribe topic[newHead] from websocket for chain[%v] [end]", chain.Name)
 	// 1、处理参数信息
@@ -229,20 +463,17 @@ func (s *wsSubscriber) NewHeads(chain model.Chain, client *Client, topic string,
 	if err != nil {
 		return err
 	}
+	client.addSubMsgID(msgID)
 
-	// 3、给服务端发送订阅消息对指定 topic 进行订阅
-	dto := model.WSMessageDTO{
-		ID:      client.Id,
-		Group:   client.Group,
-		Message: paramsStr,
-	}
-	s.wsManager.Send(dto.ID, dto.Group, []byte(dto.Message))
+	// 3、给服务端发送订阅消息对指定 topic 进行订阅，
+	// 拨号连接异步注册到管理器，直接写入连接的发送队列，避免注册完成前消息被管理器丢弃
+	client.Send([]byte(paramsStr))
 	logrus.Infof("subscribe topic[newHead] from websocket for chain[%v] [success]", chain.Name)
 	return nil
 }
 
 // 处理 websocket 事件订阅的参数
-func (s *wsSubscriber) wsSubParamsProcess(topic string, params string) (string, string, error) {
+func (s *WSSubscriber) wsSubParamsProcess(topic string, params string) (string, string, error) {
 	data := make(map[string]interface{})
 	id := primitive.NewObjectID().Hex()
 	err := json.Unmarshal([]byte(params), &data)
@@ -263,29 +494,212 @@ func (s *wsSubscriber) wsSubParamsProcess(topic string, params string) (string,
 	return id, params, nil
 }
 
-func (s *wsSubscriber) getWSClientByChain(chain model.Chain) (*Client, error) {
+func (s *WSSubscriber) getWSClientByChain(chain model.Chain) (*Client, error) {
 	_, ok := chain.ChainConfig["ws"].(map[string]interface{})
 	if !ok {
 		msg := fmt.Sprintf("chain[%s][%s:%v] lost websocket config, can't sync data from websocket for it",
 			chain.Name, chain.IP, chain.RPCPort)
 		return nil, errors.New(msg)
 	}
-	port := chain.WSPort
-	path := ""
-	group := chain.Name
-	client, err := s.wsManager.Dial(chain.IP, int64(port), path, group)
-	url := fmt.Sprintf("ws://%s:%v", chain.IP, port)
+	uri := chainWSURL(chain)
+	chainID := chain.ID
+	client, err := s.wsManager.dial(uri, chain.Name, func(c *Client) {
+		s.reconnect(chainID, c)
+	})
 	if err != nil {
 		msg := fmt.Sprintf("chain[%s][%s:%v] websocket dial [%s] error: %v",
-			chain.Name, chain.IP, chain.RPCPort, url, err)
+			chain.Name, chain.IP, chain.RPCPort, uri.String(), err)
 		return nil, errors.New(msg)
 	}
 	logrus.Debugf("chain[%s][%s:%v] websocket dial [%s] success",
-		chain.Name, chain.IP, chain.RPCPort, url)
+		chain.Name, chain.IP, chain.RPCPort, uri.String())
 	return client, nil
 }
 
-func (s *wsSubscriber) getWSTopicsByChain(chain model.Chain) ([]string, error) {
+// 订阅连接意外断开后按指数退避重连，重连成功后按保存的订阅消息重新订阅，
+// 并触发一次增量同步补齐断线期间错过的区块
+func (s *WSSubscriber) reconnect(chainID primitive.ObjectID, lost *Client) {
+	id := chainID.Hex()
+	maxRetryCnt := config.Config.WSConf.MaxRetryCnt
+	var lastErr error
+	for retry := int64(1); maxRetryCnt == 0 || retry <= maxRetryCnt; retry++ {
+		if !s.setReconnecting(id, lost, retry-1, lastErr) || !s.wsManager.wait(retryBackoff(retry)) {
+			return
+		}
+		err := s.reconnectOnce(id, lost, retry)
+		if err == errReconnectCanceled {
+			logrus.Infof("chain[%s] was unsubscribed or resubscribed, stop reconnecting websocket [%s]", lost.Group, lost.dialURL)
+			return
+		}
+		if err == nil {
+			logrus.Infof("chain[%s] websocket reconnect [%s] success after [%d] retries", lost.Group, lost.dialURL, retry)
+			s.syncManager.IncrSyncStart(id, true)
+			return
+		}
+		lastErr = err
+		logrus.Warningf("chain[%s] websocket reconnect [%d] err: %v", lost.Group, retry, err)
+	}
+	s.lock.Lock()
+	if _, ok := s.lostChain(id, lost, nil); ok {
+		info := s.infos[id]
+		info.Status = model.ChainSubStatusFailed
+		info.ErrMsg = fmt.Sprintf("reconnect failed after %d retries: %v", maxRetryCnt, lastErr)
+		info.UpdateTime = time.Now().Unix()
+	}
+	s.lock.Unlock()
+	logrus.Errorf("chain[%s] websocket [%s] reconnect failed after [%d] retries", lost.Group, lost.dialURL, maxRetryCnt)
+}
+
+// 将订阅状态设置为重连中，返回是否需要继续重连
+func (s *WSSubscriber) setReconnecting(id string, lost *Client, retry int64, err error) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.lostChain(id, lost, nil); !ok {
+		return false
+	}
+	info := s.infos[id]
+	info.Status = model.ChainSubStatusReconnecting
+	info.RetryCnt = retry
+	info.ErrMsg = ""
+	if err != nil {
+		info.ErrMsg = err.Error()
+	}
+	info.UpdateTime = time.Now().Unix()
+	return true
+}
+
+// 进行一次重连，链已经取消订阅或已经按新的配置重新订阅时返回 errReconnectCanceled。
+// 拨号时不持有 s.lock，避免节点不可达时阻塞订阅状态查询、链变更事件和其他链的重连
+func (s *WSSubscriber) reconnectOnce(id string, lost *Client, retry int64) error {
+	s.lock.Lock()
+	chain, ok := s.lostChain(id, lost, nil)
+	s.lock.Unlock()
+	if !ok {
+		return errReconnectCanceled
+	}
+	client, err := s.getWSClientByChain(*chain)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// 拨号期间链可能已经取消订阅或重新订阅，此时关闭新建的连接
+	if chain, ok = s.lostChain(id, lost, client); !ok {
+		if err := client.Close(); err != nil {
+			logrus.Warningf("chain[%s] close websocket [%s] err: %v", lost.Group, lost.dialURL, err)
+		}
+		return errReconnectCanceled
+	}
+	client.setRetryCnt(retry)
+	s.resubscribe(*chain, client, lost.SubMsgIDs())
+	info := s.infos[id]
+	info.Status = model.ChainSubStatusSubscribed
+	info.RetryCnt = retry
+	info.ErrMsg = ""
+	info.UpdateTime = time.Now().Unix()
+	return nil
+}
+
+// 断开的连接是否仍需要重连：链仍处于订阅中，地址没有变更，且除 except 外没有其他存活的订阅连接，调用方需要持有 s.lock
+func (s *WSSubscriber) lostChain(id string, lost *Client, except *Client) (*model.Chain, bool) {
+	chain, ok := s.chains[id]
+	if !ok || s.infos[id] == nil {
+		return nil, false
+	}
+	uri := chainWSURL(*chain)
+	if chain.Name != lost.Group || uri.String() != lost.dialURL || s.isSubscribedExcept(*chain, except) {
+		return nil, false
+	}
+	return chain, true
+}
+
+// 按保存的订阅消息记录重新发送订阅请求，订阅成功后消息记录中的订阅哈希会被更新
+func (s *WSSubscriber) resubscribe(chain model.Chain, client *Client, msgIDs []string) {
+	for _, id := range msgIDs {
+		msgID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			logrus.Warningf("chain[%s] invalid subscription message id [%s]: %v", chain.Name, id, err)
+			continue
+		}
+		wsMsg, err := s.wsDao.WSMsg(bson.M{"_id": msgID})
+		if err != nil {
+			logrus.Warningf("chain[%s] load subscription message [%s] err: %v", chain.Name, id, err)
+			continue
+		}
+		client.addSubMsgID(id)
+		client.Send([]byte(wsMsg.Message))
+		logrus.Infof("chain[%s] resubscribe [%s] from websocket", chain.Name, wsMsg.Message)
+	}
+}
+
+// 第 retry 次重连前的等待时长，从 retry_interval 开始每次翻倍，最长不超过 maxRetryBackoff
+func retryBackoff(retry int64) time.Duration {
+	backoff := config.Config.WSConf.RetryInterval * time.Second
+	for i := int64(1); i < retry && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// 链第一个节点的 websocket 地址
+func chainWSURL(chain model.Chain) url.URL {
+	return dialURL(chain.IP, int64(chain.WSPort), "")
+}
+
+// 链配置 ws.topics 中的一个订阅
+type topicConfig struct {
+	name   string
+	params string
+}
+
+// ValidateTopics 校验链配置中的 ws.topics，每个 topic 需要包含字符串类型的 name 和 params，没有配置 ws.topics 时不校验
+func ValidateTopics(chainConfig map[string]interface{}) error {
+	_, _, err := parseTopicConfigs(chainConfig)
+	return err
+}
+
+// 解析链配置中的 ws.topics，没有配置时 ok 为 false，配置格式不正确时返回错误
+func parseTopicConfigs(chainConfig map[string]interface{}) (topics map[string]topicConfig, ok bool, err error) {
+	wsConfig, ok := chainConfig["ws"]
+	if !ok {
+		return nil, false, nil
+	}
+	wsMap, ok := wsConfig.(map[string]interface{})
+	if !ok {
+		return nil, false, errors.New("chain_config.ws must be an object")
+	}
+	rawTopics, ok := wsMap["topics"]
+	if !ok {
+		return nil, false, nil
+	}
+	topicMaps, ok := rawTopics.(map[string]interface{})
+	if !ok {
+		return nil, false, errors.New("chain_config.ws.topics must be an object")
+	}
+	topics = make(map[string]topicConfig, len(topicMaps))
+	for k, v := range topicMaps {
+		topic, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false, fmt.Errorf("chain_config.ws.topics.%s must be an object", k)
+		}
+		name, ok := topic["name"].(string)
+		if !ok || name == "" {
+			return nil, false, fmt.Errorf("chain_config.ws.topics.%s.name must be a non-empty string", k)
+		}
+		params, ok := topic["params"].(string)
+		if !ok {
+			return nil, false, fmt.Errorf("chain_config.ws.topics.%s.params must be a string", k)
+		}
+		topics[k] = topicConfig{name: name, params: params}
+	}
+	return topics, true, nil
+}
+
+func (s *WSSubscriber) getWSTopicsByChain(chain model.Chain) ([]string, error) {
 	ws, ok := chain.ChainConfig["ws"].(map[string]interface{})
 	if !ok {
 		msg := fmt.Sprintf("chain[%s][%s:%v] without [ws] config, can't subscribe topics for it",
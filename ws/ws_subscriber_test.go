@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"graces/config"
+	"graces/fakechain"
+	"graces/model"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWSSubscriber_Reconnect(t *testing.T) {
+	retryInterval := config.Config.WSConf.RetryInterval
+	config.Config.WSConf.RetryInterval = 1
+	defer func() {
+		config.Config.WSConf.RetryInterval = retryInterval
+	}()
+	testManager.Run()
+
+	node, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	chain := node.Chain("chain-reconnect")
+	assert.True(t, testDaos.Chain.InsertChain(*chain) == nil)
+
+	subscriber := NewWSSubscriber(testManager, testDaos, testSyncManager, testCluster.Elector)
+	assert.True(t, subscriber.SubTopicsForChain(chain) == nil)
+	subHash := func() string {
+		msg, err := testDaos.WSMsg.WSMsg(bson.M{"chain_id": chain.ID})
+		if err != nil {
+			return ""
+		}
+		return msg.Hash
+	}
+	waitFor(t, func() bool { return node.Subscriptions() == 1 && subHash() != "" })
+	hash := subHash()
+
+	// 节点断开连接后自动重连，并按原来的订阅消息重新订阅
+	node.DropConnections()
+	waitFor(t, func() bool { return node.Subscriptions() == 1 && subHash() != hash })
+	assert.True(t, subscriber.IsSubscribed(*chain))
+	info, ok := subscriber.SubscriptionInfo(chain.ID.Hex())
+	assert.True(t, ok)
+	assert.Equal(t, model.ChainSubStatusSubscribed, info.Status)
+	assert.Equal(t, int64(1), info.RetryCnt)
+
+	// 主动取消订阅的连接不会重连
+	subscriber.UnsubTopicsForChain(chain)
+	time.Sleep(2 * time.Second)
+	assert.Equal(t, 0, node.Subscriptions())
+	assert.False(t, subscriber.IsSubscribed(*chain))
+}
+
+func TestWSSubscriber_ChainEvents(t *testing.T) {
+	testManager.Run()
+	subscriber := NewWSSubscriber(testManager, testDaos, testSyncManager, testCluster.Elector)
+	subscriber.Run()
+
+	node, err := fakechain.NewNode()
+	assert.True(t, err == nil)
+	defer node.Close()
+	chain := node.Chain("chain-events")
+	assert.True(t, testDaos.Chain.InsertChain(*chain) == nil)
+	_, ok := subscriber.SubscriptionInfo(chain.ID.Hex())
+	assert.False(t, ok)
+
+	// 新增的链立即订阅
+	subscriber.Publish(model.ChainEvent{Type: model.ChainEventInsert, Chain: chain})
+	waitFor(t, func() bool { return node.Subscriptions() == 1 })
+	info, ok := subscriber.SubscriptionInfo(chain.ID.Hex())
+	assert.True(t, ok)
+	assert.Equal(t, model.ChainSubStatusSubscribed, info.Status)
+	assert.Equal(t, []string{"newHeads"}, info.Topics)
+	assert.True(t, info.Connected)
+
+	// 重复的新增事件不会重复订阅
+	subscriber.Publish(model.ChainEvent{Type: model.ChainEventInsert, Chain: chain})
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 1, node.Subscriptions())
+
+	// 删除的链立即取消订阅
+	subscriber.Publish(model.ChainEvent{Type: model.ChainEventDelete, Chain: chain})
+	waitFor(t, func() bool { return node.Subscriptions() == 0 })
+	info, ok = subscriber.SubscriptionInfo(chain.ID.Hex())
+	assert.True(t, ok)
+	assert.Equal(t, model.ChainSubStatusUnsubscribed, info.Status)
+	assert.False(t, info.Connected)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.True(t, cond())
+}
+
+func TestValidateTopics(t *testing.T) {
+	assert.True(t, ValidateTopics(map[string]interface{}{}) == nil)
+	valid := map[string]interface{}{"ws": map[string]interface{}{"topics": map[string]interface{}{
+		"newHeads": map[string]interface{}{"name": "newHeads", "params": ""},
+	}}}
+	assert.True(t, ValidateTopics(valid) == nil)
+
+	invalid := []map[string]interface{}{
+		{"ws": "topics"},
+		{"ws": map[string]interface{}{"topics": []interface{}{"newHeads"}}},
+		{"ws": map[string]interface{}{"topics": map[string]interface{}{"newHeads": "newHeads"}}},
+		{"ws": map[string]interface{}{"topics": map[string]interface{}{"newHeads": map[string]interface{}{"params": ""}}}},
+		{"ws": map[string]interface{}{"topics": map[string]interface{}{"newHeads": map[string]interface{}{"name": "newHeads", "params": 1}}}},
+	}
+	for _, chainConfig := range invalid {
+		assert.True(t, ValidateTopics(chainConfig) != nil)
+	}
+}
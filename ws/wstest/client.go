@@ -0,0 +1,126 @@
+// Package wstest 使用结构化消息的 websocket 测试客户端，按请求ID收集命令的应答
+package wstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"graces/model"
+	"graces/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// 读取单条消息的默认超时时间
+const defaultTimeout = 5 * time.Second
+
+// Client 使用结构化消息的 websocket 客户端，不能并发调用
+type Client struct {
+	conn *websocket.Conn
+	// 读取单条消息的超时时间
+	Timeout time.Duration
+	seq     int
+	// 等待应答期间收到的其他消息，由 Next 依次返回
+	pending []*model.WSEnvelope
+}
+
+// Reply 单个请求的全部应答
+type Reply struct {
+	Ack     *model.WSEnvelope
+	Outputs []string
+	Result  *model.WSEnvelope
+}
+
+// Dial 带上 v=1 建立连接，token 为登录时返回的 token，可以带有前缀，为空时不携带
+func Dial(rawURL string, token string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set(ws.EnvelopeQuery, fmt.Sprint(ws.EnvelopeVersion))
+	u.RawQuery = query.Encode()
+
+	dialer := websocket.Dialer{}
+	if fields := strings.Fields(token); len(fields) > 0 {
+		dialer.Subprotocols = []string{"bearer", fields[len(fields)-1]}
+	}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, Timeout: defaultTimeout}, nil
+}
+
+// Call 发送请求并等待 result，返回期间收到的 ack、output 和 result
+func (c *Client) Call(msgType string, payload interface{}) (*Reply, error) {
+	c.seq++
+	id := fmt.Sprintf("req-%d", c.seq)
+	msg, err := json.Marshal(model.WSEnvelope{
+		V:       ws.EnvelopeVersion,
+		Type:    msgType,
+		ID:      id,
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		return nil, err
+	}
+
+	reply := &Reply{}
+	for {
+		env, err := c.read()
+		if err != nil {
+			return reply, err
+		}
+		if env.CorrelationID != id {
+			c.pending = append(c.pending, env)
+			continue
+		}
+		switch env.Type {
+		case ws.EnvelopeTypeAck:
+			reply.Ack = env
+		case ws.EnvelopeTypeOutput:
+			output, _ := env.Payload.(string)
+			reply.Outputs = append(reply.Outputs, output)
+		case ws.EnvelopeTypeResult:
+			reply.Result = env
+			return reply, nil
+		}
+	}
+}
+
+// Next 返回下一条不属于请求应答的消息，如推送和通知
+func (c *Client) Next() (*model.WSEnvelope, error) {
+	if len(c.pending) > 0 {
+		env := c.pending[0]
+		c.pending = c.pending[1:]
+		return env, nil
+	}
+	return c.read()
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) read() (*model.WSEnvelope, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var env model.WSEnvelope
+	if err = json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid envelope %s: %v", data, err)
+	}
+	return &env, nil
+}